@@ -0,0 +1,27 @@
+package libsteg
+
+import (
+	"image"
+)
+
+// Loaded returns the image loaded into the StegImage, or nil if none is
+func (s *StegImage) Loaded() image.Image {
+	return s.imgLoaded
+}
+
+// Stego returns the image holding the embedded payload, or nil if nothing
+// has been embedded yet. It's the image WriteNewImageToFile and friends
+// encode, for callers that post-process, composite or encode it
+// themselves.
+func (s *StegImage) Stego() image.Image {
+	if s.newImg == nil {
+		return nil
+	}
+	return s.newImg
+}
+
+// Format returns the name of the format the loaded image was decoded
+// from, e.g. "png", or "" if it wasn't decoded by a loader
+func (s *StegImage) Format() string {
+	return s.imgType
+}
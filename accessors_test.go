@@ -0,0 +1,73 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestAccessors verifies the loaded and stego images and format are
+// exposed, and the stego image holds the payload
+func TestAccessors(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if cleanImg.Loaded() != nil || cleanImg.Stego() != nil || cleanImg.Format() != "" {
+		t.Error("Expected nothing exposed before loading")
+	}
+	if err := cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if cleanImg.Loaded() == nil || cleanImg.Stego() != nil || cleanImg.Format() != "png" {
+		t.Errorf("Unexpected state after loading, format %q", cleanImg.Format())
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if delta, err := MaxChannelDelta(cleanImg.Loaded(), cleanImg.Stego()); err != nil || delta != 1 {
+		t.Errorf("Expected the stego image to differ by 1, got %d (%v)", delta, err)
+	}
+	stego := StegImage{imgLoaded: cleanImg.Stego()}
+	payloadOut, err := stego.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestB2BInMemory embeds into an image that was never encoded
+func TestB2BInMemory(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	before := append([]byte(nil), img.Pix...)
+
+	stego, err := Embed(img, []byte(secretStringIn), WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.Pix, before) {
+		t.Error("Embed changed the cover image")
+	}
+	if _, err = Embed(nil, []byte(secretStringIn)); err != errNoImageLoaded {
+		t.Error("Correct Error not thrown, expected: '" + errString(errNoImageLoaded) + "' got: '" + errString(err) + "'")
+	}
+
+	payloadOut, err := Extract(stego, WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
@@ -0,0 +1,172 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+const (
+	// adaptiveBlockSize is the edge length of the square blocks whose
+	// texture decides how many bit planes they carry
+	adaptiveBlockSize = 8
+	// adaptiveMaxDepth is the most bit planes a block can carry. Texture
+	// is measured above these planes so embedding never changes the map.
+	adaptiveMaxDepth = 3
+	// Mean absolute difference between neighbouring values, measured above
+	// adaptiveMaxDepth, from which blocks carry 2 and 3 bit planes
+	adaptiveNoisy     = 1
+	adaptiveVeryNoisy = 3
+)
+
+var errAdaptiveNoKey = errors.New("adaptive bit depth requires a passphrase")
+
+// WithAdaptiveDepth spreads the payload over 1 LSB in smooth blocks of the
+// image and 2 or 3 in textured blocks, where the extra noise is hardly
+// visible. Blocks are visited in an order keyed by the passphrase, which is
+// required. The block map is derived from the image content above the
+// planes written, so the extractor rebuilds it without it being stored.
+// The same option must be given on extraction. Any existing payload is
+// replaced.
+func WithAdaptiveDepth() Option {
+	return func(o *options) {
+		o.adaptive = true
+	}
+}
+
+// adaptivePlacementFor builds the adaptive placement for img keyed by the
+// options' passphrase
+func adaptivePlacementFor(img image.Image, o *options) (placement, error) {
+	if o.passphrase == "" {
+		return nil, errAdaptiveNoKey
+	}
+	key, err := placementKey(o.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return newAdaptivePlacement(img, key)
+}
+
+// adaptivePlacement walks a keyed permutation of image blocks, taking
+// every bit plane up to each block's depth
+type adaptivePlacement struct {
+	bounds   image.Rectangle
+	channels int
+	across   int     // Blocks per row
+	order    []int   // Block visited at each step
+	depth    []uint8 // Bit planes carried, by block
+	start    []int   // Bit index at which each step begins, plus the total
+}
+
+func newAdaptivePlacement(img image.Image, key []byte) (*adaptivePlacement, error) {
+	bounds := img.Bounds()
+	across := (bounds.Dx() + adaptiveBlockSize - 1) / adaptiveBlockSize
+	down := (bounds.Dy() + adaptiveBlockSize - 1) / adaptiveBlockSize
+	blocks := across * down
+	p := &adaptivePlacement{
+		bounds:   bounds,
+		channels: imageChannels(img),
+		across:   across,
+		order:    make([]int, blocks),
+		depth:    make([]uint8, blocks),
+		start:    make([]int, blocks+1),
+	}
+	if blocks == 0 {
+		return p, nil
+	}
+
+	perm, err := newFeistel(key, blocks)
+	if err != nil {
+		return nil, err
+	}
+	_, paletted := img.(*image.Paletted)
+	for step := range p.order {
+		block := int(perm.permute(uint64(step)))
+		p.order[step] = block
+		p.depth[block] = 1
+		if !paletted {
+			p.depth[block] = blockDepth(img, p.blockBounds(block))
+		}
+		p.start[step+1] = p.start[step] + p.blockBounds(block).Dx()*p.blockBounds(block).Dy()*p.channels*int(p.depth[block])
+	}
+	return p, nil
+}
+
+// blockBounds returns the pixels covered by a block, clipped to the image
+func (p *adaptivePlacement) blockBounds(block int) image.Rectangle {
+	min := p.bounds.Min.Add(image.Pt(block%p.across, block/p.across).Mul(adaptiveBlockSize))
+	return image.Rectangle{min, min.Add(image.Pt(adaptiveBlockSize, adaptiveBlockSize))}.Intersect(p.bounds)
+}
+
+func (p *adaptivePlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	step := sort.Search(len(p.order), func(i int) bool { return p.start[i+1] > bitIndex })
+	block := p.order[step]
+	r := p.blockBounds(block)
+	depth := int(p.depth[block])
+	local := bitIndex - p.start[step]
+	pixel := local / (p.channels * depth)
+	rem := local % (p.channels * depth)
+	return r.Min.X + pixel%r.Dx(), r.Min.Y + pixel/r.Dx(), rem / depth, rem % depth
+}
+
+func (p *adaptivePlacement) capacity() int {
+	return p.start[len(p.start)-1]
+}
+
+// blockDepth measures the texture of a block as the mean absolute
+// difference between horizontally and vertically neighbouring channel
+// values, ignoring the planes that may be written
+func blockDepth(img image.Image, r image.Rectangle) uint8 {
+	// RGBA returns 16-bit values, keep the bits above the writable planes
+	const shift = 8 + adaptiveMaxDepth
+	level := func(x, y int) [3]int {
+		rv, gv, bv := storedRGB(img.At(x, y))
+		return [3]int{int(rv >> shift), int(gv >> shift), int(bv >> shift)}
+	}
+	var sum, n int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := level(x, y)
+			for _, d := range []image.Point{{1, 0}, {0, 1}} {
+				if !image.Pt(x, y).Add(d).In(r) {
+					continue
+				}
+				o := level(x+d.X, y+d.Y)
+				for i := range c {
+					sum += abs(c[i] - o[i])
+					n++
+				}
+			}
+		}
+	}
+	switch {
+	case n == 0 || sum < adaptiveNoisy*n:
+		return 1
+	case sum < adaptiveVeryNoisy*n:
+		return 2
+	}
+	return adaptiveMaxDepth
+}
+
+// storedRGB returns the 16-bit colour channels of c as the image stores
+// them. Non-premultiplied colours aren't premultiplied, which would let
+// writes to the low planes change the bits above them wherever alpha isn't
+// opaque.
+func storedRGB(c color.Color) (r, g, b uint32) {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return uint32(c.R) * 0x101, uint32(c.G) * 0x101, uint32(c.B) * 0x101
+	case color.NRGBA64:
+		return uint32(c.R), uint32(c.G), uint32(c.B)
+	}
+	r, g, b, _ = c.RGBA()
+	return r, g, b
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
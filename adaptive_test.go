@@ -0,0 +1,117 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BAdaptive verifies an adaptive embed round trips, gains capacity in
+// textured blocks and only touches the LSB in smooth ones
+func TestB2BAdaptive(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	// Left half flat, right half noise
+	src := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	rng := rand.New(rand.NewSource(1))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.RGBA{128, 128, 128, 255}
+			if x >= 32 {
+				c = color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(base64.StdEncoding.EncodeToString(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := placementKey("pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	place, err := newAdaptivePlacement(cleanImg.imgLoaded, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.capacity() <= bitCapacity(src.Bounds(), 3)*3/2 {
+		t.Errorf("Adaptive capacity %d not above 1.5x sequential", place.capacity())
+	}
+
+	// More than the image holds at one bit per channel
+	payloadIn := strings.Repeat(secretStringIn, bitCapacity(src.Bounds(), 3)/8/len(secretStringIn)+1)
+	opts := []Option{WithPassphrase("pass"), WithAdaptiveDepth()}
+	if err = cleanImg.EmbedBytes([]byte(payloadIn), opts...); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+	payloadOut, err := stegImg.ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != payloadIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 32; x++ {
+			c := stegImg.imgLoaded.At(x, y).(color.RGBA)
+			if c.R>>1 != 64 || c.G>>1 != 64 || c.B>>1 != 64 {
+				t.Fatalf("Smooth pixel (%d,%d) changed above the LSB: %v", x, y, c)
+			}
+		}
+	}
+
+	if err = cleanImg.EmbedBytes([]byte("x"), WithAdaptiveDepth()); err != errAdaptiveNoKey {
+		t.Error("Correct Error not thrown, expected: '" + errAdaptiveNoKey.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestAdaptiveTranslucent verifies the block depths read back alike from a
+// semi-transparent non-premultiplied cover, where premultiplying would let
+// the written planes change the texture measured above them
+func TestAdaptiveTranslucent(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	rng := rand.New(rand.NewSource(2))
+	for trial := 0; trial < 20; trial++ {
+		// A gradient plus a little noise, at alpha between 90 and 150
+		src := image.NewNRGBA(image.Rect(0, 0, 48, 48))
+		for y := 0; y < 48; y++ {
+			for x := 0; x < 48; x++ {
+				noise := func(v int) uint8 { return uint8(v*4 + rng.Intn(6)) }
+				src.SetNRGBA(x, y, color.NRGBA{noise(x), noise(y), noise(x), uint8(90 + rng.Intn(61))})
+			}
+		}
+		cover := StegImage{imgLoaded: src, imgType: "png"}
+		opts := []Option{WithPassphrase("pass"), WithAdaptiveDepth()}
+		// Enough to reach well past the first few blocks
+		payloadIn := make([]byte, 300)
+		rng.Read(payloadIn)
+		if err := cover.EmbedBytes(payloadIn, opts...); err != nil {
+			t.Fatal(err)
+		}
+		payloadOut, err := reloadImage(t, &cover).ExtractBytes(opts...)
+		if err != nil {
+			t.Fatalf("Trial %d: %v", trial, err)
+		}
+		if !bytes.Equal(payloadOut, payloadIn) {
+			t.Errorf("Trial %d: Secrets Do Not Match!", trial)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package libsteg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+// PNG colour types supported in animated PNGs
+const (
+	pngColorGray     = 0
+	pngColorRGB      = 2
+	pngColorPaletted = 3
+	pngColorRGBA     = 6
+)
+
+var (
+	errBadAPNG         = errors.New("not an animated PNG")
+	errUnsupportedAPNG = errors.New("unsupported animated PNG pixel format")
+)
+
+// apng is an animated PNG split into its chunks, with the chunks making up
+// each animation frame indexed
+type apng struct {
+	chunks []PNGChunk
+	ihdr   []byte
+	frames []apngFrame
+}
+
+// apngFrame indexes the fcTL chunk of a frame and the IDAT or fdAT chunks
+// holding its image data
+type apngFrame struct {
+	fctl int
+	data []int
+}
+
+// EmbedAPNG embeds payload into the animated PNG read from r, spreading it
+// over as many frames as it needs, and writes the result to w. Frames keep
+// the pixel format, palette and timing of the original. Compression,
+// passphrase and recipient options apply to the payload as a whole.
+func EmbedAPNG(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	a, frames, err := readAPNG(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	out, err := embedFrames(frames, bytes.NewReader(payload), newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if err = sharePalette(frames, out); err != nil {
+		log.Error(err)
+		return err
+	}
+	if err = a.write(w, out); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// sharePalette gives paletted frames embedFrames passed through untouched
+// the palette rewrite of those carrying payload, as every frame of an
+// animated PNG is indexed into its one PLTE
+func sharePalette(frames []image.Image, out []draw.Image) error {
+	rewritten := false
+	for i := range out {
+		if image.Image(out[i]) != frames[i] {
+			rewritten = true
+		}
+	}
+	if !rewritten {
+		return nil
+	}
+	for i := range out {
+		if _, ok := frames[i].(*image.Paletted); !ok || image.Image(out[i]) != frames[i] {
+			continue
+		}
+		s := StegImage{imgLoaded: frames[i]}
+		if err := s.createMutableImage(); err != nil {
+			return err
+		}
+		out[i] = s.newImg
+	}
+	return nil
+}
+
+// ExtractAPNG retrieves a payload embedded with EmbedAPNG from the
+// animated PNG read from r
+func ExtractAPNG(r io.Reader, opts ...Option) ([]byte, error) {
+	_, frames, err := readAPNG(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	payload, err := extractFrames(frames, newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
+
+// readAPNG parses an animated PNG and decodes each of its frames
+func readAPNG(r io.Reader) (*apng, []image.Image, error) {
+	encoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	chunks, err := readPNGChunks(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chunks) == 0 || chunks[0].Type != "IHDR" || len(chunks[0].Data) != 13 {
+		return nil, nil, errBadPNG
+	}
+
+	a := &apng{chunks: chunks, ihdr: chunks[0].Data}
+	animated := false
+	for i, c := range chunks {
+		switch c.Type {
+		case "acTL":
+			animated = true
+		case "tRNS":
+			if a.colorType() != pngColorPaletted {
+				return nil, nil, errUnsupportedAPNG
+			}
+		case "fcTL":
+			if len(c.Data) != 26 {
+				return nil, nil, errBadAPNG
+			}
+			a.frames = append(a.frames, apngFrame{fctl: i})
+		case "IDAT", "fdAT":
+			// IDAT before the first fcTL is a default image outside the
+			// animation, which would be left alone
+			if len(a.frames) == 0 {
+				if a.colorType() == pngColorPaletted {
+					return nil, nil, errUnsupportedAPNG
+				}
+				continue
+			}
+			f := &a.frames[len(a.frames)-1]
+			f.data = append(f.data, i)
+		}
+	}
+	if !animated || len(a.frames) == 0 {
+		return nil, nil, errBadAPNG
+	}
+	if !a.supported() {
+		return nil, nil, errUnsupportedAPNG
+	}
+
+	frames := make([]image.Image, len(a.frames))
+	for i := range a.frames {
+		if frames[i], err = a.decodeFrame(i); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a, frames, nil
+}
+
+func (a *apng) bitDepth() byte  { return a.ihdr[8] }
+func (a *apng) colorType() byte { return a.ihdr[9] }
+
+// supported reports whether frames can be decoded to, and written back
+// from, one of the image types createMutableImage produces without
+// changing the pixel format
+func (a *apng) supported() bool {
+	if a.ihdr[12] != 0 {
+		// Interlaced
+		return false
+	}
+	switch a.colorType() {
+	case pngColorGray, pngColorRGB, pngColorRGBA:
+		return a.bitDepth() == 8 || a.bitDepth() == 16
+	case pngColorPaletted:
+		return a.bitDepth() == 8
+	}
+	return false
+}
+
+// decodeFrame decodes one frame by wrapping its image data in a standalone
+// PNG sized by its fcTL
+func (a *apng) decodeFrame(i int) (image.Image, error) {
+	f := a.frames[i]
+	ihdr := append([]byte{}, a.ihdr...)
+	copy(ihdr[0:8], a.chunks[f.fctl].Data[4:12])
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(pngSignature)
+	writePNGChunk(buf, PNGChunk{Type: "IHDR", Data: ihdr})
+	for _, c := range a.chunks {
+		if c.Type == "PLTE" || c.Type == "tRNS" {
+			writePNGChunk(buf, c)
+		}
+	}
+	idat := new(bytes.Buffer)
+	for _, d := range f.data {
+		c := a.chunks[d]
+		if c.Type == "fdAT" {
+			if len(c.Data) < 4 {
+				return nil, errBadAPNG
+			}
+			idat.Write(c.Data[4:])
+		} else {
+			idat.Write(c.Data)
+		}
+	}
+	writePNGChunk(buf, PNGChunk{Type: "IDAT", Data: idat.Bytes()})
+	writePNGChunk(buf, PNGChunk{Type: "IEND"})
+	return png.Decode(buf)
+}
+
+// write re-encodes the animated PNG with the given frames, renumbering the
+// fcTL and fdAT sequence as each frame's data is now a single chunk
+func (a *apng) write(w io.Writer, frames []draw.Image) error {
+	replaced := make(map[int][]byte)
+	dropped := make(map[int]bool)
+	for i, f := range a.frames {
+		data, err := a.encodeFrame(frames[i])
+		if err != nil {
+			return err
+		}
+		if len(f.data) == 0 {
+			continue
+		}
+		replaced[f.data[0]] = data
+		for _, d := range f.data[1:] {
+			dropped[d] = true
+		}
+	}
+
+	var pal color.Palette
+	if p, ok := frames[0].(*image.Paletted); ok {
+		pal = p.Palette
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(pngSignature)
+	var seq uint32
+	for i, c := range a.chunks {
+		switch {
+		case dropped[i]:
+			continue
+		case c.Type == "fcTL":
+			data := append([]byte{}, c.Data...)
+			binary.BigEndian.PutUint32(data[0:4], seq)
+			seq++
+			c = PNGChunk{Type: c.Type, Data: data}
+		case c.Type == "fdAT" && replaced[i] != nil:
+			data := make([]byte, 4, 4+len(replaced[i]))
+			binary.BigEndian.PutUint32(data, seq)
+			seq++
+			c = PNGChunk{Type: c.Type, Data: append(data, replaced[i]...)}
+		case c.Type == "IDAT" && replaced[i] != nil:
+			c = PNGChunk{Type: c.Type, Data: replaced[i]}
+		case c.Type == "PLTE" && pal != nil:
+			c = paletteChunk(pal)
+		case c.Type == "tRNS" && pal != nil:
+			c = transparencyChunk(pal)
+		}
+		writePNGChunk(buf, c)
+		if c.Type == "PLTE" && pal != nil && !a.hasChunk("tRNS") && hasTransparency(pal) {
+			writePNGChunk(buf, transparencyChunk(pal))
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (a *apng) hasChunk(chunkType string) bool {
+	for _, c := range a.chunks {
+		if c.Type == chunkType {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeFrame filters and compresses a frame's scanlines in the pixel
+// format given by the IHDR
+func (a *apng) encodeFrame(img draw.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	var rows [][]byte
+	var bpp int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var row []byte
+		switch img := img.(type) {
+		case *image.Gray:
+			row, bpp = img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 1
+		case *image.Gray16:
+			row, bpp = img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 2
+		case *image.Paletted:
+			row, bpp = img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 1
+		case *image.NRGBA:
+			row, bpp = img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 4
+		case *image.NRGBA64:
+			row, bpp = img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 8
+		case *image.RGBA:
+			// Opaque RGB, drop the alpha channel
+			row, bpp = dropAlpha(img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 1), 3
+		case *image.RGBA64:
+			row, bpp = dropAlpha(img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)], 2), 6
+		default:
+			return nil, errUnsupportedAPNG
+		}
+		rows = append(rows, row)
+	}
+
+	buf := new(bytes.Buffer)
+	zw, err := zlib.NewWriterLevel(buf, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = zw.Write(filterScanlines(rows, bpp)); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dropAlpha strips the alpha samples from a row of RGBA samples of the
+// given byte width
+func dropAlpha(row []byte, width int) []byte {
+	out := make([]byte, 0, len(row)/4*3)
+	for i := 0; i < len(row); i += 4 * width {
+		out = append(out, row[i:i+3*width]...)
+	}
+	return out
+}
+
+// filterScanlines applies the PNG filter minimising the sum of absolute
+// differences to each row, as most encoders do
+func filterScanlines(rows [][]byte, bpp int) []byte {
+	var out []byte
+	var prev []byte
+	for _, row := range rows {
+		if prev == nil {
+			prev = make([]byte, len(row))
+		}
+		best, bestSum := []byte(nil), -1
+		for ft := byte(0); ft < 5; ft++ {
+			filtered := make([]byte, len(row)+1)
+			filtered[0] = ft
+			sum := 0
+			for i, v := range row {
+				var left, upLeft byte
+				if i >= bpp {
+					left, upLeft = row[i-bpp], prev[i-bpp]
+				}
+				up := prev[i]
+				switch ft {
+				case 1:
+					v -= left
+				case 2:
+					v -= up
+				case 3:
+					v -= byte((int(left) + int(up)) / 2)
+				case 4:
+					v -= paeth(left, up, upLeft)
+				}
+				filtered[i+1] = v
+				sum += abs(int(int8(v)))
+			}
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = filtered, sum
+			}
+		}
+		out = append(out, best...)
+		prev = row
+	}
+	return out
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func paletteChunk(pal color.Palette) PNGChunk {
+	data := make([]byte, 0, 3*len(pal))
+	for _, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		data = append(data, n.R, n.G, n.B)
+	}
+	return PNGChunk{Type: "PLTE", Data: data}
+}
+
+// transparencyChunk holds the alpha of palette entries up to the last one
+// that isn't opaque
+func transparencyChunk(pal color.Palette) PNGChunk {
+	var data []byte
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if n.A != 0xff {
+			for len(data) < i {
+				data = append(data, 0xff)
+			}
+			data = append(data, n.A)
+		}
+	}
+	return PNGChunk{Type: "tRNS", Data: data}
+}
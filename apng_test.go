@@ -0,0 +1,162 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testAPNG assembles an animated PNG from frames encoded by image/png, the
+// first frame doubling as the default image and lending its palette
+func testAPNG(t *testing.T, frames []image.Image) []byte {
+	t.Helper()
+	out := new(bytes.Buffer)
+	out.WriteString(pngSignature)
+	var seq uint32
+	for i, frame := range frames {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, frame); err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			writePNGChunk(out, chunks[0])
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+			writePNGChunk(out, PNGChunk{Type: "acTL", Data: actl})
+			for _, c := range chunks {
+				if c.Type == "PLTE" || c.Type == "tRNS" {
+					writePNGChunk(out, c)
+				}
+			}
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], seq)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(frame.Bounds().Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(frame.Bounds().Dy()))
+		binary.BigEndian.PutUint16(fctl[20:22], 1)
+		binary.BigEndian.PutUint16(fctl[22:24], 10)
+		writePNGChunk(out, PNGChunk{Type: "fcTL", Data: fctl})
+		seq++
+		for _, c := range chunks {
+			if c.Type != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(out, c)
+				continue
+			}
+			data := make([]byte, 4, 4+len(c.Data))
+			binary.BigEndian.PutUint32(data, seq)
+			seq++
+			writePNGChunk(out, PNGChunk{Type: "fdAT", Data: append(data, c.Data...)})
+		}
+	}
+	writePNGChunk(out, PNGChunk{Type: "IEND"})
+	return out.Bytes()
+}
+
+// TestB2BAPNG verifies payloads spread over animated PNG frames round trip
+// in opaque, translucent and paletted pixel formats, and that frames left
+// without payload decode as they did
+func TestB2BAPNG(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	// Descending luma, so embedding has to re-sort the palette, and over
+	// 16 colours so image/png writes 8-bit indexes
+	var pal color.Palette
+	for i := 0; i < 32; i++ {
+		pal = append(pal, color.Gray{uint8(255 - i*8)})
+	}
+
+	for _, format := range []string{"opaque", "translucent", "paletted"} {
+		size := 20
+		payloadIn := strings.Repeat(secretStringIn, 60)
+		if format == "paletted" {
+			size, payloadIn = 40, secretStringIn
+		}
+		var frames []image.Image
+		for f := 0; f < 4; f++ {
+			var frame draw.Image
+			switch format {
+			case "opaque":
+				frame = image.NewRGBA(image.Rect(0, 0, size, size))
+			case "translucent":
+				frame = image.NewNRGBA(image.Rect(0, 0, size, size))
+			case "paletted":
+				frame = image.NewPaletted(image.Rect(0, 0, size, size), pal)
+			}
+			for x := 0; x < size; x++ {
+				for y := 0; y < size; y++ {
+					a := uint8(255)
+					if format == "translucent" {
+						a = uint8(100 + x)
+					}
+					frame.Set(x, y, color.NRGBA{uint8(x * 12), uint8(y * 12), uint8(f * 60), a})
+				}
+			}
+			frames = append(frames, frame)
+		}
+		cover := testAPNG(t, frames)
+
+		out := new(bytes.Buffer)
+		if err := EmbedAPNG(bytes.NewReader(cover), out, []byte(payloadIn), WithPassphrase("pass")); err != nil {
+			t.Fatal(err)
+		}
+		payloadOut, err := ExtractAPNG(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != payloadIn {
+			t.Error("Secrets Do Not Match!")
+		}
+
+		// The default image is still a valid PNG in the original format
+		img, err := png.Decode(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := frames[0].(*image.Paletted); ok {
+			if _, ok = img.(*image.Paletted); !ok {
+				t.Errorf("Frame format changed from %T to %T", frames[0], img)
+			}
+		} else if img.ColorModel() != frames[0].ColorModel() {
+			t.Errorf("Frame format changed from %T to %T", frames[0], img)
+		}
+
+		if format != "paletted" {
+			continue
+		}
+		// The payload fits in the first frame, the others must not change
+		_, after, err := readAPNG(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for f := 1; f < len(frames); f++ {
+			for x := 0; x < size; x++ {
+				for y := 0; y < size; y++ {
+					r0, g0, b0, a0 := frames[f].At(x, y).RGBA()
+					r1, g1, b1, a1 := after[f].At(x, y).RGBA()
+					if r0 != r1 || g0 != g1 || b0 != b1 || a0 != a1 {
+						t.Fatalf("Frame %d without payload changed at (%d,%d)", f, x, y)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := ExtractAPNG(strings.NewReader(CleanB64Image)); err != errBadPNG {
+		t.Error("Correct Error not thrown, expected: '" + errBadPNG.Error() + "' got: '" + errString(err) + "'")
+	}
+}
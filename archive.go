@@ -0,0 +1,180 @@
+package libsteg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ArchiveStatus is the outcome of verifying one archived stego image
+type ArchiveStatus int
+
+const (
+	// ArchiveOK means the payload passed its checks and the file is as
+	// last seen
+	ArchiveOK ArchiveStatus = iota
+	// ArchiveReencoded means the payload passed its checks but the file's
+	// bytes changed since the last pass, e.g. it was losslessly re-encoded
+	ArchiveReencoded
+	// ArchiveUnchecked means the payload was found but carries nothing the
+	// options can check, e.g. no checksum, or an HMAC but no passphrase
+	ArchiveUnchecked
+	// ArchiveCorrupt means the payload failed its checks or the file no
+	// longer decodes
+	ArchiveCorrupt
+	// ArchiveMissing means no payload was found, as happens when an image
+	// is re-encoded lossily or edited
+	ArchiveMissing
+)
+
+var archiveStatusNames = []string{"ok", "re-encoded", "unchecked", "corrupt", "missing"}
+
+func (s ArchiveStatus) String() string {
+	if int(s) < len(archiveStatusNames) {
+		return archiveStatusNames[s]
+	}
+	return fmt.Sprintf("ArchiveStatus(%d)", int(s))
+}
+
+// ArchiveResult is the outcome of verifying one file
+type ArchiveResult struct {
+	Path   string
+	Status ArchiveStatus
+	Err    error // Why the file wasn't ArchiveOK, if there's more to say
+}
+
+// defaultArchiveInterval is how often ArchiveVerifier.Run checks by default
+const defaultArchiveInterval = 24 * time.Hour
+
+// ArchiveVerifier re-checks the payloads of a directory or bucket of stego
+// images, reporting any that have bit-rotted or been re-encoded. It only
+// ever reads from FS.
+type ArchiveVerifier struct {
+	FS       fs.FS
+	Options  []Option      // Keys needed for HMAC and signature checks
+	Interval time.Duration // Time between passes made by Run, a day by default
+
+	// Report is called for every file not ArchiveOK, one at a time
+	Report func(ArchiveResult)
+
+	// Baseline holds the SHA-256 of each file as last seen, by path, so
+	// re-encoding can be told apart from an untouched file. Each pass
+	// fills it in. It may be set beforehand to carry it over from an
+	// earlier process.
+	Baseline map[string][sha256.Size]byte
+
+	mu sync.Mutex // Serialises passes
+}
+
+// Verify makes one pass over every image in FS, returning a result per
+// image. Files that aren't in a registered image format are skipped.
+func (v *ArchiveVerifier) Verify() ([]ArchiveResult, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.Baseline == nil {
+		v.Baseline = make(map[string][sha256.Size]byte)
+	}
+
+	var results []ArchiveResult
+	err := fs.WalkDir(v.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := fs.ReadFile(v.FS, path)
+		if err != nil {
+			return err
+		}
+		result, ok := v.verifyFile(path, data)
+		if !ok {
+			return nil
+		}
+		results = append(results, result)
+		if result.Status != ArchiveOK && v.Report != nil {
+			v.Report(result)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// Run verifies FS straight away and then every Interval until ctx is done,
+// returning ctx's error then, or the first error walking FS
+func (v *ArchiveVerifier) Run(ctx context.Context) error {
+	interval := v.Interval
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := v.Verify(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// verifyFile checks the payload of the image in data, ok is false if data
+// isn't in a registered image format
+func (v *ArchiveVerifier) verifyFile(path string, data []byte) (result ArchiveResult, ok bool) {
+	result.Path = path
+	var s StegImage
+	if err := s.loadImage(bytes.NewReader(data)); err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return result, false
+		}
+		result.Status, result.Err = ArchiveCorrupt, err
+		return result, true
+	}
+
+	sum := sha256.Sum256(data)
+	last, seen := v.Baseline[path]
+	v.Baseline[path] = sum
+	result.Status, result.Err = s.verifyArchived(newOptions(v.Options))
+	if result.Status == ArchiveOK && seen && last != sum {
+		result.Status = ArchiveReencoded
+	}
+	return result, true
+}
+
+// verifyArchived checks the trailer of the payload in the loaded image
+// without opening it
+func (s *StegImage) verifyArchived(o *options) (ArchiveStatus, error) {
+	place, err := s.extractPlacement(o)
+	if err != nil {
+		return ArchiveMissing, err
+	}
+	e, _, err := s.readEnvelopeHeader(place, 0)
+	if err != nil {
+		return ArchiveMissing, err
+	}
+	if e.flags&flagHMAC != 0 && o.passphrase == "" {
+		return ArchiveUnchecked, errHMACNoKey
+	}
+	checked := e.flags&(flagChecksum|flagBlockSums|flagHMAC) != 0 ||
+		e.flags&flagSigned != 0 && o.verifyKey != nil
+	if _, err = s.readEnvelope(o, place); err != nil {
+		if err == ErrNoPayload {
+			return ArchiveMissing, err
+		}
+		return ArchiveCorrupt, err
+	}
+	if !checked {
+		return ArchiveUnchecked, nil
+	}
+	return ArchiveOK, nil
+}
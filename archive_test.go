@@ -0,0 +1,103 @@
+package libsteg
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestArchiveVerifier verifies each kind of damage to an archive of stego
+// images is told apart on a second pass
+func TestArchiveVerifier(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	embed := func(opts ...Option) *StegImage {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedBytes([]byte(secretStringIn), opts...); err != nil {
+			t.Fatal(err)
+		}
+		return &stegImg
+	}
+	checked := embed(WithBlockChecksums())
+	// EmbedBytes always adds a CRC32, so frame one without
+	var unchecked StegImage
+	if err := unchecked.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := unchecked.embedStream(bytes.NewReader([]byte(secretStringIn)), 0, newOptions(nil)); err != nil {
+		t.Fatal(err)
+	}
+	archive := fstest.MapFS{
+		"ok.png":        {Data: pngBytes(t, checked)},
+		"reencoded.png": {Data: pngBytes(t, checked)},
+		"rotted.png":    {Data: pngBytes(t, checked)},
+		"lost.png":      {Data: pngBytes(t, checked)},
+		"unchecked.png": {Data: pngBytes(t, &unchecked)},
+		"hmac.png":      {Data: pngBytes(t, embed(WithPassphrase("hunter2"), WithHMAC()))},
+		"notes.txt":     {Data: []byte("not an image")},
+	}
+
+	var reported []ArchiveResult
+	v := &ArchiveVerifier{FS: archive, Report: func(r ArchiveResult) { reported = append(reported, r) }}
+	results, err := v.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 || len(reported) != 2 {
+		t.Fatalf("Got %d results and %d reports, expected 6 and 2", len(results), len(reported))
+	}
+
+	// Re-encode one, flip payload bits in another, wipe the third
+	reencoded := new(bytes.Buffer)
+	(&png.Encoder{CompressionLevel: png.NoCompression}).Encode(reencoded, checked.newImg)
+	archive["reencoded.png"].Data = reencoded.Bytes()
+	rotted := reloadImage(t, checked)
+	img := rotted.imgLoaded.(draw.Image)
+	r, g, b, a := img.At(0, 35).RGBA()
+	img.Set(0, 35, color.RGBA64{uint16(r ^ 0x0101), uint16(g), uint16(b), uint16(a)})
+	rotted.newImg = img
+	archive["rotted.png"].Data = pngBytes(t, rotted)
+	var clean StegImage
+	if err := clean.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	clean.newImg = clean.imgLoaded.(draw.Image)
+	archive["lost.png"].Data = pngBytes(t, &clean)
+
+	v.Options = []Option{WithPassphrase("hunter2")}
+	results, err = v.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ArchiveStatus{
+		"ok.png":        ArchiveOK,
+		"reencoded.png": ArchiveReencoded,
+		"rotted.png":    ArchiveCorrupt,
+		"lost.png":      ArchiveMissing,
+		"unchecked.png": ArchiveUnchecked,
+		"hmac.png":      ArchiveOK,
+	}
+	for _, result := range results {
+		if result.Status != want[result.Path] {
+			t.Errorf("%s: got %v, expected %v (%v)", result.Path, result.Status, want[result.Path], result.Err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	v.Interval = 10 * time.Millisecond
+	if err := v.Run(ctx); err != context.DeadlineExceeded {
+		t.Error("Correct Error not thrown, expected: '" + context.DeadlineExceeded.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,78 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrBoundsMismatch is returned when comparing images of different bounds
+var ErrBoundsMismatch = errors.New("image bounds differ")
+
+// MaxChannelDelta returns the largest absolute difference between any
+// colour or alpha channel of corresponding pixels in a and b, compared
+// without alpha premultiplication. Differences are on a 16-bit scale if
+// either image has 16-bit channels, otherwise on an 8-bit scale, so LSB
+// embedding should never give more than 1.
+func MaxChannelDelta(a image.Image, b image.Image) (int, error) {
+	if a.Bounds() != b.Bounds() {
+		log.Error(ErrBoundsMismatch)
+		return 0, ErrBoundsMismatch
+	}
+	shift := uint(8)
+	if is16Bit(a) || is16Bit(b) {
+		shift = 0
+	}
+
+	max := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.NRGBA64Model.Convert(a.At(x, y)).(color.NRGBA64)
+			cb := color.NRGBA64Model.Convert(b.At(x, y)).(color.NRGBA64)
+			for _, d := range []int{
+				int(ca.R>>shift) - int(cb.R>>shift),
+				int(ca.G>>shift) - int(cb.G>>shift),
+				int(ca.B>>shift) - int(cb.B>>shift),
+				int(ca.A>>shift) - int(cb.A>>shift),
+			} {
+				if d = abs(d); d > max {
+					max = d
+				}
+			}
+		}
+	}
+	return max, nil
+}
+
+// ChangedPixelRatio returns the fraction of pixels in b whose colour
+// differs from the corresponding pixel in a
+func ChangedPixelRatio(a image.Image, b image.Image) (float64, error) {
+	if a.Bounds() != b.Bounds() {
+		log.Error(ErrBoundsMismatch)
+		return 0, ErrBoundsMismatch
+	}
+	bounds := a.Bounds()
+	if bounds.Empty() {
+		return 0, nil
+	}
+
+	changed := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.NRGBA64Model.Convert(a.At(x, y)) != color.NRGBA64Model.Convert(b.At(x, y)) {
+				changed++
+			}
+		}
+	}
+	return float64(changed) / float64(bounds.Dx()*bounds.Dy()), nil
+}
+
+// is16Bit reports whether img stores 16 bits per channel
+func is16Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
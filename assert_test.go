@@ -0,0 +1,59 @@
+package libsteg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestMaxChannelDelta verifies embedding stays within one LSB step and the
+// ratio of changed pixels is reported
+func TestMaxChannelDelta(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	delta, err := MaxChannelDelta(cleanImg.imgLoaded, cleanImg.newImg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta != 1 {
+		t.Errorf("Expected a delta of 1, got %d", delta)
+	}
+	ratio, err := ChangedPixelRatio(cleanImg.imgLoaded, cleanImg.newImg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio <= 0 || ratio >= 0.5 {
+		t.Errorf("Unexpected changed pixel ratio %f", ratio)
+	}
+}
+
+// TestMaxChannelDelta16Bit verifies 16-bit images are compared at full depth
+func TestMaxChannelDelta16Bit(t *testing.T) {
+	t.Parallel()
+
+	a := image.NewRGBA64(image.Rect(0, 0, 2, 2))
+	b := image.NewRGBA64(a.Bounds())
+	a.SetRGBA64(1, 1, color.RGBA64{0x1234, 0, 0, 0xffff})
+	b.SetRGBA64(1, 1, color.RGBA64{0x1235, 0, 0, 0xffff})
+	if delta, _ := MaxChannelDelta(a, b); delta != 1 {
+		t.Errorf("Expected a delta of 1, got %d", delta)
+	}
+	if ratio, _ := ChangedPixelRatio(a, b); ratio != 0.25 {
+		t.Errorf("Expected a ratio of 0.25, got %f", ratio)
+	}
+
+	_, err := MaxChannelDelta(a, image.NewRGBA64(image.Rect(0, 0, 3, 3)))
+	if err != ErrBoundsMismatch {
+		t.Error("Correct Error not thrown, expected: '" + ErrBoundsMismatch.Error() + "' got: '" + errString(err) + "'")
+	}
+}
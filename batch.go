@@ -0,0 +1,169 @@
+package libsteg
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrBatchCanceled is the result of jobs a fail-fast batch never started
+var ErrBatchCanceled = errors.New("batch canceled after an earlier job failed")
+
+// BatchErrorPolicy decides how a batch handles failed jobs
+type BatchErrorPolicy int
+
+const (
+	// BatchCollectAll runs every job and returns a *BatchError listing the
+	// failures
+	BatchCollectAll BatchErrorPolicy = iota
+	// BatchFailFast starts no more jobs once one fails, those left are
+	// canceled. Jobs already running are finished.
+	BatchFailFast
+	// BatchSkipAndReport runs every job and leaves failures to the results
+	// and Report, Run itself succeeds
+	BatchSkipAndReport
+)
+
+// BatchJob hides one payload in one carrier file
+type BatchJob struct {
+	Carrier string   // Path of the cover file, its format picks the carrier
+	Payload []byte   // Payload to hide
+	Output  string   // Path the result is written to
+	Options []Option // Applied after the batch's own options
+}
+
+// BatchResult is the outcome of one job
+type BatchResult struct {
+	Job BatchJob
+	Err error // Set if the job failed, its output is then removed
+}
+
+// BatchError is returned by Batch.Run when any job fails
+type BatchError struct {
+	Failed   []BatchResult // Failed jobs, in job order, less those canceled
+	Canceled int           // Jobs never started under BatchFailFast or by a stopped Scheduler
+	Total    int           // Number of jobs given
+}
+
+func (e *BatchError) Error() string {
+	msg := fmt.Sprintf("%d of %d batch jobs failed, first: %s: %v",
+		len(e.Failed), e.Total, e.Failed[0].Job.Carrier, e.Failed[0].Err)
+	if e.Canceled > 0 {
+		msg += fmt.Sprintf(", %d canceled", e.Canceled)
+	}
+	return msg
+}
+
+// Batch embeds payloads into many carrier files at once
+type Batch struct {
+	Workers int        // Jobs run at once, by default one per CPU
+	Options []Option   // Options applied to every job
+	FS      WritableFS // Resolves job paths if set, rather than the OS
+
+	Policy BatchErrorPolicy  // How failed jobs are handled
+	Report func(BatchResult) // Called as each job finishes, one at a time
+}
+
+// Run processes jobs concurrently and returns one result per job, in job
+// order. Failed jobs are handled as the batch's Policy says; unless it's
+// BatchSkipAndReport the error is a *BatchError listing them.
+func (b *Batch) Run(jobs []BatchJob) ([]BatchResult, error) {
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]BatchResult, len(jobs))
+	indexes := make(chan int)
+	var mu sync.Mutex // Guards failed and serialises Report
+	failed := false
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				job := jobs[index]
+				result := BatchResult{Job: job, Err: b.runJob(job)}
+				results[index] = result
+				mu.Lock()
+				failed = failed || result.Err != nil
+				if b.Report != nil {
+					b.Report(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for index, job := range jobs {
+		mu.Lock()
+		stop := failed && b.Policy == BatchFailFast
+		mu.Unlock()
+		if stop {
+			results[index] = BatchResult{Job: job, Err: ErrBatchCanceled}
+			continue
+		}
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results, b.collect(results)
+}
+
+// collect returns the error Run reports for results, as the batch's
+// Policy says
+func (b *Batch) collect(results []BatchResult) error {
+	batchErr := &BatchError{Total: len(results)}
+	for _, result := range results {
+		switch result.Err {
+		case nil:
+		case ErrBatchCanceled, ErrSchedulerStopped:
+			batchErr.Canceled++
+		default:
+			batchErr.Failed = append(batchErr.Failed, result)
+		}
+	}
+	if len(batchErr.Failed) == 0 {
+		return nil
+	}
+	log.Error(batchErr)
+	if b.Policy == BatchSkipAndReport {
+		return nil
+	}
+	return batchErr
+}
+
+// runJob embeds one job's payload, removing any partial output on failure
+func (b *Batch) runJob(job BatchJob) error {
+	return b.runJobOn(b.FS, job)
+}
+
+// runJobOn runs job resolving its paths with fsys, or the OS if nil
+func (b *Batch) runJobOn(fsys WritableFS, job BatchJob) error {
+	if fsys == nil {
+		fsys = osFS{}
+	}
+	in, err := fsys.Open(job.Carrier)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fsys.Create(job.Output)
+	if err != nil {
+		return err
+	}
+
+	opts := append(append([]Option{}, b.Options...), job.Options...)
+	err = EmbedCarrier(in, out, job.Payload, opts...)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if r, ok := fsys.(removeFS); ok {
+			r.Remove(job.Output)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package libsteg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestBatchRun verifies every job is attempted, results come back in job
+// order and failures are aggregated without leaving partial output
+func TestBatchRun(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	dir := t.TempDir()
+	var jobs []BatchJob
+	for i := 0; i < 8; i++ {
+		job := BatchJob{
+			Carrier: tinyImageFile,
+			Payload: []byte(fmt.Sprintf("%s %d", secretStringIn, i)),
+			Output:  filepath.Join(dir, fmt.Sprintf("%02d.png", i)),
+		}
+		if i == 3 {
+			job.Carrier = filepath.Join(dir, "missing.png")
+		}
+		jobs = append(jobs, job)
+	}
+
+	batch := &Batch{Workers: 3, Options: []Option{WithPassphrase("hunter2")}}
+	results, err := batch.Run(jobs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Total != len(jobs) || len(batchErr.Failed) != 1 {
+		t.Fatalf("Expected one failed job, got: %v", err)
+	}
+	if batchErr.Failed[0].Job.Carrier != jobs[3].Carrier || !os.IsNotExist(batchErr.Failed[0].Err) {
+		t.Errorf("Unexpected failure: %+v", batchErr.Failed[0])
+	}
+
+	for i, result := range results {
+		if result.Job.Output != jobs[i].Output {
+			t.Errorf("Result %d out of order: %s", i, result.Job.Output)
+		}
+		if i == 3 {
+			if _, err := os.Stat(result.Job.Output); !os.IsNotExist(err) {
+				t.Error("Output left behind by a failed job")
+			}
+			continue
+		}
+		f, err := os.Open(result.Job.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := ExtractCarrier(f, WithPassphrase("hunter2"))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payload) != string(jobs[i].Payload) {
+			t.Errorf("Payloads Do Not Match for job %d!", i)
+		}
+	}
+}
+
+// TestBatchPolicy verifies fail-fast stops dispatching after a failure and
+// skip-and-report succeeds while reporting every job
+func TestBatchPolicy(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	dir := t.TempDir()
+	var jobs []BatchJob
+	for i := 0; i < 6; i++ {
+		jobs = append(jobs, BatchJob{
+			Carrier: tinyImageFile,
+			Payload: []byte(secretStringIn),
+			Output:  filepath.Join(dir, fmt.Sprintf("%02d.png", i)),
+		})
+	}
+	jobs[0].Carrier = filepath.Join(dir, "missing.png")
+
+	// One worker runs jobs in order, so the failure is seen before the rest
+	results, err := (&Batch{Workers: 1, Policy: BatchFailFast}).Run(jobs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 {
+		t.Fatalf("Expected one failed job, got: %v", err)
+	}
+	if batchErr.Canceled == 0 || results[len(results)-1].Err != ErrBatchCanceled {
+		t.Errorf("Expected later jobs to be canceled, got %d canceled", batchErr.Canceled)
+	}
+
+	var reported []BatchResult
+	results, err = (&Batch{
+		Workers: 3,
+		Policy:  BatchSkipAndReport,
+		Report:  func(r BatchResult) { reported = append(reported, r) },
+	}).Run(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != len(jobs) || !os.IsNotExist(results[0].Err) {
+		t.Errorf("Expected %d reports and a failed first job, got %d reports and %v", len(jobs), len(reported), results[0].Err)
+	}
+	for _, result := range results[1:] {
+		if result.Err != nil {
+			t.Errorf("Unexpected failure: %+v", result)
+		}
+	}
+}
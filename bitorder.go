@@ -0,0 +1,107 @@
+package libsteg
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// BitOrder is the order the bits of each payload byte are embedded in
+type BitOrder int
+
+const (
+	// MSBFirst embeds the most significant bit of each byte first, the
+	// default
+	MSBFirst BitOrder = iota
+	// LSBFirst embeds the least significant bit of each byte first, as
+	// some other LSB tools do
+	LSBFirst
+)
+
+var errLengthPrefixSize = errors.New("length prefix must be 1, 2, 4 or 8 bytes")
+
+// WithBitOrder packs payload bytes into the embedded bit stream in the
+// given order, for interoperating with tools that differ from libsteg's
+// MSBFirst. It applies to the built-in envelope and to codecs alike, and
+// the same order must be given on extraction.
+func WithBitOrder(order BitOrder) Option {
+	return func(o *options) {
+		o.bitOrder = order
+	}
+}
+
+// lsbFirstPlacement reverses the bits of every byte of a placement
+type lsbFirstPlacement struct {
+	placement
+}
+
+// orderBits applies the options' bit order to place
+func (o *options) orderBits(place placement) placement {
+	if o.bitOrder == LSBFirst {
+		return lsbFirstPlacement{place}
+	}
+	return place
+}
+
+func (p lsbFirstPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	return p.placement.locate(bitIndex - bitIndex%8 + 7 - bitIndex%8)
+}
+
+func (p lsbFirstPlacement) maskBit(bitIndex int) byte {
+	return maskBit(p.placement, bitIndex-bitIndex%8+7-bitIndex%8)
+}
+
+// LengthPrefixCodec is an EnvelopeCodec framing the payload with just its
+// length, the layout many other LSB tools use
+type LengthPrefixCodec struct {
+	Size  int              // Bytes in the length prefix: 1, 2, 4 or 8
+	Order binary.ByteOrder // Byte order of the length prefix
+}
+
+// Frame implements EnvelopeCodec
+func (c LengthPrefixCodec) Frame(payload []byte) ([]byte, error) {
+	if c.Size != 8 && uint64(len(payload)) >= 1<<(8*uint(c.Size)) {
+		return nil, ErrInsufficientCapacity
+	}
+	prefix := make([]byte, 8)
+	switch c.Size {
+	case 1:
+		prefix[0] = byte(len(payload))
+	case 2:
+		c.Order.PutUint16(prefix, uint16(len(payload)))
+	case 4:
+		c.Order.PutUint32(prefix, uint32(len(payload)))
+	case 8:
+		c.Order.PutUint64(prefix, uint64(len(payload)))
+	default:
+		return nil, errLengthPrefixSize
+	}
+	return append(prefix[:c.Size], payload...), nil
+}
+
+// Deframe implements EnvelopeCodec
+func (c LengthPrefixCodec) Deframe(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, c.Size)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrNoPayload
+	}
+	var n uint64
+	switch c.Size {
+	case 1:
+		n = uint64(prefix[0])
+	case 2:
+		n = uint64(c.Order.Uint16(prefix))
+	case 4:
+		n = uint64(c.Order.Uint32(prefix))
+	case 8:
+		n = c.Order.Uint64(prefix)
+	default:
+		return nil, errLengthPrefixSize
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(r, int64(n&(1<<62-1))))
+	if err != nil || uint64(len(payload)) != n {
+		return nil, ErrNoPayload
+	}
+	return payload, nil
+}
@@ -0,0 +1,91 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestBitOrder verifies LSB-first payloads round trip only when the order
+// is given, and that a little endian length prefix lands in the image
+// exactly as another tool would write it
+func TestBitOrder(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes([]byte(secretStringIn), WithBitOrder(LSBFirst)); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := reloadImage(t, &stegImg)
+	secret, err := reloaded.ExtractBytes(WithBitOrder(LSBFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+	if _, err := reloaded.ExtractBytes(); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	codec := LengthPrefixCodec{Size: 4, Order: binary.LittleEndian}
+	opts := []Option{WithEnvelopeCodec(codec), WithBitOrder(LSBFirst)}
+	if err := stegImg.EmbedBytes([]byte(secretStringIn), opts...); err != nil {
+		t.Fatal(err)
+	}
+	reloaded = reloadImage(t, &stegImg)
+	if secret, err = reloaded.ExtractBytes(opts...); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	raw := make([]byte, 4+len(secretStringIn))
+	if _, err := io.ReadFull(&lsbReader{img: reloaded.imgLoaded, place: newSequentialPlacement(reloaded.imgLoaded)}, raw); err != nil {
+		t.Fatal(err)
+	}
+	for i := range raw {
+		raw[i] = bits.Reverse8(raw[i])
+	}
+	want := append([]byte{byte(len(secretStringIn)), 0, 0, 0}, secretStringIn...)
+	if !bytes.Equal(raw, want) {
+		t.Errorf("Embedded bit stream is %x, expected %x", raw, want)
+	}
+}
+
+// TestLengthPrefixCodec checks each prefix size and refuses payloads too
+// long for it
+func TestLengthPrefixCodec(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, size := range []int{1, 2, 4, 8} {
+		codec := LengthPrefixCodec{Size: size, Order: binary.BigEndian}
+		frame, err := codec.Frame([]byte(secretStringIn))
+		if err != nil {
+			t.Fatal(size, err)
+		}
+		if len(frame) != size+len(secretStringIn) || frame[size-1] != byte(len(secretStringIn)) {
+			t.Errorf("Size %d: unexpected frame %x", size, frame)
+		}
+		payload, err := codec.Deframe(bytes.NewReader(append(frame, "trailing"...)))
+		if err != nil || string(payload) != secretStringIn {
+			t.Errorf("Size %d: deframed %q, %v", size, payload, err)
+		}
+	}
+	if _, err := (LengthPrefixCodec{Size: 1}).Frame(make([]byte, 256)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := (LengthPrefixCodec{Size: 3}).Frame(nil); err != errLengthPrefixSize {
+		t.Error("Correct Error not thrown, expected: '" + errLengthPrefixSize.Error() + "' got: '" + errString(err) + "'")
+	}
+}
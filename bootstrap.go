@@ -0,0 +1,140 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+const (
+	// bootstrapSlot is the well known slot name a bootstrap is stored
+	// under, so it can be found without any key
+	bootstrapSlot = "libsteg.bootstrap"
+	// bootstrapVersion is the current bootstrap encoding version
+	bootstrapVersion = 1
+)
+
+var (
+	errBadBootstrap   = errors.New("malformed bootstrap message")
+	errBootstrapNoKey = errors.New("bootstrap requires a public key")
+)
+
+// Bootstrap is a first contact message, letting two parties who have only
+// exchanged images set up a keyed channel. The receiver encrypts replies
+// to PublicKey with WithRecipients, and if VerifyKey is set it can check
+// later messages signed by the sender with WithVerifyKey.
+type Bootstrap struct {
+	PublicKey *ecdh.PublicKey   // X25519 key replies are encrypted to
+	VerifyKey ed25519.PublicKey // Ed25519 key later messages are signed with, optional
+	Params    map[string]string // Free-form channel parameters, e.g. preferred options
+}
+
+// EmbedBootstrap stores b in the image's well known bootstrap slot, next
+// to any other named slots. The bootstrap is public by design, so it isn't
+// encrypted; give WithSigningKey to let the receiver authenticate it
+// against a key obtained out of band.
+func (s *StegImage) EmbedBootstrap(b Bootstrap, opts ...Option) error {
+	data, err := b.marshal()
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return s.EmbedNamed(bootstrapSlot, data, append(opts, withoutEncryption())...)
+}
+
+// ExtractBootstrap retrieves a bootstrap stored with EmbedBootstrap
+func (s *StegImage) ExtractBootstrap(opts ...Option) (*Bootstrap, error) {
+	data, err := s.ExtractNamed(bootstrapSlot, append(opts, withoutEncryption())...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := unmarshalBootstrap(data)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	return b, nil
+}
+
+// withoutEncryption clears any passphrase or recipients, for payloads that
+// must be readable without a key
+func withoutEncryption() Option {
+	return func(o *options) {
+		o.passphrase = ""
+		o.hmac = false
+		o.recipients = nil
+		o.identity = nil
+	}
+}
+
+func (b *Bootstrap) marshal() ([]byte, error) {
+	if b.PublicKey == nil {
+		return nil, errBootstrapNoKey
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(bootstrapVersion)
+	buf.Write(b.PublicKey.Bytes())
+	writeUvarint(buf, uint64(len(b.VerifyKey)))
+	buf.Write(b.VerifyKey)
+
+	keys := make([]string, 0, len(b.Params))
+	for k := range b.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	writeUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		writeString(buf, k)
+		writeString(buf, b.Params[k])
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalBootstrap(data []byte) (*Bootstrap, error) {
+	r := bytes.NewReader(data)
+	if version, err := r.ReadByte(); err != nil || version != bootstrapVersion {
+		return nil, errBadBootstrap
+	}
+	pub := make([]byte, 32)
+	if _, err := io.ReadFull(r, pub); err != nil {
+		return nil, errBadBootstrap
+	}
+	b := &Bootstrap{}
+	var err error
+	if b.PublicKey, err = ecdh.X25519().NewPublicKey(pub); err != nil {
+		return nil, errBadBootstrap
+	}
+
+	n, err := binary.ReadUvarint(r)
+	if err != nil || (n != 0 && n != ed25519.PublicKeySize) {
+		return nil, errBadBootstrap
+	}
+	if n > 0 {
+		b.VerifyKey = make(ed25519.PublicKey, n)
+		if _, err = io.ReadFull(r, b.VerifyKey); err != nil {
+			return nil, errBadBootstrap
+		}
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(r.Len()) {
+		return nil, errBadBootstrap
+	}
+	if count > 0 {
+		b.Params = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		k, err := readString(r)
+		if err != nil {
+			return nil, errBadBootstrap
+		}
+		if b.Params[k], err = readString(r); err != nil {
+			return nil, errBadBootstrap
+		}
+	}
+	return b, nil
+}
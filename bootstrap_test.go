@@ -0,0 +1,61 @@
+package libsteg
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestBootstrapChannel verifies a signed bootstrap can be found without a
+// key and used to encrypt a reply only its sender can read
+func TestBootstrapChannel(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	alice, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	verifyKey, signingKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	b := Bootstrap{
+		PublicKey: alice.PublicKey(),
+		VerifyKey: verifyKey,
+		Params:    map[string]string{"compression": "zstd"},
+	}
+	if err := cleanImg.EmbedBootstrap(b, WithSigningKey(signingKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reloadImage(t, &cleanImg).ExtractBootstrap(WithVerifyKey(verifyKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.PublicKey.Equal(alice.PublicKey()) || !got.VerifyKey.Equal(verifyKey) ||
+		got.Params["compression"] != "zstd" {
+		t.Errorf("Bootstrap Does Not Match! got %+v", got)
+	}
+
+	var replyImg StegImage
+	if err = replyImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err = replyImg.EmbedBytes([]byte(secretStringIn), WithRecipients(got.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := reloadImage(t, &replyImg).ExtractBytes(WithIdentity(alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	if err = cleanImg.EmbedBootstrap(Bootstrap{}); err != errBootstrapNoKey {
+		t.Error("Correct Error not thrown, expected: '" + errBootstrapNoKey.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,212 @@
+package libsteg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// CalibrationPattern is a synthetic cover content, each stressing
+// detectors and encoders differently
+type CalibrationPattern int
+
+const (
+	// PatternFlat is a single mid grey, where any embedding stands out
+	PatternFlat CalibrationPattern = iota
+	// PatternGradient is a smooth diagonal colour ramp
+	PatternGradient
+	// PatternCheckerboard is 8x8 blocks of alternating tones, with hard
+	// edges on JPEG block boundaries
+	PatternCheckerboard
+	// PatternNoise is seeded uniform noise, where embedding hides best
+	PatternNoise
+)
+
+var calibrationPatternNames = []string{"flat", "gradient", "checkerboard", "noise"}
+
+func (p CalibrationPattern) String() string {
+	if int(p) < len(calibrationPatternNames) {
+		return calibrationPatternNames[p]
+	}
+	return fmt.Sprintf("CalibrationPattern(%d)", int(p))
+}
+
+var errCalibrationRate = errors.New("calibration rates must be between 0 and 1")
+
+// CalibrationConfig describes a set of calibration covers
+type CalibrationConfig struct {
+	Width, Height int
+	Patterns      []CalibrationPattern // All patterns if empty
+	Rates         []float64            // Fractions of capacity to fill, 0 gives a clean control image
+	Seed          []byte               // Seeds the noise, payloads and embedding
+	Options       []Option             // Embedding options, e.g. WithBlockChecksums to test FEC settings
+}
+
+// CalibrationCover is one generated image with everything known about it
+type CalibrationCover struct {
+	Pattern CalibrationPattern
+	Rate    float64     // Fraction of capacity requested
+	Used    int         // Bytes embedded, including envelope overhead, 0 for a control
+	Payload []byte      // Payload embedded, nil for a control
+	Image   image.Image // The stego image, or the bare cover for a control
+}
+
+// GenerateCalibration builds a cover for each pattern and embeds a known
+// payload into it at each rate, for validating detectors, error correction
+// settings and how payloads survive a platform empirically. The output is
+// reproducible from the config alone.
+func GenerateCalibration(cfg CalibrationConfig) ([]CalibrationCover, error) {
+	patterns := cfg.Patterns
+	if len(patterns) == 0 {
+		patterns = []CalibrationPattern{PatternFlat, PatternGradient, PatternCheckerboard, PatternNoise}
+	}
+	seed := sha256.Sum256(cfg.Seed)
+
+	var covers []CalibrationCover
+	for _, pattern := range patterns {
+		cover := calibrationCover(pattern, cfg.Width, cfg.Height, seed[:])
+		for _, rate := range cfg.Rates {
+			c, err := calibrate(cover, pattern, rate, seed[:], cfg.Options)
+			if err != nil {
+				log.Error(err)
+				return nil, err
+			}
+			covers = append(covers, c)
+		}
+	}
+	return covers, nil
+}
+
+// calibrate embeds a seeded payload filling rate of cover's capacity
+func calibrate(cover image.Image, pattern CalibrationPattern, rate float64, seed []byte, opts []Option) (CalibrationCover, error) {
+	c := CalibrationCover{Pattern: pattern, Rate: rate, Image: cover}
+	if rate < 0 || rate > 1 {
+		return c, errCalibrationRate
+	}
+	if rate == 0 {
+		return c, nil
+	}
+
+	s := NewStegImageFromImage(cover)
+	opts = append(append([]Option{}, opts...), WithSeed(seed))
+	spec, err := s.CapacitySpec(opts...)
+	if err != nil {
+		return c, err
+	}
+	overhead, err := spec.StoredLen(0)
+	if err != nil {
+		return c, err
+	}
+	size := int(rate*float64(spec.Capacity())) - overhead
+	if size < 0 {
+		size = 0
+	}
+	c.Payload = make([]byte, size)
+	mac := sha256.Sum256(append([]byte(fmt.Sprintf("libsteg calibration %s %g:", pattern, rate)), seed...))
+	newDeterministicReader(mac[:]).Read(c.Payload)
+
+	opts = append(opts, WithCapacityWarning(0, func(w CapacityWarning) { c.Used = w.Used }))
+	if err = s.EmbedBytes(c.Payload, opts...); err != nil {
+		return c, err
+	}
+	c.Image = s.Stego()
+	return c, nil
+}
+
+// calibrationCover draws pattern into a new image
+func calibrationCover(pattern CalibrationPattern, width, height int, seed []byte) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	noise := newDeterministicReader(seed)
+	px := make([]byte, 3)
+	// Ramp denominators, kept positive for one pixel wide covers
+	dx, dy := width-1, height-1
+	if dx < 1 {
+		dx = 1
+	}
+	if dy < 1 {
+		dy = 1
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch pattern {
+			case PatternGradient:
+				c = color.NRGBA{uint8(x * 255 / dx), uint8(y * 255 / dy), uint8((x + y) * 255 / (dx + dy)), 0xff}
+			case PatternCheckerboard:
+				v := uint8(64)
+				if (x/8+y/8)%2 == 1 {
+					v = 192
+				}
+				c = color.NRGBA{v, v, v, 0xff}
+			case PatternNoise:
+				noise.Read(px)
+				c = color.NRGBA{px[0], px[1], px[2], 0xff}
+			default:
+				c = color.NRGBA{128, 128, 128, 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// calibrationManifestEntry is one image in a calibration manifest
+type calibrationManifestEntry struct {
+	File          string  `json:"file"`
+	Pattern       string  `json:"pattern"`
+	Rate          float64 `json:"rate"`
+	Used          int     `json:"used"`
+	PayloadLen    int     `json:"payloadLen"`
+	PayloadSHA256 string  `json:"payloadSha256,omitempty"`
+}
+
+// WriteCalibration writes each cover to fsys as a PNG named for its
+// pattern and rate, with a manifest.json listing what each one holds
+func WriteCalibration(fsys WritableFS, covers []CalibrationCover) error {
+	manifest := make([]calibrationManifestEntry, 0, len(covers))
+	for _, c := range covers {
+		entry := calibrationManifestEntry{
+			File:       fmt.Sprintf("%s-%03d.png", c.Pattern, int(c.Rate*100+0.5)),
+			Pattern:    c.Pattern.String(),
+			Rate:       c.Rate,
+			Used:       c.Used,
+			PayloadLen: len(c.Payload),
+		}
+		if c.Payload != nil {
+			sum := sha256.Sum256(c.Payload)
+			entry.PayloadSHA256 = hex.EncodeToString(sum[:])
+		}
+		s := NewStegImageFromImage(c.Image)
+		if s.newImg, _ = c.Image.(draw.Image); s.newImg == nil {
+			if err := s.createMutableImage(); err != nil {
+				log.Error(err)
+				return err
+			}
+		}
+		if err := s.WriteNewImageToFS(fsys, entry.File); err != nil {
+			return err
+		}
+		manifest = append(manifest, entry)
+	}
+
+	out, err := fsys.Create("manifest.json")
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(manifest)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestGenerateCalibration verifies covers are made at the requested rates
+// with payloads that extract, controls stay clean, and the set is
+// reproducible
+func TestGenerateCalibration(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cfg := CalibrationConfig{Width: 64, Height: 48, Rates: []float64{0, 0.1, 0.5}, Seed: []byte("seed")}
+	covers, err := GenerateCalibration(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(covers) != 4*len(cfg.Rates) {
+		t.Fatalf("Generated %d covers, expected %d", len(covers), 4*len(cfg.Rates))
+	}
+	capacity := 64 * 48 * 3 / 8
+	for _, c := range covers {
+		s := NewStegImageFromImage(c.Image)
+		payload, err := s.ExtractBytes()
+		if c.Rate == 0 {
+			if err != ErrNoPayload || c.Payload != nil {
+				t.Errorf("%s control isn't clean: %v", c.Pattern, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(c.Pattern, c.Rate, err)
+		}
+		if !bytes.Equal(payload, c.Payload) {
+			t.Errorf("%s at %g: extracted payload differs", c.Pattern, c.Rate)
+		}
+		if want := int(c.Rate * float64(capacity)); c.Used != want {
+			t.Errorf("%s at %g: used %d bytes, expected %d", c.Pattern, c.Rate, c.Used, want)
+		}
+	}
+
+	again, err := GenerateCalibration(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range covers {
+		if ratio, _ := ChangedPixelRatio(covers[i].Image, again[i].Image); ratio != 0 {
+			t.Errorf("%s at %g isn't reproducible", covers[i].Pattern, covers[i].Rate)
+		}
+	}
+
+	if _, err := GenerateCalibration(CalibrationConfig{Width: 8, Height: 8, Rates: []float64{1.5}}); err != errCalibrationRate {
+		t.Error("Correct Error not thrown, expected: '" + errCalibrationRate.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestWriteCalibration checks the images and manifest written to disk
+func TestWriteCalibration(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	covers, err := GenerateCalibration(CalibrationConfig{
+		Width: 32, Height: 32, Patterns: []CalibrationPattern{PatternNoise}, Rates: []float64{0, 0.25},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "calibration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteCalibration(DirFS(dir), covers); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest []calibrationManifestEntry
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 || manifest[1].File != "noise-025.png" || manifest[1].PayloadSHA256 == "" {
+		t.Fatalf("Unexpected manifest %+v", manifest)
+	}
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(filepath.Join(dir, manifest[1].File)); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := stegImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, covers[1].Payload) {
+		t.Error("Extracted payload differs")
+	}
+}
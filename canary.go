@@ -0,0 +1,142 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// canaryMagic starts every canary token, so other payloads encrypted with
+// the same passphrase aren't mistaken for one
+const canaryMagic = "LCNY\x01"
+
+var (
+	errCanaryNoKey = errors.New("canary requires a passphrase")
+	errBadCanary   = errors.New("payload is not a canary token")
+)
+
+// Canary is a token identifying which partner a copy of an image was
+// issued to
+type Canary struct {
+	Partner string
+	Serial  [8]byte // Random, unique to each issued copy
+	Issued  time.Time
+}
+
+// CanaryMatch is an image found by ScanCanaries and the canary it holds
+type CanaryMatch struct {
+	Path   string
+	Canary Canary
+}
+
+// EmbedCanary embeds a fresh canary token for partner, so a copy of the
+// image that later surfaces can be traced back to them. The token is
+// encrypted and authenticated with the passphrase given WithPassphrase,
+// which must be kept by the issuer only: partners can neither read their
+// own token nor forge another's. Like any LSB payload the token doesn't
+// survive lossy recompression or resizing.
+func (s *StegImage) EmbedCanary(partner string, opts ...Option) (Canary, error) {
+	o := newOptions(opts)
+	if o.passphrase == "" {
+		s.logger().Error(errCanaryNoKey)
+		return Canary{}, errCanaryNoKey
+	}
+	c := Canary{Partner: partner, Issued: time.Now().UTC().Truncate(time.Second)}
+	if _, err := io.ReadFull(o.rand, c.Serial[:]); err != nil {
+		s.logger().Error(err)
+		return Canary{}, err
+	}
+	if err := s.EmbedBytes(c.marshal(), opts...); err != nil {
+		return Canary{}, err
+	}
+	s.logger().Notice("Embedded canary", hex.EncodeToString(c.Serial[:]), "for", partner)
+	return c, nil
+}
+
+// VerifyCanary retrieves and authenticates a canary embedded with
+// EmbedCanary, using the issuer's passphrase
+func (s *StegImage) VerifyCanary(opts ...Option) (*Canary, error) {
+	if newOptions(opts).passphrase == "" {
+		s.logger().Error(errCanaryNoKey)
+		return nil, errCanaryNoKey
+	}
+	data, err := s.ExtractBytes(opts...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := unmarshalCanary(data)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// ScanCanaries walks fsys for images carrying a canary issued with the
+// passphrase in opts, identifying whose copies have leaked. Images without
+// one, and files that aren't images, are skipped.
+func ScanCanaries(fsys fs.FS, opts ...Option) ([]CanaryMatch, error) {
+	if newOptions(opts).passphrase == "" {
+		log.Error(errCanaryNoKey)
+		return nil, errCanaryNoKey
+	}
+	var matches []CanaryMatch
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		f, err := fsys.Open(path)
+		if err != nil {
+			log.Warning(err)
+			return nil
+		}
+		defer f.Close()
+		var s StegImage
+		if s.loadImage(f) != nil {
+			return nil
+		}
+		if c, err := s.VerifyCanary(opts...); err == nil {
+			matches = append(matches, CanaryMatch{Path: path, Canary: *c})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return matches, nil
+}
+
+func (c *Canary) marshal() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(canaryMagic)
+	buf.Write(c.Serial[:])
+	binary.Write(buf, binary.BigEndian, c.Issued.Unix())
+	writeString(buf, c.Partner)
+	return buf.Bytes()
+}
+
+func unmarshalCanary(data []byte) (*Canary, error) {
+	if !bytes.HasPrefix(data, []byte(canaryMagic)) {
+		return nil, errBadCanary
+	}
+	r := bytes.NewReader(data[len(canaryMagic):])
+	c := &Canary{}
+	var issued int64
+	if _, err := io.ReadFull(r, c.Serial[:]); err != nil {
+		return nil, errBadCanary
+	}
+	if err := binary.Read(r, binary.BigEndian, &issued); err != nil {
+		return nil, errBadCanary
+	}
+	partner, err := readString(r)
+	if err != nil || r.Len() != 0 {
+		return nil, errBadCanary
+	}
+	c.Partner, c.Issued = partner, time.Unix(issued, 0).UTC()
+	return c, nil
+}
@@ -0,0 +1,55 @@
+package libsteg
+
+import (
+	"testing"
+	"testing/fstest"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestCanaryLeak verifies each partner's copy carries a distinct canary
+// which the scanner traces back to them
+func TestCanaryLeak(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	fsys := fstest.MapFS{"notes.txt": {Data: []byte("not an image")}}
+	issued := make(map[string]Canary)
+	for _, partner := range []string{"acme", "globex"} {
+		var img StegImage
+		if err := img.LoadImageFromB64(CleanB64Image); err != nil {
+			t.Fatal(err)
+		}
+		c, err := img.EmbedCanary(partner, WithPassphrase("issuer"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued[partner] = c
+		fsys["leaked/"+partner+".png"] = &fstest.MapFile{Data: pngBytes(t, &img)}
+	}
+	fsys["other.png"] = &fstest.MapFile{Data: stegPNG(t, secretStringIn, WithPassphrase("issuer"))}
+
+	if issued["acme"].Serial == issued["globex"].Serial {
+		t.Error("Canary serials not unique")
+	}
+	matches, err := ScanCanaries(fsys, WithPassphrase("issuer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 canaries, got %+v", matches)
+	}
+	for _, m := range matches {
+		if m.Path != "leaked/"+m.Canary.Partner+".png" || m.Canary != issued[m.Canary.Partner] {
+			t.Errorf("Canary %+v doesn't match the one issued", m)
+		}
+	}
+
+	if matches, _ = ScanCanaries(fsys, WithPassphrase("partner guess")); len(matches) != 0 {
+		t.Error("Canary verified with the wrong passphrase")
+	}
+	var img StegImage
+	if _, err = img.EmbedCanary("acme"); err != errCanaryNoKey {
+		t.Error("Correct Error not thrown, expected: '" + errCanaryNoKey.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,41 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// Canonicalize normalises the loaded cover before embedding so the stego
+// output doesn't stand out against other images from the same source
+// pipeline. The image is converted to 8-bit NRGBA and re-encoded once as
+// PNG, which drops any ancillary metadata and settles the colour type the
+// stego output will also be written with.
+func (s *StegImage) Canonicalize() error {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+
+	bounds := s.imgLoaded.Bounds()
+	normalised := image.NewNRGBA(bounds)
+	draw.Draw(normalised, bounds, s.imgLoaded, bounds.Min, draw.Src)
+
+	buf := new(bytes.Buffer)
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(buf, normalised); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+
+	img, err := png.Decode(buf)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	// The source's chunks would otherwise be copied back into the output
+	s.imgLoaded, s.imgType, s.srcChunks = img, "png", nil
+	s.logger().Notice("Image canonicalized from", bounds.Size())
+	return nil
+}
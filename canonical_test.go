@@ -0,0 +1,79 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"reflect"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestCanonicalizePaletted verifies a paletted cover is normalised to an
+// 8-bit true colour image and can still carry a secret
+func TestCanonicalizePaletted(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	paletted := image.NewPaletted(image.Rect(0, 0, 64, 64), palette.Plan9)
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			paletted.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+
+	cover := StegImage{imgLoaded: paletted, imgType: "gif"}
+	if err := cover.Canonicalize(); err != nil {
+		t.Fatal(err)
+	}
+	switch cover.imgLoaded.(type) {
+	case *image.RGBA, *image.NRGBA:
+	default:
+		t.Errorf("Cover not canonicalized, got %T", cover.imgLoaded)
+	}
+	if cover.imgType != "png" {
+		t.Error("Expected canonical type png, got", cover.imgType)
+	}
+
+	if err := cover.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := reloadImage(t, &cover).ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+}
+
+// TestCanonicalizeStripsChunks verifies the source's ancillary chunks don't
+// survive into the stego output
+func TestCanonicalizeStripsChunks(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	software := PNGChunk{Type: "tEXt", Data: []byte("Software\x00Photoshop")}
+	pHYs := PNGChunk{Type: "pHYs", Data: []byte{0, 0, 0x0b, 0x13, 0, 0, 0x0b, 0x13, 1}}
+	src := withChunks(t, cleanImg.imgLoaded, []PNGChunk{gamaChunk(), pHYs, software}, nil, nil)
+
+	var cover StegImage
+	if err := cover.LoadImage(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cover.Canonicalize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cover.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	if types := chunkTypes(t, pngBytes(t, &cover)); !reflect.DeepEqual(types, []string{"IHDR", "IDAT", "IEND"}) {
+		t.Errorf("Canonicalized output has chunks %v", types)
+	}
+}
@@ -0,0 +1,49 @@
+package libsteg
+
+import "fmt"
+
+// defaultCapacityThreshold is the fraction of capacity above which an embed
+// is reported as easier to detect
+const defaultCapacityThreshold = 0.1
+
+// CapacityWarning reports an embed using more of the image's capacity than
+// the configured threshold. High embedding rates leave statistical traces
+// that steganalysis picks up far more reliably than low ones.
+type CapacityWarning struct {
+	Used      int     // Bytes written, including envelope overhead
+	Capacity  int     // Bytes available at the placement written to
+	Ratio     float64 // Used / Capacity
+	Threshold float64 // Threshold that was exceeded
+}
+
+// WithCapacityWarning sets the fraction of capacity, between 0 and 1, above
+// which an embed is reported to fn. The embed itself still succeeds. Without
+// this option usage above 10% is only logged as a warning.
+func WithCapacityWarning(threshold float64, fn func(CapacityWarning)) Option {
+	return func(o *options) {
+		o.capacityThreshold = threshold
+		o.capacityHook = fn
+	}
+}
+
+// checkCapacity reports usage of the given placement above the threshold
+func (o *options) checkCapacity(used int, place placement, logger stegLogger) {
+	capacity := place.capacity() / 8
+	if capacity == 0 {
+		return
+	}
+	ratio := float64(used) / float64(capacity)
+	if ratio <= o.capacityThreshold {
+		return
+	}
+	logger.Warning(fmt.Sprintf("Payload uses %.1f%% of capacity, above the %.1f%% threshold",
+		ratio*100, o.capacityThreshold*100))
+	if o.capacityHook != nil {
+		o.capacityHook(CapacityWarning{
+			Used:      used,
+			Capacity:  capacity,
+			Ratio:     ratio,
+			Threshold: o.capacityThreshold,
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package libsteg
+
+import (
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestCapacityWarning verifies the hook only fires above the threshold
+func TestCapacityWarning(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	capacity := bitCapacity(cleanImg.imgLoaded.Bounds(), 3) / 8
+
+	var warnings []CapacityWarning
+	hook := WithCapacityWarning(0.5, func(w CapacityWarning) {
+		warnings = append(warnings, w)
+	})
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), hook); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Unexpected capacity warning: %+v", warnings)
+	}
+
+	payloadIn := strings.Repeat("x", capacity*3/4)
+	if err := cleanImg.EmbedBytes([]byte(payloadIn), hook); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Capacity != capacity ||
+		warnings[0].Ratio <= 0.75 || warnings[0].Threshold != 0.5 {
+		t.Errorf("Unexpected capacity warnings: %+v", warnings)
+	}
+}
@@ -0,0 +1,198 @@
+package libsteg
+
+import (
+	"errors"
+	"sort"
+)
+
+// CapacitySpecVersion identifies the capacity formula CapacitySpec
+// evaluates. It changes whenever the envelope layout does, so peers
+// holding specs from another version can tell their figures apart.
+const CapacitySpecVersion = 1
+
+var (
+	// ErrCapacitySpecVersion is returned when evaluating a spec written for
+	// a different capacity formula
+	ErrCapacitySpecVersion = errors.New("unsupported capacity spec version")
+	errSpecUnsupported     = errors.New("capacity specs don't cover adaptive, redundant, matrix, tiled or custom codec embedding")
+)
+
+// CapacitySpec describes a cover and the envelope a payload will be framed
+// in, which is all that's needed to work out whether a payload fits. It's
+// plain data, so it can be built where the image is, with
+// StegImage.CapacitySpec, and evaluated by a peer that never sees it.
+//
+// Figures are exact for uncompressed payloads. Compression is allowed for
+// by its worst case expansion, as how well a payload compresses can't be
+// known in advance, so they are then an upper bound. Only the default
+// sequential placement is covered.
+type CapacitySpec struct {
+	Version  int // CapacitySpecVersion the spec was built for
+	Width    int
+	Height   int
+	Channels int // Channels carrying bits, 1 for grayscale and paletted images and 3 otherwise
+
+	Name        string      // Slot name, see EmbedNamed
+	Visible     bool        // A visible pixel checksum, see WithVisibleChecksum
+	Digest      bool        // A payload digest, see WithPayloadDigest
+	Checksum    bool        // A CRC32 trailer, as EmbedBytes and carriers write
+	BlockSums   bool        // Block checksums, see WithBlockChecksums
+	Encrypted   bool        // Passphrase encryption
+	Recipients  int         // Public key recipients, taking precedence over Encrypted
+	HMAC        bool        // An HMAC trailer
+	Signed      bool        // An Ed25519 signature trailer
+	MetadataLen int         // Length of the marshalled metadata block, 0 for none
+	Compression Compression // Codec the payload is compressed with
+}
+
+// Worst case expansion of incompressible data by each codec, as fixed
+// framing plus a per block cost for every started blockBytes of input
+var compressionOverhead = map[Compression]struct {
+	fixed, perBlock, blockBytes int
+}{
+	CompressionNone: {0, 0, 1},
+	// gzip header and trailer, a stored block header per deflate block
+	CompressionGzip: {10 + 8 + 5, 5, 16 * 1024},
+	// Frame header and checksum, a raw block header per zstd block
+	CompressionZstd: {18 + 4 + 3, 3, 16 * 1024},
+}
+
+// CapacitySpec describes the loaded image and the envelope opts would
+// frame a payload in
+func (s *StegImage) CapacitySpec(opts ...Option) (CapacitySpec, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return CapacitySpec{}, errNoImageLoaded
+	}
+	o := newOptions(opts)
+	if o.adaptive || o.codec != nil || o.redundancy > 1 || o.matrix != 0 || o.tileSize != 0 {
+		s.logger().Error(errSpecUnsupported)
+		return CapacitySpec{}, errSpecUnsupported
+	}
+	if _, err := o.selectChannels(s.imgLoaded, newSequentialPlacement(s.imgLoaded)); err != nil {
+		s.logger().Error(err)
+		return CapacitySpec{}, err
+	}
+	bounds := s.imgLoaded.Bounds()
+	spec := CapacitySpec{
+		Version:     CapacitySpecVersion,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Channels:    o.pixelChannels(s.imgLoaded),
+		Name:        o.name,
+		Visible:     o.visibleChecksum,
+		Digest:      o.payloadDigest,
+		Checksum:    true,
+		BlockSums:   o.blockSums,
+		Encrypted:   o.passphrase != "",
+		Recipients:  len(o.recipients),
+		HMAC:        o.hmac,
+		Signed:      o.signingKey != nil,
+		Compression: o.compression,
+	}
+	if o.metadata != nil {
+		spec.MetadataLen = len(o.metadata.marshal())
+	}
+	return spec, nil
+}
+
+// Capacity returns the number of bytes the cover can hold
+func (c CapacitySpec) Capacity() int {
+	return c.Width * c.Height * c.Channels / 8
+}
+
+// StoredLen returns the number of bytes a payload of payloadLen bytes
+// takes once framed, counting the header, metadata, compression,
+// encryption and trailer
+func (c CapacitySpec) StoredLen(payloadLen int) (int, error) {
+	if c.Version != CapacitySpecVersion {
+		log.Error(ErrCapacitySpecVersion)
+		return 0, ErrCapacitySpecVersion
+	}
+
+	// Metadata is compressed along with the payload
+	n := c.MetadataLen + payloadLen
+	codecs := []Compression{c.Compression}
+	if c.Compression == CompressionAuto {
+		codecs = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+	}
+	compressed := 0
+	for _, codec := range codecs {
+		cost := compressionOverhead[codec]
+		if size := n + cost.fixed + cost.perBlock*((n+cost.blockBytes-1)/cost.blockBytes); size > compressed {
+			compressed = size
+		}
+	}
+	n = compressed
+
+	// Encryption seals at least one chunk, each carrying a header and tag
+	chunks := (n + cryptChunkSize - 1) / cryptChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	sealing := cryptNoncePrefixLen + chunks*(5+16)
+	switch {
+	case c.Recipients > 0:
+		n += uvarintLen(uint64(c.Recipients)) + c.Recipients*stanzaLen + sealing
+	case c.Encrypted:
+		n += cryptSaltLen + sealing
+	}
+
+	e := &envelope{name: c.Name}
+	if c.Name != "" {
+		e.flags |= flagNamed
+	}
+	if c.Visible {
+		e.flags |= flagVisible
+	}
+	if c.Digest {
+		e.flags |= flagPayloadDigest
+	}
+	if c.Checksum {
+		e.flags |= flagChecksum
+	}
+	if c.HMAC {
+		e.flags |= flagHMAC
+	}
+	if c.Signed {
+		e.flags |= flagSigned
+	}
+	if c.BlockSums {
+		e.flags |= flagBlockSums
+		e.blockSums = blockCount(n)
+	}
+	return e.headerLen() + n + e.trailerLen(), nil
+}
+
+// Fits reports whether a payload of payloadLen bytes fits in the cover
+func (c CapacitySpec) Fits(payloadLen int) (bool, error) {
+	stored, err := c.StoredLen(payloadLen)
+	if err != nil {
+		return false, err
+	}
+	return stored <= c.Capacity(), nil
+}
+
+// MaxPayload returns the largest payload, in bytes, that fits in the
+// cover, or -1 if not even an empty one does
+func (c CapacitySpec) MaxPayload() (int, error) {
+	if _, err := c.StoredLen(0); err != nil {
+		return 0, err
+	}
+	// StoredLen only grows with the payload
+	capacity := c.Capacity()
+	fits := sort.Search(capacity+1, func(n int) bool {
+		stored, _ := c.StoredLen(n)
+		return stored > capacity
+	})
+	return fits - 1, nil
+}
+
+// uvarintLen returns the encoded length of v as a uvarint
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestCapacitySpecStoredLen checks the spec against the envelopes actually
+// written, exactly when uncompressed and as a bound otherwise
+func TestCapacitySpecStoredLen(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	alice, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	bob, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	_, signingKey, _ := ed25519.GenerateKey(rand.Reader)
+	meta := Metadata{ContentType: "text/plain", Tags: map[string]string{"k": "v"}}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		exact bool
+	}{
+		{"plain", nil, true},
+		{"encrypted hmac", []Option{WithPassphrase("hunter2"), WithHMAC()}, true},
+		{"recipients signed", []Option{WithRecipients(alice.PublicKey(), bob.PublicKey()), WithSigningKey(signingKey)}, true},
+		{"metadata", []Option{WithMetadata(meta)}, true},
+		{"block sums", []Option{WithBlockChecksums(), WithPassphrase("hunter2")}, true},
+		{"digest", []Option{WithPayloadDigest()}, true},
+		{"gzip", []Option{WithCompression(CompressionGzip), WithPassphrase("hunter2")}, false},
+		{"zstd", []Option{WithCompression(CompressionZstd)}, false},
+		{"auto", []Option{WithCompression(CompressionAuto), WithMetadata(meta)}, false},
+	} {
+		spec, err := cleanImg.CapacitySpec(tc.opts...)
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		// Carriers aren't bound by the image's capacity
+		for _, size := range []int{0, 100, cryptChunkSize, 2*cryptChunkSize + 1} {
+			payload := make([]byte, size)
+			rand.Read(payload)
+			framed, err := marshalEnvelope(bytes.NewReader(payload), flagChecksum, newOptions(tc.opts), log)
+			if err != nil {
+				t.Fatal(tc.name, err)
+			}
+			used := len(framed)
+			stored, err := spec.StoredLen(size)
+			if err != nil {
+				t.Fatal(tc.name, err)
+			}
+			if used > stored || tc.exact && used != stored {
+				t.Errorf("%s: %d byte payload used %d bytes, spec gives %d", tc.name, size, used, stored)
+			}
+		}
+	}
+}
+
+// TestCapacitySpecMaxPayload verifies the largest payload the spec allows
+// embeds and one byte more doesn't, and other versions are refused
+func TestCapacitySpecMaxPayload(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := cleanImg.CapacitySpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Name = "slot"
+	max, err := spec.MaxPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cleanImg.EmbedNamed("slot", make([]byte, max)); err != nil {
+		t.Errorf("Largest payload of %d bytes didn't fit: %v", max, err)
+	}
+	if err = cleanImg.EmbedNamed("slot", make([]byte, max+1)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+
+	spec.Version++
+	if _, err = spec.Fits(1); err != ErrCapacitySpecVersion {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrCapacitySpecVersion) + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,278 @@
+package libsteg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/karlwebster/libsteg/text"
+)
+
+// carrierSniffLen is how much of the input carriers are shown to decide
+// whether they handle it
+const carrierSniffLen = 4096
+
+// ErrUnknownCarrier is returned when no registered carrier handles an
+// input, or none is registered under a requested name
+var ErrUnknownCarrier = errors.New("no carrier for input")
+
+// Embedder hides a payload in the carrier file read from r, writing the
+// result to w
+type Embedder interface {
+	Embed(r io.Reader, w io.Writer, payload []byte, opts ...Option) error
+}
+
+// Extractor retrieves a payload hidden by the matching Embedder
+type Extractor interface {
+	Extract(r io.Reader, opts ...Option) ([]byte, error)
+}
+
+// Carrier is a file format payloads can be hidden in. Carriers that store
+// raw bytes should frame them with MarshalPayload and UnmarshalPayload, so
+// every carrier shares the same framing, encryption and integrity checks.
+type Carrier interface {
+	Name() string
+	// Sniff reports whether the input starting with head is in this
+	// carrier's format. head holds up to the first 4KiB.
+	Sniff(head []byte) bool
+	Embedder
+	Extractor
+}
+
+var (
+	carriersMu sync.RWMutex
+	carriers   []Carrier
+)
+
+// RegisterCarrier adds c to the carriers EmbedCarrier and ExtractCarrier
+// choose from. Carriers are sniffed most recently registered first, so a
+// new carrier can take over a format from a built in one.
+func RegisterCarrier(c Carrier) {
+	carriersMu.Lock()
+	defer carriersMu.Unlock()
+	carriers = append(carriers, c)
+}
+
+// LookupCarrier returns the carrier registered under name, most recent
+// first
+func LookupCarrier(name string) (Carrier, error) {
+	carriersMu.RLock()
+	defer carriersMu.RUnlock()
+	for i := len(carriers) - 1; i >= 0; i-- {
+		if carriers[i].Name() == name {
+			return carriers[i], nil
+		}
+	}
+	return nil, ErrUnknownCarrier
+}
+
+// EmbedCarrier hides payload in the file read from r with the carrier its
+// format calls for, writing the result to w
+func EmbedCarrier(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	c, r, err := sniffCarrier(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Notice("Embedding with carrier", c.Name())
+	return c.Embed(r, w, payload, opts...)
+}
+
+// ExtractCarrier retrieves a payload hidden with EmbedCarrier
+func ExtractCarrier(r io.Reader, opts ...Option) ([]byte, error) {
+	c, r, err := sniffCarrier(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return c.Extract(r, opts...)
+}
+
+// sniffCarrier picks the carrier for the input read from r, returning a
+// reader over the whole input
+func sniffCarrier(r io.Reader) (Carrier, io.Reader, error) {
+	br := bufio.NewReaderSize(r, carrierSniffLen)
+	head, err := br.Peek(carrierSniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	carriersMu.RLock()
+	defer carriersMu.RUnlock()
+	for i := len(carriers) - 1; i >= 0; i-- {
+		if carriers[i].Sniff(head) {
+			return carriers[i], br, nil
+		}
+	}
+	return nil, nil, ErrUnknownCarrier
+}
+
+// MarshalPayload frames payload in an envelope with the given options, for
+// carriers that store bytes rather than image LSBs
+func MarshalPayload(payload []byte, opts ...Option) ([]byte, error) {
+	framed, err := marshalEnvelope(bytes.NewReader(payload), flagChecksum, newOptions(opts), log)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return framed, nil
+}
+
+// UnmarshalPayload verifies and opens an envelope written by
+// MarshalPayload. Bytes after the envelope are ignored.
+func UnmarshalPayload(framed []byte, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	e, err := parseEnvelope(framed, o)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	payload, _, err := e.open(o)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
+
+// carrierFuncs adapts a set of functions to the Carrier interface
+type carrierFuncs struct {
+	name    string
+	sniff   func(head []byte) bool
+	embed   func(r io.Reader, w io.Writer, payload []byte, opts ...Option) error
+	extract func(r io.Reader, opts ...Option) ([]byte, error)
+}
+
+func (c carrierFuncs) Name() string           { return c.name }
+func (c carrierFuncs) Sniff(head []byte) bool { return c.sniff(head) }
+
+func (c carrierFuncs) Embed(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	return c.embed(r, w, payload, opts...)
+}
+
+func (c carrierFuncs) Extract(r io.Reader, opts ...Option) ([]byte, error) {
+	return c.extract(r, opts...)
+}
+
+// The built in carriers, lowest priority first
+func init() {
+	RegisterCarrier(carrierFuncs{"text", sniffText, embedText, extractText})
+	RegisterCarrier(carrierFuncs{"image", sniffLSBImage, embedImage, extractImage})
+	RegisterCarrier(carrierFuncs{"apng", sniffAPNG, EmbedAPNG, ExtractAPNG})
+	RegisterCarrier(carrierFuncs{"gif", func(head []byte) bool { return sniffFormat(head) == "gif" }, EmbedGIF, ExtractGIF})
+	RegisterCarrier(carrierFuncs{"jpeg", func(head []byte) bool { return sniffFormat(head) == "jpeg" }, EmbedJPEG, ExtractJPEG})
+	RegisterCarrier(carrierFuncs{"pdf", func(head []byte) bool { return bytes.HasPrefix(head, []byte("%PDF-")) }, EmbedPDF, ExtractPDF})
+	RegisterCarrier(carrierFuncs{"xml", sniffXML, EmbedXML, ExtractXML})
+	RegisterCarrier(carrierFuncs{"tiff", func(head []byte) bool { return sniffFormat(head) == "tiff" }, EmbedTIFF, ExtractTIFF})
+	RegisterCarrier(carrierFuncs{"y4m", func(head []byte) bool { return bytes.HasPrefix(head, []byte(y4mSignature)) }, EmbedY4M, ExtractY4M})
+}
+
+// sniffLSBImage matches the still image formats embedded in pixel LSBs
+func sniffLSBImage(head []byte) bool {
+	switch sniffFormat(head) {
+	case "png", "bmp", "webp":
+		return true
+	}
+	return false
+}
+
+func embedImage(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	var s StegImage
+	if err := s.loadImage(r); err != nil {
+		return err
+	}
+	if err := s.EmbedBytes(payload, opts...); err != nil {
+		return err
+	}
+	if err := s.encodeNewImage(w, png.DefaultCompression); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func extractImage(r io.Reader, opts ...Option) ([]byte, error) {
+	var s StegImage
+	if err := s.loadImage(r); err != nil {
+		return nil, err
+	}
+	return s.ExtractBytes(opts...)
+}
+
+// sniffAPNG matches PNGs with an animation control chunk before the image
+// data
+func sniffAPNG(head []byte) bool {
+	if sniffFormat(head) != "png" {
+		return false
+	}
+	actl, idat := bytes.Index(head, []byte("acTL")), bytes.Index(head, []byte("IDAT"))
+	return actl >= 0 && (idat < 0 || actl < idat)
+}
+
+// sniffXML matches documents opening with an XML declaration or an SVG
+// root, after any byte order mark and whitespace
+func sniffXML(head []byte) bool {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return bytes.HasPrefix(head, []byte("<?xml")) || bytes.HasPrefix(head, []byte("<svg"))
+}
+
+// sniffText matches anything that looks like UTF-8 text, so it must stay
+// the lowest priority carrier
+func sniffText(head []byte) bool {
+	// The head may end part way through a rune
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size <= 1 && len(head) >= utf8.UTFMax {
+			return false
+		}
+		if r != utf8.RuneError && unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+		head = head[size:]
+	}
+	return true
+}
+
+func embedText(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	cover, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	framed, err := MarshalPayload(payload, opts...)
+	if err != nil {
+		return err
+	}
+	out, err := text.Embed(string(cover), framed)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if _, err = io.WriteString(w, out); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func extractText(r io.Reader, opts ...Option) ([]byte, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	framed, err := text.Extract(string(doc))
+	if err == text.ErrNoPayload {
+		err = ErrNoPayload
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return UnmarshalPayload(framed, opts...)
+}
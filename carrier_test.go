@@ -0,0 +1,92 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// toyCarrier appends the framed payload to files starting "TOY1", standing
+// in for a third party carrier
+type toyCarrier struct{}
+
+func (toyCarrier) Name() string           { return "toy" }
+func (toyCarrier) Sniff(head []byte) bool { return bytes.HasPrefix(head, []byte("TOY1")) }
+
+func (toyCarrier) Embed(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	framed, err := MarshalPayload(payload, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		return err
+	}
+	_, err = w.Write(framed)
+	return err
+}
+
+func (toyCarrier) Extract(r io.Reader, opts ...Option) ([]byte, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.Index(doc, []byte(envelopeMagic))
+	if i < 0 {
+		return nil, ErrNoPayload
+	}
+	return UnmarshalPayload(doc[i:], opts...)
+}
+
+// TestCarrierRegistry verifies inputs are routed to the right carrier by
+// sniffing and round trip through it, including a registered one
+func TestCarrierRegistry(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+	RegisterCarrier(toyCarrier{})
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	cleanPNG, _ := base64.StdEncoding.DecodeString(CleanB64Image)
+	covers := map[string][]byte{
+		"image": cleanPNG,
+		"apng":  testAPNG(t, []image.Image{cleanImg.imgLoaded, cleanImg.imgLoaded}),
+		"gif":   testGIF(t, 2),
+		"jpeg":  testJPEG(t, true),
+		"pdf":   testPDF(),
+		"xml":   []byte(testSVG),
+		"y4m":   testY4M(32, 32, 2),
+		"text":  []byte("The quick brown fox jumps over the lazy dog"),
+		"toy":   []byte("TOY1 some toy file"),
+	}
+	for name, cover := range covers {
+		c, _, err := sniffCarrier(bytes.NewReader(cover))
+		if err != nil || c.Name() != name {
+			t.Errorf("Cover for %s sniffed as %v, %v", name, c, err)
+			continue
+		}
+		out := new(bytes.Buffer)
+		if err = EmbedCarrier(bytes.NewReader(cover), out, []byte(secretStringIn), WithPassphrase("pass")); err != nil {
+			t.Errorf("Embed with %s failed: %v", name, err)
+			continue
+		}
+		payloadOut, err := ExtractCarrier(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+		if err != nil || string(payloadOut) != secretStringIn {
+			t.Errorf("Extract with %s failed: %v", name, err)
+		}
+	}
+
+	if _, err := LookupCarrier("pdf"); err != nil {
+		t.Error(err)
+	}
+	_, err := ExtractCarrier(bytes.NewReader([]byte{0, 1, 2, 3}))
+	if err != ErrUnknownCarrier {
+		t.Error("Correct Error not thrown, expected: '" + ErrUnknownCarrier.Error() + "' got: '" + errString(err) + "'")
+	}
+}
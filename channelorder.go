@@ -0,0 +1,114 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"strings"
+)
+
+// ChannelOrder names the colour channels carrying payload bits, in the
+// order they're used within each pixel, as a string of the letters R, G
+// and B. Any subset in any order may be given, such as "BR".
+type ChannelOrder string
+
+const (
+	// ChannelsRGB uses every channel, red first. It's the default.
+	ChannelsRGB ChannelOrder = "RGB"
+	// ChannelsBGR uses every channel, blue first, as some other tools do
+	ChannelsBGR ChannelOrder = "BGR"
+	// ChannelsR uses the red channel only
+	ChannelsR ChannelOrder = "R"
+	// ChannelsB uses the blue channel only, the least perceptible
+	ChannelsB ChannelOrder = "B"
+	// ChannelsGB uses the green then the blue channel
+	ChannelsGB ChannelOrder = "GB"
+)
+
+var (
+	errChannelOrder            = errors.New("channel order must name each of R, G and B at most once")
+	errChannelOrderUnsupported = errors.New("channel orders need plain LSB embedding of an image without palette transparency")
+)
+
+// WithChannelOrder embeds in the given colour channels only, in the given
+// order. Fewer channels lower the capacity along with the visual impact.
+// The order isn't recorded in the image, so the same order must be given
+// on extraction. Grayscale and paletted images have a single channel and
+// ignore it, and adaptive depth doesn't support it.
+func WithChannelOrder(order ChannelOrder) Option {
+	return func(o *options) {
+		o.channelOrder = order
+	}
+}
+
+// indices returns the channel numbers named by the order
+func (c ChannelOrder) indices() ([]int, error) {
+	if len(c) == 0 || len(c) > 3 {
+		return nil, errChannelOrder
+	}
+	var seen [3]bool
+	channels := make([]int, len(c))
+	for i, r := range c {
+		n := strings.IndexRune("RGB", r)
+		if n < 0 || seen[n] {
+			return nil, errChannelOrder
+		}
+		seen[n] = true
+		channels[i] = n
+	}
+	return channels, nil
+}
+
+// channelPlacement walks a subset of the channels of each pixel of a
+// placement, in a chosen order
+type channelPlacement struct {
+	placement
+	channels []int // Channels used, in order
+	stride   int   // Channels per pixel of the placement
+}
+
+// selectChannels applies the options' channel order to place, which walks
+// every channel of img
+func (o *options) selectChannels(img image.Image, place placement) (placement, error) {
+	if o.channelOrder == "" || imageChannels(img) != 3 {
+		return place, nil
+	}
+	channels, err := o.channelOrder.indices()
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 3 && channels[0] == 0 && channels[1] == 1 {
+		return place, nil
+	}
+	switch place.(type) {
+	case sequentialPlacement, orderedPlacement:
+	default:
+		return nil, errChannelOrderUnsupported
+	}
+	return channelPlacement{placement: place, channels: channels, stride: 3}, nil
+}
+
+// pixelChannels returns the number of channels carrying payload bits in
+// each pixel of img under the options' channel order
+func (o *options) pixelChannels(img image.Image) int {
+	channels := imageChannels(img)
+	if o.channelOrder != "" && channels == 3 && len(o.channelOrder) <= 3 {
+		return len(o.channelOrder)
+	}
+	return channels
+}
+
+func (p channelPlacement) source(bitIndex int) int {
+	return bitIndex/len(p.channels)*p.stride + p.channels[bitIndex%len(p.channels)]
+}
+
+func (p channelPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	return p.placement.locate(p.source(bitIndex))
+}
+
+func (p channelPlacement) capacity() int {
+	return p.placement.capacity() / p.stride * len(p.channels)
+}
+
+func (p channelPlacement) maskBit(bitIndex int) byte {
+	return maskBit(p.placement, p.source(bitIndex))
+}
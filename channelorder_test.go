@@ -0,0 +1,88 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestChannelOrder verifies payloads round trip in each channel order,
+// touch only the chosen channels and need the same order to extract
+func TestChannelOrder(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := bytes.Repeat([]byte(secretStringIn), 200)
+	for _, order := range []ChannelOrder{ChannelsRGB, ChannelsBGR, ChannelsR, ChannelsB, ChannelsGB, "BR"} {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedBytes(payloadIn, WithChannelOrder(order)); err != nil {
+			t.Fatal(order, err)
+		}
+		stego := reloadImage(t, &stegImg)
+		payloadOut, err := stego.ExtractBytes(WithChannelOrder(order))
+		if err != nil {
+			t.Fatal(order, err)
+		}
+		if !bytes.Equal(payloadOut, payloadIn) {
+			t.Errorf("%s: payloads do not match", order)
+		}
+		if order != ChannelsRGB && order != "BR" {
+			if _, err := stego.ExtractBytes(); err != ErrNoPayload {
+				t.Errorf("%s: extracted without the channel order: %v", order, err)
+			}
+		}
+
+		used := map[int]bool{}
+		for _, r := range order {
+			used[map[rune]int{'R': 0, 'G': 1, 'B': 2}[r]] = true
+		}
+		b := stegImg.imgLoaded.Bounds()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				r0, g0, b0, _ := stegImg.imgLoaded.At(x, y).RGBA()
+				r1, g1, b1, _ := stego.imgLoaded.At(x, y).RGBA()
+				for ch, changed := range []bool{r0 != r1, g0 != g1, b0 != b1} {
+					if changed && !used[ch] {
+						t.Fatalf("%s: channel %d of %d,%d was changed", order, ch, x, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestChannelOrderCapacity checks capacity follows the channels used and
+// that bad or unsupported orders are refused
+func TestChannelOrderCapacity(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := stegImg.CapacitySpec(WithChannelOrder(ChannelsB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Capacity() != 150*219/8 {
+		t.Errorf("Capacity %d, expected %d", spec.Capacity(), 150*219/8)
+	}
+	if err := stegImg.EmbedBytes(make([]byte, spec.Capacity()), WithChannelOrder(ChannelsB)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	for _, order := range []ChannelOrder{"RR", "RGBA", "X"} {
+		if err := stegImg.EmbedBytes([]byte(secretStringIn), WithChannelOrder(order)); err != errChannelOrder {
+			t.Error("Correct Error not thrown, expected: '" + errChannelOrder.Error() + "' got: '" + errString(err) + "'")
+		}
+	}
+	err = stegImg.EmbedBytes([]byte(secretStringIn), WithChannelOrder(ChannelsB), WithAdaptiveDepth())
+	if err != errChannelOrderUnsupported {
+		t.Error("Correct Error not thrown, expected: '" + errChannelOrderUnsupported.Error() + "' got: '" + errString(err) + "'")
+	}
+}
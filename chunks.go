@@ -0,0 +1,208 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// maxCopiedChunkLen caps the size of a single chunk kept for copying, so
+// a hostile file can't make the loader buffer it whole
+const maxCopiedChunkLen = 1 << 24
+
+// chunkPosition is where a chunk sits relative to the critical chunks,
+// which the PNG spec constrains for many ancillary types
+type chunkPosition int
+
+const (
+	chunkBeforePLTE chunkPosition = iota
+	chunkBeforeIDAT
+	chunkAfterIDAT
+)
+
+// sourceChunk is an ancillary chunk of the loaded PNG
+type sourceChunk struct {
+	PNGChunk
+	pos chunkPosition
+}
+
+// chunkPolicy says which of the loaded PNG's chunks the new image gets
+type chunkPolicy int
+
+const (
+	chunksDefault chunkPolicy = iota // Those in defaultChunks
+	chunksAll
+	chunksNone
+)
+
+// defaultChunks are the types copied unless SetPreserveChunks says
+// otherwise: those describing colour and physical size, which change how
+// the image renders when dropped, and text, whose loss is as much a sign
+// of re-encoding
+var defaultChunks = map[string]bool{
+	"gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "pHYs": true, "tEXt": true,
+}
+
+// SetPreserveChunks chooses which ancillary chunks of a loaded PNG the new
+// image keeps. By default the colour profile and DPI chunks (gAMA, cHRM,
+// sRGB, iCCP and pHYs) and tEXt are copied. With preserve set every
+// ancillary chunk, such as tIME and eXIf too, is copied at the same
+// position, so the output's chunk profile matches the input's; with it
+// clear none are. Chunks tied to the pixel encoding or animation (tRNS,
+// acTL, fcTL, fdAT) are regenerated or dropped rather than copied. Chunks
+// a PNGProfile would add are skipped where the source already has one of
+// the same type.
+func (s *StegImage) SetPreserveChunks(preserve bool) {
+	s.chunkPolicy = chunksNone
+	if preserve {
+		s.chunkPolicy = chunksAll
+	}
+}
+
+// copiedChunks returns the loaded PNG's chunks the new image gets
+func (s *StegImage) copiedChunks() []sourceChunk {
+	switch s.chunkPolicy {
+	case chunksAll:
+		return s.srcChunks
+	case chunksNone:
+		return nil
+	}
+	var chunks []sourceChunk
+	for _, c := range s.srcChunks {
+		if defaultChunks[c.Type] {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+// copyableChunk reports whether a chunk of type t can be copied into a
+// re-encoded image
+func copyableChunk(t string) bool {
+	// Critical chunks have an upper case first letter
+	if t[0]&0x20 == 0 {
+		return false
+	}
+	switch t {
+	case "tRNS", "acTL", "fcTL", "fdAT":
+		return false
+	}
+	return true
+}
+
+// pngChunkCollector is an io.Writer that picks the copyable chunks out of
+// a PNG stream as it's decoded, without buffering the image data. It never
+// fails a write; anything that isn't a PNG is just ignored.
+type pngChunkCollector struct {
+	head    []byte // Signature or chunk header bytes read so far
+	started bool   // Signature checked
+	stopped bool   // IEND reached or stream not a PNG
+	skip    int    // Bytes of the current chunk left to discard
+	need    int    // Bytes of the current kept chunk left to read
+	current sourceChunk
+	pos     chunkPosition
+	chunks  []sourceChunk
+}
+
+func (c *pngChunkCollector) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && !c.stopped {
+		switch {
+		case !c.started:
+			p = c.fill(p, len(pngSignature))
+			if len(c.head) == len(pngSignature) {
+				c.started, c.stopped = true, string(c.head) != pngSignature
+				c.head = c.head[:0]
+			}
+		case c.skip > 0:
+			k := minInt(c.skip, len(p))
+			c.skip, p = c.skip-k, p[k:]
+		case c.need > 0:
+			k := minInt(c.need, len(p))
+			c.current.Data = append(c.current.Data, p[:k]...)
+			c.need, p = c.need-k, p[k:]
+			if c.need == 0 {
+				// Drop the CRC, it's recomputed on output
+				c.current.Data = c.current.Data[:len(c.current.Data)-4]
+				c.chunks = append(c.chunks, c.current)
+			}
+		default:
+			p = c.fill(p, 8)
+			if len(c.head) == 8 {
+				c.startChunk()
+			}
+		}
+	}
+	return n, nil
+}
+
+// fill moves bytes from p into head until it holds n, returning the rest
+func (c *pngChunkCollector) fill(p []byte, n int) []byte {
+	k := minInt(n-len(c.head), len(p))
+	c.head = append(c.head, p[:k]...)
+	return p[k:]
+}
+
+// startChunk decides what to do with the chunk whose header is in head
+func (c *pngChunkCollector) startChunk() {
+	length := binary.BigEndian.Uint32(c.head[:4])
+	t := string(c.head[4:8])
+	c.head = c.head[:0]
+	switch t {
+	case "IEND":
+		c.stopped = true
+		return
+	case "PLTE":
+		c.pos = chunkBeforeIDAT
+	case "IDAT":
+		c.pos = chunkAfterIDAT
+	}
+	if copyableChunk(t) && length <= maxCopiedChunkLen {
+		c.current = sourceChunk{PNGChunk: PNGChunk{Type: t}, pos: c.pos}
+		c.need = int(length) + 4
+	} else {
+		c.skip = int(length) + 4
+	}
+}
+
+// insertSourceChunks writes the chunks into an encoded PNG at the positions
+// they held in the source
+func insertSourceChunks(encoded []byte, chunks []sourceChunk) ([]byte, error) {
+	encodedChunks, err := readPNGChunks(encoded)
+	if err != nil {
+		return nil, err
+	}
+	byPos := make(map[chunkPosition][]PNGChunk)
+	for _, c := range chunks {
+		byPos[c.pos] = append(byPos[c.pos], c.PNGChunk)
+	}
+
+	out := new(bytes.Buffer)
+	out.WriteString(pngSignature)
+	flush := func(pos chunkPosition) {
+		for _, c := range byPos[pos] {
+			writePNGChunk(out, c)
+		}
+		delete(byPos, pos)
+	}
+	for _, c := range encodedChunks {
+		switch c.Type {
+		case "PLTE":
+			flush(chunkBeforePLTE)
+		case "IDAT":
+			// Without a PLTE both sets go straight before the image data
+			flush(chunkBeforePLTE)
+			flush(chunkBeforeIDAT)
+		case "IEND":
+			flush(chunkAfterIDAT)
+		}
+		writePNGChunk(out, c)
+	}
+	return out.Bytes(), nil
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
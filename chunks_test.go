@@ -0,0 +1,172 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	logging "github.com/op/go-logging"
+)
+
+// withChunks re-encodes a PNG with extra chunks placed after IHDR, after
+// PLTE and after the last IDAT
+func withChunks(t *testing.T, img image.Image, afterIHDR, afterPLTE, afterIDAT []PNGChunk) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readPNGChunks(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	out.WriteString(pngSignature)
+	for _, c := range chunks {
+		if c.Type == "IEND" {
+			for _, extra := range afterIDAT {
+				writePNGChunk(out, extra)
+			}
+		}
+		writePNGChunk(out, c)
+		switch c.Type {
+		case "IHDR":
+			for _, extra := range afterIHDR {
+				writePNGChunk(out, extra)
+			}
+		case "PLTE":
+			for _, extra := range afterPLTE {
+				writePNGChunk(out, extra)
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+// chunkTypes lists the chunk types of an encoded PNG, counting a run of
+// IDAT chunks once
+func chunkTypes(t *testing.T, encoded []byte) (types []string) {
+	t.Helper()
+	chunks, err := readPNGChunks(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if c.Type == "IDAT" && types[len(types)-1] == "IDAT" {
+			continue
+		}
+		types = append(types, c.Type)
+	}
+	return types
+}
+
+// TestPreserveChunks verifies the colour, DPI and text chunks are copied by
+// default, and every ancillary chunk to the same position, with its data,
+// when asked
+func TestPreserveChunks(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.Black, color.White})
+	text := PNGChunk{Type: "tEXt", Data: []byte("Comment\x00hello")}
+	tIME := PNGChunk{Type: "tIME", Data: []byte{0x07, 0xea, 10, 16, 12, 0, 0}}
+	bKGD := PNGChunk{Type: "bKGD", Data: []byte{1}}
+
+	for _, tc := range []struct {
+		src  []byte
+		want []string // With ProfileEditor, whose gAMA the first source has
+	}{
+		{
+			withChunks(t, cleanImg.imgLoaded, []PNGChunk{gamaChunk(), text}, nil, []PNGChunk{tIME}),
+			[]string{"IHDR", "sRGB", "pHYs", "gAMA", "tEXt", "IDAT", "tIME", "IEND"},
+		},
+		{
+			withChunks(t, paletted, []PNGChunk{text}, []PNGChunk{bKGD}, []PNGChunk{tIME}),
+			[]string{"IHDR", "gAMA", "sRGB", "pHYs", "tEXt", "PLTE", "bKGD", "IDAT", "tIME", "IEND"},
+		},
+	} {
+		var img StegImage
+		if err := img.loadImage(iotest.OneByteReader(bytes.NewReader(tc.src))); err != nil {
+			t.Fatal(err)
+		}
+		if err := img.EmbedBytes([]byte(secretStringIn)); err != nil {
+			t.Fatal(err)
+		}
+		for _, typ := range chunkTypes(t, pngBytes(t, &img)) {
+			if typ == "tIME" || typ == "bKGD" {
+				t.Errorf("Chunk %s copied without SetPreserveChunks", typ)
+			}
+		}
+
+		img.SetPreserveChunks(false)
+		if types := chunkTypes(t, pngBytes(t, &img)); !reflect.DeepEqual(types, []string{"IHDR", "IDAT", "IEND"}) &&
+			!reflect.DeepEqual(types, []string{"IHDR", "PLTE", "IDAT", "IEND"}) {
+			t.Errorf("Chunks %v copied with SetPreserveChunks(false)", types)
+		}
+
+		img.SetPreserveChunks(true)
+		img.SetPNGProfile(ProfileEditor)
+		out := pngBytes(t, &img)
+		if types := chunkTypes(t, out); !reflect.DeepEqual(types, tc.want) {
+			t.Errorf("Expected chunks %v, got %v", tc.want, types)
+		}
+		chunks, _ := readPNGChunks(out)
+		for _, c := range chunks {
+			if c.Type == "tEXt" && !bytes.Equal(c.Data, text.Data) {
+				t.Errorf("tEXt data changed: %q", c.Data)
+			}
+		}
+
+		var reloaded StegImage
+		if err := reloaded.loadImage(bytes.NewReader(out)); err != nil {
+			t.Fatal(err)
+		}
+		if payload, err := reloaded.ExtractBytes(); err != nil || string(payload) != secretStringIn {
+			t.Errorf("Extract from preserved output failed: %v", err)
+		}
+	}
+}
+
+// TestDefaultChunks verifies the colour profile, DPI and text chunks of the
+// source survive without SetPreserveChunks
+func TestDefaultChunks(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	iCCP := PNGChunk{Type: "iCCP", Data: []byte("icc\x00\x00profile")}
+	pHYs := PNGChunk{Type: "pHYs", Data: []byte{0, 0, 0x0b, 0x13, 0, 0, 0x0b, 0x13, 1}}
+	text := PNGChunk{Type: "tEXt", Data: []byte("Comment\x00hello")}
+	tIME := PNGChunk{Type: "tIME", Data: []byte{0x07, 0xea, 10, 16, 12, 0, 0}}
+	src := withChunks(t, cleanImg.imgLoaded, []PNGChunk{gamaChunk(), iCCP, pHYs, text, tIME}, nil, nil)
+
+	var img StegImage
+	if err := img.LoadImage(bytes.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	out := pngBytes(t, &img)
+	want := []string{"IHDR", "gAMA", "iCCP", "pHYs", "tEXt", "IDAT", "IEND"}
+	if types := chunkTypes(t, out); !reflect.DeepEqual(types, want) {
+		t.Errorf("Expected chunks %v, got %v", want, types)
+	}
+	chunks, _ := readPNGChunks(out)
+	for i, c := range []PNGChunk{gamaChunk(), iCCP, pHYs, text} {
+		if !bytes.Equal(chunks[i+1].Data, c.Data) {
+			t.Errorf("%s data changed: %q", c.Type, chunks[i+1].Data)
+		}
+	}
+}
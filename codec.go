@@ -0,0 +1,142 @@
+package libsteg
+
+import (
+	"bytes"
+	"io"
+)
+
+// EnvelopeCodec frames payload bytes in a custom format, replacing the
+// built-in envelope. Compression and encryption are applied before Frame
+// and undone after Deframe as usual, and the framed bytes are written with
+// the same placements, so a codec only decides how its frame is laid out.
+type EnvelopeCodec interface {
+	// Frame returns the bytes to embed for the stored payload
+	Frame(payload []byte) ([]byte, error)
+	// Deframe reads a frame from the start of r, the embedded bit stream,
+	// and returns the stored payload. r ends where the image does, so the
+	// frame must carry its own length or terminator.
+	Deframe(r io.Reader) ([]byte, error)
+}
+
+// WithEnvelopeCodec frames the payload with c instead of the built-in
+// envelope. The same codec and the same compression, passphrase or
+// identity options must be given on extraction. Features carried by the
+// built-in envelope's header and trailer, such as metadata, checksums,
+// HMACs, signatures and named slots, are not available.
+func WithEnvelopeCodec(c EnvelopeCodec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// writeCodec compresses and encrypts the payload read from r, frames it
+// with the options' codec and writes it at the given placement
+func (s *StegImage) writeCodec(r io.Reader, o *options, place placement) (err error) {
+	stored, err := sealPayload(r, o, s.logger())
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	framed, err := o.codec.Frame(stored)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if _, err = (&lsbWriter{img: s.newImg, place: place}).Write(framed); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	o.checkCapacity(len(framed), place, s.logger())
+	return nil
+}
+
+// readCodec deframes the payload with the options' codec, then decrypts
+// and decompresses it
+func (s *StegImage) readCodec(o *options) ([]byte, error) {
+	var place placement
+	var err error
+	if o.scanOrder == ScanColumnMajor {
+		place, err = s.extractPlacement(o)
+	} else {
+		place, err = s.orderedCodecPlacement(o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := o.codec.Deframe(&lsbReader{img: s.imgLoaded, place: place})
+	if err != nil {
+		return nil, err
+	}
+	return openPayload(data, o)
+}
+
+// sealPayload compresses and encrypts the payload read from r as the
+// options say, for formats that store it outside the built-in envelope
+func sealPayload(r io.Reader, o *options, logger stegLogger) (stored []byte, err error) {
+	if len(o.recipients) > 0 && o.passphrase != "" {
+		return nil, errRecipientsAndPass
+	}
+	plain := new(bytes.Buffer)
+	codec := o.compression
+	if codec == CompressionAuto {
+		// Without a header to hold the choice, it leads the sealed data
+		if codec, r, err = chooseCompression(r, logger); err != nil {
+			return nil, err
+		}
+		plain.WriteByte(byte(codec))
+	}
+	compressed, err := newCompressWriter(plain, codec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(compressed, r); err == nil {
+		err = compressed.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.deterministic {
+		// Keyed by the plaintext as sealed, as envelopes are
+		o.seedDeterministic(plain.Bytes())
+	}
+
+	buf := new(bytes.Buffer)
+	var encrypted io.WriteCloser = nopWriteCloser{buf}
+	switch {
+	case len(o.recipients) > 0:
+		encrypted, err = newRecipientWriter(buf, o.recipients, o.rand)
+	case o.passphrase != "":
+		encrypted, err = newEncryptWriter(buf, o.passphrase, o.rand)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = encrypted.Write(plain.Bytes()); err == nil {
+		err = encrypted.Close()
+	}
+	return buf.Bytes(), err
+}
+
+// openPayload reverses sealPayload
+func openPayload(data []byte, o *options) (payload []byte, err error) {
+	switch {
+	case o.identity != nil:
+		data, err = decryptForIdentity(data, o.identity)
+	case o.passphrase != "":
+		data, err = decrypt(data, o.passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	codec := o.compression
+	if codec == CompressionAuto {
+		if len(data) == 0 {
+			return nil, errUnknownCodec
+		}
+		codec, data = Compression(data[0]), data[1:]
+		if codec == CompressionAuto {
+			return nil, errUnknownCodec
+		}
+	}
+	return decompress(data, codec, o.maxPayload)
+}
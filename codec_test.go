@@ -0,0 +1,63 @@
+package libsteg
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testCodec frames payloads as "ACME" followed by a 16-bit length
+type testCodec struct{}
+
+func (testCodec) Frame(payload []byte) ([]byte, error) {
+	if len(payload) > 0xffff {
+		return nil, errors.New("payload too large for frame")
+	}
+	frame := append([]byte("ACME"), 0, 0)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(payload)))
+	return append(frame, payload...), nil
+}
+
+func (testCodec) Deframe(r io.Reader) ([]byte, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "ACME" {
+		return nil, ErrNoPayload
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[4:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, ErrNoPayload
+	}
+	return payload, nil
+}
+
+// TestB2BCodec verifies a custom envelope codec round trips with
+// compression and encryption, and isn't mistaken for the built-in format
+func TestB2BCodec(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	opts := []Option{WithEnvelopeCodec(testCodec{}), WithPassphrase("pass"), WithCompression(CompressionGzip)}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), opts...); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+
+	payloadOut, err := stegImg.ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	if _, err = stegImg.ExtractBytes(WithPassphrase("pass")); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,174 @@
+package libsteg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects the codec used to compress a payload before it is
+// embedded. The codec is recorded in the envelope so extraction is
+// transparent.
+type Compression uint8
+
+// Supported compression codecs
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	// CompressionAuto trial compresses the start of the payload with each
+	// codec and uses whichever stores it smallest, including none
+	CompressionAuto
+)
+
+// autoCompressSample is how much of the payload CompressionAuto trial
+// compresses; smaller payloads are compared whole
+const autoCompressSample = 64 << 10
+
+// defaultMaxPayload is the largest payload extraction decompresses unless
+// WithMaxPayload says otherwise
+const defaultMaxPayload = 256 << 20
+
+var errUnknownCodec = errors.New("unknown compression codec")
+
+// ErrPayloadTooLarge is returned when a payload decompresses to more than
+// the limit set by WithMaxPayload, as a compression bomb would
+var ErrPayloadTooLarge = errors.New("payload larger than the extraction limit")
+
+// WithCompression compresses the payload with the given codec before
+// embedding, for text payloads this multiplies the effective capacity.
+// With CompressionAuto the codec is picked per payload.
+func WithCompression(codec Compression) Option {
+	return func(o *options) {
+		o.compression = codec
+	}
+}
+
+// newCompressWriter returns a writer compressing into w with the codec
+func newCompressWriter(w io.Writer, codec Compression) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, errUnknownCodec
+}
+
+// WithMaxPayload bounds the size a payload may decompress to on
+// extraction, failing with ErrPayloadTooLarge past n bytes, so a small
+// image holding a compression bomb can't exhaust memory. The default is
+// 256 MiB; n <= 0 removes the limit.
+func WithMaxPayload(n int64) Option {
+	return func(o *options) {
+		o.maxPayload = n
+	}
+}
+
+func decompress(data []byte, codec Compression, max int64) ([]byte, error) {
+	zr, err := newDecompressReader(bytes.NewReader(data), codec, max)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// newDecompressReader returns a reader decompressing r with the codec,
+// which must be closed to release the decoder. Reading more than max bytes
+// fails with ErrPayloadTooLarge, unless max <= 0.
+func newDecompressReader(r io.Reader, codec Compression, max int64) (zr io.ReadCloser, err error) {
+	switch codec {
+	case CompressionNone:
+		zr = ioutil.NopCloser(r)
+	case CompressionGzip:
+		zr, err = gzip.NewReader(r)
+	case CompressionZstd:
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(r); err == nil {
+			zr = zstdReadCloser{d}
+		}
+	default:
+		err = errUnknownCodec
+	}
+	if err != nil {
+		return nil, err
+	}
+	return limitPayload(zr, max), nil
+}
+
+// limitPayload returns r failing with ErrPayloadTooLarge past max bytes,
+// or r itself if max <= 0
+func limitPayload(r io.ReadCloser, max int64) io.ReadCloser {
+	if max <= 0 {
+		return r
+	}
+	return &maxPayloadReader{ReadCloser: r, n: max}
+}
+
+// maxPayloadReader fails with ErrPayloadTooLarge once more than n bytes
+// could be read
+type maxPayloadReader struct {
+	io.ReadCloser
+	n int64 // Bytes left before the limit
+}
+
+func (m *maxPayloadReader) Read(p []byte) (int, error) {
+	// Read one past the limit to tell a payload ending on it from a longer one
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.ReadCloser.Read(p)
+	if int64(n) > m.n {
+		n, m.n = int(m.n), 0
+		return n, ErrPayloadTooLarge
+	}
+	m.n -= int64(n)
+	return n, err
+}
+
+// zstdReadCloser adapts the zstd decoder's Close to io.Closer
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// chooseCompression picks the codec storing the start of the payload read
+// from r smallest, returning it with a reader replacing r. Ties go to the
+// simpler codec.
+func chooseCompression(r io.Reader, logger stegLogger) (Compression, io.Reader, error) {
+	sample := make([]byte, autoCompressSample)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, nil, err
+	}
+	sample = sample[:n]
+
+	best, bestLen := CompressionNone, len(sample)
+	for _, codec := range []Compression{CompressionGzip, CompressionZstd} {
+		buf := new(bytes.Buffer)
+		w, err := newCompressWriter(buf, codec)
+		if err != nil {
+			return 0, nil, err
+		}
+		w.Write(sample)
+		if err = w.Close(); err != nil {
+			return 0, nil, err
+		}
+		if buf.Len() < bestLen {
+			best, bestLen = codec, buf.Len()
+		}
+	}
+	logger.Info("Compression chosen:", best)
+	return best, io.MultiReader(bytes.NewReader(sample), r), nil
+}
@@ -0,0 +1,124 @@
+package libsteg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BCompression does back to back tests with each compression codec,
+// checking the payload larger than the image's raw capacity still fits
+func TestB2BCompression(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	payloadIn := strings.Repeat(secretStringIn, bitCapacity(cleanImg.imgLoaded.Bounds(), 3)/8)
+
+	for _, codec := range []Compression{CompressionGzip, CompressionZstd} {
+		if err := cleanImg.EmbedBytes([]byte(payloadIn), WithCompression(codec)); err != nil {
+			t.Fatal(err)
+		}
+
+		payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != payloadIn {
+			t.Errorf("Payloads Do Not Match for codec %d!", codec)
+		}
+	}
+}
+
+// TestCompressionAuto verifies the auto codec compresses text, leaves
+// random data alone, and extracts without being told the choice
+func TestCompressionAuto(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	random := make([]byte, 200)
+	rand.New(rand.NewSource(1)).Read(random)
+	for _, tc := range []struct {
+		payload    []byte
+		compressed bool
+	}{
+		{[]byte(strings.Repeat(secretStringIn, 100)), true},
+		{random, false},
+	} {
+		codec, r, err := chooseCompression(bytes.NewReader(tc.payload), log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (codec != CompressionNone) != tc.compressed {
+			t.Errorf("Chose codec %d, expected compression: %t", codec, tc.compressed)
+		}
+		if rest, _ := ioutil.ReadAll(r); !bytes.Equal(rest, tc.payload) {
+			t.Error("Payload lost while sampling")
+		}
+
+		var cleanImg StegImage
+		if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+			t.Fatal(err)
+		}
+		if err := cleanImg.EmbedBytes(tc.payload, WithCompression(CompressionAuto)); err != nil {
+			t.Fatal(err)
+		}
+		payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payloadOut, tc.payload) {
+			t.Error("Payloads Do Not Match!")
+		}
+
+		// Formats without a header carry the choice in the sealed data
+		stored, err := sealPayload(bytes.NewReader(tc.payload), newOptions([]Option{WithCompression(CompressionAuto)}), log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := openPayload(stored, newOptions([]Option{WithCompression(CompressionAuto)}))
+		if err != nil || !bytes.Equal(opened, tc.payload) {
+			t.Errorf("Sealed payload didn't round trip: %v", err)
+		}
+	}
+}
+
+// TestMaxPayload verifies a payload decompressing past WithMaxPayload is
+// refused rather than read into memory
+func TestMaxPayload(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	payloadIn := make([]byte, 1<<20)
+	for _, codec := range []Compression{CompressionGzip, CompressionZstd} {
+		if err := cleanImg.EmbedBytes(payloadIn, WithCompression(codec)); err != nil {
+			t.Fatal(err)
+		}
+		stego := reloadImage(t, &cleanImg)
+
+		if _, err := stego.ExtractBytes(WithMaxPayload(64 << 10)); err != ErrPayloadTooLarge {
+			t.Error("Correct Error not thrown, expected: '" + ErrPayloadTooLarge.Error() + "' got: '" + errString(err) + "'")
+		}
+		if _, err := stego.ExtractTo(ioutil.Discard, WithMaxPayload(64<<10)); err != ErrPayloadTooLarge {
+			t.Error("Correct Error not thrown, expected: '" + ErrPayloadTooLarge.Error() + "' got: '" + errString(err) + "'")
+		}
+		payloadOut, err := stego.ExtractBytes(WithMaxPayload(int64(len(payloadIn))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payloadOut, payloadIn) {
+			t.Errorf("Payloads Do Not Match for codec %d!", codec)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+)
+
+var errConformancePayload = errors.New("extracted payload differs")
+
+// ConformanceRunner is an implementation of the libsteg formats under
+// test, e.g. a fork or a port to another language behind a bridge
+type ConformanceRunner interface {
+	// Embed hides payload in cover as c describes, returning the stego
+	// image
+	Embed(cover image.Image, payload []byte, c ConformanceCase) (image.Image, error)
+	// Extract retrieves the payload embedded in stego as c describes
+	Extract(stego image.Image, c ConformanceCase) ([]byte, error)
+}
+
+// ConformanceCase is one format version and mode exercised by Conformance.
+// Every case is seeded, so a conforming implementation reproduces
+// upstream's stego image pixel for pixel.
+type ConformanceCase struct {
+	Name           string
+	Legacy         bool   // The legacy stop marker terminated string format, the rest are ignored
+	Passphrase     string // Encrypts the payload if set
+	HMAC           bool
+	Compression    Compression
+	ScanOrder      ScanOrder
+	BlockChecksums bool
+	Metadata       *Metadata
+	Seed           []byte // See WithSeed
+}
+
+// Options returns the libsteg options the case describes, for runners
+// built on this package
+func (c ConformanceCase) Options() []Option {
+	opts := []Option{WithSeed(c.Seed), WithScanOrder(c.ScanOrder), WithCompression(c.Compression)}
+	if c.Passphrase != "" {
+		opts = append(opts, WithPassphrase(c.Passphrase))
+	}
+	if c.HMAC {
+		opts = append(opts, WithHMAC())
+	}
+	if c.BlockChecksums {
+		opts = append(opts, WithBlockChecksums())
+	}
+	if c.Metadata != nil {
+		opts = append(opts, WithMetadata(*c.Metadata))
+	}
+	return opts
+}
+
+// ConformanceFailure is one check a runner failed
+type ConformanceFailure struct {
+	Case  string
+	Cover string // Cover image kind, e.g. "nrgba"
+	Check string // "embed", "extract", "upstream-extract" or "pixels"
+	Err   error
+}
+
+// ConformanceError is returned by Conformance when any check fails
+type ConformanceError struct {
+	Failures []ConformanceFailure
+	Checks   int // Number of checks made
+}
+
+func (e *ConformanceError) Error() string {
+	f := e.Failures[0]
+	return fmt.Sprintf("%d of %d conformance checks failed, first: %s on %s %s: %v",
+		len(e.Failures), e.Checks, f.Check, f.Case, f.Cover, f.Err)
+}
+
+// ConformanceCases returns the cases Conformance runs, covering each
+// format version and embedding mode
+func ConformanceCases() []ConformanceCase {
+	seed := []byte("libsteg conformance")
+	meta := &Metadata{ContentType: "text/plain", Created: time.Unix(1700000000, 0).UTC(), Tags: map[string]string{"k": "v"}}
+	return []ConformanceCase{
+		{Name: "legacy", Legacy: true, Seed: seed},
+		{Name: "plain", Seed: seed},
+		{Name: "passphrase-hmac", Passphrase: "conformance", HMAC: true, Seed: seed},
+		{Name: "gzip-metadata", Compression: CompressionGzip, Metadata: meta, Seed: seed},
+		{Name: "zstd", Compression: CompressionZstd, Seed: seed},
+		{Name: "row-major", ScanOrder: ScanRowMajor, Seed: seed},
+		{Name: "zigzag-block-checksums", ScanOrder: ScanZigzag, BlockChecksums: true, Seed: seed},
+	}
+}
+
+// conformanceCovers returns the covers each case runs against, one per
+// channel layout libsteg handles differently
+func conformanceCovers() map[string]image.Image {
+	rect := image.Rect(0, 0, 48, 40)
+	nrgba, rgba64, gray := image.NewNRGBA(rect), image.NewRGBA64(rect), image.NewGray(rect)
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			v := uint8(x*5 + y*3)
+			nrgba.SetNRGBA(x, y, color.NRGBA{v, v ^ 0x5a, uint8(x * y), 0xff})
+			rgba64.SetRGBA64(x, y, color.RGBA64{uint16(v) << 8, uint16(x * y * 97), uint16(y) << 10, 0xffff})
+			gray.SetGray(x, y, color.Gray{v})
+		}
+	}
+	return map[string]image.Image{"nrgba": nrgba, "rgba64": rgba64, "gray": gray}
+}
+
+// Conformance runs every ConformanceCase against runner on a set of
+// covers, checking that runner's stego images match upstream's pixel for
+// pixel and extract with libsteg, and that runner extracts libsteg's. It
+// returns a *ConformanceError listing any failures.
+func Conformance(runner ConformanceRunner) error {
+	payload := []byte("libsteg conformance payload: " + strings.Repeat("0123456789", 4))
+	covers := conformanceCovers()
+	result := &ConformanceError{}
+	fail := func(c ConformanceCase, cover string, check string, err error) {
+		result.Failures = append(result.Failures, ConformanceFailure{Case: c.Name, Cover: cover, Check: check, Err: err})
+	}
+	for _, c := range ConformanceCases() {
+		for _, name := range []string{"nrgba", "rgba64", "gray"} {
+			cover := covers[name]
+			result.Checks += 4
+			upstream, err := conformanceEmbed(cover, payload, c)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+
+			got, err := runner.Extract(upstream, c)
+			if err == nil && !bytes.Equal(got, payload) {
+				err = errConformancePayload
+			}
+			if err != nil {
+				fail(c, name, "extract", err)
+			}
+
+			stego, err := runner.Embed(cover, payload, c)
+			if err != nil {
+				fail(c, name, "embed", err)
+				continue
+			}
+			got, err = conformanceExtract(stego, c)
+			if err == nil && !bytes.Equal(got, payload) {
+				err = errConformancePayload
+			}
+			if err != nil {
+				fail(c, name, "upstream-extract", err)
+			}
+			ratio, err := ChangedPixelRatio(upstream, stego)
+			if err == nil && ratio != 0 {
+				err = fmt.Errorf("%.2f%% of pixels differ from upstream", ratio*100)
+			}
+			if err != nil {
+				fail(c, name, "pixels", err)
+			}
+		}
+	}
+	if len(result.Failures) > 0 {
+		log.Error(result)
+		return result
+	}
+	return nil
+}
+
+// UpstreamRunner is the ConformanceRunner for this package, as a reference
+// for runners and to check the suite itself
+type UpstreamRunner struct{}
+
+// Embed implements ConformanceRunner
+func (UpstreamRunner) Embed(cover image.Image, payload []byte, c ConformanceCase) (image.Image, error) {
+	return conformanceEmbed(cover, payload, c)
+}
+
+// Extract implements ConformanceRunner
+func (UpstreamRunner) Extract(stego image.Image, c ConformanceCase) ([]byte, error) {
+	return conformanceExtract(stego, c)
+}
+
+func conformanceEmbed(cover image.Image, payload []byte, c ConformanceCase) (image.Image, error) {
+	s := NewStegImageFromImage(cover)
+	var err error
+	if c.Legacy {
+		err = s.DoStegEmbed(string(payload))
+	} else {
+		err = s.EmbedBytes(payload, c.Options()...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Stego(), nil
+}
+
+func conformanceExtract(stego image.Image, c ConformanceCase) ([]byte, error) {
+	s := NewStegImageFromImage(stego)
+	if c.Legacy {
+		secret, err := s.DoStegExtract()
+		return []byte(secret), err
+	}
+	return s.ExtractBytes(c.Options()...)
+}
@@ -0,0 +1,51 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// flippingRunner embeds as upstream does but then flips a pixel's LSB,
+// as an implementation with an off by one might
+type flippingRunner struct{ UpstreamRunner }
+
+func (r flippingRunner) Embed(cover image.Image, payload []byte, c ConformanceCase) (image.Image, error) {
+	stego, err := r.UpstreamRunner.Embed(cover, payload, c)
+	if err != nil {
+		return nil, err
+	}
+	img := stego.(draw.Image)
+	bounds := img.Bounds()
+	setBit(img, bounds.Max.X-1, bounds.Max.Y-1, 0, 0, getBit(img, bounds.Max.X-1, bounds.Max.Y-1, 0, 0)^1)
+	return img, nil
+}
+
+// TestConformance verifies upstream conforms to itself and a runner that
+// differs by a single bit is caught
+func TestConformance(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	if err := Conformance(UpstreamRunner{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var confErr *ConformanceError
+	err := Conformance(flippingRunner{})
+	if !errors.As(err, &confErr) {
+		t.Fatalf("Expected a *ConformanceError, got: '%s'", errString(err))
+	}
+	cases := len(ConformanceCases()) * 3
+	if confErr.Checks != cases*4 || len(confErr.Failures) != cases {
+		t.Errorf("Got %d failures of %d checks, expected %d of %d", len(confErr.Failures), confErr.Checks, cases, cases*4)
+	}
+	for _, f := range confErr.Failures {
+		if f.Check != "pixels" {
+			t.Errorf("%s on %s failed %s: %v", f.Case, f.Cover, f.Check, f.Err)
+		}
+	}
+}
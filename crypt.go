@@ -0,0 +1,238 @@
+package libsteg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// cryptChunkSize is the amount of plaintext sealed in each chunk
+	cryptChunkSize = 64 * 1024
+	// cryptSaltLen is the length of the random key derivation salt
+	cryptSaltLen = 16
+	// cryptNoncePrefixLen is the random part of each chunk nonce, the
+	// remaining 4 bytes hold the chunk counter
+	cryptNoncePrefixLen = 8
+	// kdfIterations is the PBKDF2 work factor for passphrase derived keys
+	kdfIterations = 100000
+)
+
+// Chunk flags, authenticated as additional data so a stream can't be
+// truncated on a chunk boundary without detection
+const (
+	chunkMore byte = iota
+	chunkLast
+)
+
+// ErrDecryptFailed is returned when an encrypted payload can't be
+// authenticated, either due to a wrong passphrase or tampering
+var ErrDecryptFailed = errors.New("payload decryption failed")
+
+// ErrNotEncrypted is returned when a passphrase is given but the payload
+// was stored without encryption or an HMAC keyed by it, so can't have
+// come from its holder
+var ErrNotEncrypted = errors.New("payload is not encrypted")
+
+// deriveKey derives an AES-256 key from the passphrase and salt
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+}
+
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, cryptNoncePrefixLen+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[cryptNoncePrefixLen:], counter)
+	return nonce
+}
+
+// encryptWriter seals plaintext in fixed size chunks as it is written
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer that encrypts and authenticates data
+// chunk by chunk with a key derived from passphrase, so large payloads
+// never need to be held in memory for a single AEAD pass. Close must be
+// called to seal the final chunk.
+func NewEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	return newEncryptWriter(w, passphrase, rand.Reader)
+}
+
+// newEncryptWriter is NewEncryptWriter drawing its salt and nonces from rng
+func newEncryptWriter(w io.Writer, passphrase string, rng io.Reader) (io.WriteCloser, error) {
+	salt := make([]byte, cryptSaltLen)
+	if _, err := io.ReadFull(rng, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(salt); err != nil {
+		return nil, err
+	}
+	return newKeyedEncryptWriter(w, key, rng)
+}
+
+// newKeyedEncryptWriter returns a chunk encrypting writer for a raw
+// AES-256 key
+func newKeyedEncryptWriter(w io.Writer, key []byte, rng io.Reader) (io.WriteCloser, error) {
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, cryptNoncePrefixLen)
+	if _, err = io.ReadFull(rng, prefix); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, cryptChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (n int, err error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	for len(p) > 0 {
+		// Only flush a full chunk once more data arrives, so the last
+		// chunk is always sealed by Close
+		if len(e.buf) == cryptChunkSize {
+			if err = e.seal(chunkMore); err != nil {
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):cryptChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close seals the final chunk, it does not close the underlying writer
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(chunkLast)
+}
+
+func (e *encryptWriter) seal(flag byte) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.counter), e.buf, []byte{flag})
+	e.counter++
+	e.buf = e.buf[:0]
+
+	chunkHeader := make([]byte, 5)
+	chunkHeader[0] = flag
+	binary.BigEndian.PutUint32(chunkHeader[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(chunkHeader); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader opens chunks sealed by encryptWriter as they are read
+type decryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+// NewDecryptReader returns a reader that decrypts a stream produced by
+// NewEncryptWriter. Each chunk is authenticated before any of its
+// plaintext is returned.
+func NewDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	salt := make([]byte, cryptSaltLen)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, ErrDecryptFailed
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyedDecryptReader(r, key)
+}
+
+// newKeyedDecryptReader returns a chunk decrypting reader for a raw
+// AES-256 key
+func newKeyedDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	prefix := make([]byte, cryptNoncePrefixLen)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrDecryptFailed
+	}
+	aead, err := newChunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, aead: aead, prefix: prefix}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	chunkHeader := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, chunkHeader); err != nil {
+		return ErrDecryptFailed
+	}
+	flag := chunkHeader[0]
+	sealedLen := binary.BigEndian.Uint32(chunkHeader[1:])
+	if flag > chunkLast || sealedLen > uint32(cryptChunkSize+d.aead.Overhead()) {
+		return ErrDecryptFailed
+	}
+
+	sealed := make([]byte, sealedLen)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return ErrDecryptFailed
+	}
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.prefix, d.counter), sealed, []byte{flag})
+	if err != nil {
+		return ErrDecryptFailed
+	}
+	d.counter++
+	d.buf = plain
+	d.done = flag == chunkLast
+	return nil
+}
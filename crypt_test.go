@@ -0,0 +1,89 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestEncryptStreamRoundTrip verifies a multi chunk stream decrypts back
+// to the original plaintext
+func TestEncryptStreamRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	plainIn := make([]byte, cryptChunkSize*2+123)
+	rand.Read(plainIn)
+
+	sealed := new(bytes.Buffer)
+	ew, err := NewEncryptWriter(sealed, secretStringIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in odd sized pieces to cross chunk boundaries
+	for p := plainIn; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err = ew.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err = ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dr, err := NewDecryptReader(bytes.NewReader(sealed.Bytes()), secretStringIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainOut, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plainIn, plainOut) {
+		t.Error("Plaintexts Do Not Match!")
+	}
+
+	// Dropping the final chunk must be detected
+	truncated := sealed.Bytes()[:sealed.Len()-200]
+	dr, _ = NewDecryptReader(bytes.NewReader(truncated), secretStringIn)
+	if _, err = ioutil.ReadAll(dr); err != ErrDecryptFailed {
+		t.Error("Correct Error not thrown for truncated stream, got: '" + errString(err) + "'")
+	}
+}
+
+// TestB2BEncryptedBytes does a back to back passphrase encrypted test and
+// checks a wrong passphrase is rejected
+func TestB2BEncryptedBytes(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	tamperedImg := reloadImage(t, &cleanImg)
+
+	payloadOut, err := tamperedImg.ExtractBytes(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	if _, err = tamperedImg.ExtractBytes(WithPassphrase("hunter3")); err != ErrDecryptFailed {
+		t.Error("Correct Error not thrown, expected: '" + ErrDecryptFailed.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err = tamperedImg.ExtractBytes(); err != errPassphraseRequired {
+		t.Error("Correct Error not thrown, expected: '" + errPassphraseRequired.Error() + "' got: '" + errString(err) + "'")
+	}
+}
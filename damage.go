@@ -0,0 +1,136 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	// DamageBlockLen is the number of bytes of stored data covered by each
+	// block checksum, see WithBlockChecksums
+	DamageBlockLen = 64
+	// blockSumLen is the length of each block checksum, the low 16 bits of
+	// the block's CRC32
+	blockSumLen = 2
+)
+
+// DamageError is returned when a payload embedded WithBlockChecksums fails
+// them. It says where the unrecoverable bits were, to help tell what edit
+// destroyed the payload.
+type DamageError struct {
+	Blocks []int // Indexes of the damaged DamageBlockLen byte blocks of stored data
+	Total  int   // Number of blocks stored
+
+	// Regions bounds the pixels holding each damaged block and Mask is
+	// opaque over exactly those pixels. Both are only set when extracting
+	// from an image.
+	Regions []image.Rectangle
+	Mask    *image.Alpha
+
+	Err error
+}
+
+func (e *DamageError) Error() string {
+	return fmt.Sprintf("%v: %d of %d blocks damaged", e.Err, len(e.Blocks), e.Total)
+}
+
+func (e *DamageError) Unwrap() error { return e.Err }
+
+// WithBlockChecksums stores a checksum for every DamageBlockLen bytes of
+// the stored payload, costing about 3% of its size. If extraction finds
+// the payload damaged it fails with a *DamageError saying which parts of
+// the image held the bad bits.
+func WithBlockChecksums() Option {
+	return func(o *options) {
+		o.blockSums = true
+	}
+}
+
+// blockCount returns the number of blocks dataLen bytes span
+func blockCount(dataLen int) int {
+	return (dataLen + DamageBlockLen - 1) / DamageBlockLen
+}
+
+// blockSummer checksums the stored data written to it block by block
+type blockSummer struct {
+	n    int
+	crc  uint32
+	sums []byte
+}
+
+func (b *blockSummer) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		take := minInt(len(p), DamageBlockLen-b.n%DamageBlockLen)
+		b.crc = crc32.Update(b.crc, crc32.IEEETable, p[:take])
+		b.n += take
+		p = p[take:]
+		if b.n%DamageBlockLen == 0 {
+			b.flush()
+		}
+	}
+	return written, nil
+}
+
+func (b *blockSummer) flush() {
+	b.sums = binary.BigEndian.AppendUint16(b.sums, uint16(b.crc))
+	b.crc = 0
+}
+
+// Sum returns the checksums of every block, the last possibly partial
+func (b *blockSummer) Sum() []byte {
+	if b.n%DamageBlockLen != 0 {
+		b.flush()
+	}
+	return b.sums
+}
+
+// checkBlocks checks the stored data read from data against the block
+// checksums in sums, returning a *DamageError if any fail
+func checkBlocks(data io.Reader, sums []byte) error {
+	b := &blockSummer{}
+	if _, err := io.Copy(b, data); err != nil {
+		return err
+	}
+	got := b.Sum()
+	if len(got) != len(sums) {
+		return ErrPayloadCorrupt
+	}
+	var damaged []int
+	for i := 0; i < len(sums); i += blockSumLen {
+		if !bytes.Equal(got[i:i+blockSumLen], sums[i:i+blockSumLen]) {
+			damaged = append(damaged, i/blockSumLen)
+		}
+	}
+	if damaged == nil {
+		return nil
+	}
+	return &DamageError{Blocks: damaged, Total: len(sums) / blockSumLen, Err: ErrPayloadCorrupt}
+}
+
+// locateDamage fills in where in the loaded image the damaged blocks of a
+// *DamageError lie, given the placement and byte offset of the stored data
+func (s *StegImage) locateDamage(err error, place placement, offset int, dataLen int) {
+	var damage *DamageError
+	if !errors.As(err, &damage) {
+		return
+	}
+	bounds := s.imgLoaded.Bounds()
+	damage.Mask = image.NewAlpha(bounds)
+	damage.Regions = make([]image.Rectangle, len(damage.Blocks))
+	for i, block := range damage.Blocks {
+		start := block * DamageBlockLen
+		end := minInt(start+DamageBlockLen, dataLen)
+		for bit := (offset + start) * 8; bit < (offset+end)*8; bit++ {
+			x, y, _, _ := place.locate(bit)
+			damage.Mask.SetAlpha(x, y, color.Alpha{A: 0xff})
+			damage.Regions[i] = damage.Regions[i].Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+}
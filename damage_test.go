@@ -0,0 +1,69 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestDamageReport verifies a payload with block checksums survives
+// intact and, once a column of pixels is edited, reports the damage there
+func TestDamageReport(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payload := make([]byte, 2000)
+	rand.Read(payload)
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes(payload, WithBlockChecksums()); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := reloadImage(t, &stegImg)
+	secret, err := reloaded.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, payload) {
+		t.Error("Extracted payload differs")
+	}
+
+	// Flip the LSBs of one column well inside the payload
+	img := reloaded.imgLoaded.(draw.Image)
+	bounds := img.Bounds()
+	const editX = 5
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		r, g, b, a := img.At(editX, y).RGBA()
+		img.Set(editX, y, color.NRGBA64{uint16(r ^ 0x0101), uint16(g), uint16(b), uint16(a)})
+	}
+	column := image.Rect(editX, bounds.Min.Y, editX+1, bounds.Max.Y)
+
+	for _, extract := range []func() error{
+		func() error { _, err := reloaded.ExtractBytes(); return err },
+		func() error { _, err := reloaded.ExtractTo(new(bytes.Buffer)); return err },
+	} {
+		var damage *DamageError
+		if err := extract(); !errors.As(err, &damage) || !errors.Is(err, ErrPayloadCorrupt) {
+			t.Fatalf("Expected a *DamageError, got: '%s'", errString(err))
+		}
+		if len(damage.Blocks) == 0 || len(damage.Blocks) == damage.Total || len(damage.Regions) != len(damage.Blocks) {
+			t.Errorf("Expected some of %d blocks damaged, got %v", damage.Total, damage.Blocks)
+		}
+		for i, region := range damage.Regions {
+			if !region.Overlaps(column) {
+				t.Errorf("Block %d at %v doesn't overlap the edit at %v", damage.Blocks[i], region, column)
+			}
+		}
+		if damage.Mask.AlphaAt(editX, bounds.Min.Y+10).A != 0xff || damage.Mask.AlphaAt(bounds.Max.X-1, bounds.Max.Y-1).A != 0 {
+			t.Error("Mask doesn't cover just the damaged pixels")
+		}
+	}
+}
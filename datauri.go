@@ -0,0 +1,39 @@
+package libsteg
+
+import (
+	"errors"
+	"strings"
+)
+
+// dataURIPNGPrefix starts the data URIs written for stego images
+const dataURIPNGPrefix = "data:image/png;base64,"
+
+var errBadDataURI = errors.New("data URI isn't base64 encoded")
+
+// splitDataURI strips the header from a base64 data URI, such as
+// data:image/png;base64,..., returning the base64 data and whether s was
+// a data URI. Bare base64 is returned unchanged.
+func splitDataURI(s string) (b64 string, isURI bool, err error) {
+	if len(s) < 5 || !strings.EqualFold(s[:5], "data:") {
+		return s, false, nil
+	}
+	comma := strings.IndexByte(s, ',')
+	if comma < 0 || !strings.HasSuffix(strings.ToLower(s[:comma]), ";base64") {
+		return "", true, errBadDataURI
+	}
+	return s[comma+1:], true, nil
+}
+
+// WriteNewImageToDataURI encodes the image held in StegImage.newImg as a
+// data:image/png;base64 URI, as web clients expect, or with the source's
+// media type under SetKeepFormat
+func (s *StegImage) WriteNewImageToDataURI() (string, error) {
+	b64Img, err := s.WriteNewImageToB64()
+	if err != nil {
+		return "", err
+	}
+	if format := s.OutputFormat(); format != "png" {
+		return "data:image/" + format + ";base64," + b64Img, nil
+	}
+	return dataURIPNGPrefix + b64Img, nil
+}
@@ -0,0 +1,44 @@
+package libsteg
+
+import (
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BDataURI verifies a data URI round trips through the base64
+// helpers and bare base64 stays bare
+func TestB2BDataURI(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, tc := range []struct {
+		in     string
+		prefix string
+	}{
+		{"data:image/png;base64," + CleanB64Image, dataURIPNGPrefix},
+		{"DATA:image/png;charset=binary;BASE64," + CleanB64Image, dataURIPNGPrefix},
+		{CleanB64Image, ""},
+	} {
+		out, err := Base64Embed(tc.in, secretStringIn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.prefix != "" && !strings.HasPrefix(out, tc.prefix) || tc.prefix == "" && strings.HasPrefix(out, "data:") {
+			t.Errorf("Unexpected output prefix for input %.40s: %.40s", tc.in, out)
+		}
+		secret, err := Base64Extract(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret != secretStringIn {
+			t.Error("Secrets Do Not Match!")
+		}
+	}
+
+	_, err := Base64Extract("data:image/png," + CleanB64Image)
+	if err != errBadDataURI {
+		t.Error("Correct Error not thrown, expected: '" + errString(errBadDataURI) + "' got: '" + errString(err) + "'")
+	}
+}
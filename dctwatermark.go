@@ -0,0 +1,198 @@
+package libsteg
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	// dctBlock is the side of the blocks transformed, matching JPEG's
+	dctBlock = 8
+	// dctMinBlocks is the fewest blocks each bit of the ID may be repeated
+	// in
+	dctMinBlocks = 4
+	// dctMargin is how far, in orthonormal DCT units, the two coefficients
+	// holding a bit are pushed apart. Quality 70 quantizes them in steps of
+	// about 15, so the order survives.
+	dctMargin = 32
+)
+
+// dctCoeffs are the two mid-frequency coefficients, as (u, v), whose
+// relative size holds a bit. JPEG quantizes them alike, so recompression
+// rarely swaps them.
+var dctCoeffs = [2][2]int{{2, 3}, {3, 2}}
+
+// dctBasis holds the orthonormal 8x8 DCT basis of each of dctCoeffs,
+// indexed by y*dctBlock+x
+var dctBasis = func() (basis [2][dctBlock * dctBlock]float64) {
+	scale := func(u int) float64 {
+		if u == 0 {
+			return math.Sqrt(1.0 / dctBlock)
+		}
+		return math.Sqrt(2.0 / dctBlock)
+	}
+	for i, uv := range dctCoeffs {
+		for y := 0; y < dctBlock; y++ {
+			for x := 0; x < dctBlock; x++ {
+				basis[i][y*dctBlock+x] = scale(uv[0]) * scale(uv[1]) *
+					math.Cos(float64(2*x+1)*float64(uv[0])*math.Pi/(2*dctBlock)) *
+					math.Cos(float64(2*y+1)*float64(uv[1])*math.Pi/(2*dctBlock))
+			}
+		}
+	}
+	return basis
+}()
+
+// EmbedDCTWatermark embeds a short ID, such as a recipient or asset
+// number, in the mid-frequency DCT coefficients of the luma of 8x8 pixel
+// blocks, aligned with the blocks JPEG compresses. Each bit is repeated in
+// blocks chosen by the passphrase, so the ID can still be read after the
+// image is saved as JPEG at quality 70 or better, for tracing where a copy
+// leaked. Only WithPassphrase applies and the ID isn't encrypted.
+func (s *StegImage) EmbedDCTWatermark(id []byte, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+	dst, err := dctEmbed(s.imgLoaded, id, newOptions(opts))
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	s.newImg, s.robustImg = dst, dst
+	ob.bytes = int64(len(id))
+	return nil
+}
+
+// dctEmbed returns a copy of img with id embedded in its DCT coefficients
+func dctEmbed(img image.Image, id []byte, o *options) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	pattern, err := newDCTPattern(bounds, o.passphrase, len(id))
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	for b, one := range spreadFrame(id) {
+		for j := 0; j < pattern.reps; j++ {
+			block := pattern.block(b, j)
+			c := dctCoefficients(dst, block)
+			diff := c[0] - c[1]
+			if !one {
+				diff = -diff
+			}
+			if diff >= dctMargin {
+				continue
+			}
+			// Push the pair apart evenly, in the direction the bit needs
+			shift := (dctMargin - diff) / 2
+			if !one {
+				shift = -shift
+			}
+			for y := 0; y < dctBlock; y++ {
+				for x := 0; x < dctBlock; x++ {
+					i := y*dctBlock + x
+					delta := shift * (dctBasis[0][i] - dctBasis[1][i])
+					px := dst.NRGBAAt(block.Min.X+x, block.Min.Y+y)
+					px.R, px.G, px.B = spreadShift(px.R, delta), spreadShift(px.G, delta), spreadShift(px.B, delta)
+					dst.SetNRGBA(block.Min.X+x, block.Min.Y+y, px)
+				}
+			}
+		}
+	}
+	return dst, nil
+}
+
+// ExtractDCTWatermark reads an n byte ID embedded with EmbedDCTWatermark
+// using the same passphrase, returning ErrNoPayload if it isn't found
+func (s *StegImage) ExtractDCTWatermark(n int, opts ...Option) (id []byte, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+	o := newOptions(opts)
+	pattern, err := newDCTPattern(s.imgLoaded.Bounds(), o.passphrase, n)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	id, _ = pattern.detect(s.imgLoaded)
+	if id == nil {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	ob.bytes = int64(len(id))
+	return id, nil
+}
+
+// dctPattern assigns each bit of a framed ID the blocks repeating it
+type dctPattern struct {
+	bounds image.Rectangle
+	cols   int // Whole blocks across the image
+	bits   int
+	reps   int // Blocks per bit
+	perm   *feistel
+}
+
+func newDCTPattern(bounds image.Rectangle, passphrase string, n int) (*dctPattern, error) {
+	cols, rows := bounds.Dx()/dctBlock, bounds.Dy()/dctBlock
+	bits := (n + spreadSumLen) * 8
+	if n < 0 || cols*rows/bits < dctMinBlocks {
+		return nil, ErrInsufficientCapacity
+	}
+	key, err := placementKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	perm, err := newFeistel(key, cols*rows)
+	if err != nil {
+		return nil, err
+	}
+	return &dctPattern{bounds: bounds, cols: cols, bits: bits, reps: cols * rows / bits, perm: perm}, nil
+}
+
+// block returns the pixels of repetition j of bit b
+func (p *dctPattern) block(b int, j int) image.Rectangle {
+	i := int(p.perm.permute(uint64(b*p.reps + j)))
+	min := p.bounds.Min.Add(image.Pt(i%p.cols*dctBlock, i/p.cols*dctBlock))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(dctBlock, dctBlock))}
+}
+
+// detect reads every bit by summing the coefficient differences of its
+// blocks, returning the ID if its check matches, and the watermarkScore of
+// the bits
+func (p *dctPattern) detect(img image.Image) (id []byte, score float64) {
+	bits := make([]bool, p.bits)
+	confident := 0
+	for b := range bits {
+		var sum, energy float64
+		for j := 0; j < p.reps; j++ {
+			c := dctCoefficients(img, p.block(b, j))
+			sum += c[0] - c[1]
+			energy += (c[0] - c[1]) * (c[0] - c[1])
+		}
+		bits[b] = sum > 0
+		if confidentBit(sum, energy) {
+			confident++
+		}
+	}
+	return spreadDeframe(bits), float64(confident) / float64(p.bits)
+}
+
+// dctCoefficients returns dctCoeffs of the luma of block
+func dctCoefficients(img image.Image, block image.Rectangle) (c [2]float64) {
+	for y := 0; y < dctBlock; y++ {
+		for x := 0; x < dctBlock; x++ {
+			l := luma(img.At(block.Min.X+x, block.Min.Y+y))
+			c[0] += l * dctBasis[0][y*dctBlock+x]
+			c[1] += l * dctBasis[1][y*dctBlock+x]
+		}
+	}
+	return c
+}
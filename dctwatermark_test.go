@@ -0,0 +1,54 @@
+package libsteg
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestDCTWatermark verifies an ID survives JPEG recompression down to
+// quality 70, and isn't found with the wrong key
+func TestDCTWatermark(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	id := []byte{0xde, 0xad, 0xbe, 0xef}
+	if err := stegImg.EmbedDCTWatermark(id, WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	for _, quality := range []int{95, 85, 70} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, stegImg.newImg, &jpeg.Options{Quality: quality}); err != nil {
+			t.Fatal(err)
+		}
+		var recompressed StegImage
+		if err := recompressed.LoadImage(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := recompressed.ExtractDCTWatermark(len(id), WithPassphrase("hunter2"))
+		if err != nil {
+			t.Errorf("Quality %d: %v", quality, err)
+		} else if !bytes.Equal(got, id) {
+			t.Errorf("Quality %d: extracted %x, expected %x", quality, got, id)
+		}
+	}
+
+	reloaded := reloadImage(t, &stegImg)
+	if _, err := reloaded.ExtractDCTWatermark(len(id), WithPassphrase("wrong")); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrNoPayload) + "' got: '" + errString(err) + "'")
+	}
+
+	var tinyImg StegImage
+	if err := tinyImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := tinyImg.EmbedDCTWatermark(id); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,99 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// deniableSlots is the number of disjoint pixel sets a deniable image is
+// split into, one for the decoy and one for the real payload
+const deniableSlots = 2
+
+var errDeniablePassphrases = errors.New("deniable payloads need two different passphrases")
+
+// EmbedDeniable embeds a plausible decoy and the real secret, each
+// encrypted under its own passphrase and scattered over its own
+// key-derived set of pixels. The sets are disjoint, so revealing the decoy
+// passphrase doesn't expose the real payload. Slots are assigned at random
+// so their order gives nothing away either.
+func (s *StegImage) EmbedDeniable(decoy []byte, decoyPassphrase string, secret []byte, secretPassphrase string, opts ...Option) (err error) {
+	if decoyPassphrase == "" || secretPassphrase == "" || decoyPassphrase == secretPassphrase {
+		s.logger().Error(errDeniablePassphrases)
+		return errDeniablePassphrases
+	}
+	err = s.createMutableImage()
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+
+	payloads := [][]byte{decoy, secret}
+	passphrases := []string{decoyPassphrase, secretPassphrase}
+	slotOpts := newOptions(opts)
+	if slotOpts.deterministic {
+		slotOpts.passphrase = decoyPassphrase + secretPassphrase
+		slotOpts.seedDeterministic(append(append([]byte{}, decoy...), secret...))
+	}
+	pick := make([]byte, 1)
+	if _, err = io.ReadFull(slotOpts.rand, pick); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	first := int(pick[0]) % deniableSlots
+	for i := range payloads {
+		key, err := placementKey(passphrases[i])
+		if err != nil {
+			s.logger().Error(err)
+			return err
+		}
+		place, err := newKeyedPlacement(s.newImg, key, (first+i)%deniableSlots, deniableSlots)
+		if err != nil {
+			s.logger().Error(err)
+			return err
+		}
+		o := newOptions(append(opts, WithPassphrase(passphrases[i])))
+		if err = s.writeEnvelope(bytes.NewReader(payloads[i]), 0, o, place); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractDeniable retrieves whichever payload embedded with EmbedDeniable
+// the passphrase unlocks
+func (s *StegImage) ExtractDeniable(passphrase string, opts ...Option) ([]byte, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+	key, err := placementKey(passphrase)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+
+	o := newOptions(append(opts, WithPassphrase(passphrase)))
+	for slot := 0; slot < deniableSlots; slot++ {
+		place, err := newKeyedPlacement(s.imgLoaded, key, slot, deniableSlots)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		e, err := s.readEnvelope(o, place)
+		if err == ErrNoPayload {
+			continue
+		} else if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		return payload, nil
+	}
+	s.logger().Error(ErrNoPayload)
+	return nil, ErrNoPayload
+}
@@ -0,0 +1,62 @@
+package libsteg
+
+import (
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BDeniable does a back to back deniable test, checking each
+// passphrase only unlocks its own payload
+func TestB2BDeniable(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	err := cleanImg.EmbedDeniable([]byte("shopping list"), "decoy", []byte(secretStringIn), "real")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperedImg := reloadImage(t, &cleanImg)
+
+	for passphrase, expected := range map[string]string{"decoy": "shopping list", "real": secretStringIn} {
+		payloadOut, err := tamperedImg.ExtractDeniable(passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != expected {
+			t.Errorf("Payload for %s Does Not Match!", passphrase)
+		}
+	}
+
+	if _, err = tamperedImg.ExtractDeniable("wrong"); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+	// Nothing should be found where a plain envelope would sit
+	if _, err = tamperedImg.ExtractBytes(); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestFeistelPermutation verifies the keyed permutation is a bijection
+func TestFeistelPermutation(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 7, 100, 1001} {
+		perm, err := newFeistel(make([]byte, 16), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[uint64]bool)
+		for i := 0; i < n; i++ {
+			v := perm.permute(uint64(i))
+			if v >= uint64(n) || seen[v] {
+				t.Fatalf("Not a permutation of %d at %d", n, i)
+			}
+			seen[v] = true
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package libsteg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// WithDeterministic makes embedding idempotent: the same payload embedded
+// with the same key and options into the same cover yields a byte
+// identical stego image across runs and machines, so outputs can be
+// content addressed. Every salt, nonce and ephemeral key is derived from
+// the passphrase and the plaintext as sealed, payload, metadata and all,
+// instead of drawn at random, which means identical payloads embedded
+// alike produce identical ciphertext. The payload is read
+// into memory before embedding and Metadata.Created must be set explicitly.
+// For the encoded file to match too, write it with the same function and
+// PNG profile each time, ideally ProfileReproducible.
+func WithDeterministic() Option {
+	return func(o *options) {
+		o.deterministic = true
+	}
+}
+
+// WithSeed embeds deterministically, as WithDeterministic does, with seed
+// mixed into everything derived. The same cover, payload, key and seed
+// give the same output, while a different seed gives unrelated salts,
+// nonces and keys, even without a passphrase.
+func WithSeed(seed []byte) Option {
+	return func(o *options) {
+		o.deterministic = true
+		o.seed = append([]byte(nil), seed...)
+	}
+}
+
+// seedDeterministic replaces the options' randomness with a stream keyed
+// by the passphrase, any seed and parts. Sealing reseeds with the header
+// and the plaintext it encrypts, so a stream is only ever reused for the
+// same plaintext and derived nonces never meet different plaintext.
+func (o *options) seedDeterministic(parts ...[]byte) {
+	mac := hmac.New(sha256.New, []byte("libsteg deterministic:"+o.passphrase))
+	if o.seed != nil {
+		parts = append([][]byte{o.seed}, parts...)
+	}
+	for _, part := range parts {
+		// Length prefixed so bytes can't be shifted between parts
+		mac.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		mac.Write(part)
+	}
+	o.rand = newDeterministicReader(mac.Sum(nil))
+}
+
+// deterministicReader is an AES-CTR keystream used as a seeded random
+// source
+type deterministicReader struct {
+	stream cipher.Stream
+}
+
+func newDeterministicReader(seed []byte) *deterministicReader {
+	block, _ := aes.NewCipher(seed)
+	return &deterministicReader{stream: cipher.NewCTR(block, make([]byte, aes.BlockSize))}
+}
+
+func (d *deterministicReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	d.stream.XORKeyStream(p, p)
+	return len(p), nil
+}
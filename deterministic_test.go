@@ -0,0 +1,131 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestDeterministicEmbed verifies embedding the same payload twice gives
+// byte identical output that still extracts
+func TestDeterministicEmbed(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	embed := func(payload string) string {
+		var cleanImg StegImage
+		if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+			t.Fatal(err)
+		}
+		err := cleanImg.EmbedBytes([]byte(payload), WithPassphrase("hunter2"), WithHMAC(),
+			WithCompression(CompressionGzip), WithDeterministic())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b64, err := cleanImg.WriteNewImageToB64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b64
+	}
+
+	first, second := embed(secretStringIn), embed(secretStringIn)
+	if first != second {
+		t.Error("Deterministic embeds differ")
+	}
+	if first == embed(secretStringIn+"!") {
+		t.Error("Different payloads gave identical output")
+	}
+
+	var tamperedImg StegImage
+	if err := tamperedImg.LoadImageFromB64(first); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := tamperedImg.ExtractBytes(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+}
+
+// TestDeterministicNonces verifies one payload embedded deterministically
+// with different metadata doesn't reuse the salt and nonce
+func TestDeterministicNonces(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	nonce := func(contentType string) []byte {
+		o := newOptions([]Option{WithPassphrase("hunter2"), WithDeterministic(),
+			WithMetadata(Metadata{ContentType: contentType})})
+		framed, err := marshalEnvelope(bytes.NewReader([]byte(secretStringIn)), flagChecksum, o, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := envelopeHeaderLen
+		return framed[start : start+cryptSaltLen+cryptNoncePrefixLen]
+	}
+
+	if !bytes.Equal(nonce("text/plain"), nonce("text/plain")) {
+		t.Error("Deterministic embeds differ")
+	}
+	if bytes.Equal(nonce("text/plain"), nonce("text/html")) {
+		t.Error("Salt and nonce reused with different metadata")
+	}
+}
+
+// TestSeededReproducibleOutput verifies seeded embeds written with the
+// reproducible profile give identical files whichever writer is used, and
+// that the seed changes the output
+func TestSeededReproducibleOutput(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	recipient, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	embed := func(seed string) *StegImage {
+		var cleanImg StegImage
+		if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+			t.Fatal(err)
+		}
+		cleanImg.SetPNGProfile(ProfileReproducible)
+		err := cleanImg.EmbedBytes([]byte(secretStringIn), WithRecipients(recipient.PublicKey()),
+			WithMetadata(Metadata{ContentType: "text/plain"}), WithSeed([]byte(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &cleanImg
+	}
+
+	first, second := embed("run"), embed("run")
+	buf := new(bytes.Buffer)
+	if err := first.WriteNewImage(buf); err != nil {
+		t.Fatal(err)
+	}
+	b64, err := second.WriteNewImageToB64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base64.StdEncoding.EncodeToString(buf.Bytes()) != b64 {
+		t.Error("Seeded embeds differ")
+	}
+	other, err := embed("other").WriteNewImageToB64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == b64 {
+		t.Error("Different seeds gave identical output")
+	}
+
+	payloadOut, err := reloadImage(t, first).ExtractBytes(WithIdentity(recipient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+}
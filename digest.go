@@ -0,0 +1,59 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+)
+
+// payloadDigestLen is the length of the payload digest in the header
+const payloadDigestLen = sha256.Size
+
+// ErrDigestMismatch is returned when the extracted payload doesn't match
+// the SHA-256 stored with it
+var ErrDigestMismatch = errors.New("payload digest mismatch")
+
+// WithPayloadDigest stores a SHA-256 of the original payload, before any
+// compression or encryption, in the envelope header. Extraction hashes the
+// payload it recovers and fails with ErrDigestMismatch unless it's exactly
+// what was embedded. The digest isn't encrypted, so anyone can confirm a
+// guess of the payload against it; use WithHMAC instead where that matters.
+// ExtractTo streams the payload, so the mismatch is only reported once it
+// has all been written.
+func WithPayloadDigest() Option {
+	return func(o *options) {
+		o.payloadDigest = true
+	}
+}
+
+// payloadDigestOffset returns where the payload digest starts in the header
+func (e *envelope) payloadDigestOffset() int {
+	n := envelopeHeaderLen
+	if e.flags&flagNamed != 0 {
+		n += 1 + len(e.name)
+	}
+	return n
+}
+
+// digestReader hashes the payload as it's read, failing at the end if it
+// doesn't match the digest stored in the envelope
+type digestReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	want []byte
+}
+
+func newDigestReader(r io.ReadCloser, want []byte) *digestReader {
+	return &digestReader{ReadCloser: r, hash: sha256.New(), want: want}
+}
+
+func (r *digestReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(r.hash.Sum(nil), r.want) {
+		err = ErrDigestMismatch
+	}
+	return n, err
+}
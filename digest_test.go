@@ -0,0 +1,45 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestPayloadDigest verifies the digest of the original payload is checked
+// through compression and encryption, and a tampered digest is caught
+func TestPayloadDigest(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := bytes.Repeat([]byte(secretStringIn), 50)
+	opts := []Option{WithPayloadDigest(), WithPassphrase("hunter2"), WithCompression(CompressionGzip),
+		WithMetadata(Metadata{ContentType: "text/plain"})}
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes(payloadIn, opts...); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payloadOut, payloadIn) {
+		t.Error("Payloads Do Not Match!")
+	}
+
+	// Flip a bit of the stored digest, which the CRC32 doesn't cover
+	place := newSequentialPlacement(cleanImg.newImg)
+	x, y, ch, plane := place.locate(envelopeHeaderLen*8 + 3)
+	setBit(cleanImg.newImg, x, y, ch, plane, getBit(cleanImg.newImg, x, y, ch, plane)^1)
+	stego := reloadImage(t, &cleanImg)
+	if _, err := stego.ExtractBytes(opts...); err != ErrDigestMismatch {
+		t.Error("Correct Error not thrown, expected: '" + ErrDigestMismatch.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := stego.ExtractTo(new(bytes.Buffer), opts...); err != ErrDigestMismatch {
+		t.Error("Correct Error not thrown, expected: '" + ErrDigestMismatch.Error() + "' got: '" + errString(err) + "'")
+	}
+}
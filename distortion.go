@@ -0,0 +1,51 @@
+package libsteg
+
+import (
+	"errors"
+	"image/color"
+)
+
+// ErrDistortionBudget is returned when embedding would exceed the
+// perceptual distortion budget set with WithDistortionBudget
+var ErrDistortionBudget = errors.New("perceptual distortion budget exceeded")
+
+// WithDistortionBudget stops embedding with ErrDistortionBudget once the
+// cumulative perceptual distortion exceeds budget. Each changed pixel
+// costs its change in luma, in 8-bit steps, weighted by how visible a
+// change is at that brightness: twice as much in black as in mid grey, a
+// third less in white. Flipping the green LSB of a mid grey pixel costs
+// about 0.6. Embedding only stops; the payload isn't moved to less visible
+// pixels, so a budget that's too tight means a failed embed. The partly
+// written stego image is discarded, though WithInPlace leaves the cover
+// part written.
+func WithDistortionBudget(budget float64) Option {
+	return func(o *options) {
+		o.distortionBudget = budget
+	}
+}
+
+// distortionMeter accumulates the perceptual cost of pixel changes
+type distortionMeter struct {
+	budget float64
+	total  float64
+}
+
+// add accounts for a pixel changing from before to after
+func (m *distortionMeter) add(before color.Color, after color.Color) error {
+	l0, l1 := luma(before), luma(after)
+	delta := l1 - l0
+	if delta < 0 {
+		delta = -delta
+	}
+	m.total += delta * 256 / (128 + l0)
+	if m.total > m.budget {
+		return ErrDistortionBudget
+	}
+	return nil
+}
+
+// luma returns the Rec. 601 luma of c on a 0-255 scale
+func luma(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0x101
+}
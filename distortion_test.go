@@ -0,0 +1,49 @@
+package libsteg
+
+import (
+	"image/color"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestDistortionBudget verifies embedding stops once the budget is spent,
+// leaving no stego image
+func TestDistortionBudget(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithDistortionBudget(1)); err != ErrDistortionBudget {
+		t.Error("Correct Error not thrown, expected: '" + ErrDistortionBudget.Error() + "' got: '" + errString(err) + "'")
+	}
+	if cleanImg.Stego() != nil {
+		t.Error("Partly written stego image kept after the budget ran out")
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithDistortionBudget(1000)); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+}
+
+// TestDistortionWeighting verifies the same change costs more in dark
+// pixels than in bright ones
+func TestDistortionWeighting(t *testing.T) {
+	t.Parallel()
+
+	dark, bright := &distortionMeter{budget: 100}, &distortionMeter{budget: 100}
+	dark.add(color.RGBA{10, 10, 10, 255}, color.RGBA{10, 11, 10, 255})
+	bright.add(color.RGBA{240, 240, 240, 255}, color.RGBA{240, 241, 240, 255})
+	if dark.total <= bright.total {
+		t.Errorf("Dark change cost %f not above bright change cost %f", dark.total, bright.total)
+	}
+}
@@ -0,0 +1,125 @@
+package libsteg
+
+import (
+	"encoding/hex"
+	"image"
+	"io"
+	"sync"
+	"time"
+)
+
+// EmbedRecord describes one embed, as kept by an EmbedIndex. The payload
+// itself is never recorded.
+type EmbedRecord struct {
+	ImageHash  string    // ImageHash of the stego image
+	Time       time.Time // When the embed happened, zero if deterministic
+	PayloadLen int64     // Length of the payload before framing
+	Name       string    // Slot name, if any
+	Encrypted  bool      // Whether the payload was encrypted
+	Metadata   *Metadata // Metadata stored with the payload, if any
+}
+
+// EmbedIndex records the embeds made WithEmbedIndex so they can be looked
+// up by image later, e.g. to track what an organisation has embedded
+// where. Implementations must be safe for concurrent use.
+type EmbedIndex interface {
+	Record(rec EmbedRecord) error
+	// Lookup returns the embeds recorded for the image with hash, oldest
+	// first
+	Lookup(hash string) ([]EmbedRecord, error)
+}
+
+// WithEmbedIndex records each successful EmbedBytes, EmbedFromReader and
+// structured payload embed in idx. If recording fails the embed returns
+// its error, though the stego image is still made.
+func WithEmbedIndex(idx EmbedIndex) Option {
+	return func(o *options) {
+		o.index = idx
+	}
+}
+
+// ImageHash returns the key an EmbedIndex files img under: the hex SHA-256
+// of its size and pixels with the bit planes embedding changes cleared. A
+// cover and the stego images made from it hash alike, as does the image
+// after lossless re-encoding.
+func ImageHash(img image.Image) string {
+	return hex.EncodeToString(visibleDigest(img))
+}
+
+// embedIndexed embeds as embedStream does, recording the embed in the
+// options' index once it succeeds
+func (s *StegImage) embedIndexed(r io.Reader, flags uint16, o *options) error {
+	idx := o.index
+	o.index = nil
+	defer func() { o.index = idx }()
+
+	counted := &countingReader{r: r}
+	if err := s.embedStream(counted, flags, o); err != nil {
+		return err
+	}
+	rec := EmbedRecord{
+		ImageHash:  ImageHash(s.newImg),
+		PayloadLen: counted.n,
+		Name:       o.name,
+		Encrypted:  o.passphrase != "" || len(o.recipients) > 0,
+		Metadata:   o.metadata,
+	}
+	if !o.deterministic {
+		rec.Time = time.Now()
+	}
+	if err := idx.Record(rec); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo hands w to r's own WriteTo, if it has one, so counting doesn't
+// change how much of r io.Copy reads before the image fills
+func (c *countingReader) WriteTo(w io.Writer) (n int64, err error) {
+	if wt, ok := c.r.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	} else {
+		n, err = io.Copy(w, c.r)
+	}
+	c.n += n
+	return n, err
+}
+
+// MemoryEmbedIndex is an EmbedIndex held in memory
+type MemoryEmbedIndex struct {
+	mu      sync.Mutex
+	records map[string][]EmbedRecord
+}
+
+// NewMemoryEmbedIndex returns an empty in-memory index
+func NewMemoryEmbedIndex() *MemoryEmbedIndex {
+	return &MemoryEmbedIndex{records: make(map[string][]EmbedRecord)}
+}
+
+// Record implements EmbedIndex
+func (m *MemoryEmbedIndex) Record(rec EmbedRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[rec.ImageHash] = append(m.records[rec.ImageHash], rec)
+	return nil
+}
+
+// Lookup implements EmbedIndex
+func (m *MemoryEmbedIndex) Lookup(hash string) ([]EmbedRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]EmbedRecord(nil), m.records[hash]...), nil
+}
@@ -0,0 +1,56 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestEmbedIndex verifies embeds are recorded under a hash the stego
+// image, once reloaded, and its cover both look up
+func TestEmbedIndex(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	idx := NewMemoryEmbedIndex()
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	meta := Metadata{ContentType: "text/plain"}
+	err := stegImg.EmbedFromReader(bytes.NewReader([]byte(secretStringIn)),
+		WithEmbedIndex(idx), WithPassphrase("hunter2"), WithMetadata(meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := ImageHash(reloadImage(t, &stegImg).imgLoaded)
+	if hash != ImageHash(stegImg.imgLoaded) {
+		t.Error("Stego image and cover hash differently")
+	}
+	records, err := idx.Lookup(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Found %d records, expected 1", len(records))
+	}
+	rec := records[0]
+	if rec.PayloadLen != int64(len(secretStringIn)) || !rec.Encrypted || rec.Metadata == nil ||
+		rec.Metadata.ContentType != meta.ContentType || rec.Time.IsZero() {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+
+	// Failed embeds aren't recorded
+	var tinyImg StegImage
+	if err := tinyImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := tinyImg.EmbedBytes(make([]byte, 10000), WithEmbedIndex(idx)); err == nil {
+		t.Fatal("Oversized payload embedded")
+	}
+	if records, _ := idx.Lookup(ImageHash(tinyImg.imgLoaded)); len(records) != 0 {
+		t.Errorf("Found %d records for a failed embed", len(records))
+	}
+}
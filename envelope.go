@@ -0,0 +1,768 @@
+package libsteg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// envelopeMagic marks the start of a framed binary payload
+	envelopeMagic string = "LSTG"
+	// envelopeVersion is the current envelope format version
+	envelopeVersion uint8 = 1
+	// envelopeHeaderLen is magic + version + flags + codec + data length
+	envelopeHeaderLen = 4 + 1 + 2 + 1 + 4
+)
+
+// Envelope flags
+const (
+	// flagChecksum indicates a CRC32 of the stored data follows it
+	flagChecksum uint16 = 1 << iota
+	// flagEncrypted indicates the data is a passphrase encrypted stream
+	flagEncrypted
+	// flagMetadata indicates a metadata block precedes the payload
+	flagMetadata
+	// flagHMAC indicates an HMAC salt and sum follow the data
+	flagHMAC
+	// flagSigned indicates an Ed25519 public key and signature follow the data
+	flagSigned
+	// flagRecipients indicates the data is encrypted to X25519 recipients
+	flagRecipients
+	// flagNamed indicates a length prefixed slot name follows the header
+	flagNamed
+	// flagVisible indicates a digest of the cover's visible pixels follows
+	// the header and any slot name
+	flagVisible
+	// flagBlockSums indicates a checksum per DamageBlockLen bytes of stored
+	// data ends the trailer
+	flagBlockSums
+
+	// Bits 9 to 11 hold the text normalization, see flagTextForm
+
+	// flagPayloadDigest indicates a SHA-256 of the original payload follows
+	// any slot name in the header
+	flagPayloadDigest uint16 = 1 << 12
+
+	// Bit 13 is free, bits 14 and 15 hold the scan order, see flagScanOrder
+)
+
+var (
+	// ErrNoPayload is returned when no framed payload is found in the image
+	ErrNoPayload = errors.New("error finding embedded payload")
+	// ErrPayloadCorrupt is returned when the stored data doesn't match its
+	// CRC32, as when the image was altered after embedding
+	ErrPayloadCorrupt = errors.New("payload corrupt, checksum mismatch")
+	// errPassphraseRequired is returned when extracting an encrypted
+	// payload without a passphrase
+	errPassphraseRequired = errors.New("payload is encrypted, passphrase required")
+)
+
+// envelope frames a binary payload so it can be found and validated on
+// extraction
+type envelope struct {
+	flags   uint16
+	codec   Compression
+	name    string // Slot name, see EmbedNamed
+	digest  []byte // SHA-256 of the original payload, see WithPayloadDigest
+	visible []byte // Digest of the cover's visible pixels, see WithVisibleChecksum
+	data    []byte // Payload as stored, i.e. after compression and encryption
+
+	blockSums int // Number of block checksums, see WithBlockChecksums
+}
+
+// open returns the original payload held in the envelope and its metadata,
+// if any was stored
+func (e *envelope) open(o *options) (data []byte, meta *Metadata, err error) {
+	r, meta, err := e.openReader(bytes.NewReader(e.data), o)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	if data, err = ioutil.ReadAll(r); err != nil {
+		return nil, nil, err
+	}
+	return data, meta, nil
+}
+
+// openReader returns a reader over the original payload in the stored
+// data read from data, decrypting and decompressing as it goes, and the
+// metadata stored ahead of it, if any. The reader must be closed.
+func (e *envelope) openReader(data io.Reader, o *options) (r io.ReadCloser, meta *Metadata, err error) {
+	// Given a passphrase, the payload must be encrypted or carry an HMAC
+	// keyed by it, or one stored without either would pass for the
+	// passphrase holder's. The HMAC covers the header, so its flags can't
+	// be stripped.
+	if o.passphrase != "" && e.flags&(flagEncrypted|flagHMAC) == 0 {
+		return nil, nil, ErrNotEncrypted
+	}
+	plain := data
+	switch {
+	case e.flags&flagEncrypted != 0:
+		if o.passphrase == "" {
+			return nil, nil, errPassphraseRequired
+		}
+		if plain, err = NewDecryptReader(data, o.passphrase); err != nil {
+			return nil, nil, err
+		}
+	case e.flags&flagRecipients != 0:
+		if o.identity == nil {
+			return nil, nil, errIdentityRequired
+		}
+		if plain, err = newIdentityDecryptReader(data, o.identity); err != nil {
+			return nil, nil, err
+		}
+	}
+	if r, err = newDecompressReader(plain, e.codec, o.maxPayload); err != nil {
+		return nil, nil, err
+	}
+	if e.flags&flagMetadata != 0 {
+		br := bufio.NewReader(r)
+		if meta, err = readMetadata(br); err != nil {
+			r.Close()
+			return nil, nil, err
+		}
+		r = readCloser{br, r}
+	}
+	if e.flags&flagPayloadDigest != 0 {
+		r = newDigestReader(r, e.digest)
+	}
+	return r, meta, nil
+}
+
+// readCloser pairs a reader with the closer of the stream beneath it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// marshalHeader serialises the envelope header, the fixed size part
+// followed by the slot name if there is one
+func (e *envelope) marshalHeader(dataLen int) []byte {
+	header := make([]byte, envelopeHeaderLen, e.headerLen())
+	copy(header, envelopeMagic)
+	header[4] = envelopeVersion
+	binary.BigEndian.PutUint16(header[5:7], e.flags)
+	header[7] = byte(e.codec)
+	binary.BigEndian.PutUint32(header[8:12], uint32(dataLen))
+	if e.flags&flagNamed != 0 {
+		header = append(header, byte(len(e.name)))
+		header = append(header, e.name...)
+	}
+	if e.flags&flagPayloadDigest != 0 {
+		header = append(header, e.digest...)
+	}
+	if e.flags&flagVisible != 0 {
+		header = append(header, e.visible...)
+	}
+	return header
+}
+
+// headerLen returns the number of bytes stored before the data
+func (e *envelope) headerLen() (n int) {
+	n = envelopeHeaderLen
+	if e.flags&flagNamed != 0 {
+		n += 1 + len(e.name)
+	}
+	if e.flags&flagPayloadDigest != 0 {
+		n += payloadDigestLen
+	}
+	if e.flags&flagVisible != 0 {
+		n += visibleDigestLen
+	}
+	return n
+}
+
+// trailerLen returns the number of bytes stored after the data
+func (e *envelope) trailerLen() (n int) {
+	if e.flags&flagChecksum != 0 {
+		n += 4
+	}
+	if e.flags&flagHMAC != 0 {
+		n += hmacTrailerLen
+	}
+	if e.flags&flagSigned != 0 {
+		n += signTrailerLen
+	}
+	if e.flags&flagBlockSums != 0 {
+		n += e.blockSums * blockSumLen
+	}
+	return n
+}
+
+// parseEnvelopeHeader reads the fixed size envelope header, returning the
+// envelope (without data) and the length of the stored data
+func parseEnvelopeHeader(header []byte) (e *envelope, dataLen int, err error) {
+	if len(header) < envelopeHeaderLen ||
+		string(header[:4]) != envelopeMagic || header[4] != envelopeVersion {
+		return nil, 0, ErrNoPayload
+	}
+	e = &envelope{
+		flags: binary.BigEndian.Uint16(header[5:7]),
+		codec: Compression(header[7]),
+	}
+	dataLen = int(binary.BigEndian.Uint32(header[8:12]))
+	if e.flags&flagBlockSums != 0 {
+		e.blockSums = blockCount(dataLen)
+	}
+	return e, dataLen, nil
+}
+
+// verify checks the trailer stored after the data
+func (e *envelope) verify(trailer []byte, o *options) error {
+	return e.verifyFrom(func() io.Reader { return bytes.NewReader(e.data) }, len(e.data), trailer, o)
+}
+
+// verifyFrom checks the trailer against data of dataLen bytes, read afresh
+// from newData for each check so the data never has to be held in memory
+func (e *envelope) verifyFrom(newData func() io.Reader, dataLen int, trailer []byte, o *options) error {
+	// An HMAC asked for must be present, or a payload stored without one
+	// would pass for authenticated
+	if o.hmac && e.flags&flagHMAC == 0 {
+		return ErrHMACMismatch
+	}
+	// Block checksums come last but are checked first, as they say where
+	// any damage is
+	if e.flags&flagBlockSums != 0 {
+		sums := trailer[len(trailer)-e.blockSums*blockSumLen:]
+		trailer = trailer[:len(trailer)-len(sums)]
+		if err := checkBlocks(newData(), sums); err != nil {
+			return err
+		}
+	}
+	var sum []byte
+	if e.flags&flagChecksum != 0 {
+		sum, trailer = trailer[:4], trailer[4:]
+	}
+	header := e.marshalHeader(dataLen)
+	// An HMAC is checked before the CRC32, so a mismatch is reported as
+	// tampering rather than damage
+	if e.flags&flagHMAC != 0 {
+		if err := verifyHMAC(trailer, header, newData(), o.passphrase); err != nil {
+			return err
+		}
+		trailer = trailer[hmacTrailerLen:]
+	}
+	if sum != nil {
+		crc := crc32.NewIEEE()
+		if _, err := io.Copy(crc, newData()); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint32(sum) != crc.Sum32() {
+			return ErrPayloadCorrupt
+		}
+	}
+	if o.verifyKey != nil {
+		if e.flags&flagSigned == 0 {
+			return ErrNotSigned
+		}
+		return verifySignature(trailer, header, newData(), o.verifyKey)
+	}
+	return nil
+}
+
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	dr, err := NewDecryptReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+// EmbedBytes embeds the given binary payload into the loaded image, with
+// a CRC32 so extraction fails with ErrPayloadCorrupt rather than return
+// damaged data
+func (s *StegImage) EmbedBytes(payload []byte, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("embed"), &err)
+	if err = s.embedStream(bytes.NewReader(payload), flagChecksum, newOptions(opts)); err == nil {
+		ob.bytes = int64(len(payload))
+	}
+	return err
+}
+
+// ExtractBytes retrieves a binary payload embedded with EmbedBytes
+func (s *StegImage) ExtractBytes(opts ...Option) ([]byte, error) {
+	payload, _, err := s.ExtractWithMetadata(opts...)
+	return payload, err
+}
+
+// embedStream frames the payload read from r and streams it into the
+// image. The data length isn't known until r is exhausted, so the header
+// is written last.
+func (s *StegImage) embedStream(r io.Reader, flags uint16, o *options) (err error) {
+	if o.verify {
+		return s.embedVerified(r, flags, o)
+	}
+	if o.index != nil {
+		return s.embedIndexed(r, flags, o)
+	}
+	if o.quality != nil {
+		if o.inPlace {
+			s.logger().Error(errInPlaceQuality)
+			return errInPlaceQuality
+		}
+		return s.embedGated(r, flags, o)
+	}
+	if o.inPlace {
+		err = s.adoptLoadedImage()
+	} else {
+		err = s.createMutableImage()
+	}
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if !o.inPlace {
+		// A budget runs out part way through, so leave no half written
+		// image to be saved
+		defer func() {
+			if err == ErrDistortionBudget {
+				s.newImg = nil
+			}
+		}()
+	}
+	if o.tileSize != 0 {
+		return s.writeTiles(r, flags, o)
+	}
+	if o.scanOrder != ScanColumnMajor && o.adaptive {
+		s.logger().Error(errScanOrderUnsupported)
+		return errScanOrderUnsupported
+	}
+	if o.channelOrder != "" && o.adaptive {
+		s.logger().Error(errChannelOrderUnsupported)
+		return errChannelOrderUnsupported
+	}
+	var place placement
+	switch {
+	case o.redundancy > 1:
+		place, err = o.redundantPlacement(s.newImg, func(p placement) placement {
+			return o.orderBits(saltPlacement(s.newImg, p, o))
+		})
+	case o.adaptive:
+		place, err = adaptivePlacementFor(s.newImg, o)
+	default:
+		if place, err = s.existingPlacement(o); err == nil {
+			place, err = o.selectChannels(s.newImg, place)
+		}
+	}
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	flags |= uint16(o.scanOrder) << scanOrderFlagShift
+	if o.redundancy <= 1 {
+		place = o.orderBits(saltPlacement(s.newImg, place, o))
+	}
+	if o.matrix != 0 {
+		if place, err = o.matrixPlacement(place); err != nil {
+			s.logger().Error(err)
+			return err
+		}
+	}
+	if o.codec != nil {
+		return s.writeCodec(r, o, place)
+	}
+	return s.writeEnvelope(r, flags, o, place)
+}
+
+// writeEnvelope frames the payload read from r and streams it into the
+// manipulated image at the given placement
+func (s *StegImage) writeEnvelope(r io.Reader, flags uint16, o *options, place placement) error {
+	e, r, err := newEnvelope(r, flags, o, s.logger())
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if o.visibleChecksum {
+		e.flags |= flagVisible
+		e.visible = visibleDigest(s.newImg)
+	}
+
+	var meter *distortionMeter
+	if o.distortionBudget > 0 {
+		meter = &distortionMeter{budget: o.distortionBudget}
+	}
+	lsb := &lsbWriter{img: s.newImg, place: place, offset: e.headerLen() * 8, meter: meter}
+	header, trailer, err := e.seal(r, o, lsb, s.logger())
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if _, err = lsb.Write(trailer); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if _, err = (&lsbWriter{img: s.newImg, place: place, meter: meter}).Write(header); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	o.checkCapacity(lsb.offset/8, place, s.logger())
+	return nil
+}
+
+// marshalEnvelope frames the payload read from r as bytes, for carriers
+// that don't store it in image LSBs
+func marshalEnvelope(r io.Reader, flags uint16, o *options, logger stegLogger) ([]byte, error) {
+	e, r, err := newEnvelope(r, flags, o, logger)
+	if err != nil {
+		return nil, err
+	}
+	data := new(bytes.Buffer)
+	header, trailer, err := e.seal(r, o, data, logger)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(header)+data.Len()+len(trailer))
+	out = append(out, header...)
+	out = append(out, data.Bytes()...)
+	return append(out, trailer...), nil
+}
+
+// newEnvelope returns an envelope flagged for the options. If embedding
+// is deterministic the payload is read into memory to seed the options'
+// randomness, and the returned reader replaces r.
+func newEnvelope(r io.Reader, flags uint16, o *options, logger stegLogger) (*envelope, io.Reader, error) {
+	if o.deterministic {
+		payload, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		o.seedDeterministic(payload)
+		r = bytes.NewReader(payload)
+	}
+
+	e := &envelope{flags: flags, codec: o.compression, name: o.name}
+	if e.codec == CompressionAuto {
+		var err error
+		if e.codec, r, err = chooseCompression(r, logger); err != nil {
+			return nil, nil, err
+		}
+	}
+	if e.name != "" {
+		e.flags |= flagNamed
+	}
+	switch {
+	case len(o.recipients) > 0 && o.passphrase != "" && !o.hmac:
+		return nil, nil, errRecipientsAndPass
+	case len(o.recipients) > 0:
+		e.flags |= flagRecipients
+	case o.passphrase != "":
+		e.flags |= flagEncrypted
+	}
+	if o.metadata != nil {
+		e.flags |= flagMetadata
+	}
+	if o.hmac {
+		if o.passphrase == "" {
+			return nil, nil, errHMACNoKey
+		}
+		e.flags |= flagHMAC
+	}
+	if o.signingKey != nil {
+		e.flags |= flagSigned
+	}
+	if o.blockSums {
+		e.flags |= flagBlockSums
+	}
+	if o.payloadDigest {
+		e.flags |= flagPayloadDigest
+	}
+	return e, r, nil
+}
+
+// seal streams the payload read from r through compression and encryption
+// to w, returning the header and trailer that frame the stored data
+func (e *envelope) seal(r io.Reader, o *options, w io.Writer, logger stegLogger) (header []byte, trailer []byte, err error) {
+	// Deterministic embeds key their randomness by the header and the
+	// plaintext as sealed, SIV style, so metadata, the codec or anything
+	// else changing either also changes every salt and nonce drawn below
+	var plain *bytes.Buffer
+	var n int64
+	if o.deterministic {
+		plain = new(bytes.Buffer)
+		if n, err = e.compress(r, o, plain); err != nil {
+			return nil, nil, err
+		}
+		o.seedDeterministic(e.marshalHeader(0), plain.Bytes())
+	}
+
+	var mac hash.Hash
+	var macSalt []byte
+	if e.flags&flagHMAC != 0 {
+		if macSalt, err = newHMACSalt(o.rand); err == nil {
+			mac, err = newPayloadMAC(o.passphrase, macSalt)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var digest hash.Hash
+	if e.flags&flagSigned != 0 {
+		digest = sha512.New()
+	}
+
+	// Build the writer chain: compress -> encrypt -> checksum -> w
+	crc := crc32.NewIEEE()
+	sums := []io.Writer{w, crc}
+	if mac != nil {
+		sums = append(sums, mac)
+	}
+	if digest != nil {
+		sums = append(sums, digest)
+	}
+	var blocks *blockSummer
+	if e.flags&flagBlockSums != 0 {
+		blocks = &blockSummer{}
+		sums = append(sums, blocks)
+	}
+	stored := &countingWriter{w: io.MultiWriter(sums...)}
+
+	var encrypted io.WriteCloser = nopWriteCloser{stored}
+	switch {
+	case e.flags&flagRecipients != 0:
+		encrypted, err = newRecipientWriter(stored, o.recipients, o.rand)
+	case e.flags&flagEncrypted != 0:
+		encrypted, err = newEncryptWriter(stored, o.passphrase, o.rand)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if plain != nil {
+		_, err = encrypted.Write(plain.Bytes())
+	} else {
+		n, err = e.compress(r, o, encrypted)
+	}
+	if err == nil {
+		err = encrypted.Close()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Notice("Loaded payload of", n, "bytes, stored as", stored.n, "bytes")
+
+	header = e.marshalHeader(stored.n)
+	buf := new(bytes.Buffer)
+	if e.flags&flagChecksum != 0 {
+		binary.Write(buf, binary.BigEndian, crc.Sum32())
+	}
+	if mac != nil {
+		mac.Write(header)
+		buf.Write(macSalt)
+		buf.Write(mac.Sum(nil))
+	}
+	if digest != nil {
+		digest.Write(header)
+		sig, err := signEnvelope(o.signingKey, digest.Sum(nil))
+		if err != nil {
+			return nil, nil, err
+		}
+		buf.Write(sig)
+	}
+	if blocks != nil {
+		e.blockSums = blockCount(stored.n)
+		buf.Write(blocks.Sum())
+	}
+	return header, buf.Bytes(), nil
+}
+
+// compress writes any metadata block and the payload read from r to w
+// through the envelope's codec, returning the payload length. With
+// WithPayloadDigest it also records the payload's digest.
+func (e *envelope) compress(r io.Reader, o *options, w io.Writer) (n int64, err error) {
+	compressed, err := newCompressWriter(w, e.codec)
+	if err != nil {
+		return 0, err
+	}
+	if o.metadata != nil {
+		meta := *o.metadata
+		if meta.Created.IsZero() && !o.deterministic {
+			meta.Created = time.Now()
+		}
+		if _, err = compressed.Write(meta.marshal()); err != nil {
+			return 0, err
+		}
+	}
+	var payloadHash hash.Hash
+	if e.flags&flagPayloadDigest != 0 {
+		payloadHash = sha256.New()
+		r = io.TeeReader(r, payloadHash)
+	}
+	if n, err = io.Copy(compressed, r); err == nil {
+		err = compressed.Close()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if payloadHash != nil {
+		e.digest = payloadHash.Sum(nil)
+	}
+	return n, nil
+}
+
+// parseEnvelope reads and verifies an envelope from the start of b, as
+// written by marshalEnvelope
+func parseEnvelope(b []byte, o *options) (*envelope, error) {
+	e, dataLen, err := parseEnvelopeHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if e.flags&flagNamed != 0 {
+		if len(b) <= envelopeHeaderLen || len(b) < envelopeHeaderLen+1+int(b[envelopeHeaderLen]) {
+			return nil, ErrNoPayload
+		}
+		e.name = string(b[envelopeHeaderLen+1 : envelopeHeaderLen+1+int(b[envelopeHeaderLen])])
+	}
+	if e.flags&flagPayloadDigest != 0 {
+		start := e.payloadDigestOffset()
+		if len(b) < start+payloadDigestLen {
+			return nil, ErrNoPayload
+		}
+		e.digest = b[start : start+payloadDigestLen]
+	}
+	if e.flags&flagVisible != 0 {
+		start := e.headerLen() - visibleDigestLen
+		if len(b) < start+visibleDigestLen {
+			return nil, ErrNoPayload
+		}
+		e.visible = b[start : start+visibleDigestLen]
+	}
+	body := b[e.headerLen():]
+	if len(body) < dataLen+e.trailerLen() {
+		return nil, ErrNoPayload
+	}
+	e.data = body[:dataLen]
+	if err = e.verify(body[dataLen:dataLen+e.trailerLen()], o); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *StegImage) extractEnvelope(o *options) (*envelope, error) {
+	place, err := s.extractPlacement(o)
+	if err != nil {
+		return nil, err
+	}
+	return s.readEnvelope(o, place)
+}
+
+// extractPlacement returns where the options say the payload starts in the
+// loaded image
+func (s *StegImage) extractPlacement(o *options) (placement, error) {
+	if s.imgLoaded == nil {
+		return nil, errNoImageLoaded
+	}
+	if o.tileSize != 0 {
+		return s.tilePlacement(o)
+	}
+	if o.channelOrder != "" && o.adaptive {
+		return nil, errChannelOrderUnsupported
+	}
+	if o.matrix != 0 {
+		return s.matrixExtractPlacement(o)
+	}
+	channels := o.pixelChannels(s.imgLoaded)
+	if o.redundancy > 1 {
+		return o.redundantPlacement(s.imgLoaded, func(p placement) placement {
+			return o.scanLimit(o.orderBits(saltPlacement(s.imgLoaded, p, o)), channels)
+		})
+	}
+	place, err := o.selectChannels(s.imgLoaded, newSequentialPlacement(s.imgLoaded))
+	if err != nil {
+		return nil, err
+	}
+	place = o.scanLimit(o.orderBits(saltPlacement(s.imgLoaded, place, o)), channels)
+	if o.adaptive {
+		adaptive, err := adaptivePlacementFor(s.imgLoaded, o)
+		if err != nil {
+			return nil, err
+		}
+		adaptive = o.scanLimit(o.orderBits(saltPlacement(s.imgLoaded, adaptive, o)), channels)
+		// Payloads a quality gate pushed back to plain LSBs are found too
+		if _, _, err = s.readEnvelopeHeader(adaptive, 0); err == nil {
+			return adaptive, nil
+		}
+		if _, _, err = s.readEnvelopeHeader(place, 0); err != nil {
+			return adaptive, nil
+		}
+	} else if _, _, err := s.readEnvelopeHeader(place, 0); err != nil {
+		if ordered := s.detectScanOrder(o); ordered != nil {
+			return ordered, nil
+		}
+	}
+	return place, nil
+}
+
+// readEnvelope reads and verifies an envelope from the loaded image at the
+// given placement
+func (s *StegImage) readEnvelope(o *options, place placement) (*envelope, error) {
+	e, dataLen, err := s.readEnvelopeHeader(place, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.readBytes(place, e.headerLen(), dataLen+e.trailerLen())
+	if err != nil {
+		return nil, noPayload(err)
+	}
+	e.data = body[:dataLen]
+	if err = e.verify(body[dataLen:], o); err != nil {
+		s.locateDamage(err, place, e.headerLen(), dataLen)
+		return nil, err
+	}
+	return e, nil
+}
+
+// readEnvelopeHeader reads the envelope header, including any slot name,
+// at the given byte offset
+func (s *StegImage) readEnvelopeHeader(place placement, offset int) (e *envelope, dataLen int, err error) {
+	header, err := s.readBytes(place, offset, envelopeHeaderLen+1)
+	if err != nil {
+		return nil, 0, noPayload(err)
+	}
+	e, dataLen, err = parseEnvelopeHeader(header)
+	if err != nil {
+		return nil, 0, err
+	}
+	if e.flags&flagNamed != 0 {
+		name, err := s.readBytes(place, offset+envelopeHeaderLen+1, int(header[envelopeHeaderLen]))
+		if err != nil {
+			return nil, 0, noPayload(err)
+		}
+		e.name = string(name)
+	}
+	if e.flags&flagPayloadDigest != 0 {
+		if e.digest, err = s.readBytes(place, offset+e.payloadDigestOffset(), payloadDigestLen); err != nil {
+			return nil, 0, noPayload(err)
+		}
+	}
+	if e.flags&flagVisible != 0 {
+		if e.visible, err = s.readBytes(place, offset+e.headerLen()-visibleDigestLen, visibleDigestLen); err != nil {
+			return nil, 0, noPayload(err)
+		}
+	}
+	return e, dataLen, nil
+}
+
+// readBytes reads n bytes from the LSBs of the loaded image, starting at
+// the given byte offset into the embedded bit stream
+func (s *StegImage) readBytes(place placement, offset int, n int) ([]byte, error) {
+	if (offset+n)*8 > place.capacity() {
+		if limited, ok := place.(scanLimitedPlacement); ok && (offset+n)*8 <= limited.placement.capacity() {
+			return nil, ErrScanLimit
+		}
+		return nil, errors.New("read past end of image")
+	}
+	out := make([]byte, n)
+	_, err := io.ReadFull(&lsbReader{img: s.imgLoaded, place: place, offset: offset * 8}, out)
+	return out, err
+}
@@ -0,0 +1,126 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BBytes does a back to back binary payload test
+func TestB2BBytes(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := []byte{0x00, 0xff, 0x10, 0x80, '#', '#'}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes(payloadIn); err != nil {
+		t.Fatal(err)
+	}
+
+	tamperedImg := reloadImage(t, &cleanImg)
+	payloadOut, err := tamperedImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payloadIn, payloadOut) {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestCleanFileExtractBytes tests that ExtractBytes returns ErrNoPayload
+// on an image without an embedded payload
+func TestCleanFileExtractBytes(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cleanImg.ExtractBytes(); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// reloadImage writes the manipulated image out and loads it back in, as
+// an extracting party would see it
+func reloadImage(t *testing.T, s *StegImage) *StegImage {
+	t.Helper()
+	b64, err := s.WriteNewImageToB64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloaded StegImage
+	if err = reloaded.LoadImageFromB64(b64); err != nil {
+		t.Fatal(err)
+	}
+	return &reloaded
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+// TestCorruptPayload verifies a bit flipped in the stored data is caught
+// by the CRC32 rather than returned
+func TestCorruptPayload(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	// Flip the first data bit, just after the envelope header
+	place := newSequentialPlacement(cleanImg.newImg)
+	x, y, ch, plane := place.locate(envelopeHeaderLen * 8)
+	setBit(cleanImg.newImg, x, y, ch, plane, getBit(cleanImg.newImg, x, y, ch, plane)^1)
+
+	stego := reloadImage(t, &cleanImg)
+	if _, err := stego.ExtractBytes(); err != ErrPayloadCorrupt {
+		t.Error("Correct Error not thrown, expected: '" + ErrPayloadCorrupt.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := stego.ExtractTo(new(bytes.Buffer)); err != ErrPayloadCorrupt {
+		t.Error("Correct Error not thrown, expected: '" + ErrPayloadCorrupt.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestEnvelopeFlagsDisjoint verifies no two envelope flags share a bit
+func TestEnvelopeFlagsDisjoint(t *testing.T) {
+	t.Parallel()
+
+	flags := map[string]uint16{
+		"flagChecksum":      flagChecksum,
+		"flagEncrypted":     flagEncrypted,
+		"flagMetadata":      flagMetadata,
+		"flagHMAC":          flagHMAC,
+		"flagSigned":        flagSigned,
+		"flagRecipients":    flagRecipients,
+		"flagNamed":         flagNamed,
+		"flagVisible":       flagVisible,
+		"flagBlockSums":     flagBlockSums,
+		"flagTextForm":      flagTextForm,
+		"flagTextNewlines":  flagTextNewlines,
+		"flagPayloadDigest": flagPayloadDigest,
+		"flagScanOrder":     flagScanOrder,
+	}
+	var used uint16
+	for name, flag := range flags {
+		if used&flag != 0 {
+			t.Errorf("%s overlaps another envelope flag", name)
+		}
+		used |= flag
+	}
+}
@@ -0,0 +1,202 @@
+package libsteg
+
+import (
+	"image"
+	"math"
+)
+
+// evaluationPassphrase encrypts the payload in every evaluated mode, so
+// the embedded bits look like they would in real use
+const evaluationPassphrase = "libsteg evaluation"
+
+// evaluationMode is an embedding algorithm compared by EvaluateModes
+type evaluationMode struct {
+	name string
+	opts []Option
+}
+
+// evaluationModes lists the algorithms EvaluateModes compares
+var evaluationModes = []evaluationMode{
+	{name: "lsb"},
+	{name: "adaptive", opts: []Option{WithAdaptiveDepth()}},
+}
+
+// ModeReport holds the rate-distortion figures for one embedding mode
+type ModeReport struct {
+	Mode      string
+	Used      int     // Bytes written, including envelope overhead
+	Capacity  int     // Bytes the mode could have written
+	Rate      float64 // Used / Capacity
+	PSNR      float64 // Peak signal to noise ratio against the cover, in dB
+	SSIM      float64 // Structural similarity to the cover, 1 if identical
+	ChiSquare float64 // Chi-square detector's probability the image carries LSB data
+	Err       error   // Set if the mode couldn't embed the payload
+}
+
+// EvaluateModes embeds payload into a copy of the loaded image with each
+// available algorithm and reports capacity used, distortion and detector
+// score side by side. The loaded image is left untouched.
+func (s *StegImage) EvaluateModes(payload []byte) ([]ModeReport, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+
+	reports := make([]ModeReport, 0, len(evaluationModes))
+	for _, mode := range evaluationModes {
+		report := ModeReport{Mode: mode.name}
+		trial := StegImage{imgLoaded: s.imgLoaded}
+		opts := append([]Option{
+			WithPassphrase(evaluationPassphrase),
+			WithCapacityWarning(0, func(w CapacityWarning) {
+				report.Used, report.Capacity, report.Rate = w.Used, w.Capacity, w.Ratio
+			}),
+		}, mode.opts...)
+		if report.Err = trial.EmbedBytes(payload, opts...); report.Err == nil {
+			report.PSNR = PSNR(s.imgLoaded, trial.newImg)
+			report.SSIM = SSIM(s.imgLoaded, trial.newImg)
+			report.ChiSquare = ChiSquareScore(trial.newImg)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// PSNR returns the peak signal to noise ratio of b against a over their
+// RGB channels on an 8-bit scale, +Inf if they're identical. The images
+// must have the same bounds.
+func PSNR(a image.Image, b image.Image) float64 {
+	bounds := a.Bounds()
+	var sum float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, _ := a.At(x, y).RGBA()
+			br, bg, bb, _ := b.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(ar) - float64(br), float64(ag) - float64(bg), float64(ab) - float64(bb),
+			} {
+				d /= 0x101
+				sum += d * d
+			}
+		}
+	}
+	mse := sum / float64(bounds.Dx()*bounds.Dy()*3)
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM returns the mean structural similarity of the luma of b against a,
+// over non-overlapping 8x8 windows. The images must have the same bounds.
+func SSIM(a image.Image, b image.Image) float64 {
+	const (
+		window = 8
+		c1     = (0.01 * 255) * (0.01 * 255)
+		c2     = (0.03 * 255) * (0.03 * 255)
+	)
+	bounds := a.Bounds()
+	var total float64
+	var windows int
+	for wy := bounds.Min.Y; wy < bounds.Max.Y; wy += window {
+		for wx := bounds.Min.X; wx < bounds.Max.X; wx += window {
+			r := image.Rect(wx, wy, wx+window, wy+window).Intersect(bounds)
+			n := float64(r.Dx() * r.Dy())
+			var sa, sb, saa, sbb, sab float64
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					la, lb := luma(a.At(x, y)), luma(b.At(x, y))
+					sa, sb = sa+la, sb+lb
+					saa, sbb, sab = saa+la*la, sbb+lb*lb, sab+la*lb
+				}
+			}
+			ma, mb := sa/n, sb/n
+			va, vb, cov := saa/n-ma*ma, sbb/n-mb*mb, sab/n-ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// ChiSquareScore runs the Westfeld-Pfitzmann chi-square attack over the
+// RGB channels of img. Embedding random bits in LSBs evens out the counts
+// of each pair of values differing only in their LSB; the score is the
+// probability that the observed counts are that even, near 1 for fully
+// embedded images and near 0 for natural ones.
+func ChiSquareScore(img image.Image) float64 {
+	var hist [256]float64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			hist[r>>8]++
+			hist[g>>8]++
+			hist[b>>8]++
+		}
+	}
+
+	var chi float64
+	dof := -1
+	for k := 0; k < 256; k += 2 {
+		expected := (hist[k] + hist[k+1]) / 2
+		if expected == 0 {
+			continue
+		}
+		d := hist[k] - expected
+		chi += d * d / expected
+		dof++
+	}
+	if dof < 1 {
+		return 0
+	}
+	return 1 - regularizedGammaP(float64(dof)/2, chi/2)
+}
+
+// regularizedGammaP returns the regularised lower incomplete gamma function
+// P(a, x), the chi-square CDF with 2a degrees of freedom at 2x
+func regularizedGammaP(a float64, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lg, _ := math.Lgamma(a)
+	if x < a+1 {
+		// Series expansion
+		sum, term := 1/a, 1/a
+		for n := 1; n < 500; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if term < sum*1e-14 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lg)
+	}
+	// Continued fraction for Q(a, x), by the modified Lentz method
+	const tiny = 1e-300
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for n := 1; n < 500; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-14 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lg)*h
+}
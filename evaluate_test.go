@@ -0,0 +1,74 @@
+package libsteg
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestEvaluateModes verifies every mode reports sane figures for a payload
+// that fits
+func TestEvaluateModes(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	reports, err := cleanImg.EvaluateModes([]byte(secretStringIn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != len(evaluationModes) {
+		t.Fatalf("Expected %d reports, got %d", len(evaluationModes), len(reports))
+	}
+	for _, r := range reports {
+		if r.Err != nil {
+			t.Errorf("Mode %s failed: %v", r.Mode, r.Err)
+			continue
+		}
+		if r.Used <= 0 || r.Capacity < r.Used || r.PSNR < 40 || r.SSIM < 0.95 || r.SSIM > 1 {
+			t.Errorf("Unexpected report: %+v", r)
+		}
+	}
+	if cleanImg.newImg != nil {
+		t.Error("Evaluation changed the loaded image")
+	}
+}
+
+// TestChiSquareScore verifies the detector separates a smooth cover from
+// one with every LSB randomised
+func TestChiSquareScore(t *testing.T) {
+	t.Parallel()
+
+	cover := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			// Only even values, as after some colour quantisation
+			cover.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+	stego := image.NewRGBA(cover.Bounds())
+	copy(stego.Pix, cover.Pix)
+	rng := rand.New(rand.NewSource(1))
+	for i := range stego.Pix {
+		if i%4 != 3 {
+			stego.Pix[i] = stego.Pix[i]&^1 | byte(rng.Intn(2))
+		}
+	}
+
+	if score := ChiSquareScore(cover); score > 0.1 {
+		t.Errorf("Cover scored %f", score)
+	}
+	if score := ChiSquareScore(stego); score < 0.9 {
+		t.Errorf("Stego image scored %f", score)
+	}
+	if !math.IsInf(PSNR(cover, cover), 1) || SSIM(cover, cover) != 1 {
+		t.Error("Identical images not reported as such")
+	}
+}
@@ -0,0 +1,136 @@
+package libsteg
+
+import (
+	"errors"
+	"io"
+)
+
+// ExistingPayloadPolicy decides what happens when embedding into an image
+// that already carries a libsteg envelope
+type ExistingPayloadPolicy int
+
+// Existing payload policies
+const (
+	// RefuseExisting fails the embed with ErrPayloadExists
+	RefuseExisting ExistingPayloadPolicy = iota
+	// OverwriteExisting replaces the existing payloads
+	OverwriteExisting
+	// AppendToExisting adds the payload after the existing ones, see
+	// ExtractAll
+	AppendToExisting
+)
+
+// ErrPayloadExists is returned when embedding into an image that already
+// carries a payload under the RefuseExisting policy
+var ErrPayloadExists = errors.New("image already contains an embedded payload")
+
+// WithExistingPayload sets the policy for images that already carry a
+// payload. The default is RefuseExisting, as a second embed would
+// otherwise silently corrupt the first.
+func WithExistingPayload(policy ExistingPayloadPolicy) Option {
+	return func(o *options) {
+		o.existing = policy
+	}
+}
+
+// ExtractAll retrieves every payload chained into the image with
+// AppendToExisting, in the order they were embedded
+func (s *StegImage) ExtractAll(opts ...Option) (payloads [][]byte, err error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+
+	o := newOptions(opts)
+	offsets, _ := s.envelopeOffsets()
+	for _, offset := range offsets {
+		place := shiftedPlacement{newSequentialPlacement(s.imgLoaded), offset * 8}
+		e, err := s.readEnvelope(o, place)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+	}
+	if len(payloads) == 0 {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	return payloads, nil
+}
+
+// envelopeOffsets walks the chain of envelopes at the start of the loaded
+// image, returning the byte offset of each one and the offset just past
+// the last
+func (s *StegImage) envelopeOffsets() (offsets []int, end int) {
+	place := newSequentialPlacement(s.imgLoaded)
+	for {
+		e, dataLen, err := s.readEnvelopeHeader(place, end)
+		if err != nil {
+			return offsets, end
+		}
+		offsets = append(offsets, end)
+		end += e.headerLen() + dataLen + e.trailerLen()
+	}
+}
+
+// existingPlacement applies the existing payload policy, returning where
+// the new envelope should be written
+func (s *StegImage) existingPlacement(o *options) (placement, error) {
+	place := newSequentialPlacement(s.newImg)
+	target, err := newOrderedPlacement(s.newImg, o.scanOrder)
+	if err != nil {
+		return nil, err
+	}
+	offsets, end := s.envelopeOffsets()
+	if len(offsets) == 0 {
+		// A payload in another scan order is never chained onto
+		ordered := s.detectScanOrder(o)
+		if ordered == nil {
+			return target, nil
+		}
+		switch o.existing {
+		case OverwriteExisting:
+			s.logger().Warning("Overwriting existing payload in another scan order")
+			noise := make([]byte, envelopeHeaderLen)
+			if _, err := io.ReadFull(o.rand, noise); err != nil {
+				return nil, err
+			}
+			if _, err := (&lsbWriter{img: s.newImg, place: ordered}).Write(noise); err != nil {
+				return nil, err
+			}
+			return target, nil
+		case AppendToExisting:
+			return nil, errScanOrderAppend
+		}
+		s.logger().Warning("Refusing to embed over existing payload in another scan order")
+		return nil, ErrPayloadExists
+	}
+
+	switch o.existing {
+	case OverwriteExisting:
+		// Scrub the old chain so no trailing envelope outlives the new one
+		s.logger().Warning("Overwriting", len(offsets), "existing payload(s)")
+		noise := make([]byte, end)
+		if _, err := io.ReadFull(o.rand, noise); err != nil {
+			return nil, err
+		}
+		if _, err := (&lsbWriter{img: s.newImg, place: place}).Write(noise); err != nil {
+			return nil, err
+		}
+		return target, nil
+	case AppendToExisting:
+		if o.scanOrder != ScanColumnMajor {
+			return nil, errScanOrderAppend
+		}
+		s.logger().Warning("Appending after", len(offsets), "existing payload(s)")
+		return shiftedPlacement{place, end * 8}, nil
+	}
+	s.logger().Warning("Refusing to embed over", len(offsets), "existing payload(s)")
+	return nil, ErrPayloadExists
+}
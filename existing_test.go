@@ -0,0 +1,50 @@
+package libsteg
+
+import (
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestExistingPayloadPolicies verifies a second embed is refused by
+// default and can be appended or overwritten on request
+func TestExistingPayloadPolicies(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+
+	if err := stegImg.EmbedBytes([]byte("second")); err != ErrPayloadExists {
+		t.Error("Correct Error not thrown, expected: '" + ErrPayloadExists.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	if err := stegImg.EmbedBytes([]byte("second"), WithExistingPayload(AppendToExisting)); err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := reloadImage(t, stegImg).ExtractAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 2 || string(payloads[0]) != "first" || string(payloads[1]) != "second" {
+		t.Errorf("Appended Payloads Do Not Match! got %q", payloads)
+	}
+
+	stegImg = reloadImage(t, stegImg)
+	if err = stegImg.EmbedBytes([]byte("3"), WithExistingPayload(OverwriteExisting)); err != nil {
+		t.Fatal(err)
+	}
+	payloads, err = reloadImage(t, stegImg).ExtractAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 1 || string(payloads[0]) != "3" {
+		t.Errorf("Overwritten Payloads Do Not Match! got %q", payloads)
+	}
+}
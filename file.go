@@ -0,0 +1,132 @@
+package libsteg
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The file based API is kept apart from the core, which only works on
+// readers and writers so it also runs where there's no file system, such
+// as GOOS=js GOARCH=wasm in a browser
+
+// WritableFS is a file system images can be written to as well as read
+// from. Paths are slash separated and unrooted, as for fs.FS.
+type WritableFS interface {
+	fs.FS
+	Create(name string) (io.WriteCloser, error)
+}
+
+// removeFS is implemented by file systems that can remove files, used to
+// clean up partial output
+type removeFS interface {
+	Remove(name string) error
+}
+
+// DirFS returns a WritableFS for the directory tree rooted at dir
+func DirFS(dir string) WritableFS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(d)).Open(name)
+}
+
+func (d dirFS) Create(name string) (io.WriteCloser, error) {
+	path, err := d.join("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (d dirFS) Remove(name string) error {
+	path, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// join turns the fs style name into an OS path under d
+func (d dirFS) join(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
+
+// osFS opens OS paths directly, so they keep working where a WritableFS
+// is expected
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.FromSlash(name))
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(filepath.FromSlash(name))
+}
+
+// LoadImageFromFile loads the given image into the StegImage structure.
+// Slash separated paths work on every platform.
+func (s *StegImage) LoadImageFromFile(imgPath string) error {
+	reader, err := os.Open(filepath.FromSlash(imgPath))
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	defer reader.Close()
+	return s.loadImage(reader)
+}
+
+// LoadImageFromFS loads the image at path in fsys, such as an embed.FS or
+// a zip archive, into the StegImage structure
+func (s *StegImage) LoadImageFromFS(fsys fs.FS, path string) error {
+	reader, err := fsys.Open(path)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	defer reader.Close()
+	return s.loadImage(reader)
+}
+
+// WriteNewImageToFile outputs the image held in StegImage.newImg to
+// the imgPath given
+func (s *StegImage) WriteNewImageToFile(imgPath string) (err error) {
+	myfile, err := os.Create(filepath.FromSlash(imgPath))
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	defer myfile.Close()
+
+	return s.WriteNewImage(myfile)
+}
+
+// WriteNewImageToFS outputs the image held in StegImage.newImg to path in
+// fsys
+func (s *StegImage) WriteNewImageToFS(fsys WritableFS, path string) error {
+	w, err := fsys.Create(path)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if err = s.WriteNewImage(w); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package libsteg
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestWriteNewImageToFS writes through DirFS and reads the image back,
+// rejecting paths that escape the root
+func TestWriteNewImageToFS(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	fsys := DirFS(dir)
+	if err := cleanImg.WriteNewImageToFS(fsys, "../escape.png"); !errors.Is(err, fs.ErrInvalid) {
+		t.Error("Correct Error not thrown, expected: '" + errString(fs.ErrInvalid) + "' got: '" + errString(err) + "'")
+	}
+	if err := cleanImg.WriteNewImageToFS(fsys, "out/stego.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "out/stego.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stego StegImage
+	if err = stego.LoadImage(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stego.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestBatchFS runs a batch against a WritableFS rather than OS paths
+func TestBatchFS(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	dir := t.TempDir()
+	cover, err := ioutil.ReadFile(tinyImageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "cover.png"), cover, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := &Batch{FS: DirFS(dir)}
+	_, err = batch.Run([]BatchJob{
+		{Carrier: "cover.png", Payload: []byte(secretStringIn), Output: "stego.png"},
+		{Carrier: "missing.png", Payload: []byte(secretStringIn), Output: "failed.png"},
+	})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || !errors.Is(batchErr.Failed[0].Err, fs.ErrNotExist) {
+		t.Fatalf("Expected the missing cover to fail, got: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "failed.png")); !os.IsNotExist(err) {
+		t.Error("Output left behind by a failed job")
+	}
+
+	f, err := os.Open(filepath.Join(dir, "stego.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	payloadOut, err := ExtractCarrier(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestLoadImageFromFS loads a cover from a zip archive and a stego image
+// from an in memory file system
+func TestLoadImageFromFS(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover, err := ioutil.ReadFile(tinyImageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := new(bytes.Buffer)
+	zw := zip.NewWriter(archive)
+	w, err := zw.Create("covers/tiny.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(cover)
+	zw.Close()
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cleanImg StegImage
+	if err = cleanImg.LoadImageFromFS(zr, "covers/missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("Correct Error not thrown, expected: '" + errString(fs.ErrNotExist) + "' got: '" + errString(err) + "'")
+	}
+	if err = cleanImg.LoadImageFromFS(zr, "covers/tiny.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err = cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures := fstest.MapFS{"stego.png": &fstest.MapFile{Data: pngBytes(t, &cleanImg)}}
+	var stego StegImage
+	if err = stego.LoadImageFromFS(fixtures, "stego.png"); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stego.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
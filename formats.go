@@ -0,0 +1,91 @@
+package libsteg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrFormatNotAllowed is returned when a loader is given an image in a
+// format excluded by SetDecodeFormats
+var ErrFormatNotAllowed = errors.New("image format not allowed")
+
+// formatMagic identifies the formats SetDecodeFormats can allow, by the
+// names they register with the image package. '?' matches any byte.
+var formatMagic = []struct {
+	name  string
+	magic string
+}{
+	{"png", "\x89PNG\r\n\x1a\n"},
+	{"gif", "GIF87a"},
+	{"gif", "GIF89a"},
+	{"jpeg", "\xff\xd8"},
+	{"bmp", "BM????\x00\x00\x00\x00"},
+	{"tiff", "II*\x00"},
+	{"tiff", "MM\x00*"},
+	{"webp", "RIFF????WEBPVP8"},
+}
+
+var (
+	decodeFormatsMu sync.RWMutex
+	decodeFormats   map[string]bool // nil allows every registered format
+)
+
+// SetDecodeFormats restricts the loaders to decoding the named formats,
+// e.g. "png" and "bmp", so services that should never accept other inputs
+// don't expose those decoders. Inputs are checked by their magic bytes
+// before any decoder sees them. With no formats given every registered
+// format is allowed again.
+func SetDecodeFormats(formats ...string) {
+	decodeFormatsMu.Lock()
+	defer decodeFormatsMu.Unlock()
+	if len(formats) == 0 {
+		decodeFormats = nil
+		return
+	}
+	decodeFormats = make(map[string]bool, len(formats))
+	for _, format := range formats {
+		decodeFormats[strings.ToLower(format)] = true
+	}
+}
+
+// checkDecodeFormat returns a reader over the same data as r after
+// checking it holds an allowed format
+func checkDecodeFormat(r io.Reader) (io.Reader, error) {
+	decodeFormatsMu.RLock()
+	allowed := decodeFormats
+	decodeFormatsMu.RUnlock()
+	if allowed == nil {
+		return r, nil
+	}
+
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(16)
+	if format := sniffFormat(head); format == "" || !allowed[format] {
+		return nil, ErrFormatNotAllowed
+	}
+	return br, nil
+}
+
+// sniffFormat returns the name of the format head starts with, or "" if
+// it isn't one SetDecodeFormats knows
+func sniffFormat(head []byte) string {
+	for _, f := range formatMagic {
+		if len(head) < len(f.magic) {
+			continue
+		}
+		match := true
+		for i := 0; i < len(f.magic); i++ {
+			if f.magic[i] != '?' && f.magic[i] != head[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return f.name
+		}
+	}
+	return ""
+}
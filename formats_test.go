@@ -0,0 +1,52 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestSniffFormat verifies the magic byte table
+func TestSniffFormat(t *testing.T) {
+	t.Parallel()
+
+	for head, want := range map[string]string{
+		"\x89PNG\r\n\x1a\n\x00\x00":          "png",
+		"GIF89a\x01\x00":                     "gif",
+		"\xff\xd8\xff\xe0":                   "jpeg",
+		"BM\x10\x00\x00\x00\x00\x00\x00\x00": "bmp",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ":       "webp",
+		"MM\x00*":                            "tiff",
+		"<svg>":                              "",
+		"":                                   "",
+	} {
+		if got := sniffFormat([]byte(head)); got != want {
+			t.Errorf("Sniffed %q as %q, expected %q", head, got, want)
+		}
+	}
+}
+
+// TestSetDecodeFormats verifies loaders refuse formats outside the allowed
+// set. Not parallel, as the setting is package wide.
+func TestSetDecodeFormats(t *testing.T) {
+	SetLoggingLevel(logging.Level(*loggingLevel))
+	defer SetDecodeFormats()
+
+	png := stegPNG(t, secretStringIn)
+	var img StegImage
+
+	SetDecodeFormats("gif", "jpeg")
+	err := img.loadImage(bytes.NewReader(png))
+	if err != ErrFormatNotAllowed {
+		t.Error("Correct Error not thrown, expected: '" + ErrFormatNotAllowed.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	SetDecodeFormats("PNG", "bmp")
+	if err := img.loadImage(bytes.NewReader(png)); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := img.ExtractBytes(); err != nil || string(payload) != secretStringIn {
+		t.Errorf("Extract after restricted load failed: %v", err)
+	}
+}
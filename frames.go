@@ -0,0 +1,123 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// frameChunkHeaderLen is the chunk index and chunk count stored at the
+// start of each frame's envelope
+const frameChunkHeaderLen = 2 + 2
+
+// errFrameChunksMissing is returned when a multi-frame payload is missing
+// some of its chunks
+var errFrameChunksMissing = errors.New("payload chunks missing from frames")
+
+// embedFrames seals the payload read from r and spreads it over the
+// frames, one envelope per frame holding a chunk of the stored payload
+// behind a chunk header. Frames too small to hold a chunk, or past the end
+// of the payload, are returned as given, so a paletted frame only has its
+// palette rewritten when it carries a chunk.
+func embedFrames(frames []image.Image, r io.Reader, o *options) ([]draw.Image, error) {
+	stored, err := sealPayload(r, o, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks only need a checksum, the stored payload is already sealed
+	frameOpts := newOptions(nil)
+	frameOpts.rand = o.rand
+	frameOverhead := envelopeHeaderLen + frameChunkHeaderLen + 4
+
+	out := make([]draw.Image, len(frames))
+	var chunks [][]byte
+	var used []int
+	for i, frame := range frames {
+		s := StegImage{imgLoaded: frame}
+		if err = s.createMutableImage(); err != nil {
+			return nil, err
+		}
+		out[i] = s.newImg
+		room := newSequentialPlacement(s.newImg).capacity()/8 - frameOverhead
+		if room <= 0 || len(stored) == 0 {
+			if d, ok := frame.(draw.Image); ok {
+				out[i] = d
+			}
+			continue
+		}
+		if room > len(stored) {
+			room = len(stored)
+		}
+		chunks = append(chunks, stored[:room])
+		used = append(used, i)
+		stored = stored[room:]
+	}
+	if len(stored) > 0 {
+		return nil, ErrInsufficientCapacity
+	}
+	if len(chunks) > 0xffff {
+		return nil, errors.New("payload spread over too many frames")
+	}
+
+	for c, chunk := range chunks {
+		data := make([]byte, frameChunkHeaderLen, frameChunkHeaderLen+len(chunk))
+		binary.BigEndian.PutUint16(data[0:2], uint16(c))
+		binary.BigEndian.PutUint16(data[2:4], uint16(len(chunks)))
+		data = append(data, chunk...)
+
+		s := StegImage{newImg: out[used[c]]}
+		err = s.writeEnvelope(bytes.NewReader(data), flagChecksum, frameOpts, newSequentialPlacement(s.newImg))
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Notice("Spread payload over", len(chunks), "of", len(frames), "frames")
+	return out, nil
+}
+
+// extractFrames reassembles and opens a payload spread over frames with
+// embedFrames
+func extractFrames(frames []image.Image, o *options) ([]byte, error) {
+	type chunk struct {
+		index int
+		data  []byte
+	}
+	var chunks []chunk
+	count := -1
+	frameOpts := newOptions(nil)
+	for _, frame := range frames {
+		s := StegImage{imgLoaded: frame}
+		e, err := s.readEnvelope(frameOpts, newSequentialPlacement(frame))
+		if err != nil || len(e.data) < frameChunkHeaderLen {
+			continue
+		}
+		index := int(binary.BigEndian.Uint16(e.data[0:2]))
+		total := int(binary.BigEndian.Uint16(e.data[2:4]))
+		if count >= 0 && total != count {
+			continue
+		}
+		count = total
+		chunks = append(chunks, chunk{index, e.data[frameChunkHeaderLen:]})
+	}
+	if count < 0 {
+		return nil, ErrNoPayload
+	}
+	if len(chunks) != count {
+		return nil, errFrameChunksMissing
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].index < chunks[j].index })
+	var stored []byte
+	for i, c := range chunks {
+		if c.index != i {
+			return nil, errFrameChunksMissing
+		}
+		stored = append(stored, c.data...)
+	}
+	return openPayload(stored, o)
+}
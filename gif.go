@@ -0,0 +1,61 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"io"
+)
+
+// EmbedGIF embeds payload into the animated GIF read from r, spreading it
+// over as many frames as it needs, and writes the result to w. Each frame
+// carries its chunk in palette index parity, so the GIF keeps its palettes
+// and timing. Compression, passphrase and recipient options apply to the
+// payload as a whole.
+func EmbedGIF(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	frames := make([]image.Image, len(g.Image))
+	for i, frame := range g.Image {
+		frames[i] = frame
+	}
+	out, err := embedFrames(frames, bytes.NewReader(payload), newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	for i := range g.Image {
+		g.Image[i] = out[i].(*image.Paletted)
+	}
+
+	if err = gif.EncodeAll(w, g); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ExtractGIF retrieves a payload embedded with EmbedGIF from the animated
+// GIF read from r
+func ExtractGIF(r io.Reader, opts ...Option) ([]byte, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	frames := make([]image.Image, len(g.Image))
+	for i, frame := range g.Image {
+		frames[i] = frame
+	}
+	payload, err := extractFrames(frames, newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
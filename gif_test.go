@@ -0,0 +1,111 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testGIF builds an animated GIF whose frames have a transparent palette
+// entry, as is common for animations
+func testGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+	pal := color.Palette{color.RGBA{}}
+	for i := 0; i < 16; i++ {
+		pal = append(pal, color.RGBA{uint8(i * 16), uint8(255 - i*16), 128, 255})
+	}
+	g := &gif.GIF{}
+	for f := 0; f < frames; f++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 24, 24), pal)
+		for i := range frame.Pix {
+			frame.Pix[i] = uint8((i + f) % len(pal))
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestB2BGIF verifies a payload larger than one frame round trips across
+// an animated GIF without touching transparent pixels
+func TestB2BGIF(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover := testGIF(t, 6)
+	payloadIn := strings.Repeat(secretStringIn, 6)
+	opts := []Option{WithPassphrase("pass")}
+	out := new(bytes.Buffer)
+	if err := EmbedGIF(bytes.NewReader(cover), out, []byte(payloadIn), opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	payloadOut, err := ExtractGIF(bytes.NewReader(out.Bytes()), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != payloadIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	before, _ := gif.DecodeAll(bytes.NewReader(cover))
+	after, err := gif.DecodeAll(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Image) != len(before.Image) || after.Delay[0] != 10 {
+		t.Fatal("Animation frames or timing changed")
+	}
+	for f := range before.Image {
+		for i := range before.Image[f].Pix {
+			x, y := i%24, i/24
+			wasClear := isTransparent(before.Image[f].At(x, y))
+			if wasClear != isTransparent(after.Image[f].At(x, y)) {
+				t.Fatalf("Transparency changed at frame %d (%d,%d)", f, x, y)
+			}
+		}
+	}
+
+	if err = EmbedGIF(bytes.NewReader(cover), new(bytes.Buffer), []byte(strings.Repeat(payloadIn, 100))); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestGIFUntouchedFrames verifies frames past the end of the payload keep
+// their palette and pixels
+func TestGIFUntouchedFrames(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover := testGIF(t, 4)
+	out := new(bytes.Buffer)
+	if err := EmbedGIF(bytes.NewReader(cover), out, []byte(secretStringIn), WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+
+	before, _ := gif.DecodeAll(bytes.NewReader(cover))
+	after, err := gif.DecodeAll(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := len(before.Image) - 1
+	if !reflect.DeepEqual(before.Image[last].Palette, after.Image[last].Palette) {
+		t.Error("Palette of a frame without payload was rewritten")
+	}
+	if !bytes.Equal(before.Image[last].Pix, after.Image[last].Pix) {
+		t.Error("Pixels of a frame without payload changed")
+	}
+	if len(out.Bytes()) > len(cover)+len(cover)/2 {
+		t.Errorf("Stego GIF grew from %d to %d bytes", len(cover), len(out.Bytes()))
+	}
+}
@@ -0,0 +1,73 @@
+package libsteg
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+)
+
+const (
+	// hmacSaltLen is the length of the salt used to derive the HMAC key
+	hmacSaltLen = 16
+	// hmacTrailerLen is the salt followed by the HMAC-SHA256 sum
+	hmacTrailerLen = hmacSaltLen + sha256.Size
+)
+
+var (
+	// ErrHMACMismatch is returned when a payload fails HMAC verification,
+	// meaning it was tampered with, corrupted or the passphrase is wrong
+	ErrHMACMismatch = errors.New("payload failed hmac verification")
+	errHMACNoKey    = errors.New("hmac requires a passphrase")
+)
+
+// WithHMAC appends an HMAC-SHA256 over the envelope keyed by the
+// passphrase, so extraction rejects tampered or partially corrupted
+// payloads instead of returning garbage. It requires WithPassphrase. Given
+// on extraction, payloads stored without an HMAC fail with
+// ErrHMACMismatch, so one can't be stripped off.
+func WithHMAC() Option {
+	return func(o *options) {
+		o.hmac = true
+	}
+}
+
+// newPayloadMAC returns an HMAC keyed from the passphrase and salt. The
+// key is derived separately from the encryption key so the two never
+// coincide.
+func newPayloadMAC(passphrase string, salt []byte) (hash.Hash, error) {
+	key, err := pbkdf2.Key(sha256.New, "hmac:"+passphrase, salt, kdfIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return hmac.New(sha256.New, key), nil
+}
+
+// newHMACSalt returns a random salt for newPayloadMAC
+func newHMACSalt(rng io.Reader) ([]byte, error) {
+	salt := make([]byte, hmacSaltLen)
+	_, err := io.ReadFull(rng, salt)
+	return salt, err
+}
+
+// verifyHMAC checks the HMAC trailer against the envelope header and the
+// data read from data
+func verifyHMAC(trailer []byte, header []byte, data io.Reader, passphrase string) error {
+	if passphrase == "" {
+		return errPassphraseRequired
+	}
+	mac, err := newPayloadMAC(passphrase, trailer[:hmacSaltLen])
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(mac, data); err != nil {
+		return err
+	}
+	mac.Write(header)
+	if !hmac.Equal(mac.Sum(nil), trailer[hmacSaltLen:]) {
+		return ErrHMACMismatch
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestHMACTamper verifies an HMAC protected payload round trips and that
+// flipping a single stored bit is rejected
+func TestHMACTamper(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithPassphrase("hunter2"), WithHMAC()); err != nil {
+		t.Fatal(err)
+	}
+
+	payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	// Flip the LSB of the first red value after the envelope header
+	x, y := 0, envelopeHeaderLen*8/3
+	img := cleanImg.newImg.(*image.RGBA)
+	c := img.RGBAAt(x, y)
+	c.R ^= 1
+	img.SetRGBA(x, y, c)
+
+	_, err = reloadImage(t, &cleanImg).ExtractBytes(WithPassphrase("hunter2"))
+	if err != ErrHMACMismatch {
+		t.Error("Correct Error not thrown, expected: '" + ErrHMACMismatch.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestHMACRequiresPassphrase verifies WithHMAC can't be used unkeyed
+func TestHMACRequiresPassphrase(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithHMAC()); err != errHMACNoKey {
+		t.Error("Correct Error not thrown, expected: '" + errHMACNoKey.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestHMACDowngrade verifies a payload stored without the HMAC or
+// encryption the extraction asks for is rejected, rather than passed off as
+// authentic
+func TestHMACDowngrade(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte("forged")); err != nil {
+		t.Fatal(err)
+	}
+	forged := reloadImage(t, &cleanImg)
+	for _, tc := range []struct {
+		opts []Option
+		err  error
+	}{
+		{[]Option{WithPassphrase("secret"), WithHMAC()}, ErrHMACMismatch},
+		{[]Option{WithPassphrase("secret")}, ErrNotEncrypted},
+	} {
+		if _, err := forged.ExtractBytes(tc.opts...); err != tc.err {
+			t.Error("Correct Error not thrown, expected: '" + tc.err.Error() + "' got: '" + errString(err) + "'")
+		}
+		var buf bytes.Buffer
+		if _, err := forged.ExtractTo(&buf, tc.opts...); err != tc.err {
+			t.Error("Correct Error not thrown, expected: '" + tc.err.Error() + "' got: '" + errString(err) + "'")
+		}
+	}
+
+	// An encrypted payload without an HMAC opens only without WithHMAC
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithPassphrase("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloadImage(t, &cleanImg).ExtractBytes(WithPassphrase("secret"), WithHMAC()); err != ErrHMACMismatch {
+		t.Error("Correct Error not thrown, expected: '" + ErrHMACMismatch.Error() + "' got: '" + errString(err) + "'")
+	}
+	if payload, err := reloadImage(t, &cleanImg).ExtractBytes(WithPassphrase("secret")); err != nil || string(payload) != secretStringIn {
+		t.Errorf("Encrypted payload not extracted: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+)
+
+var (
+	errInPlaceFormat  = errors.New("in place embedding needs an 8-bit RGBA or NRGBA image")
+	errInPlaceQuality = errors.New("in place embedding leaves no cover for a quality gate to measure")
+)
+
+// WithInPlace embeds straight into the loaded image rather than a copy of
+// it, halving the memory an embed needs. Only *image.RGBA and
+// *image.NRGBA images are supported. The loaded image becomes the stego
+// image, so the caller's cover is changed, and is left part written if the
+// embed fails. It can't be combined with WithQualityGate.
+func WithInPlace() Option {
+	return func(o *options) {
+		o.inPlace = true
+	}
+}
+
+// adoptLoadedImage makes the loaded image the manipulated image, for
+// embedding in place
+func (s *StegImage) adoptLoadedImage() error {
+	switch img := s.imgLoaded.(type) {
+	case nil:
+		return errNoImageLoaded
+	case *image.RGBA:
+		s.newImg = img
+	case *image.NRGBA:
+		s.newImg = img
+	default:
+		return errInPlaceFormat
+	}
+	return nil
+}
+
+// copyPix copies rows bytes wide from src to dst, each stepping on by its
+// own stride
+func copyPix(dst []byte, dstStride int, src []byte, srcStride int, rowBytes int, rows int) {
+	for y := 0; y < rows; y++ {
+		copy(dst[y*dstStride:y*dstStride+rowBytes], src[y*srcStride:y*srcStride+rowBytes])
+	}
+}
@@ -0,0 +1,76 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestInPlace verifies embedding in place writes the payload into the
+// loaded image itself and refuses images it can't mutate
+func TestInPlace(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range cover.Pix {
+		cover.Pix[i] = byte(i * 7)
+	}
+	stegImg := NewStegImageFromImage(cover)
+	if err := stegImg.EmbedBytes([]byte(secretStringIn), WithInPlace()); err != nil {
+		t.Fatal(err)
+	}
+	if stegImg.Stego() != image.Image(cover) {
+		t.Errorf("Stego image %T isn't the cover", stegImg.Stego())
+	}
+	secret, err := NewStegImageFromImage(cover).ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, []byte(secretStringIn)) {
+		t.Errorf("Extracted %q, expected %q", secret, secretStringIn)
+	}
+
+	gray := NewStegImageFromImage(image.NewGray(image.Rect(0, 0, 64, 64)))
+	if err := gray.EmbedBytes([]byte(secretStringIn), WithInPlace()); err != errInPlaceFormat {
+		t.Error("Correct Error not thrown, expected: '" + errInPlaceFormat.Error() + "' got: '" + errString(err) + "'")
+	}
+	err = NewStegImageFromImage(cover).EmbedBytes([]byte(secretStringIn), WithInPlace(), WithQualityGate(40, 0))
+	if err != errInPlaceQuality {
+		t.Error("Correct Error not thrown, expected: '" + errInPlaceQuality.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestCreateMutableImageSubImage checks the row copy of 8-bit images
+// honours sub-image bounds and leaves the source untouched
+func TestCreateMutableImageSubImage(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	full := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range full.Pix {
+		full.Pix[i] = byte(i)
+	}
+	sub := full.SubImage(image.Rect(3, 5, 11, 13)).(*image.RGBA)
+	s := StegImage{imgLoaded: sub}
+	if err := s.createMutableImage(); err != nil {
+		t.Fatal(err)
+	}
+	copied := s.newImg.(*image.RGBA)
+	if copied.Bounds() != sub.Bounds() {
+		t.Fatalf("Copy has bounds %v, expected %v", copied.Bounds(), sub.Bounds())
+	}
+	for y := sub.Rect.Min.Y; y < sub.Rect.Max.Y; y++ {
+		for x := sub.Rect.Min.X; x < sub.Rect.Max.X; x++ {
+			if copied.RGBAAt(x, y) != sub.RGBAAt(x, y) {
+				t.Fatalf("Pixel %d,%d copied as %v, expected %v", x, y, copied.RGBAAt(x, y), sub.RGBAAt(x, y))
+			}
+		}
+	}
+	copied.Pix[0] ^= 1
+	if full.Pix[full.PixOffset(3, 5)] != byte(full.PixOffset(3, 5)) {
+		t.Error("Copy shares pixels with the source")
+	}
+}
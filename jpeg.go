@@ -0,0 +1,158 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	jpegSOI = 0xd8
+	jpegSOS = 0xda
+	jpegCOM = 0xfe
+
+	// jpegSegmentMax is the most data a segment can hold, as its length
+	// field is 16 bits and counts itself
+	jpegSegmentMax = 0xffff - 2
+)
+
+var errBadJPEG = errors.New("not a JPEG file")
+
+// EmbedJPEG hides payload in the JPEG read from r and writes the result to
+// w. The payload is framed in the usual envelope, with the usual options,
+// and stored in consecutive COM segments after any APPn segments, so the
+// compressed image data is copied untouched and every viewer shows the
+// image as before. Tools that strip metadata remove the payload.
+func EmbedJPEG(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	segments, err := jpegSegments(doc)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	o := newOptions(opts)
+	framed, err := marshalEnvelope(bytes.NewReader(payload), flagChecksum, o, log)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// JFIF and Exif readers expect their APPn segments straight after SOI
+	insert := 2
+	for _, seg := range segments {
+		if seg.marker < 0xe0 || seg.marker > 0xef {
+			break
+		}
+		insert = seg.end
+	}
+
+	out := new(bytes.Buffer)
+	out.Write(doc[:insert])
+	for len(framed) > 0 {
+		n := len(framed)
+		if n > jpegSegmentMax {
+			n = jpegSegmentMax
+		}
+		out.Write([]byte{0xff, jpegCOM})
+		binary.Write(out, binary.BigEndian, uint16(n+2))
+		out.Write(framed[:n])
+		framed = framed[n:]
+	}
+	out.Write(doc[insert:])
+
+	if _, err = w.Write(out.Bytes()); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ExtractJPEG retrieves a payload embedded with EmbedJPEG from the JPEG
+// read from r
+func ExtractJPEG(r io.Reader, opts ...Option) ([]byte, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	segments, err := jpegSegments(doc)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	for i, seg := range segments {
+		data := doc[seg.start:seg.end]
+		if seg.marker != jpegCOM || !bytes.HasPrefix(data, []byte(envelopeMagic)) {
+			continue
+		}
+		// Large payloads run on into the following COM segments
+		framed := append([]byte(nil), data...)
+		for _, next := range segments[i+1:] {
+			if next.marker != jpegCOM {
+				break
+			}
+			framed = append(framed, doc[next.start:next.end]...)
+		}
+		e, err := parseEnvelope(framed, o)
+		if err == ErrNoPayload {
+			continue
+		} else if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return payload, nil
+	}
+	log.Error(ErrNoPayload)
+	return nil, ErrNoPayload
+}
+
+// jpegSegment locates a marker segment, start and end bounding its data
+type jpegSegment struct {
+	marker byte
+	start  int
+	end    int
+}
+
+// jpegSegments lists the marker segments of doc up to the start of scan
+func jpegSegments(doc []byte) (segments []jpegSegment, err error) {
+	if len(doc) < 4 || doc[0] != 0xff || doc[1] != jpegSOI {
+		return nil, errBadJPEG
+	}
+	pos := 2
+	for {
+		if pos+4 > len(doc) || doc[pos] != 0xff {
+			return nil, errBadJPEG
+		}
+		// Markers may be preceded by any number of fill bytes
+		for pos+1 < len(doc) && doc[pos+1] == 0xff {
+			pos++
+		}
+		if pos+4 > len(doc) {
+			return nil, errBadJPEG
+		}
+		marker := doc[pos+1]
+		length := int(binary.BigEndian.Uint16(doc[pos+2:]))
+		if length < 2 || pos+2+length > len(doc) {
+			return nil, errBadJPEG
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: pos + 4, end: pos + 2 + length})
+		if marker == jpegSOS {
+			return segments, nil
+		}
+		pos += 2 + length
+	}
+}
@@ -0,0 +1,85 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testJPEG encodes the clean test image as a JPEG, optionally with a JFIF
+// APP0 segment as most encoders write
+func testJPEG(t *testing.T, jfif bool) []byte {
+	t.Helper()
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, cleanImg.imgLoaded, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !jfif {
+		return buf.Bytes()
+	}
+	app0 := []byte("\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	return append(append(buf.Bytes()[:2:2], app0...), buf.Bytes()[2:]...)
+}
+
+// TestB2BJPEG verifies payloads, including ones needing several segments,
+// round trip without changing the decoded image
+func TestB2BJPEG(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, jfif := range []bool{false, true} {
+		for _, payload := range []string{secretStringIn, strings.Repeat(secretStringIn, 40000)} {
+			cover := testJPEG(t, jfif)
+			out := new(bytes.Buffer)
+			if err := EmbedJPEG(bytes.NewReader(cover), out, []byte(payload), WithPassphrase("pass")); err != nil {
+				t.Fatal(err)
+			}
+			payloadOut, err := ExtractJPEG(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payloadOut) != payload {
+				t.Error("Secrets Do Not Match!")
+			}
+			if jfif && !bytes.HasPrefix(out.Bytes(), cover[:20]) {
+				t.Error("JFIF segment no longer follows SOI")
+			}
+
+			before, err := jpeg.Decode(bytes.NewReader(cover))
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := jpeg.Decode(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before.(*image.YCbCr).Y, after.(*image.YCbCr).Y) {
+				t.Error("Decoded image changed")
+			}
+		}
+	}
+}
+
+// TestJPEGErrors verifies non-JPEG input and JPEGs without a payload are
+// rejected
+func TestJPEGErrors(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	err := EmbedJPEG(bytes.NewReader(testPDF()), new(bytes.Buffer), []byte(secretStringIn))
+	if err != errBadJPEG {
+		t.Error("Correct Error not thrown, expected: '" + errBadJPEG.Error() + "' got: '" + errString(err) + "'")
+	}
+	_, err = ExtractJPEG(bytes.NewReader(testJPEG(t, true)))
+	if err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,289 @@
+package libsteg
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+)
+
+func init() {
+	// The formats SetKeepFormat writes back, so LoadImage can read them
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", decodeBMP, decodeBMPConfig)
+	image.RegisterFormat("tiff", "II*\x00", decodeTIFF, decodeTIFFConfig)
+	image.RegisterFormat("tiff", "MM\x00*", decodeTIFF, decodeTIFFConfig)
+}
+
+// ErrFormatLossy is returned when the stego image is to be written in the
+// source's format but that format would destroy the payload, such as a
+// JPEG of an LSB embed
+var ErrFormatLossy = errors.New("source format can't hold the payload losslessly")
+
+var (
+	errKeepFormat = errors.New("stego image can't be written in the source format")
+	errBadBMP     = errors.New("not a supported uncompressed BMP")
+)
+
+// keepFormatJPEGQuality is the quality robust embeds are written at when
+// the source was a JPEG, high enough for each to survive it
+const keepFormatJPEGQuality = 90
+
+// SetKeepFormat writes the new image in the container it was loaded from,
+// rather than always as PNG, since a JPEG that comes back a PNG is
+// suspicious in itself. PNG, BMP and TIFF sources are written losslessly,
+// libsteg registering decoders for uncompressed BMPs and baseline TIFFs;
+// BMP needs an opaque 8-bit RGB image and TIFF is written as a baseline
+// file, without the source's tags, which EmbedTIFF keeps. A GIF source is
+// written as a GIF when the embed left it paletted. A JPEG source can only
+// carry the robust embeds, EmbedDCTWatermark, EmbedSpreadSpectrum and
+// WatermarkEmbed, which are written as a JPEG; for the others writing
+// fails with ErrFormatLossy, and EmbedJPEG is the way to keep a JPEG.
+// Formats libsteg can't write, such as WebP, fail likewise.
+func (s *StegImage) SetKeepFormat(keep bool) {
+	s.keepFormat = keep
+}
+
+// OutputFormat returns the name of the format the new image is written
+// in: "png", or the source's format under SetKeepFormat
+func (s *StegImage) OutputFormat() string {
+	if s.keepFormat && s.imgType != "" {
+		return s.imgType
+	}
+	return "png"
+}
+
+// encodeSourceFormat writes StegImage.newImg in the source's format, for
+// the formats other than PNG
+func (s *StegImage) encodeSourceFormat(w io.Writer) error {
+	switch s.imgType {
+	case "jpeg":
+		if s.robustImg != image.Image(s.newImg) {
+			return ErrFormatLossy
+		}
+		return jpeg.Encode(w, s.newImg, &jpeg.Options{Quality: keepFormatJPEGQuality})
+	case "gif":
+		img, ok := s.newImg.(*image.Paletted)
+		if !ok {
+			return ErrFormatLossy
+		}
+		return gif.Encode(w, img, nil)
+	case "bmp":
+		return writeBMP(w, s.newImg)
+	case "tiff":
+		t, err := newTIFFFile(s.newImg)
+		if err != nil {
+			return err
+		}
+		return t.write(w, s.newImg)
+	}
+	return ErrFormatLossy
+}
+
+// writeBMP writes img as an uncompressed, bottom up, 24-bit BMP. The RGB
+// bytes are written as stored, so only opaque 8-bit RGBA and NRGBA images
+// are accepted.
+func writeBMP(w io.Writer, img image.Image) error {
+	var pix []byte
+	var stride int
+	switch img := img.(type) {
+	case *image.RGBA:
+		if !img.Opaque() {
+			return errKeepFormat
+		}
+		pix, stride = img.Pix, img.Stride
+	case *image.NRGBA:
+		if !img.Opaque() {
+			return errKeepFormat
+		}
+		pix, stride = img.Pix, img.Stride
+	default:
+		return errKeepFormat
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	rowBytes := (width*3 + 3) &^ 3
+	const headerLen = 14 + 40
+	out := make([]byte, headerLen+rowBytes*height)
+
+	// File header, then BITMAPINFOHEADER
+	out[0], out[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(out[2:], uint32(len(out)))
+	binary.LittleEndian.PutUint32(out[10:], headerLen)
+	binary.LittleEndian.PutUint32(out[14:], 40)
+	binary.LittleEndian.PutUint32(out[18:], uint32(width))
+	binary.LittleEndian.PutUint32(out[22:], uint32(height))
+	binary.LittleEndian.PutUint16(out[26:], 1)
+	binary.LittleEndian.PutUint16(out[28:], 24)
+	binary.LittleEndian.PutUint32(out[34:], uint32(rowBytes*height))
+
+	for y := 0; y < height; y++ {
+		src := pix[y*stride:]
+		dst := out[headerLen+(height-1-y)*rowBytes:]
+		for x := 0; x < width; x++ {
+			dst[x*3], dst[x*3+1], dst[x*3+2] = src[x*4+2], src[x*4+1], src[x*4]
+		}
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// newTIFFFile returns a little endian, uncompressed baseline TIFF laid out
+// for img. Paletted images are refused, as converting them would lose a
+// payload held in their indexes.
+func newTIFFFile(img image.Image) (*tiffFile, error) {
+	bounds := img.Bounds()
+	t := &tiffFile{
+		order:       binary.LittleEndian,
+		width:       bounds.Dx(),
+		height:      bounds.Dy(),
+		compression: tiffCompressionNone,
+	}
+	switch img.(type) {
+	case *image.Gray:
+		t.bits, t.spp = 8, 1
+	case *image.Gray16:
+		t.bits, t.spp = 16, 1
+	case *image.RGBA, *image.NRGBA:
+		t.bits, t.spp = 8, 4
+	case *image.RGBA64, *image.NRGBA64:
+		t.bits, t.spp = 16, 4
+	default:
+		return nil, errKeepFormat
+	}
+	if bounds.Min != (image.Point{}) {
+		// channelByte indexes from the origin
+		return nil, errKeepFormat
+	}
+
+	var photometric uint32 = 1
+	if t.spp == 4 {
+		photometric = 2
+	}
+	bits := make([]byte, 2*t.spp)
+	for i := 0; i < t.spp; i++ {
+		t.order.PutUint16(bits[2*i:], uint16(t.bits))
+	}
+	t.entries = []tiffEntry{
+		t.longEntry(tiffImageWidth, uint32(t.width)),
+		t.longEntry(tiffImageLength, uint32(t.height)),
+		{tag: tiffBitsPerSample, typ: 3, count: uint32(t.spp), value: bits},
+		t.shortEntry(tiffPhotometric, photometric),
+		t.shortEntry(tiffSamplesPerPixel, uint32(t.spp)),
+	}
+	switch img.(type) {
+	case *image.RGBA, *image.RGBA64:
+		t.entries = append(t.entries, t.shortEntry(tiffExtraSamples, tiffAlphaAssociated))
+	case *image.NRGBA, *image.NRGBA64:
+		t.entries = append(t.entries, t.shortEntry(tiffExtraSamples, tiffAlphaUnassociated))
+	}
+	return t, nil
+}
+
+// readBMP decodes an uncompressed 24 or 32-bit BMP, bottom up or top down,
+// into an opaque RGBA image. The fourth byte of 32-bit pixels is unused in
+// uncompressed BMPs and is ignored.
+func readBMP(r io.Reader) (*image.RGBA, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	width, height, bits, topDown, err := readBMPHeader(doc)
+	if err != nil {
+		return nil, err
+	}
+	bytesPP := bits / 8
+	rowBytes := (width*bytesPP + 3) &^ 3
+	offset := int(binary.LittleEndian.Uint32(doc[10:]))
+	if offset < 14 || offset > len(doc) || len(doc)-offset < rowBytes*height {
+		return nil, errBadBMP
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := height - 1 - y
+		if topDown {
+			row = y
+		}
+		src := doc[offset+row*rowBytes:]
+		dst := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			dst[x*4], dst[x*4+1], dst[x*4+2], dst[x*4+3] = src[x*bytesPP+2], src[x*bytesPP+1], src[x*bytesPP], 0xff
+		}
+	}
+	return img, nil
+}
+
+// readBMPHeader returns the dimensions and bit depth of a BMP, and
+// whether its rows are stored top down
+func readBMPHeader(doc []byte) (width int, height int, bits int, topDown bool, err error) {
+	if len(doc) < 14+40 || string(doc[:2]) != "BM" || binary.LittleEndian.Uint32(doc[14:]) < 40 {
+		return 0, 0, 0, false, errBadBMP
+	}
+	width = int(int32(binary.LittleEndian.Uint32(doc[18:])))
+	height = int(int32(binary.LittleEndian.Uint32(doc[22:])))
+	if height < 0 {
+		height, topDown = -height, true
+	}
+	bits = int(binary.LittleEndian.Uint16(doc[28:]))
+	compression := binary.LittleEndian.Uint32(doc[30:])
+	if width <= 0 || height <= 0 || width*height > tiffMaxPixels ||
+		(bits != 24 && bits != 32) || compression != 0 {
+		return 0, 0, 0, false, errBadBMP
+	}
+	return width, height, bits, topDown, nil
+}
+
+func decodeBMP(r io.Reader) (image.Image, error) {
+	return readBMP(r)
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	head := make([]byte, 14+40)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return image.Config{}, errBadBMP
+	}
+	width, height, _, _, err := readBMPHeader(head)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: width, Height: height}, nil
+}
+
+// decodeTIFF decodes a TIFF as readTIFF does, except that unassociated
+// alpha is returned as non-premultiplied, so its colours are right. The
+// samples are kept as stored either way.
+func decodeTIFF(r io.Reader) (image.Image, error) {
+	t, err := readTIFF(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range t.entries {
+		if e.tag != tiffExtraSamples {
+			continue
+		}
+		if v := e.uints(t.order); len(v) == 0 || v[0] != tiffAlphaUnassociated {
+			break
+		}
+		switch img := t.img.(type) {
+		case *image.RGBA:
+			return &image.NRGBA{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect}, nil
+		case *image.RGBA64:
+			return &image.NRGBA64{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect}, nil
+		}
+	}
+	return t.img, nil
+}
+
+func decodeTIFFConfig(r io.Reader) (image.Config, error) {
+	img, err := decodeTIFF(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	bounds := img.Bounds()
+	return image.Config{ColorModel: img.ColorModel(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
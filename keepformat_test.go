@@ -0,0 +1,137 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestKeepFormat verifies stego images are written in the lossless source
+// formats with the payload intact
+func TestKeepFormat(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	cover := image.NewRGBA(cleanImg.imgLoaded.Bounds())
+	copy(cover.Pix, cleanImg.imgLoaded.(*image.RGBA).Pix)
+	var bmpDoc bytes.Buffer
+	if err := writeBMP(&bmpDoc, cover); err != nil {
+		t.Fatal(err)
+	}
+	srcTIFF, err := newTIFFFile(cover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tiffDoc bytes.Buffer
+	if err = srcTIFF.write(&tiffDoc, cover); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		format string
+		src    []byte
+		magic  string
+	}{
+		{"bmp", bmpDoc.Bytes(), "BM"},
+		{"tiff", tiffDoc.Bytes(), "II*\x00"},
+	} {
+		var img StegImage
+		if err := img.LoadImage(bytes.NewReader(tc.src)); err != nil {
+			t.Fatal(tc.format, err)
+		}
+		if img.Format() != tc.format {
+			t.Errorf("Loaded as %s, expected %s", img.Format(), tc.format)
+		}
+		img.SetKeepFormat(true)
+		if err := img.EmbedBytes([]byte(secretStringIn)); err != nil {
+			t.Fatal(err)
+		}
+		if img.OutputFormat() != tc.format {
+			t.Errorf("Output format %s, expected %s", img.OutputFormat(), tc.format)
+		}
+		var out bytes.Buffer
+		if err := img.WriteNewImage(&out); err != nil {
+			t.Fatal(tc.format, err)
+		}
+		if !strings.HasPrefix(out.String(), tc.magic) {
+			t.Errorf("%s written as %q", tc.format, out.Bytes()[:8])
+		}
+		var reloaded StegImage
+		if err := reloaded.LoadImage(&out); err != nil {
+			t.Fatal(tc.format, err)
+		}
+		if payload, err := reloaded.ExtractBytes(); err != nil || string(payload) != secretStringIn {
+			t.Errorf("%s: extract failed: %v", tc.format, err)
+		}
+
+		uri, err := img.WriteNewImageToDataURI()
+		if err != nil || !strings.HasPrefix(uri, "data:image/"+tc.format+";base64,") {
+			t.Errorf("%s: data URI %.30q, %v", tc.format, uri, err)
+		}
+	}
+
+	if _, err = readBMP(bytes.NewReader(tiffDoc.Bytes())); err != errBadBMP {
+		t.Error("Correct Error not thrown, expected: '" + errBadBMP.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestKeepFormatJPEG verifies a JPEG source is written as a JPEG only when
+// its payload is robust enough to survive
+func TestKeepFormatJPEG(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	var src bytes.Buffer
+	if err := jpeg.Encode(&src, cleanImg.imgLoaded, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	var img StegImage
+	if err := img.LoadImage(&src); err != nil {
+		t.Fatal(err)
+	}
+	img.SetKeepFormat(true)
+
+	if err := img.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := img.WriteNewImage(&out); err != ErrFormatLossy {
+		t.Error("Correct Error not thrown, expected: '" + ErrFormatLossy.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	const id = 0x0123456789abcdef
+	if err := img.WatermarkEmbed(id); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := img.WriteNewImage(&out); err != nil {
+		t.Fatal(err)
+	}
+	var reloaded StegImage
+	if err := reloaded.LoadImage(&out); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Format() != "jpeg" {
+		t.Errorf("Written as %s, expected jpeg", reloaded.Format())
+	}
+	if result, err := WatermarkVerify(reloaded.Loaded()); err != nil || !result.Detected || result.ID != id {
+		t.Errorf("Watermark lost writing the JPEG: %+v, %v", result, err)
+	}
+
+	img.SetKeepFormat(false)
+	if img.OutputFormat() != "png" {
+		t.Errorf("Output format %s without SetKeepFormat", img.OutputFormat())
+	}
+}
@@ -0,0 +1,107 @@
+package libsteg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// LogFormat selects how libsteg log messages are written
+type LogFormat int
+
+const (
+	// LogFormatText is the default coloured format, for people to read
+	LogFormatText LogFormat = iota
+	// LogFormatJSON writes one JSON object per message, for ingesting
+	// into ELK, Loki and the like. Every object has "time", "level",
+	// "module" and "message". The INFO message logged as each operation
+	// ends adds "operation", "image_width", "image_height",
+	// "payload_bytes", "duration_ms" and, if it failed, "error", an
+	// ErrorClass.
+	LogFormatJSON
+)
+
+// SetLogFormat writes libsteg log messages in format from now on
+func SetLogFormat(format LogFormat) {
+	logFormat = format
+	setupLogging(logOutput, logging.GetLevel("libsteg"))
+}
+
+// formatter returns the go-logging formatter writing format
+func (format LogFormat) formatter() logging.Formatter {
+	if format == LogFormatJSON {
+		return jsonFormatter{}
+	}
+	return logging.MustStringFormatter(
+		`%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x}%{color:reset} %{message}`,
+	)
+}
+
+// operationLog is logged as an operation ends. The JSON format turns its
+// fields into the stable ones documented on LogFormatJSON.
+type operationLog struct {
+	Operation    Operation  `json:"operation"`
+	ImageWidth   int        `json:"image_width"`
+	ImageHeight  int        `json:"image_height"`
+	PayloadBytes int64      `json:"payload_bytes"`
+	DurationMS   float64    `json:"duration_ms"`
+	Error        ErrorClass `json:"error,omitempty"`
+}
+
+func (l operationLog) String() string {
+	msg := fmt.Sprintf("%s of %dx%d image, %d payload bytes, took %.3fms", l.Operation, l.ImageWidth, l.ImageHeight,
+		l.PayloadBytes, l.DurationMS)
+	if l.Error != ErrorNone {
+		msg += ", failed: " + string(l.Error)
+	}
+	return msg
+}
+
+// logOperation logs the operation ob observed at INFO
+func logOperation(ob *observation, class ErrorClass, d time.Duration) {
+	if !ob.logger.IsEnabledFor(logging.INFO) {
+		return
+	}
+	rec := operationLog{
+		Operation:   ob.op,
+		ImageWidth:  ob.bounds.Dx(),
+		ImageHeight: ob.bounds.Dy(),
+		DurationMS:  float64(d) / float64(time.Millisecond),
+		Error:       class,
+	}
+	if class == ErrorNone {
+		rec.PayloadBytes = ob.bytes
+	}
+	ob.logger.Info(rec)
+}
+
+// jsonLine is one message in the JSON format
+type jsonLine struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Message string `json:"message"`
+	*operationLog
+}
+
+// jsonFormatter writes the JSON format
+type jsonFormatter struct{}
+
+// Format implements logging.Formatter
+func (jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	line := jsonLine{
+		Time:    r.Time.UTC().Format(time.RFC3339Nano),
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Message: r.Message(),
+	}
+	if len(r.Args) == 1 {
+		if op, ok := r.Args[0].(operationLog); ok {
+			line.operationLog = &op
+		}
+	}
+	return json.NewEncoder(w).Encode(line)
+}
@@ -0,0 +1,129 @@
+package libsteg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestJSONLogFormat verifies operations are logged with the stable JSON
+// fields. It isn't parallel as the logger is global.
+func TestJSONLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogOutput(&buf)
+	SetLogFormat(LogFormatJSON)
+	SetLoggingLevel(logging.INFO)
+	defer func() {
+		SetLogFormat(LogFormatText)
+		SetLogOutput(os.Stdout)
+		SetLoggingLevel(logging.Level(*loggingLevel))
+	}()
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	bounds := stegImg.imgLoaded.Bounds()
+	if err := stegImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes(make([]byte, 1<<20)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	var ops []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Line %q isn't JSON: %v", scanner.Text(), err)
+		}
+		for _, field := range []string{"time", "level", "module", "message"} {
+			if _, ok := line[field]; !ok {
+				t.Errorf("Line %q has no %s", scanner.Text(), field)
+			}
+		}
+		if _, ok := line["operation"]; ok {
+			ops = append(ops, line)
+		}
+	}
+	if len(ops) != 2 {
+		t.Fatalf("%d operations logged, expected 2", len(ops))
+	}
+	for i, line := range ops {
+		if line["operation"] != "embed" || line["level"] != "INFO" || line["module"] != "libsteg" {
+			t.Errorf("Operation %d logged as %v", i, line)
+		}
+		if line["image_width"] != float64(bounds.Dx()) || line["image_height"] != float64(bounds.Dy()) {
+			t.Errorf("Operation %d logged image size %vx%v, expected %dx%d", i, line["image_width"], line["image_height"],
+				bounds.Dx(), bounds.Dy())
+		}
+		if _, ok := line["duration_ms"].(float64); !ok {
+			t.Errorf("Operation %d has no duration", i)
+		}
+	}
+	if ops[0]["payload_bytes"] != float64(len(secretStringIn)) || ops[0]["error"] != nil {
+		t.Errorf("Successful embed logged as %v", ops[0])
+	}
+	if ops[1]["payload_bytes"] != float64(0) || ops[1]["error"] != string(ErrorCapacity) {
+		t.Errorf("Failed embed logged as %v", ops[1])
+	}
+}
+
+// TestInstanceLoggingLevel verifies a StegImage's own level applies to it
+// alone. It isn't parallel as the logger is global.
+func TestInstanceLoggingLevel(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogOutput(&buf)
+	SetLoggingLevel(logging.CRITICAL)
+	defer func() {
+		SetLogOutput(os.Stdout)
+		SetLoggingLevel(logging.Level(*loggingLevel))
+	}()
+
+	var quiet, noisy StegImage
+	for _, stegImg := range []*StegImage{&quiet, &noisy} {
+		if err := stegImg.LoadImageFromFile(tinyImageFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noisy.SetLoggingLevel(logging.DEBUG)
+
+	if _, err := quiet.ExtractBytes(); err == nil {
+		t.Fatal("Extracted a secret from a clean image")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Logged %q below the global level", buf.String())
+	}
+	if _, err := noisy.ExtractBytes(); err == nil {
+		t.Fatal("Extracted a secret from a clean image")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("extract of")) {
+		t.Errorf("Logged %q at the StegImage's level, expected the operation", buf.String())
+	}
+
+	// Helpers the embed runs through log at the StegImage's level too
+	payload := make([]byte, bitCapacity(quiet.imgLoaded.Bounds(), 3)/8/4)
+	buf.Reset()
+	if err := quiet.EmbedBytes(payload); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Logged %q below the global level", buf.String())
+	}
+	if err := noisy.EmbedBytes(payload); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Loaded payload of", "of capacity"} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("Logged %q at the StegImage's level, expected %q", buf.String(), want)
+		}
+	}
+	if logging.GetLevel("libsteg") != logging.CRITICAL {
+		t.Error("StegImage's level changed the global level")
+	}
+}
@@ -0,0 +1,210 @@
+package libsteg
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+const (
+	// mailSlotPrefix starts the slot name of every mailbox message, the
+	// rest of the name is the hex encoded recipient tag
+	mailSlotPrefix = "mbx:"
+	// mailTagLen is the length of the PRF output in a recipient tag, which
+	// follows a 32 byte ephemeral public key
+	mailTagLen = 8
+	// mailTagInfo binds recipient tags to their purpose
+	mailTagInfo = "libsteg mailbox tag"
+)
+
+// MailMessage is a message addressed to a mailbox
+type MailMessage struct {
+	Source  string // Path or URL of the image the message was found in
+	Payload []byte
+}
+
+// Mailbox turns a feed of images into an inbox: it finds the messages
+// addressed to its identity among any others and decrypts them
+type Mailbox struct {
+	identity *ecdh.PrivateKey
+
+	// Client fetches images for ReceiveURLs, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// NewMailbox returns a mailbox receiving messages for identity
+func NewMailbox(identity *ecdh.PrivateKey) *Mailbox {
+	return &Mailbox{identity: identity}
+}
+
+// EmbedMail addresses payload to the mailbox of recipient, encrypting it
+// to their key and tagging it so they can find it without trying to
+// decrypt every payload in a feed. Every message gets a fresh tag, so an
+// observer can't link messages to the same recipient. Messages already in
+// the image are kept.
+func (s *StegImage) EmbedMail(recipient *ecdh.PublicKey, payload []byte, opts ...Option) error {
+	o := newOptions(opts)
+	if o.deterministic {
+		o.seedDeterministic(payload)
+	}
+	tag, err := newMailTag(recipient, o.rand)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return s.EmbedNamed(mailSlotPrefix+hex.EncodeToString(tag), payload, append(opts, WithRecipients(recipient))...)
+}
+
+// newMailTag returns a recipient tag: a fresh ephemeral public key and a
+// PRF of the secret it shares with the recipient. Only the recipient can
+// recompute the PRF, and with a new ephemeral key per message no two tags
+// have anything in common.
+func newMailTag(recipient *ecdh.PublicKey, rng io.Reader) ([]byte, error) {
+	scalar := make([]byte, 32)
+	if _, err := io.ReadFull(rng, scalar); err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeral.PublicKey().Bytes(), mailTagPRF(shared)...), nil
+}
+
+func mailTagPRF(shared []byte) []byte {
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(mailTagInfo))
+	return mac.Sum(nil)[:mailTagLen]
+}
+
+// addressed reports whether a slot name holds a tag for the mailbox
+func (m *Mailbox) addressed(name string) bool {
+	if !strings.HasPrefix(name, mailSlotPrefix) {
+		return false
+	}
+	tag, err := hex.DecodeString(name[len(mailSlotPrefix):])
+	if err != nil || len(tag) != 32+mailTagLen {
+		return false
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(tag[:32])
+	if err != nil {
+		return false
+	}
+	shared, err := m.identity.ECDH(ephemeral)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(tag[32:], mailTagPRF(shared))
+}
+
+// ReceiveFS returns the messages addressed to the mailbox in every image
+// in fsys. Files that aren't images are skipped, and those with a message
+// tagged for the mailbox that fails to open are logged and skipped.
+func (m *Mailbox) ReceiveFS(fsys fs.FS) ([]MailMessage, error) {
+	var messages []MailMessage
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		f, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		found, err := m.receive(path, f)
+		if err != nil {
+			// Anyone can tag a file for the mailbox, so one that won't
+			// open mustn't block delivery of the rest
+			log.Warning("Skipping", path, err)
+			return nil
+		}
+		messages = append(messages, found...)
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ReceiveURLs returns the messages addressed to the mailbox in the images
+// at the given URLs. Feeds are expected to churn, so URLs that can't be
+// fetched or aren't images are logged and skipped.
+func (m *Mailbox) ReceiveURLs(ctx context.Context, urls []string) ([]MailMessage, error) {
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var messages []MailMessage
+	for _, url := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Error(ctx.Err())
+				return nil, ctx.Err()
+			}
+			log.Warning("Skipping", url, err)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			log.Warning("Skipping", url, resp.Status)
+			continue
+		}
+		found, err := m.receive(url, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			log.Warning("Skipping", url, err)
+			continue
+		}
+		messages = append(messages, found...)
+	}
+	return messages, nil
+}
+
+// receive opens the messages addressed to the mailbox in the image read
+// from r. A tagged message that fails to open is an error, anything else
+// that isn't addressed to the mailbox is ignored.
+func (m *Mailbox) receive(source string, r io.Reader) ([]MailMessage, error) {
+	var s StegImage
+	if err := s.loadImage(r); err != nil {
+		return nil, nil
+	}
+
+	var messages []MailMessage
+	o := newOptions([]Option{WithIdentity(m.identity)})
+	place := newSequentialPlacement(s.imgLoaded)
+	offsets, _ := s.envelopeOffsets()
+	for _, offset := range offsets {
+		e, _, err := s.readEnvelopeHeader(place, offset)
+		if err != nil || !m.addressed(e.name) {
+			continue
+		}
+		e, err = s.readEnvelope(o, shiftedPlacement{place, offset * 8})
+		if err != nil {
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, MailMessage{Source: source, Payload: payload})
+	}
+	return messages, nil
+}
@@ -0,0 +1,130 @@
+package libsteg
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestMailbox verifies a mailbox only receives the messages addressed to
+// it, from both file system and URL feeds
+func TestMailbox(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	alice, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	bob, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedMail(alice.PublicKey(), []byte("for alice")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+	if err := stegImg.EmbedMail(bob.PublicKey(), []byte("for bob")); err != nil {
+		t.Fatal(err)
+	}
+	mailPNG := pngBytes(t, stegImg)
+	otherPNG := stegPNG(t, "unaddressed")
+
+	// A message tagged for alice but encrypted to bob won't open for her
+	tag, err := newMailTag(alice.PublicKey(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var planted StegImage
+	if err = planted.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err = planted.EmbedNamed(mailSlotPrefix+hex.EncodeToString(tag), []byte("junk"), WithRecipients(bob.PublicKey())); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"feed/0.png": {Data: pngBytes(t, &planted)},
+		"feed/1.png": {Data: mailPNG},
+		"feed/2.png": {Data: otherPNG},
+		"readme.txt": {Data: []byte("not an image")},
+	}
+	for identity, want := range map[*ecdh.PrivateKey]string{alice: "for alice", bob: "for bob"} {
+		messages, err := NewMailbox(identity).ReceiveFS(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 1 || string(messages[0].Payload) != want || messages[0].Source != "feed/1.png" {
+			t.Errorf("Unexpected messages: %+v", messages)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.png":
+			w.Write(mailPNG)
+		case "/2.png":
+			w.Write(otherPNG)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/1.png", server.URL + "/missing.png", server.URL + "/2.png"}
+	messages, err := NewMailbox(bob).ReceiveURLs(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || string(messages[0].Payload) != "for bob" {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+}
+
+// TestMailTagsUnlinkable verifies two messages to the same recipient carry
+// unrelated tags and are both received
+func TestMailTagsUnlinkable(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	alice, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	bob, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedMail(alice.PublicKey(), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+	if err := stegImg.EmbedMail(alice.PublicKey(), []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	mailPNG := pngBytes(t, stegImg)
+
+	slots, _, err := reloadImage(t, stegImg).Slots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 || slots[0].Name[:20] == slots[1].Name[:20] {
+		t.Errorf("Tags are linkable: %+v", slots)
+	}
+	if NewMailbox(bob).addressed(slots[0].Name) {
+		t.Error("Tag matched the wrong recipient")
+	}
+
+	messages, err := NewMailbox(alice).ReceiveFS(fstest.MapFS{"1.png": {Data: mailPNG}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || string(messages[0].Payload) != "first" || string(messages[1].Payload) != "second" {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+}
@@ -0,0 +1,75 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// changedLSBs counts the colour channels whose LSB differs between the
+// loaded and manipulated images
+func changedLSBs(s *StegImage) (n int) {
+	b := s.imgLoaded.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for ch := 0; ch < 3; ch++ {
+				if getBit(s.imgLoaded, x, y, ch, 0) != getBit(s.newImg, x, y, ch, 0) {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// TestMatrixEmbedding verifies matrix embedded payloads round trip in any
+// scan order while changing fewer LSBs than plain embedding
+func TestMatrixEmbedding(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := bytes.Repeat([]byte("matrix embedding "), 40)
+	embed := func(opts ...Option) *StegImage {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedBytes(payloadIn, opts...); err != nil {
+			t.Fatal(err)
+		}
+		return &stegImg
+	}
+
+	plain := changedLSBs(embed())
+	for _, opts := range [][]Option{
+		{WithMatrixEmbedding(3)},
+		{WithMatrixEmbedding(4), WithScanOrder(ScanZigzag), WithPassphrase("hunter2"), WithCoverSalt()},
+	} {
+		stegImg := embed(opts...)
+		if changed := changedLSBs(stegImg); changed*4 > plain*3 {
+			t.Errorf("Matrix embedding changed %d LSBs, plain embedding %d", changed, plain)
+		}
+		payloadOut, err := reloadImage(t, stegImg).ExtractBytes(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payloadOut, payloadIn) {
+			t.Error("Payloads Do Not Match!")
+		}
+	}
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes(payloadIn, WithMatrixEmbedding(3)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	if err := stegImg.EmbedBytes(payloadIn, WithMatrixEmbedding(9)); err != errMatrixParam {
+		t.Error("Correct Error not thrown, expected: '" + errMatrixParam.Error() + "' got: '" + errString(err) + "'")
+	}
+	if err := stegImg.EmbedBytes(payloadIn, WithMatrixEmbedding(3), WithBitOrder(LSBFirst)); err != errMatrixUnsupported {
+		t.Error("Correct Error not thrown, expected: '" + errMatrixUnsupported.Error() + "' got: '" + errString(err) + "'")
+	}
+}
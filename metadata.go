@@ -0,0 +1,151 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// Metadata describes an embedded payload so an extractor knows how to
+// interpret the recovered bytes
+type Metadata struct {
+	ContentType string            // MIME type of the payload
+	Created     time.Time         // When the payload was embedded
+	Tags        map[string]string // Free-form key/value tags
+}
+
+var errBadMetadata = errors.New("malformed payload metadata")
+
+// WithMetadata stores m alongside the payload. If m.Created is not set the
+// time of embedding is used, unless embedding is deterministic.
+func WithMetadata(m Metadata) Option {
+	return func(o *options) {
+		o.metadata = &m
+	}
+}
+
+// ExtractWithMetadata retrieves a binary payload along with any metadata
+// stored with it. The returned Metadata is nil if none was embedded.
+func (s *StegImage) ExtractWithMetadata(opts ...Option) (payload []byte, meta *Metadata, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("extract"), &err)
+	o := newOptions(opts)
+	if o.codec != nil {
+		payload, err = s.readCodec(o)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, nil, err
+		}
+		ob.bytes = int64(len(payload))
+		return payload, nil, nil
+	}
+	e, err := s.extractEnvelope(o)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, nil, err
+	}
+	payload, meta, err = e.open(o)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, nil, err
+	}
+	ob.bytes = int64(len(payload))
+	return payload, meta, nil
+}
+
+// marshal serialises the metadata as length prefixed fields, tags are
+// written in key order so the encoding is stable
+func (m *Metadata) marshal() []byte {
+	buf := new(bytes.Buffer)
+	writeString(buf, m.ContentType)
+	binary.Write(buf, binary.BigEndian, m.Created.Unix())
+
+	keys := make([]string, 0, len(m.Tags))
+	for k := range m.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	writeUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		writeString(buf, k)
+		writeString(buf, m.Tags[k])
+	}
+
+	// Prefix the block with its length so it can be split from the payload
+	block := new(bytes.Buffer)
+	writeUvarint(block, uint64(buf.Len()))
+	block.Write(buf.Bytes())
+	return block.Bytes()
+}
+
+// maxMetadataLen bounds the metadata block read from a stream
+const maxMetadataLen = 1 << 20
+
+// readMetadata reads a metadata block from the start of r, leaving r at
+// the payload following it
+func readMetadata(r interface {
+	io.Reader
+	io.ByteReader
+}) (*Metadata, error) {
+	blockLen, err := binary.ReadUvarint(r)
+	if err != nil || blockLen > maxMetadataLen {
+		return nil, errBadMetadata
+	}
+	raw := make([]byte, blockLen)
+	if _, err = io.ReadFull(r, raw); err != nil {
+		return nil, errBadMetadata
+	}
+	block := bytes.NewReader(raw)
+
+	m := &Metadata{}
+	if m.ContentType, err = readString(block); err != nil {
+		return nil, err
+	}
+	var created int64
+	if err = binary.Read(block, binary.BigEndian, &created); err != nil {
+		return nil, errBadMetadata
+	}
+	m.Created = time.Unix(created, 0)
+
+	count, err := binary.ReadUvarint(block)
+	if err != nil || count > uint64(block.Len()) {
+		return nil, errBadMetadata
+	}
+	if count > 0 {
+		m.Tags = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		k, err := readString(block)
+		if err != nil {
+			return nil, err
+		}
+		if m.Tags[k], err = readString(block); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	buf.Write(tmp[:binary.PutUvarint(tmp, v)])
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return "", errBadMetadata
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return string(b), nil
+}
@@ -0,0 +1,41 @@
+package libsteg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BMetadata does a back to back test of a payload carrying metadata
+func TestB2BMetadata(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	metaIn := Metadata{
+		ContentType: "text/plain",
+		Created:     time.Unix(1500000000, 0),
+		Tags:        map[string]string{"author": secretStringIn, "v": "1"},
+	}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	err := cleanImg.EmbedBytes([]byte(secretStringIn), WithMetadata(metaIn), WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadOut, metaOut, err := reloadImage(t, &cleanImg).ExtractWithMetadata(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+	if metaOut == nil || !reflect.DeepEqual(metaIn, *metaOut) {
+		t.Errorf("Metadata Does Not Match! got %+v", metaOut)
+	}
+}
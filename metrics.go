@@ -0,0 +1,74 @@
+package libsteg
+
+import (
+	"sync"
+	"time"
+)
+
+// Names of the metrics reported to Metrics
+const (
+	// MetricOperations counts calls, labelled by "op", an Operation
+	MetricOperations = "libsteg_operations_total"
+	// MetricFailures counts failed calls, labelled by "op" and "class", an
+	// ErrorClass
+	MetricFailures = "libsteg_failures_total"
+	// MetricBytes counts payload bytes embedded or extracted, labelled by
+	// "op"
+	MetricBytes = "libsteg_payload_bytes_total"
+	// MetricDuration times calls, labelled by "op"
+	MetricDuration = "libsteg_operation_duration"
+)
+
+// Metrics receives counters and timings for the same calls as telemetry,
+// plus the payload bytes they process, so callers can wire libsteg into
+// Prometheus, statsd or the like without the library importing either.
+// Label values are drawn from small fixed sets. Implementations must be
+// safe for concurrent use and should return quickly, as they're called on
+// the caller's goroutine.
+type Metrics interface {
+	// Count adds delta to the counter name
+	Count(name string, delta int64, labels map[string]string)
+	// Time records a duration against the timer name
+	Time(name string, d time.Duration, labels map[string]string)
+}
+
+var metrics struct {
+	sync.RWMutex
+	m Metrics
+}
+
+// SetMetrics reports metrics to m from now on. Unlike EnableTelemetry it's
+// meant for the caller's own monitoring, so payload sizes are included. A
+// nil m turns reporting off again.
+func SetMetrics(m Metrics) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.m = m
+}
+
+func currentMetrics() Metrics {
+	metrics.RLock()
+	defer metrics.RUnlock()
+	return metrics.m
+}
+
+// reportMetrics reports a call observed by observe
+func reportMetrics(op Operation, class ErrorClass, d time.Duration) {
+	m := currentMetrics()
+	if m == nil {
+		return
+	}
+	labels := map[string]string{"op": string(op)}
+	m.Count(MetricOperations, 1, labels)
+	m.Time(MetricDuration, d, labels)
+	if class != ErrorNone {
+		m.Count(MetricFailures, 1, map[string]string{"op": string(op), "class": string(class)})
+	}
+}
+
+// countBytes reports n payload bytes processed by a successful call
+func countBytes(op Operation, n int64) {
+	if m := currentMetrics(); m != nil {
+		m.Count(MetricBytes, n, map[string]string{"op": string(op)})
+	}
+}
@@ -0,0 +1,74 @@
+package libsteg
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// recordingMetrics totals counters by name and labels and counts timings
+type recordingMetrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	timings  map[string]int
+}
+
+func metricKey(name string, labels map[string]string) string {
+	return name + "{" + labels["op"] + "," + labels["class"] + "}"
+}
+
+func (m *recordingMetrics) Count(name string, delta int64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[metricKey(name, labels)] += delta
+}
+
+func (m *recordingMetrics) Time(name string, d time.Duration, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timings[metricKey(name, labels)]++
+}
+
+// TestMetrics verifies calls, failures, bytes and timings are reported once
+// metrics are set. It isn't parallel as the metrics are global.
+func TestMetrics(t *testing.T) {
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	m := &recordingMetrics{counters: make(map[string]int64), timings: make(map[string]int)}
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	if err := stegImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloadImage(t, &stegImg).ExtractBytes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes(make([]byte, 1<<20)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	SetMetrics(nil)
+	stegImg.EmbedBytes([]byte(secretStringIn))
+
+	for key, want := range map[string]int64{
+		metricKey(MetricOperations, map[string]string{"op": "embed"}):                      2,
+		metricKey(MetricOperations, map[string]string{"op": "extract"}):                    1,
+		metricKey(MetricBytes, map[string]string{"op": "embed"}):                           int64(len(secretStringIn)),
+		metricKey(MetricBytes, map[string]string{"op": "extract"}):                         int64(len(secretStringIn)),
+		metricKey(MetricFailures, map[string]string{"op": "embed", "class": "capacity"}):   1,
+		metricKey(MetricFailures, map[string]string{"op": "extract", "class": "capacity"}): 0,
+	} {
+		if got := m.counters[key]; got != want {
+			t.Errorf("%s = %d, expected %d", key, got, want)
+		}
+	}
+	if got := m.timings[metricKey(MetricDuration, map[string]string{"op": "embed"})]; got != 2 {
+		t.Errorf("%d embeds timed, expected 2", got)
+	}
+}
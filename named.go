@@ -0,0 +1,145 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// maxSlotNameLen is the longest slot name the envelope header can hold
+const maxSlotNameLen = 255
+
+var (
+	// ErrSlotNotFound is returned when no payload is stored under the
+	// requested slot name
+	ErrSlotNotFound = errors.New("no payload stored under slot name")
+	errBadSlotName  = errors.New("slot name must be 1 to 255 bytes")
+)
+
+// SlotInfo describes one payload in the chain at the start of the image
+type SlotInfo struct {
+	Name  string // Slot name, empty for payloads not embedded with EmbedNamed
+	Bytes int    // Bytes the envelope occupies, including header and trailer
+}
+
+// EmbedNamed stores payload in the slot called name, alongside any other
+// payloads already in the image. A payload already stored under the same
+// name is replaced, the others are kept byte for byte so they don't need
+// their passphrases to be rewritten.
+func (s *StegImage) EmbedNamed(name string, payload []byte, opts ...Option) error {
+	if len(name) == 0 || len(name) > maxSlotNameLen {
+		s.logger().Error(errBadSlotName)
+		return errBadSlotName
+	}
+	err := s.createMutableImage()
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+
+	o := newOptions(opts)
+	o.name = name
+	place := newSequentialPlacement(s.newImg)
+	offsets, end := s.envelopeOffsets()
+
+	var kept []byte
+	for i, offset := range offsets {
+		next := end
+		if i+1 < len(offsets) {
+			next = offsets[i+1]
+		}
+		e, _, err := s.readEnvelopeHeader(place, offset)
+		if err != nil {
+			s.logger().Error(err)
+			return err
+		}
+		if e.name == name {
+			s.logger().Notice("Replacing payload in slot", name)
+			continue
+		}
+		raw, err := s.readBytes(place, offset, next-offset)
+		if err != nil {
+			s.logger().Error(err)
+			return err
+		}
+		kept = append(kept, raw...)
+	}
+
+	// Scrub the old chain first, in case the rebuilt one is shorter
+	noise := make([]byte, end)
+	if _, err = io.ReadFull(o.rand, noise); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	lsb := &lsbWriter{img: s.newImg, place: place}
+	if _, err = lsb.Write(noise); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	lsb.offset = 0
+	if _, err = lsb.Write(kept); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	err = s.writeEnvelope(bytes.NewReader(payload), flagChecksum, o, shiftedPlacement{place, len(kept) * 8})
+	if err != nil {
+		s.logger().Error(err)
+	}
+	return err
+}
+
+// ExtractNamed retrieves the payload stored in the slot called name
+func (s *StegImage) ExtractNamed(name string, opts ...Option) ([]byte, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+
+	o := newOptions(opts)
+	place := newSequentialPlacement(s.imgLoaded)
+	offsets, _ := s.envelopeOffsets()
+	for _, offset := range offsets {
+		e, _, err := s.readEnvelopeHeader(place, offset)
+		if err != nil || e.name != name {
+			continue
+		}
+		e, err = s.readEnvelope(o, shiftedPlacement{place, offset * 8})
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, err
+		}
+		return payload, nil
+	}
+	s.logger().Error(ErrSlotNotFound)
+	return nil, ErrSlotNotFound
+}
+
+// Slots lists the payloads chained into the loaded image and the number of
+// bytes still free after them
+func (s *StegImage) Slots() (slots []SlotInfo, free int, err error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, 0, errNoImageLoaded
+	}
+
+	place := newSequentialPlacement(s.imgLoaded)
+	offsets, end := s.envelopeOffsets()
+	for i, offset := range offsets {
+		next := end
+		if i+1 < len(offsets) {
+			next = offsets[i+1]
+		}
+		e, _, err := s.readEnvelopeHeader(place, offset)
+		if err != nil {
+			s.logger().Error(err)
+			return nil, 0, err
+		}
+		slots = append(slots, SlotInfo{Name: e.name, Bytes: next - offset})
+	}
+	return slots, place.capacity()/8 - end, nil
+}
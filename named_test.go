@@ -0,0 +1,75 @@
+package libsteg
+
+import (
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestNamedSlots verifies slots are stored, replaced and extracted by name
+// with independent passphrases
+func TestNamedSlots(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedNamed("notes", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+	if err := stegImg.EmbedNamed("keys", []byte("secret"), WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg = reloadImage(t, stegImg)
+	if err := stegImg.EmbedNamed("notes", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	stegImg = reloadImage(t, stegImg)
+
+	notes, err := stegImg.ExtractNamed("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(notes) != "hi" {
+		t.Errorf("Replaced Slot Does Not Match! got %q", notes)
+	}
+	keys, err := stegImg.ExtractNamed("keys", WithPassphrase("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(keys) != "secret" {
+		t.Errorf("Encrypted Slot Does Not Match! got %q", keys)
+	}
+
+	if _, err = stegImg.ExtractNamed("missing"); err != ErrSlotNotFound {
+		t.Error("Correct Error not thrown, expected: '" + ErrSlotNotFound.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	slots, free, err := stegImg.Slots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 || slots[0].Name != "keys" || slots[1].Name != "notes" {
+		t.Errorf("Unexpected slots: %+v", slots)
+	}
+	if free <= 0 || free >= bitCapacity(stegImg.imgLoaded.Bounds(), 3)/8 {
+		t.Errorf("Unexpected free capacity: %d", free)
+	}
+}
+
+// TestBadSlotName verifies names that don't fit the header are rejected
+func TestBadSlotName(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedNamed("", []byte("x")); err != errBadSlotName {
+		t.Error("Correct Error not thrown, expected: '" + errBadSlotName.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,70 @@
+package libsteg
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+)
+
+// Option configures how a payload is embedded or extracted
+type Option func(*options)
+
+// options holds the settings built up from a list of Option
+type options struct {
+	passphrase      string
+	metadata        *Metadata
+	compression     Compression
+	hmac            bool
+	signingKey      ed25519.PrivateKey
+	verifyKey       ed25519.PublicKey
+	recipients      []*ecdh.PublicKey
+	identity        *ecdh.PrivateKey
+	existing        ExistingPayloadPolicy
+	name            string
+	adaptive        bool
+	coverSalt       bool
+	visibleChecksum bool
+	payloadDigest   bool
+	inPlace         bool
+	verify          bool
+	blockSums       bool
+	index           EmbedIndex
+	scanOrder       ScanOrder
+	bitOrder        BitOrder
+	channelOrder    ChannelOrder
+	textNorm        TextNormalization
+	codec           EnvelopeCodec
+
+	capacityThreshold float64
+	distortionBudget  float64
+	quality           *qualityGate
+	capacityHook      func(CapacityWarning)
+	maxScanBits       int
+	maxScanPixels     int
+	maxPayload        int64
+	redundancy        int
+	matrix            int
+	tileSize          int
+	spreadStrength    float64
+
+	deterministic bool
+	seed          []byte
+	rand          io.Reader // Source of salts, nonces and keys
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{rand: rand.Reader, capacityThreshold: defaultCapacityThreshold, maxPayload: defaultMaxPayload}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPassphrase encrypts the payload with a key derived from passphrase.
+// The same passphrase must be given on extraction.
+func WithPassphrase(passphrase string) Option {
+	return func(o *options) {
+		o.passphrase = passphrase
+	}
+}
@@ -0,0 +1,57 @@
+package libsteg
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// newPalettedCopy copies a paletted image for embedding in palette index
+// parity. Flipping an index's LSB swaps between the colours at 2k and
+// 2k+1, so the palette is first sorted by alpha then luma to make those
+// pairs as close as possible. The sort is stable, so an image already
+// written by this function keeps its palette order and any payload in it.
+func newPalettedCopy(src *image.Paletted) *image.Paletted {
+	n := len(src.Palette)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		ai, li := paletteKey(src.Palette[order[i]])
+		aj, lj := paletteKey(src.Palette[order[j]])
+		if ai != aj {
+			return ai < aj
+		}
+		return li < lj
+	})
+
+	pal := make(color.Palette, n, n+1)
+	remap := make([]uint8, 256)
+	for i := range remap {
+		remap[i] = uint8(i)
+	}
+	for newIndex, oldIndex := range order {
+		pal[newIndex] = src.Palette[oldIndex]
+		remap[oldIndex] = uint8(newIndex)
+	}
+	// Give the last colour a partner so every index can carry a bit
+	if n%2 == 1 && n < 256 {
+		pal = append(pal, pal[n-1])
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewPaletted(bounds, pal)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.SetColorIndex(x, y, remap[src.ColorIndexAt(x, y)])
+		}
+	}
+	return dst
+}
+
+// paletteKey returns the alpha and luma a palette entry is sorted by
+func paletteKey(c color.Color) (alpha uint32, luma uint32) {
+	r, g, b, a := c.RGBA()
+	return a, 299*r + 587*g + 114*b
+}
@@ -0,0 +1,74 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BPaletted verifies paletted images round trip, stay paletted and
+// only ever swap a pixel for its palette partner
+func TestB2BPaletted(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var pal color.Palette
+	for i := 0; i < 15; i++ {
+		pal = append(pal, color.RGBA{uint8(255 - i*17), uint8(i * 13), uint8(i * 7), 255})
+	}
+	src := image.NewPaletted(image.Rect(0, 0, 40, 40), pal)
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i*7) % uint8(len(pal))
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(base64.StdEncoding.EncodeToString(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+	stegPal, ok := stegImg.imgLoaded.(*image.Paletted)
+	if !ok {
+		t.Fatalf("Paletted image written as %T", stegImg.imgLoaded)
+	}
+
+	payloadOut, err := stegImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			i := stegPal.ColorIndexAt(x, y)
+			if src.At(x, y) != stegPal.Palette[i] && src.At(x, y) != stegPal.Palette[i^1] {
+				t.Fatalf("Pixel (%d,%d) changed to a colour outside its pair", x, y)
+			}
+		}
+	}
+
+	// A second embed keeps the palette order, so appending is possible
+	if err = stegImg.EmbedBytes([]byte("second"), WithExistingPayload(AppendToExisting)); err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := reloadImage(t, stegImg).ExtractAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 2 || string(payloads[1]) != "second" {
+		t.Errorf("Appended Payloads Do Not Match! got %q", payloads)
+	}
+}
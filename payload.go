@@ -0,0 +1,69 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// EmbedJSON marshals v to JSON and embeds it as a compressed, checksummed
+// payload
+func (s *StegImage) EmbedJSON(v interface{}, opts ...Option) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return s.embedStructured(data, opts)
+}
+
+// ExtractJSON retrieves a payload embedded with EmbedJSON and unmarshals
+// it into v
+func (s *StegImage) ExtractJSON(v interface{}, opts ...Option) error {
+	data, err := s.ExtractBytes(opts...)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		s.logger().Error(err)
+	}
+	return err
+}
+
+// EmbedProto marshals m to the protobuf wire format and embeds it as a
+// compressed, checksummed payload
+func (s *StegImage) EmbedProto(m proto.Message, opts ...Option) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return s.embedStructured(data, opts)
+}
+
+// ExtractProto retrieves a payload embedded with EmbedProto and unmarshals
+// it into m
+func (s *StegImage) ExtractProto(m proto.Message, opts ...Option) error {
+	data, err := s.ExtractBytes(opts...)
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(data, m); err != nil {
+		s.logger().Error(err)
+	}
+	return err
+}
+
+// embedStructured embeds serialised data checksummed and, unless the
+// options say otherwise, gzip compressed
+func (s *StegImage) embedStructured(data []byte, opts []Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("embed"), &err)
+	opts = append([]Option{WithCompression(CompressionGzip)}, opts...)
+	if err = s.embedStream(bytes.NewReader(data), flagChecksum, newOptions(opts)); err == nil {
+		ob.bytes = int64(len(data))
+	}
+	return err
+}
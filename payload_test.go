@@ -0,0 +1,100 @@
+package libsteg
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	logging "github.com/op/go-logging"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type testDocument struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+// TestB2BJSON does a back to back JSON payload test
+func TestB2BJSON(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	docIn := testDocument{Name: secretStringIn, Count: 42, Tags: []string{"a", "b"}}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedJSON(docIn); err != nil {
+		t.Fatal(err)
+	}
+
+	var docOut testDocument
+	if err := reloadImage(t, &cleanImg).ExtractJSON(&docOut); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(docIn, docOut) {
+		t.Errorf("Documents Do Not Match! got %+v", docOut)
+	}
+}
+
+// TestB2BProto does a back to back protobuf payload test
+func TestB2BProto(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	msgIn, err := structpb.NewStruct(map[string]interface{}{
+		"name":  secretStringIn,
+		"count": 42,
+		"tags":  []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cleanImg StegImage
+	if err = cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err = cleanImg.EmbedProto(msgIn, WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+
+	msgOut := &structpb.Struct{}
+	if err = reloadImage(t, &cleanImg).ExtractProto(msgOut, WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(msgIn, msgOut) {
+		t.Errorf("Messages Do Not Match! got %v", msgOut)
+	}
+}
+
+// TestChecksumMismatch verifies that a corrupted checksummed payload is
+// rejected rather than returned
+func TestChecksumMismatch(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedJSON(testDocument{Name: secretStringIn}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip the LSB of the first red value after the envelope header
+	pixel := envelopeHeaderLen * 8 / 3
+	x, y := 0, pixel
+	img := cleanImg.newImg.(*image.RGBA)
+	c := img.RGBAAt(x, y)
+	c.R ^= 1
+	img.SetRGBA(x, y, c)
+
+	var docOut testDocument
+	if err := reloadImage(t, &cleanImg).ExtractJSON(&docOut); err != ErrPayloadCorrupt {
+		t.Error("Correct Error not thrown, expected: '" + ErrPayloadCorrupt.Error() + "' got: '" + errString(err) + "'")
+	}
+}
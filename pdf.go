@@ -0,0 +1,154 @@
+package libsteg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+)
+
+var (
+	errBadPDF = errors.New("not a PDF with a cross-reference section")
+
+	pdfStartXref = regexp.MustCompile(`startxref\s+(\d+)`)
+	pdfSize      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRoot      = regexp.MustCompile(`/Root\s+\d+\s+\d+\s+R`)
+	pdfInfo      = regexp.MustCompile(`/Info\s+\d+\s+\d+\s+R`)
+	pdfEncrypt   = regexp.MustCompile(`/Encrypt\s+\d+\s+\d+\s+R`)
+	pdfID        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+	pdfStream    = regexp.MustCompile(`stream\r?\n`)
+)
+
+// EmbedPDF hides payload in the PDF read from r and writes the result to
+// w. The payload is framed in the usual envelope, with the usual options,
+// and stored as an unreferenced Flate stream object appended in an
+// incremental update. The original file is kept byte for byte, and the
+// update's cross-reference section indexes only the new object, with a
+// trailer chained to the previous section by /Prev. Viewers never reach
+// the object from the document's root.
+func EmbedPDF(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	loc := pdfStartXref.FindAllSubmatchIndex(doc, -1)
+	if len(loc) == 0 {
+		log.Error(errBadPDF)
+		return errBadPDF
+	}
+	last := loc[len(loc)-1]
+	xref, err := strconv.Atoi(string(doc[last[2]:last[3]]))
+	if err != nil || xref <= 0 || xref >= last[0] {
+		log.Error(errBadPDF)
+		return errBadPDF
+	}
+	trailer := doc[xref:last[0]]
+	root := lastMatch(pdfRoot, trailer)
+	if root == nil {
+		log.Error(errBadPDF)
+		return errBadPDF
+	}
+
+	o := newOptions(opts)
+	framed, err := marshalEnvelope(bytes.NewReader(payload), flagChecksum, o, log)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	stream := new(bytes.Buffer)
+	zw, _ := zlib.NewWriterLevel(stream, zlib.BestCompression)
+	zw.Write(framed)
+	zw.Close()
+
+	// Number the object past the highest one in use, so it can't clash
+	objNum := 1
+	for _, m := range pdfSize.FindAllSubmatch(trailer, -1) {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && n > objNum {
+			objNum = n
+		}
+	}
+
+	out := bytes.NewBuffer(doc)
+	if doc[len(doc)-1] != '\n' && doc[len(doc)-1] != '\r' {
+		out.WriteByte('\n')
+	}
+	objOffset := out.Len()
+	fmt.Fprintf(out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", objNum, stream.Len())
+	out.Write(stream.Bytes())
+	out.WriteString("\nendstream\nendobj\n")
+
+	newXref := out.Len()
+	fmt.Fprintf(out, "xref\n%d 1\n%010d 00000 n \n", objNum, objOffset)
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Prev %d %s", objNum+1, xref, root)
+	// The rest of the document's identity carries over to the update
+	for _, re := range []*regexp.Regexp{pdfInfo, pdfEncrypt, pdfID} {
+		if m := lastMatch(re, trailer); m != nil {
+			fmt.Fprintf(out, " %s", m)
+		}
+	}
+	fmt.Fprintf(out, " >>\nstartxref\n%d\n%%%%EOF\n", newXref)
+
+	if _, err = w.Write(out.Bytes()); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// lastMatch returns the last match of re in b, or nil
+func lastMatch(re *regexp.Regexp, b []byte) []byte {
+	m := re.FindAll(b, -1)
+	if len(m) == 0 {
+		return nil
+	}
+	return m[len(m)-1]
+}
+
+// ExtractPDF retrieves a payload embedded with EmbedPDF from the PDF read
+// from r
+func ExtractPDF(r io.Reader, opts ...Option) ([]byte, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	for _, loc := range pdfStream.FindAllIndex(doc, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(doc[loc[1]:]))
+		if err != nil {
+			continue
+		}
+		magic := make([]byte, len(envelopeMagic))
+		if _, err = io.ReadFull(zr, magic); err != nil || string(magic) != envelopeMagic {
+			continue
+		}
+		rest, err := ioutil.ReadAll(limitPayload(zr, o.maxPayload))
+		if err == ErrPayloadTooLarge {
+			log.Error(err)
+			return nil, err
+		} else if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			continue
+		}
+		e, err := parseEnvelope(append(magic, rest...), o)
+		if err == ErrNoPayload {
+			continue
+		} else if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return payload, nil
+	}
+	log.Error(ErrNoPayload)
+	return nil, ErrNoPayload
+}
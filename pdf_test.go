@@ -0,0 +1,86 @@
+package libsteg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testPDF builds a minimal single page PDF with a classic xref table
+func testPDF() []byte {
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] >>",
+	}
+	doc := new(bytes.Buffer)
+	doc.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, obj := range objs {
+		offsets = append(offsets, doc.Len())
+		fmt.Fprintf(doc, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(doc, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(doc, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return doc.Bytes()
+}
+
+// TestB2BPDF verifies a payload round trips through a PDF whose cross
+// references still resolve afterwards
+func TestB2BPDF(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	out := new(bytes.Buffer)
+	if err := EmbedPDF(bytes.NewReader(testPDF()), out, []byte(secretStringIn), WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := ExtractPDF(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	// The original file is kept, and the update indexes the new object
+	orig := testPDF()
+	doc := out.Bytes()
+	if !bytes.HasPrefix(doc, orig) {
+		t.Fatal("Original revision changed")
+	}
+	m := regexp.MustCompile(`startxref\s+(\d+)`).FindAllSubmatch(doc, -1)
+	prev, _ := strconv.Atoi(string(m[0][1]))
+	xref, _ := strconv.Atoi(string(m[len(m)-1][1]))
+	if xref <= len(orig) || !bytes.HasPrefix(doc[xref:], []byte("xref")) {
+		t.Fatal("startxref doesn't point at the update's xref section")
+	}
+	entry := regexp.MustCompile(`xref\n(\d+) 1\n(\d{10}) 00000 n \n`).FindSubmatch(doc[xref:])
+	if entry == nil {
+		t.Fatal("Update's xref section doesn't index the new object")
+	}
+	objNum, _ := strconv.Atoi(string(entry[1]))
+	off, _ := strconv.Atoi(string(entry[2]))
+	if !bytes.HasPrefix(doc[off:], []byte(fmt.Sprintf("%d 0 obj", objNum))) {
+		t.Error("xref entry doesn't point at the new object")
+	}
+	want := fmt.Sprintf("/Size %d /Prev %d /Root 1 0 R", objNum+1, prev)
+	if !bytes.Contains(doc[xref:], []byte(want)) {
+		t.Error("Update's trailer doesn't chain to the original, expected: '" + want + "'")
+	}
+
+	if _, err = ExtractPDF(bytes.NewReader(testPDF())); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+	if err = EmbedPDF(bytes.NewReader([]byte("not a pdf")), out, nil); err != errBadPDF {
+		t.Error("Correct Error not thrown, expected: '" + errBadPDF.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,194 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"time"
+)
+
+var (
+	errPipelineNoStego  = errors.New("no stego image to encode")
+	errPipelineNoOutput = errors.New("no encoded image to save")
+)
+
+// PipelineContext is the state shared by the steps of a pipeline
+type PipelineContext struct {
+	Image   *StegImage             // Image being worked on
+	Payload []byte                 // Payload to embed
+	Options []Option               // Options used when embedding
+	Output  []byte                 // Encoded stego image
+	Values  map[string]interface{} // Free-form values passed between steps
+
+	stepBytes int64 // Bytes processed by the running step
+}
+
+// CountBytes adds n to the bytes the running step reports as processed
+func (ctx *PipelineContext) CountBytes(n int) {
+	ctx.stepBytes += int64(n)
+}
+
+// StepReport describes one step run by a pipeline
+type StepReport struct {
+	Step     string
+	Start    time.Time
+	Duration time.Duration
+	Bytes    int64 // Bytes processed, as counted by the step
+	Err      error // Set if the step failed
+}
+
+// Step is one stage of a pipeline
+type Step interface {
+	Name() string
+	Run(ctx *PipelineContext) error
+}
+
+// PipelineError reports which step of a pipeline failed
+type PipelineError struct {
+	Step string
+	Err  error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline step %s: %v", e.Step, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error { return e.Err }
+
+// Pipeline runs a fixed sequence of steps over a shared context
+type Pipeline struct {
+	steps  []Step
+	report func(StepReport)
+}
+
+// NewPipeline returns a pipeline running steps in order
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Then appends step to the pipeline, returning the pipeline for chaining
+func (p *Pipeline) Then(step Step) *Pipeline {
+	p.steps = append(p.steps, step)
+	return p
+}
+
+// OnStep calls fn after each step runs with its timing and the bytes it
+// processed, returning the pipeline for chaining
+func (p *Pipeline) OnStep(fn func(StepReport)) *Pipeline {
+	p.report = fn
+	return p
+}
+
+// Run runs each step in turn over ctx, stopping at the first to fail. A nil
+// ctx starts from an empty context.
+func (p *Pipeline) Run(ctx *PipelineContext) (*PipelineContext, error) {
+	if ctx == nil {
+		ctx = &PipelineContext{}
+	}
+	if ctx.Image == nil {
+		ctx.Image = &StegImage{}
+	}
+	if ctx.Values == nil {
+		ctx.Values = make(map[string]interface{})
+	}
+	for _, step := range p.steps {
+		ctx.stepBytes = 0
+		start := time.Now()
+		err := step.Run(ctx)
+		elapsed := time.Since(start)
+		log.Debug("Pipeline step", step.Name(), "took", elapsed)
+		if p.report != nil {
+			p.report(StepReport{
+				Step:     step.Name(),
+				Start:    start,
+				Duration: elapsed,
+				Bytes:    ctx.stepBytes,
+				Err:      err,
+			})
+		}
+		if err != nil {
+			err = &PipelineError{Step: step.Name(), Err: err}
+			log.Error(err)
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// stepFunc adapts a function into a Step
+type stepFunc struct {
+	name string
+	fn   func(ctx *PipelineContext) error
+}
+
+func (s stepFunc) Name() string                   { return s.name }
+func (s stepFunc) Run(ctx *PipelineContext) error { return s.fn(ctx) }
+
+// FuncStep returns a step named name that runs fn
+func FuncStep(name string, fn func(ctx *PipelineContext) error) Step {
+	return stepFunc{name: name, fn: fn}
+}
+
+// LoadStep loads the cover image from path
+func LoadStep(path string) Step {
+	return FuncStep("load", func(ctx *PipelineContext) error {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ctx.CountBytes(len(data))
+		return ctx.Image.loadImage(bytes.NewReader(data))
+	})
+}
+
+// SanitizeStep canonicalizes the cover, see StegImage.Canonicalize
+func SanitizeStep() Step {
+	return FuncStep("sanitize", func(ctx *PipelineContext) error {
+		return ctx.Image.Canonicalize()
+	})
+}
+
+// EncryptStep has the payload encrypted with passphrase when it's embedded
+func EncryptStep(passphrase string) Step {
+	return FuncStep("encrypt", func(ctx *PipelineContext) error {
+		ctx.Options = append(ctx.Options, WithPassphrase(passphrase))
+		return nil
+	})
+}
+
+// EmbedStep embeds the payload with the options gathered so far
+func EmbedStep() Step {
+	return FuncStep("embed", func(ctx *PipelineContext) error {
+		ctx.CountBytes(len(ctx.Payload))
+		return ctx.Image.EmbedBytes(ctx.Payload, ctx.Options...)
+	})
+}
+
+// EncodeStep encodes the stego image into the context's Output
+func EncodeStep() Step {
+	return FuncStep("encode", func(ctx *PipelineContext) error {
+		if ctx.Image.newImg == nil {
+			return errPipelineNoStego
+		}
+		buf := new(bytes.Buffer)
+		if err := ctx.Image.encodeNewImage(buf, png.BestCompression); err != nil {
+			return err
+		}
+		ctx.Output = buf.Bytes()
+		ctx.CountBytes(len(ctx.Output))
+		return nil
+	})
+}
+
+// SaveStep writes the encoded stego image to path
+func SaveStep(path string) Step {
+	return FuncStep("save", func(ctx *PipelineContext) error {
+		if ctx.Output == nil {
+			return errPipelineNoOutput
+		}
+		ctx.CountBytes(len(ctx.Output))
+		return ioutil.WriteFile(path, ctx.Output, 0644)
+	})
+}
@@ -0,0 +1,108 @@
+package libsteg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestPipelineB2B runs a full load to save pipeline and extracts the result
+func TestPipelineB2B(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	out := filepath.Join(t.TempDir(), "out.png")
+	var steps []string
+	trace := func(name string) Step {
+		return FuncStep(name, func(ctx *PipelineContext) error {
+			steps = append(steps, name)
+			return nil
+		})
+	}
+	p := NewPipeline(LoadStep(tinyImageFile), SanitizeStep(), EncryptStep("hunter2")).
+		Then(trace("between")).
+		Then(EmbedStep()).
+		Then(EncodeStep()).
+		Then(SaveStep(out))
+	if _, err := p.Run(&PipelineContext{Payload: []byte(secretStringIn)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 {
+		t.Errorf("Custom step ran %d times", len(steps))
+	}
+
+	var stego StegImage
+	if err := stego.LoadImageFromFile(out); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stego.ExtractBytes(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestPipelineError verifies a failing step stops the pipeline and is named
+// in the error
+func TestPipelineError(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	ran := false
+	_, err := NewPipeline(LoadStep(tinyImageFile), EncodeStep(),
+		FuncStep("after", func(ctx *PipelineContext) error {
+			ran = true
+			return nil
+		})).Run(nil)
+	var pipeErr *PipelineError
+	if !errors.As(err, &pipeErr) || pipeErr.Step != "encode" || !errors.Is(err, errPipelineNoStego) {
+		t.Error("Correct Error not thrown, expected: '" + errString(&PipelineError{Step: "encode", Err: errPipelineNoStego}) + "' got: '" + errString(err) + "'")
+	}
+	if ran {
+		t.Error("Pipeline continued past a failed step")
+	}
+}
+
+// TestPipelineOnStep verifies each step is reported with the bytes it
+// processed, failed steps included
+func TestPipelineOnStep(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var reports []StepReport
+	ctx, err := NewPipeline(LoadStep(tinyImageFile), EmbedStep(), EncodeStep(), SaveStep(t.TempDir())).
+		OnStep(func(r StepReport) { reports = append(reports, r) }).
+		Run(&PipelineContext{Payload: []byte(secretStringIn)})
+	if err == nil {
+		t.Fatal("Saving over a directory should fail")
+	}
+	info, statErr := os.Stat(tinyImageFile)
+	if statErr != nil {
+		t.Fatal(statErr)
+	}
+	expected := []struct {
+		step  string
+		bytes int64
+	}{
+		{"load", info.Size()},
+		{"embed", int64(len(secretStringIn))},
+		{"encode", int64(len(ctx.Output))},
+		{"save", int64(len(ctx.Output))},
+	}
+	if len(reports) != len(expected) {
+		t.Fatalf("Expected %d reports, got %d", len(expected), len(reports))
+	}
+	for i, r := range reports {
+		if r.Step != expected[i].step || r.Bytes != expected[i].bytes || r.Duration < 0 {
+			t.Errorf("Unexpected report %d: %+v", i, r)
+		}
+		if (r.Err != nil) != (r.Step == "save") {
+			t.Errorf("Unexpected error for step %s: %v", r.Step, r.Err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package libsteg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// placementSalt domain separates placement keys from encryption keys. It
+// is fixed because the extractor must find the envelope before it has
+// read anything.
+const placementSalt = "libsteg placement"
+
+// placement maps indices into the embedded bit stream onto the pixel,
+// colour channel (0=R, 1=G, 2=B, or 0 for grayscale) and bit plane (0 for
+// the LSB) holding them
+type placement interface {
+	locate(bitIndex int) (x int, y int, ch int, plane int)
+	capacity() int // Number of bits available
+}
+
+// sequentialPlacement walks pixels column by column, matching embedSecret
+type sequentialPlacement struct {
+	bounds   image.Rectangle
+	channels int
+}
+
+// newSequentialPlacement walks every channel of img, except for paletted
+// images with transparency, see newPaletteMaskPlacement
+func newSequentialPlacement(img image.Image) placement {
+	if p, ok := img.(*image.Paletted); ok && hasTransparency(p.Palette) {
+		return newPaletteMaskPlacement(p)
+	}
+	return sequentialPlacement{bounds: img.Bounds(), channels: imageChannels(img)}
+}
+
+func (p sequentialPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	x, y, ch = channelLocation(p.bounds, p.channels, bitIndex)
+	return x, y, ch, 0
+}
+
+func (p sequentialPlacement) capacity() int {
+	return bitCapacity(p.bounds, p.channels)
+}
+
+// keyedPlacement scatters bits over a key-derived permutation of the
+// pixels in one slot. The image is split into slots by pixel index modulo
+// the slot count, so payloads in different slots never share a pixel.
+type keyedPlacement struct {
+	bounds   image.Rectangle
+	channels int
+	slot     int
+	slots    int
+	pixels   int // Number of pixels in this slot
+	perm     *feistel
+}
+
+// placementKey derives the key for a keyedPlacement from a passphrase
+func placementKey(passphrase string) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, []byte(placementSalt), kdfIterations, 16)
+}
+
+func newKeyedPlacement(img image.Image, key []byte, slot int, slots int) (*keyedPlacement, error) {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	pixels := total / slots
+	if slot < total%slots {
+		pixels++
+	}
+	perm, err := newFeistel(key, pixels)
+	if err != nil {
+		return nil, err
+	}
+	return &keyedPlacement{
+		bounds:   bounds,
+		channels: imageChannels(img),
+		slot:     slot,
+		slots:    slots,
+		pixels:   pixels,
+		perm:     perm,
+	}, nil
+}
+
+func (p *keyedPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	pixel := int(p.perm.permute(uint64(bitIndex/p.channels)))*p.slots + p.slot
+	x, y, ch = channelLocation(p.bounds, p.channels, pixel*p.channels+bitIndex%p.channels)
+	return x, y, ch, 0
+}
+
+func (p *keyedPlacement) capacity() int {
+	return p.pixels * p.channels
+}
+
+// feistel is a keyed pseudo-random permutation of [0, n), built from a
+// balanced Feistel network with AES as the round function and cycle
+// walking to stay inside the domain. It avoids holding a permutation table
+// the size of the image in memory.
+type feistel struct {
+	block    cipher.Block
+	n        uint64
+	halfBits uint
+	mask     uint64
+}
+
+func newFeistel(key []byte, n int) (*feistel, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	halfBits := uint(1)
+	for uint64(1)<<(2*halfBits) < uint64(n) {
+		halfBits++
+	}
+	return &feistel{block: block, n: uint64(n), halfBits: halfBits, mask: 1<<halfBits - 1}, nil
+}
+
+func (f *feistel) permute(i uint64) uint64 {
+	for {
+		i = f.encrypt(i)
+		if i < f.n {
+			return i
+		}
+	}
+}
+
+func (f *feistel) encrypt(v uint64) uint64 {
+	l, r := v>>f.halfBits, v&f.mask
+	for round := 0; round < 4; round++ {
+		l, r = r, l^f.round(round, r)
+	}
+	return l<<f.halfBits | r
+}
+
+func (f *feistel) round(round int, r uint64) uint64 {
+	var in, out [aes.BlockSize]byte
+	in[0] = byte(round)
+	binary.BigEndian.PutUint64(in[8:], r)
+	f.block.Encrypt(out[:], in[:])
+	return binary.BigEndian.Uint64(out[:8]) & f.mask
+}
+
+// paletteMaskPlacement walks the pixels of a paletted image column by
+// column, skipping any whose index parity can't be flipped without
+// changing transparency. Transparent pixels and opaque pixels whose
+// palette partner is transparent keep their index.
+type paletteMaskPlacement struct {
+	bounds image.Rectangle
+	pixels []int32 // Usable pixels, as column-major indices into bounds
+}
+
+func newPaletteMaskPlacement(img *image.Paletted) *paletteMaskPlacement {
+	usable := make([]bool, len(img.Palette))
+	for i := range img.Palette {
+		partner := i ^ 1
+		usable[i] = partner < len(img.Palette) &&
+			!isTransparent(img.Palette[i]) && !isTransparent(img.Palette[partner])
+	}
+	bounds := img.Bounds()
+	p := &paletteMaskPlacement{bounds: bounds}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if i := int(img.ColorIndexAt(x, y)); i < len(usable) && usable[i] {
+				p.pixels = append(p.pixels, int32((x-bounds.Min.X)*bounds.Dy()+y-bounds.Min.Y))
+			}
+		}
+	}
+	return p
+}
+
+func (p *paletteMaskPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	x, y, _ = channelLocation(p.bounds, 1, int(p.pixels[bitIndex]))
+	return x, y, 0, 0
+}
+
+func (p *paletteMaskPlacement) capacity() int {
+	return len(p.pixels)
+}
+
+func hasTransparency(pal color.Palette) bool {
+	for _, c := range pal {
+		if isTransparent(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTransparent(c color.Color) bool {
+	_, _, _, a := c.RGBA()
+	return a == 0
+}
+
+// shiftedPlacement starts another placement part way through, so several
+// envelopes can be chained one after another
+type shiftedPlacement struct {
+	placement
+	shift int // Number of bits skipped
+}
+
+func (p shiftedPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	return p.placement.locate(bitIndex + p.shift)
+}
+
+func (p shiftedPlacement) capacity() int {
+	return p.placement.capacity() - p.shift
+}
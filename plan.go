@@ -0,0 +1,96 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+)
+
+// EmbedPlan reports what embedding a secret would do to the loaded image,
+// see Plan
+type EmbedPlan struct {
+	Stored        int     // Bytes written, including envelope overhead
+	Capacity      int     // Bytes available with the chosen parameters
+	ChangedPixels int     // Pixels with at least one channel altered
+	PSNR          float64 // Peak signal to noise ratio against the cover, in dB
+
+	// The parameters chosen from the options
+	Mode         string      // "lsb", "adaptive", "matrix", "redundant" or "tiled"
+	Compression  Compression // Never CompressionAuto, which is resolved for the secret
+	Encrypted    bool
+	ScanOrder    ScanOrder
+	BitOrder     BitOrder
+	ChannelOrder ChannelOrder
+}
+
+// Plan works out what EmbedBytes would do with secret and opts, without
+// producing an image: the capacity it needs and has, how many pixels
+// change, the resulting PSNR and the parameters chosen. It embeds into a
+// scratch copy, so the figures are exact for this secret, and fails as
+// EmbedBytes would, e.g. with ErrInsufficientCapacity. The loaded image is
+// left untouched, even WithInPlace.
+func (s *StegImage) Plan(secret []byte, opts ...Option) (EmbedPlan, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return EmbedPlan{}, errNoImageLoaded
+	}
+	o := newOptions(opts)
+	o.inPlace = false
+	plan := EmbedPlan{
+		Mode:         o.planMode(),
+		Compression:  o.compression,
+		Encrypted:    o.passphrase != "" || len(o.recipients) > 0,
+		ScanOrder:    o.scanOrder,
+		BitOrder:     o.bitOrder,
+		ChannelOrder: o.channelOrder,
+	}
+	if plan.Compression == CompressionAuto {
+		var err error
+		if plan.Compression, _, err = chooseCompression(bytes.NewReader(secret), s.logger()); err != nil {
+			s.logger().Error(err)
+			return EmbedPlan{}, err
+		}
+	}
+	o.capacityThreshold = 0
+	o.capacityHook = func(w CapacityWarning) {
+		plan.Stored, plan.Capacity = w.Used, w.Capacity
+	}
+
+	trial := StegImage{imgLoaded: s.imgLoaded}
+	if err := trial.embedStream(bytes.NewReader(secret), flagChecksum, o); err != nil {
+		return EmbedPlan{}, err
+	}
+	plan.ChangedPixels = changedPixels(s.imgLoaded, trial.newImg)
+	plan.PSNR = PSNR(s.imgLoaded, trial.newImg)
+	return plan, nil
+}
+
+// planMode names the embedding the options select
+func (o *options) planMode() string {
+	switch {
+	case o.tileSize != 0:
+		return "tiled"
+	case o.redundancy > 1:
+		return "redundant"
+	case o.matrix != 0:
+		return "matrix"
+	case o.adaptive:
+		return "adaptive"
+	}
+	return "lsb"
+}
+
+// changedPixels counts the pixels of b that differ from a. The images must
+// have the same bounds.
+func changedPixels(a image.Image, b image.Image) (n int) {
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, aa := a.At(x, y).RGBA()
+			br, bg, bb, ba := b.At(x, y).RGBA()
+			if ar != br || ag != bg || ab != bb || aa != ba {
+				n++
+			}
+		}
+	}
+	return n
+}
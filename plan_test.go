@@ -0,0 +1,60 @@
+package libsteg
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestPlan checks a plan matches what embedding then does, and leaves the
+// loaded image alone
+func TestPlan(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	bounds := stegImg.imgLoaded.Bounds()
+	cover := image.NewNRGBA(bounds)
+	draw.Draw(cover, bounds, stegImg.imgLoaded, bounds.Min, draw.Src)
+
+	secret := []byte("planned secret")
+	plan, err := stegImg.Plan(secret, WithCompression(CompressionAuto), WithInPlace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedPixels(cover, stegImg.imgLoaded) != 0 {
+		t.Error("Plan changed the loaded image")
+	}
+	if plan.Mode != "lsb" || plan.Compression != CompressionNone || plan.Encrypted {
+		t.Errorf("Unexpected parameters: %+v", plan)
+	}
+	if plan.Stored == 0 || plan.Capacity != bounds.Dx()*bounds.Dy()*3/8 {
+		t.Errorf("Unexpected capacity: %+v", plan)
+	}
+
+	if err = stegImg.EmbedBytes(secret, WithCompression(CompressionAuto)); err != nil {
+		t.Fatal(err)
+	}
+	if changed := changedPixels(cover, stegImg.newImg); plan.ChangedPixels != changed {
+		t.Errorf("Plan changes %d pixels, embedding changed %d", plan.ChangedPixels, changed)
+	}
+	if psnr := PSNR(cover, stegImg.newImg); plan.PSNR != psnr {
+		t.Errorf("Plan estimates PSNR %.2f, embedding gave %.2f", plan.PSNR, psnr)
+	}
+
+	plan, err = stegImg.Plan(secret, WithMatrixEmbedding(3), WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mode != "matrix" || !plan.Encrypted {
+		t.Errorf("Unexpected parameters: %+v", plan)
+	}
+	if _, err = stegImg.Plan(make([]byte, plan.Capacity*8)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,188 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"math/rand"
+)
+
+// PNGChunk is a raw PNG chunk
+type PNGChunk struct {
+	Type string // Four letter chunk type, e.g. "sRGB"
+	Data []byte
+}
+
+// PNGProfile describes the container level fingerprint of a PNG encoder,
+// so stego output can be made to look like it came from an ordinary
+// source pipeline rather than from Go's image/png
+type PNGProfile struct {
+	CompressionLevel png.CompressionLevel
+	// Chunks are ancillary chunks written, in order, between IHDR and the
+	// first IDAT
+	Chunks []PNGChunk
+}
+
+// Built in profiles resembling common encoder pipelines
+var (
+	// ProfileMinimal writes no ancillary chunks at default compression,
+	// resembling browser canvas exports and most server side encoders
+	ProfileMinimal = PNGProfile{CompressionLevel: png.DefaultCompression}
+	// ProfileScreenshot tags the image sRGB at 144 DPI, resembling HiDPI
+	// desktop screenshot tools
+	ProfileScreenshot = PNGProfile{
+		CompressionLevel: png.DefaultCompression,
+		Chunks:           []PNGChunk{srgbChunk(), physChunk(5669)},
+	}
+	// ProfileEditor writes gamma, sRGB and 72 DPI chunks at best
+	// compression, resembling desktop image editor exports
+	ProfileEditor = PNGProfile{
+		CompressionLevel: png.BestCompression,
+		Chunks:           []PNGChunk{gamaChunk(), srgbChunk(), physChunk(2835)},
+	}
+	// ProfileReproducible writes no ancillary chunks and stores the image
+	// data unfiltered and uncompressed, so the file depends only on the
+	// pixels and not on the deflate implementation of the Go release or
+	// platform encoding it. Pair it with WithDeterministic or WithSeed for
+	// content addressable output, at the cost of larger files.
+	ProfileReproducible = PNGProfile{CompressionLevel: png.NoCompression}
+)
+
+var errBadPNG = errors.New("malformed png stream")
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// RandomPNGProfile returns one of the built in profiles at random, so a
+// batch of stego outputs doesn't share a single container fingerprint
+func RandomPNGProfile() PNGProfile {
+	profiles := []PNGProfile{ProfileMinimal, ProfileScreenshot, ProfileEditor}
+	return profiles[rand.Intn(len(profiles))]
+}
+
+// SetPNGProfile sets the encoder profile used when writing the new image
+func (s *StegImage) SetPNGProfile(profile PNGProfile) {
+	s.pngProfile = &profile
+}
+
+// encodeNewImage writes StegImage.newImg in the source's format under
+// SetKeepFormat, or otherwise as PNG, using the profile if one
+// is set or level otherwise, and copying the source chunks chosen by
+// SetPreserveChunks
+func (s *StegImage) encodeNewImage(w io.Writer, level png.CompressionLevel) (err error) {
+	defer endSpan(s.startSpan("encode"), &err)
+	if s.OutputFormat() != "png" {
+		return s.encodeSourceFormat(w)
+	}
+	copied := s.copiedChunks()
+	if s.pngProfile == nil && len(copied) == 0 {
+		enc := png.Encoder{CompressionLevel: level}
+		return enc.Encode(w, s.newImg)
+	}
+
+	var chunks []PNGChunk
+	if s.pngProfile != nil {
+		level = s.pngProfile.CompressionLevel
+		chunks = s.pngProfile.Chunks
+	}
+	buf := new(bytes.Buffer)
+	enc := png.Encoder{CompressionLevel: level}
+	if err = enc.Encode(buf, s.newImg); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if len(copied) > 0 {
+		if out, err = insertSourceChunks(out, copied); err != nil {
+			return err
+		}
+		// The source's own chunks win over the profile's
+		have := make(map[string]bool)
+		for _, c := range copied {
+			have[c.Type] = true
+		}
+		var extra []PNGChunk
+		for _, c := range chunks {
+			if !have[c.Type] {
+				extra = append(extra, c)
+			}
+		}
+		chunks = extra
+	}
+	out, err = insertPNGChunks(out, chunks)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// insertPNGChunks inserts chunks straight after the IHDR chunk of an
+// encoded PNG
+func insertPNGChunks(encoded []byte, chunks []PNGChunk) ([]byte, error) {
+	// Signature, then IHDR: length(4) + type(4) + 13 bytes data + crc(4)
+	ihdrEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if len(encoded) < ihdrEnd || string(encoded[:len(pngSignature)]) != pngSignature ||
+		string(encoded[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, errBadPNG
+	}
+
+	out := new(bytes.Buffer)
+	out.Write(encoded[:ihdrEnd])
+	for _, c := range chunks {
+		writePNGChunk(out, c)
+	}
+	out.Write(encoded[ihdrEnd:])
+	return out.Bytes(), nil
+}
+
+// readPNGChunks splits an encoded PNG into its chunks, without checking
+// their CRCs
+func readPNGChunks(encoded []byte) ([]PNGChunk, error) {
+	if len(encoded) < len(pngSignature) || string(encoded[:len(pngSignature)]) != pngSignature {
+		return nil, errBadPNG
+	}
+	var chunks []PNGChunk
+	for rest := encoded[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, errBadPNG
+		}
+		n := binary.BigEndian.Uint32(rest[:4])
+		if uint64(n)+12 > uint64(len(rest)) {
+			return nil, errBadPNG
+		}
+		chunks = append(chunks, PNGChunk{Type: string(rest[4:8]), Data: rest[8 : 8+n]})
+		rest = rest[12+n:]
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w io.Writer, c PNGChunk) {
+	binary.Write(w, binary.BigEndian, uint32(len(c.Data)))
+	crc := crc32.NewIEEE()
+	mw := io.MultiWriter(w, crc)
+	mw.Write([]byte(c.Type))
+	mw.Write(c.Data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+func srgbChunk() PNGChunk {
+	// Perceptual rendering intent
+	return PNGChunk{Type: "sRGB", Data: []byte{0}}
+}
+
+func gamaChunk() PNGChunk {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, 45455)
+	return PNGChunk{Type: "gAMA", Data: data}
+}
+
+func physChunk(pixelsPerMetre uint32) PNGChunk {
+	data := make([]byte, 9)
+	binary.BigEndian.PutUint32(data[0:4], pixelsPerMetre)
+	binary.BigEndian.PutUint32(data[4:8], pixelsPerMetre)
+	data[8] = 1 // Unit is the metre
+	return PNGChunk{Type: "pHYs", Data: data}
+}
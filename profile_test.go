@@ -0,0 +1,50 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestPNGProfileChunks verifies a profile's chunks are written after IHDR
+// and the output still decodes and extracts
+func TestPNGProfileChunks(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	cleanImg.SetPNGProfile(ProfileEditor)
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+
+	b64, err := cleanImg.WriteNewImageToB64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(b64)
+	ihdrEnd := len(pngSignature) + 25
+	for _, chunkType := range []string{"gAMA", "sRGB", "pHYs"} {
+		i := bytes.Index(raw, []byte(chunkType))
+		if i < ihdrEnd || i > bytes.Index(raw, []byte("IDAT")) {
+			t.Errorf("Chunk %s not found between IHDR and IDAT", chunkType)
+		}
+	}
+
+	var tamperedImg StegImage
+	if err = tamperedImg.LoadImageFromB64(b64); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := tamperedImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+}
@@ -0,0 +1,93 @@
+package libsteg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// qualityGate is the minimum quality set with WithQualityGate
+type qualityGate struct {
+	minPSNR float64
+	minSSIM float64
+}
+
+// QualityError is returned when no embedding mode keeps the stego image
+// within the quality gate set with WithQualityGate
+type QualityError struct {
+	Mode    string  // Last mode tried, "lsb" or "adaptive"
+	PSNR    float64 // PSNR it reached, in dB
+	SSIM    float64 // SSIM it reached
+	MinPSNR float64
+	MinSSIM float64
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("embedding with %s gives PSNR %.2f dB and SSIM %.4f, below the minimum of %.2f dB and %.4f",
+		e.Mode, e.PSNR, e.SSIM, e.MinPSNR, e.MinSSIM)
+}
+
+// WithQualityGate requires the stego image to keep a PSNR of at least
+// minPSNR dB and an SSIM of at least minSSIM against the cover, a zero
+// minimum skipping that measure. If adaptive depth falls short the payload
+// is embedded with plain LSBs instead, which extraction with adaptive depth
+// still finds. If plain LSBs fall short the embed fails with a
+// *QualityError. With adaptive depth the payload is held in memory, so it
+// can be embedded again.
+func WithQualityGate(minPSNR float64, minSSIM float64) Option {
+	return func(o *options) {
+		o.quality = &qualityGate{minPSNR: minPSNR, minSSIM: minSSIM}
+	}
+}
+
+// embedGated embeds as embedStream does, falling back from adaptive depth
+// to plain LSBs when the result fails the quality gate
+func (s *StegImage) embedGated(r io.Reader, flags uint16, o *options) error {
+	gate := o.quality
+	o.quality = nil
+	defer func() { o.quality = gate }()
+
+	modes := []bool{false}
+	if o.adaptive {
+		modes = []bool{true, false}
+		payload, err := ioutil.ReadAll(r)
+		if err != nil {
+			s.logger().Error(err)
+			return err
+		}
+		r = bytes.NewReader(payload)
+	}
+	for _, adaptive := range modes {
+		o.adaptive = adaptive
+		if seeker, ok := r.(io.Seeker); ok {
+			seeker.Seek(0, io.SeekStart)
+		}
+		if err := s.embedStream(r, flags, o); err != nil {
+			return err
+		}
+		qerr := gate.check(s, adaptive)
+		if qerr == nil {
+			return nil
+		}
+		if !adaptive {
+			s.logger().Error(qerr)
+			return qerr
+		}
+		s.logger().Warning(qerr, "- falling back to plain LSBs")
+	}
+	return nil
+}
+
+// check measures the stego image against the cover
+func (g *qualityGate) check(s *StegImage, adaptive bool) *QualityError {
+	qerr := &QualityError{Mode: "lsb", MinPSNR: g.minPSNR, MinSSIM: g.minSSIM}
+	if adaptive {
+		qerr.Mode = "adaptive"
+	}
+	qerr.PSNR, qerr.SSIM = PSNR(s.imgLoaded, s.newImg), SSIM(s.imgLoaded, s.newImg)
+	if qerr.PSNR >= g.minPSNR && qerr.SSIM >= g.minSSIM {
+		return nil
+	}
+	return qerr
+}
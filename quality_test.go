@@ -0,0 +1,54 @@
+package libsteg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestQualityGate verifies adaptive depth falls back to plain LSBs when
+// it would break the gate, and a gate plain LSBs can't meet fails
+func TestQualityGate(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(strings.Repeat(secretStringIn, 1000))
+	reports, err := cleanImg.EvaluateModes(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsb, adaptive := reports[0].PSNR, reports[1].PSNR
+	if adaptive >= lsb {
+		t.Fatalf("Expected adaptive depth to distort more, PSNR %.2f against %.2f", adaptive, lsb)
+	}
+
+	opts := []Option{WithPassphrase("hunter2"), WithAdaptiveDepth()}
+	if err = cleanImg.EmbedBytes(payload, append(opts, WithQualityGate((lsb+adaptive)/2, 0))...); err != nil {
+		t.Fatal(err)
+	}
+	if psnr := PSNR(cleanImg.Loaded(), cleanImg.Stego()); psnr < (lsb+adaptive)/2 {
+		t.Errorf("Gate not kept, PSNR %.2f", psnr)
+	}
+	stego := reloadImage(t, &cleanImg)
+	for _, extractOpts := range [][]Option{opts, {WithPassphrase("hunter2")}} {
+		payloadOut, err := stego.ExtractBytes(extractOpts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != string(payload) {
+			t.Error("Payloads Do Not Match!")
+		}
+	}
+
+	err = cleanImg.EmbedBytes(payload, append(opts, WithQualityGate(lsb+10, 0))...)
+	var qerr *QualityError
+	if !errors.As(err, &qerr) || qerr.Mode != "lsb" || qerr.MinPSNR != lsb+10 {
+		t.Errorf("Expected a quality error for plain LSBs, got: %v", err)
+	}
+}
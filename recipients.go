@@ -0,0 +1,160 @@
+package libsteg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// fileKeyLen is the length of the random key the payload is encrypted
+	// with, wrapped once per recipient
+	fileKeyLen = 32
+	// stanzaLen is an ephemeral X25519 public key followed by the sealed
+	// file key
+	stanzaLen = 32 + fileKeyLen + 16
+	// recipientInfo binds derived wrapping keys to their purpose
+	recipientInfo = "libsteg recipient v1"
+	// maxStanzas bounds the recipient count read from a stream, as no
+	// image holds more stanzas than this
+	maxStanzas = 1 << 16
+)
+
+var (
+	// ErrNotRecipient is returned when the payload wasn't encrypted to the
+	// given identity
+	ErrNotRecipient      = errors.New("payload not encrypted to this identity")
+	errIdentityRequired  = errors.New("payload is encrypted to recipients, identity required")
+	errRecipientsAndPass = errors.New("passphrase and recipients can't both encrypt a payload")
+)
+
+// WithRecipients encrypts the payload to one or more X25519 public keys so
+// no passphrase has to be shared. Any one of the matching private keys can
+// extract it using WithIdentity. A passphrase may still be given alongside
+// recipients, but only to key WithHMAC.
+func WithRecipients(recipients ...*ecdh.PublicKey) Option {
+	return func(o *options) {
+		o.recipients = append(o.recipients, recipients...)
+	}
+}
+
+// WithIdentity sets the X25519 private key used to extract a payload
+// encrypted with WithRecipients
+func WithIdentity(identity *ecdh.PrivateKey) Option {
+	return func(o *options) {
+		o.identity = identity
+	}
+}
+
+// wrapKey derives the key wrapping the file key for one recipient
+func wrapKey(shared []byte, ephemeral []byte, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, recipientInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newRecipientWriter writes a stanza per recipient holding the wrapped file
+// key, then returns a chunk encrypting writer keyed by the file key
+func newRecipientWriter(w io.Writer, recipients []*ecdh.PublicKey, rng io.Reader) (io.WriteCloser, error) {
+	fileKey := make([]byte, fileKeyLen)
+	if _, err := io.ReadFull(rng, fileKey); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	writeUvarint(buf, uint64(len(recipients)))
+	for _, recipient := range recipients {
+		scalar := make([]byte, 32)
+		if _, err := io.ReadFull(rng, scalar); err != nil {
+			return nil, err
+		}
+		ephemeral, err := ecdh.X25519().NewPrivateKey(scalar)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := ephemeral.ECDH(recipient)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := wrapKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		// Each wrapping key is used exactly once, so a zero nonce is safe
+		buf.Write(ephemeral.PublicKey().Bytes())
+		buf.Write(aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil))
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return newKeyedEncryptWriter(w, fileKey, rng)
+}
+
+// decryptForIdentity unwraps the file key from the stanza matching the
+// identity and decrypts the payload stream following the stanzas
+func decryptForIdentity(data []byte, identity *ecdh.PrivateKey) ([]byte, error) {
+	dr, err := newIdentityDecryptReader(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+// newIdentityDecryptReader returns a reader decrypting the recipient
+// encrypted stream read from r with the identity
+func newIdentityDecryptReader(r io.Reader, identity *ecdh.PrivateKey) (io.Reader, error) {
+	br, ok := r.(interface {
+		io.Reader
+		io.ByteReader
+	})
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil || count > maxStanzas {
+		return nil, ErrDecryptFailed
+	}
+
+	var fileKey []byte
+	stanza := make([]byte, stanzaLen)
+	for i := uint64(0); i < count; i++ {
+		if _, err = io.ReadFull(br, stanza); err != nil {
+			return nil, ErrDecryptFailed
+		}
+		if fileKey != nil {
+			continue
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:32])
+		if err != nil {
+			continue
+		}
+		shared, err := identity.ECDH(ephemeral)
+		if err != nil {
+			continue
+		}
+		aead, err := wrapKey(shared, stanza[:32], identity.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		fileKey, _ = aead.Open(nil, make([]byte, aead.NonceSize()), stanza[32:], nil)
+	}
+	if fileKey == nil {
+		return nil, ErrNotRecipient
+	}
+	return newKeyedDecryptReader(br, fileKey)
+}
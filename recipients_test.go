@@ -0,0 +1,44 @@
+package libsteg
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BRecipients does a back to back test of a payload encrypted to two
+// recipients, checking each can extract it and an outsider can't
+func TestB2BRecipients(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	alice, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	bob, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	eve, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	err := cleanImg.EmbedBytes([]byte(secretStringIn), WithRecipients(alice.PublicKey(), bob.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperedImg := reloadImage(t, &cleanImg)
+
+	for _, identity := range []*ecdh.PrivateKey{alice, bob} {
+		payloadOut, err := tamperedImg.ExtractBytes(WithIdentity(identity))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != secretStringIn {
+			t.Error("Secrets Do Not Match!")
+		}
+	}
+
+	if _, err = tamperedImg.ExtractBytes(WithIdentity(eve)); err != ErrNotRecipient {
+		t.Error("Correct Error not thrown, expected: '" + ErrNotRecipient.Error() + "' got: '" + errString(err) + "'")
+	}
+}
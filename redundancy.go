@@ -0,0 +1,93 @@
+package libsteg
+
+import (
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/draw"
+)
+
+var errRedundancyUnsupported = errors.New("redundancy needs plain LSB embedding of every channel in column-major order, in an image without palette transparency")
+
+// WithRedundancy embeds the payload k times, each copy scattered over its
+// own key-derived share of the pixels, and reads every bit back by majority
+// vote. A payload then survives local edits, such as a cropped corner or a
+// scribble, that damage fewer than half its copies of any bit, at the cost
+// of k times the space. An odd k avoids tied votes, which read as zero.
+// The passphrase, if any, keys the locations; the same k must be given on
+// extraction. Any payload already in the image is overwritten.
+func WithRedundancy(k int) Option {
+	return func(o *options) {
+		o.redundancy = k
+	}
+}
+
+// redundantPlacement writes each bit to the same index of several disjoint
+// placements and reads it back by majority vote
+type redundantPlacement struct {
+	copies []placement
+}
+
+// redundantPlacement returns the options' redundant placement over img,
+// each copy wrapped by wrap
+func (o *options) redundantPlacement(img image.Image, wrap func(placement) placement) (placement, error) {
+	if _, ok := newSequentialPlacement(img).(sequentialPlacement); !ok ||
+		o.adaptive || o.scanOrder != ScanColumnMajor || o.channelOrder != "" {
+		return nil, errRedundancyUnsupported
+	}
+	key, err := placementKey(o.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	p := redundantPlacement{copies: make([]placement, o.redundancy)}
+	for i := range p.copies {
+		// Each copy is permuted with its own key, so a local edit hits
+		// different bits of each
+		copyKey := sha256.Sum256(append([]byte{byte(i)}, key...))
+		place, err := newKeyedPlacement(img, copyKey[:16], i, o.redundancy)
+		if err != nil {
+			return nil, err
+		}
+		p.copies[i] = wrap(place)
+	}
+	return p, nil
+}
+
+func (p redundantPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	return p.copies[0].locate(bitIndex)
+}
+
+// capacity is that of the smallest copy, the slots differ by a pixel at
+// most
+func (p redundantPlacement) capacity() int {
+	n := p.copies[0].capacity()
+	for _, c := range p.copies[1:] {
+		n = minInt(n, c.capacity())
+	}
+	return n
+}
+
+func (p redundantPlacement) maskBit(bitIndex int) byte {
+	return maskBit(p.copies[0], bitIndex)
+}
+
+// set writes bit to every copy
+func (p redundantPlacement) set(img draw.Image, bitIndex int, bit byte) {
+	for _, c := range p.copies {
+		x, y, ch, plane := c.locate(bitIndex)
+		setBit(img, x, y, ch, plane, bit^maskBit(c, bitIndex))
+	}
+}
+
+// vote returns the bit most copies hold
+func (p redundantPlacement) vote(img image.Image, bitIndex int) byte {
+	ones := 0
+	for _, c := range p.copies {
+		x, y, ch, plane := c.locate(bitIndex)
+		ones += int(getBit(img, x, y, ch, plane) ^ maskBit(c, bitIndex))
+	}
+	if ones*2 > len(p.copies) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestRedundancy verifies a payload embedded several times survives a
+// corner being drawn over, where a single copy doesn't
+func TestRedundancy(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := bytes.Repeat([]byte(secretStringIn), 10)
+	scribble := func(opts ...Option) (*StegImage, error) {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedBytes(payloadIn, opts...); err != nil {
+			return nil, err
+		}
+		white := image.NewUniform(color.White)
+		draw.Draw(stegImg.newImg, image.Rect(0, 0, 20, 20), white, image.Point{}, draw.Src)
+		return reloadImage(t, &stegImg), nil
+	}
+
+	opts := []Option{WithRedundancy(3), WithPassphrase("hunter2"), WithSeed([]byte("redundancy"))}
+	stego, err := scribble(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stego.ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payloadOut, payloadIn) {
+		t.Error("Payloads Do Not Match!")
+	}
+	if _, err := stego.ExtractBytes(WithPassphrase("hunter2")); err == nil {
+		t.Error("Extracted without the redundancy option")
+	}
+
+	stego, err = scribble(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stego.ExtractBytes(WithPassphrase("hunter2")); err == nil {
+		t.Error("Single copy survived the scribble")
+	}
+
+	if _, err := scribble(WithRedundancy(3), WithScanOrder(ScanRowMajor)); err != errRedundancyUnsupported {
+		t.Error("Correct Error not thrown, expected: '" + errRedundancyUnsupported.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,170 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// ResizeFilter selects how Resize resamples the image
+type ResizeFilter uint8
+
+// Supported resize filters
+const (
+	// ResizeNearest picks the closest source pixel, keeping paletted
+	// images paletted
+	ResizeNearest ResizeFilter = iota
+	// ResizeBilinear interpolates between the four closest source pixels
+	ResizeBilinear
+	// ResizeBox averages every source pixel the output pixel covers, the
+	// best choice for thumbnails
+	ResizeBox
+)
+
+var (
+	errBadResize     = errors.New("resize dimensions must be positive")
+	errUnknownFilter = errors.New("unknown resize filter")
+)
+
+// Resize extracts the payload from the loaded image, resizes the image to
+// width by height with filter, and embeds the payload again into the
+// resized result, so stego assets can be thumbnailed without losing their
+// data. The same options are used to extract and embed, and any metadata
+// is carried over. On success the resized cover replaces the loaded image
+// and the new image holds the payload, ready to be written.
+func (s *StegImage) Resize(width int, height int, filter ResizeFilter, opts ...Option) error {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+	if width <= 0 || height <= 0 {
+		s.logger().Error(errBadResize)
+		return errBadResize
+	}
+	payload, meta, err := s.ExtractWithMetadata(opts...)
+	if err != nil {
+		return err
+	}
+	resized, err := resizeImage(s.imgLoaded, width, height, filter)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+
+	if meta != nil && newOptions(opts).metadata == nil {
+		opts = append(opts, WithMetadata(*meta))
+	}
+	resizedImg := StegImage{imgLoaded: resized, imgType: s.imgType, pngProfile: s.pngProfile}
+	if err = resizedImg.EmbedBytes(payload, opts...); err != nil {
+		return err
+	}
+	s.imgLoaded, s.newImg = resizedImg.imgLoaded, resizedImg.newImg
+	return nil
+}
+
+// resizeImage resamples src to width by height, keeping its pixel format
+// where the filter allows
+func resizeImage(src image.Image, width int, height int, filter ResizeFilter) (image.Image, error) {
+	bounds := src.Bounds()
+	rect := image.Rect(0, 0, width, height)
+	xTaps, err := resizeTaps(bounds.Min.X, bounds.Dx(), width, filter)
+	if err != nil {
+		return nil, err
+	}
+	yTaps, _ := resizeTaps(bounds.Min.Y, bounds.Dy(), height, filter)
+
+	if p, ok := src.(*image.Paletted); ok && filter == ResizeNearest {
+		dst := image.NewPaletted(rect, p.Palette)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				dst.SetColorIndex(x, y, p.ColorIndexAt(xTaps[x][0].index, yTaps[y][0].index))
+			}
+		}
+		return dst, nil
+	}
+
+	// Average in premultiplied 16-bit, then convert to the source's format
+	tmp := image.NewRGBA64(rect)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var sum [4]float64
+			for _, ty := range yTaps[y] {
+				for _, tx := range xTaps[x] {
+					r, g, b, a := src.At(tx.index, ty.index).RGBA()
+					w := tx.weight * ty.weight
+					sum[0] += w * float64(r)
+					sum[1] += w * float64(g)
+					sum[2] += w * float64(b)
+					sum[3] += w * float64(a)
+				}
+			}
+			var c [4]uint16
+			for i, v := range sum {
+				c[i] = uint16(math.Min(math.Max(math.Round(v), 0), 0xffff))
+			}
+			tmp.SetRGBA64(x, y, color.RGBA64{c[0], c[1], c[2], c[3]})
+		}
+	}
+
+	var dst draw.Image
+	switch src.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model:
+		dst = image.NewNRGBA64(rect)
+	case color.GrayModel:
+		dst = image.NewGray(rect)
+	case color.Gray16Model:
+		dst = image.NewGray16(rect)
+	default:
+		dst = image.NewNRGBA(rect)
+	}
+	draw.Draw(dst, rect, tmp, image.Point{}, draw.Src)
+	return dst, nil
+}
+
+// resizeTap is one source pixel contributing to an output pixel
+type resizeTap struct {
+	index  int
+	weight float64
+}
+
+// resizeTaps lists, for each of n output pixels along one axis, the source
+// pixels from the srcLen starting at min that make it up, weights summing
+// to one
+func resizeTaps(min int, srcLen int, n int, filter ResizeFilter) ([][]resizeTap, error) {
+	scale := float64(srcLen) / float64(n)
+	taps := make([][]resizeTap, n)
+	for i := range taps {
+		switch filter {
+		case ResizeNearest:
+			src := int((float64(i) + 0.5) * scale)
+			taps[i] = []resizeTap{{min + minInt(src, srcLen-1), 1}}
+		case ResizeBilinear:
+			centre := (float64(i)+0.5)*scale - 0.5
+			lo := math.Floor(centre)
+			frac := centre - lo
+			a, b := clampInt(int(lo), 0, srcLen-1), clampInt(int(lo)+1, 0, srcLen-1)
+			taps[i] = []resizeTap{{min + a, 1 - frac}, {min + b, frac}}
+		case ResizeBox:
+			start, end := float64(i)*scale, float64(i+1)*scale
+			for src := int(start); float64(src) < end && src < srcLen; src++ {
+				cover := math.Min(end, float64(src+1)) - math.Max(start, float64(src))
+				taps[i] = append(taps[i], resizeTap{min + src, cover / scale})
+			}
+		default:
+			return nil, errUnknownFilter
+		}
+	}
+	return taps, nil
+}
+
+func clampInt(v int, lo int, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
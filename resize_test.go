@@ -0,0 +1,75 @@
+package libsteg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestResizeKeepsPayload verifies the payload and metadata survive a
+// thumbnail with each filter
+func TestResizeKeepsPayload(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, filter := range []ResizeFilter{ResizeNearest, ResizeBilinear, ResizeBox} {
+		var cleanImg StegImage
+		if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+			t.Fatal(err)
+		}
+		meta := Metadata{ContentType: "text/plain"}
+		if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithPassphrase("pass"), WithMetadata(meta)); err != nil {
+			t.Fatal(err)
+		}
+		stego := reloadImage(t, &cleanImg)
+
+		bounds := stego.imgLoaded.Bounds()
+		width, height := bounds.Dx()/2, bounds.Dy()/2
+		if err := stego.Resize(width, height, filter, WithPassphrase("pass")); err != nil {
+			t.Fatal(err)
+		}
+		if got := stego.newImg.Bounds(); got.Dx() != width || got.Dy() != height {
+			t.Fatalf("Resized to %v, expected %dx%d", got, width, height)
+		}
+		payloadOut, metaOut, err := reloadImage(t, stego).ExtractWithMetadata(WithPassphrase("pass"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != secretStringIn || metaOut == nil || metaOut.ContentType != meta.ContentType {
+			t.Errorf("Payload or metadata lost with filter %d", filter)
+		}
+	}
+}
+
+// TestResizeFilters checks the filters on a known gradient
+func TestResizeFilters(t *testing.T) {
+	t.Parallel()
+
+	src := image.NewGray(image.Rect(0, 0, 4, 1))
+	for x, v := range []uint8{0, 100, 200, 40} {
+		src.SetGray(x, 0, color.Gray{v})
+	}
+	for filter, want := range map[ResizeFilter][]uint8{
+		ResizeNearest:  {100, 40},
+		ResizeBilinear: {50, 120},
+		ResizeBox:      {50, 120},
+	} {
+		out, err := resizeImage(src, 2, 1, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gray, ok := out.(*image.Gray)
+		if !ok {
+			t.Fatalf("Filter %d didn't keep the grayscale format", filter)
+		}
+		if gray.Pix[0] != want[0] || gray.Pix[1] != want[1] {
+			t.Errorf("Filter %d gave %v, expected %v", filter, gray.Pix, want)
+		}
+	}
+
+	if _, err := resizeImage(src, 2, 1, ResizeFilter(99)); err != errUnknownFilter {
+		t.Error("Correct Error not thrown, expected: '" + errUnknownFilter.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,130 @@
+package libsteg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// saltMaskedPlanes is how many low bit planes are left out of the cover
+// hash, enough for the deepest adaptive embedding
+const saltMaskedPlanes = 3
+
+// WithCoverSalt whitens every embedded bit, header included, with a
+// keystream salted by the cover itself, so the same payload embedded into
+// many covers leaves unrelated bitstreams and can't be correlated across
+// images. The salt is a hash of the cover's pixels above the bit planes
+// embedding changes, so nothing extra is stored and deterministic
+// embedding stays deterministic. The same option must be given on
+// extraction, and payloads written with it aren't visible to Slots, scans
+// or existing payload handling.
+func WithCoverSalt() Option {
+	return func(o *options) {
+		o.coverSalt = true
+	}
+}
+
+// bitMask is implemented by placements whose bits are whitened, giving the
+// keystream bit to XOR with each stored bit
+type bitMask interface {
+	maskBit(bitIndex int) byte
+}
+
+// maskBit returns the keystream bit for bitIndex if place is whitened
+func maskBit(place placement, bitIndex int) byte {
+	if m, ok := place.(bitMask); ok {
+		return m.maskBit(bitIndex)
+	}
+	return 0
+}
+
+// saltedPlacement whitens the bits of a placement with AES-CTR keyed by
+// the cover salt, seekable so any bit can be read on its own
+type saltedPlacement struct {
+	placement
+	block  cipher.Block
+	cached int // Keystream block held in buf, or -1
+	buf    [aes.BlockSize]byte
+}
+
+// saltPlacement wraps place in a saltedPlacement for img if the options
+// ask for one
+func saltPlacement(img image.Image, place placement, o *options) placement {
+	if !o.coverSalt {
+		return place
+	}
+	mac := hmac.New(sha256.New, []byte("libsteg cover salt:"+o.passphrase))
+	writeCoverHash(mac, img)
+	block, _ := aes.NewCipher(mac.Sum(nil))
+	return &saltedPlacement{placement: place, block: block, cached: -1}
+}
+
+func (p *saltedPlacement) maskBit(bitIndex int) byte {
+	blockIndex := bitIndex / (aes.BlockSize * 8)
+	if blockIndex != p.cached {
+		var counter [aes.BlockSize]byte
+		binary.BigEndian.PutUint64(counter[8:], uint64(blockIndex))
+		p.block.Encrypt(p.buf[:], counter[:])
+		p.cached = blockIndex
+	}
+	bit := bitIndex % (aes.BlockSize * 8)
+	return p.buf[bit/8] >> uint(7-bit%8) & 1
+}
+
+// writeCoverHash writes the pixels of img to w with the bit planes that
+// embedding may change cleared, so a cover and its stego image hash alike.
+// Opaque 8-bit RGBA and NRGBA share a layout, as do their 16-bit forms,
+// since PNG round trips of opaque images may swap one for the other.
+func writeCoverHash(w io.Writer, img image.Image) {
+	const c = ^byte(1<<saltMaskedPlanes - 1) // Colour byte holding the LSBs
+	var pix []byte
+	var stride int
+	var mask []byte // Applied to the bytes of each pixel
+	bounds := img.Bounds()
+	switch img := img.(type) {
+	case *image.RGBA:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, []byte{c, c, c, 0xff}
+	case *image.NRGBA:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, []byte{c, c, c, 0xff}
+	case *image.RGBA64:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride,
+			[]byte{0xff, c, 0xff, c, 0xff, c, 0xff, 0xff}
+	case *image.NRGBA64:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride,
+			[]byte{0xff, c, 0xff, c, 0xff, c, 0xff, 0xff}
+	case *image.Gray:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, []byte{c}
+	case *image.Gray16:
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, []byte{0xff, c}
+	case *image.Paletted:
+		for _, col := range img.Palette {
+			r, g, b, a := col.RGBA()
+			binary.Write(w, binary.BigEndian, [4]uint16{uint16(r), uint16(g), uint16(b), uint16(a)})
+		}
+		pix, stride, mask = img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride, []byte{c}
+	default:
+		row := make([]byte, 0, bounds.Dx()*4)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row = row[:0]
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := img.At(x, y).RGBA()
+				row = append(row, byte(r>>8)&c, byte(g>>8)&c, byte(b>>8)&c, byte(a>>8))
+			}
+			w.Write(row)
+		}
+		return
+	}
+
+	row := make([]byte, bounds.Dx()*len(mask))
+	for y := 0; y < bounds.Dy(); y++ {
+		copy(row, pix[y*stride:])
+		for i := range row {
+			row[i] &= mask[i%len(mask)]
+		}
+		w.Write(row)
+	}
+}
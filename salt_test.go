@@ -0,0 +1,88 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// embedLSBs embeds payload into cover and returns the reloaded stego image
+// and the first n bytes of its sequential LSB stream
+func embedLSBs(t *testing.T, cover image.Image, payload string, n int, opts ...Option) (*StegImage, []byte) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, cover); err != nil {
+		t.Fatal(err)
+	}
+	var img StegImage
+	if err := img.loadImage(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := img.EmbedBytes([]byte(payload), opts...); err != nil {
+		t.Fatal(err)
+	}
+	var stego StegImage
+	if err := stego.loadImage(bytes.NewReader(pngBytes(t, &img))); err != nil {
+		t.Fatal(err)
+	}
+	lsbs := make([]byte, n)
+	r := &lsbReader{img: stego.imgLoaded, place: newSequentialPlacement(stego.imgLoaded)}
+	if _, err := io.ReadFull(r, lsbs); err != nil {
+		t.Fatal(err)
+	}
+	return &stego, lsbs
+}
+
+// TestCoverSalt verifies the same payload leaves unrelated bitstreams in
+// different covers, and still round trips
+func TestCoverSalt(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	other := image.NewRGBA(cleanImg.imgLoaded.Bounds())
+	draw.Draw(other, other.Bounds(), cleanImg.imgLoaded, other.Bounds().Min, draw.Src)
+	for i := range other.Pix {
+		if i%4 != 3 {
+			other.Pix[i] ^= 0x80
+		}
+	}
+
+	const n = 32
+	_, plainA := embedLSBs(t, cleanImg.imgLoaded, secretStringIn, n)
+	_, plainB := embedLSBs(t, other, secretStringIn, n)
+	if !bytes.Equal(plainA, plainB) {
+		t.Fatal("Unsalted bitstreams expected to match")
+	}
+
+	for i, opts := range [][]Option{
+		{WithCoverSalt()},
+		{WithCoverSalt(), WithPassphrase("pass"), WithAdaptiveDepth(), WithDeterministic()},
+	} {
+		stegoA, saltedA := embedLSBs(t, cleanImg.imgLoaded, secretStringIn, n, opts...)
+		_, saltedB := embedLSBs(t, other, secretStringIn, n, opts...)
+		// Adaptive embedding doesn't start at the sequential LSBs
+		if i == 0 && (bytes.Equal(saltedA[:4], saltedB[:4]) || bytes.Equal(saltedA[:4], []byte(envelopeMagic))) {
+			t.Error("Salted bitstreams not whitened")
+		}
+
+		payloadOut, err := stegoA.ExtractBytes(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != secretStringIn {
+			t.Error("Secrets Do Not Match!")
+		}
+		if _, err = stegoA.ExtractBytes(opts[1:]...); err == nil {
+			t.Error("Extracted a salted payload without WithCoverSalt")
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// PayloadInfo describes an embedded payload as stored, without opening it
+type PayloadInfo struct {
+	Name      string   // Slot name, empty if not embedded with EmbedNamed
+	Bytes     int      // Bytes the stored data occupies
+	Encrypted bool     // Whether the data is encrypted
+	Digest    [32]byte // SHA-256 of the stored data
+}
+
+// ScanResult lists the payloads found in one scanned image
+type ScanResult struct {
+	Path     string
+	Payloads []PayloadInfo
+	Err      error // Set if the image couldn't be read
+}
+
+// Duplicate is a set of payloads with identical stored data
+type Duplicate struct {
+	Digest [32]byte
+	Bytes  int
+	Paths  []string // One entry per copy, a path repeats if it holds several
+}
+
+// ScanFS walks fsys and lists the payloads held in every image found.
+// Payloads are never decrypted, so no keys are needed. Files that aren't
+// in a registered image format are skipped.
+func ScanFS(fsys fs.FS, opts ...ScanOption) ([]ScanResult, error) {
+	var walkErr error
+	results := newScanOptions(opts).pipeline(func(read func(path string, open func() (io.ReadCloser, error))) {
+		walkErr = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			read(path, func() (io.ReadCloser, error) { return fsys.Open(path) })
+			return nil
+		})
+	})
+	if walkErr != nil {
+		log.Error(walkErr)
+		return nil, walkErr
+	}
+	return results, nil
+}
+
+// ScanBatch lists the payloads held in each of the given image files, as
+// ScanFS does for a file system
+func ScanBatch(paths []string, opts ...ScanOption) []ScanResult {
+	return newScanOptions(opts).pipeline(func(read func(path string, open func() (io.ReadCloser, error))) {
+		for _, path := range paths {
+			path := path
+			read(path, func() (io.ReadCloser, error) { return os.Open(path) })
+		}
+	})
+}
+
+// scanImage reads the payloads from the image read from r, ok is false if
+// r doesn't hold a registered image format
+func scanImage(path string, r io.Reader) (result ScanResult, ok bool) {
+	result.Path = path
+	var s StegImage
+	if err := s.loadImage(r); err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return result, false
+		}
+		result.Err = err
+		return result, true
+	}
+	result.Payloads, result.Err = s.payloadInfo()
+	return result, true
+}
+
+// payloadInfo describes each payload chained into the loaded image
+func (s *StegImage) payloadInfo() (infos []PayloadInfo, err error) {
+	place := newSequentialPlacement(s.imgLoaded)
+	offsets, _ := s.envelopeOffsets()
+	for _, offset := range offsets {
+		e, dataLen, err := s.readEnvelopeHeader(place, offset)
+		if err != nil {
+			return nil, err
+		}
+		data, err := s.readBytes(place, offset+e.headerLen(), dataLen)
+		if err != nil {
+			return nil, ErrNoPayload
+		}
+		infos = append(infos, PayloadInfo{
+			Name:      e.name,
+			Bytes:     dataLen,
+			Encrypted: e.flags&(flagEncrypted|flagRecipients) != 0,
+			Digest:    sha256.Sum256(data),
+		})
+	}
+	return infos, nil
+}
+
+// FindDuplicates groups the scanned payloads by digest, returning the
+// groups stored more than once ordered by wasted bytes, largest first.
+// Encrypted payloads only match when they are byte identical copies, e.g.
+// from copying an image or embedding WithDeterministic.
+func FindDuplicates(results []ScanResult) []Duplicate {
+	byDigest := make(map[[32]byte]*Duplicate)
+	var order []*Duplicate
+	for _, result := range results {
+		for _, p := range result.Payloads {
+			d, ok := byDigest[p.Digest]
+			if !ok {
+				d = &Duplicate{Digest: p.Digest, Bytes: p.Bytes}
+				byDigest[p.Digest] = d
+				order = append(order, d)
+			}
+			d.Paths = append(d.Paths, result.Path)
+		}
+	}
+
+	var dups []Duplicate
+	for _, d := range order {
+		if len(d.Paths) > 1 {
+			dups = append(dups, *d)
+		}
+	}
+	sort.SliceStable(dups, func(i, j int) bool {
+		wi, wj := dups[i].Bytes*(len(dups[i].Paths)-1), dups[j].Bytes*(len(dups[j].Paths)-1)
+		if wi != wj {
+			return wi > wj
+		}
+		return bytes.Compare(dups[i].Digest[:], dups[j].Digest[:]) < 0
+	})
+	return dups
+}
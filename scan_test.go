@@ -0,0 +1,69 @@
+package libsteg
+
+import (
+	"encoding/base64"
+	"testing"
+	"testing/fstest"
+
+	logging "github.com/op/go-logging"
+)
+
+// stegPNG embeds payload into the clean test image and returns the PNG
+func stegPNG(t *testing.T, payload string, opts ...Option) []byte {
+	t.Helper()
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(payload), opts...); err != nil {
+		t.Fatal(err)
+	}
+	return pngBytes(t, &cleanImg)
+}
+
+// pngBytes returns the manipulated image as an encoded PNG
+func pngBytes(t *testing.T, s *StegImage) []byte {
+	t.Helper()
+	b64, err := s.WriteNewImageToB64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// TestFindDuplicates verifies identical stored payloads are grouped without
+// a passphrase and non-image files are skipped
+func TestFindDuplicates(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	fsys := fstest.MapFS{
+		"a.png":       {Data: stegPNG(t, secretStringIn, WithPassphrase("pass"), WithDeterministic())},
+		"sub/b.png":   {Data: stegPNG(t, secretStringIn, WithPassphrase("pass"), WithDeterministic())},
+		"c.png":       {Data: stegPNG(t, "something else")},
+		"notes.txt":   {Data: []byte("not an image")},
+		"clean/d.png": {Data: stegPNG(t, secretStringIn, WithPassphrase("pass"))},
+	}
+	results, err := ScanFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 scanned images, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Err != nil || len(result.Payloads) != 1 {
+			t.Errorf("Unexpected scan result for %s: %+v", result.Path, result)
+		}
+	}
+
+	dups := FindDuplicates(results)
+	if len(dups) != 1 || len(dups[0].Paths) != 2 ||
+		dups[0].Paths[0] != "a.png" || dups[0].Paths[1] != "sub/b.png" {
+		t.Errorf("Unexpected duplicates: %+v", dups)
+	}
+}
@@ -0,0 +1,66 @@
+package libsteg
+
+import "errors"
+
+// ErrScanLimit is returned when extraction would read past the scan budget
+// set by WithMaxScanBits or WithMaxScanPixels before finding a payload
+var ErrScanLimit = errors.New("scan limit reached before a payload was found")
+
+// WithMaxScanBits stops extraction after reading n embedded bits, failing
+// with ErrScanLimit, so untrusted images can't make it decode the whole
+// image before concluding there's no payload
+func WithMaxScanBits(n int) Option {
+	return func(o *options) {
+		o.maxScanBits = n
+	}
+}
+
+// WithMaxScanPixels stops extraction after the bits held by n pixels, as
+// WithMaxScanBits does
+func WithMaxScanPixels(n int) Option {
+	return func(o *options) {
+		o.maxScanPixels = n
+	}
+}
+
+// scanBudget returns the number of bits extraction may read from an image
+// with the given number of channels per pixel, or 0 for no limit
+func (o *options) scanBudget(channels int) int {
+	budget := o.maxScanBits
+	if o.maxScanPixels > 0 && (budget == 0 || o.maxScanPixels*channels < budget) {
+		budget = o.maxScanPixels * channels
+	}
+	return budget
+}
+
+// scanLimitedPlacement cuts a placement short at the scan budget
+type scanLimitedPlacement struct {
+	placement
+	limit int
+}
+
+// scanLimit applies the options' scan budget to place
+func (o *options) scanLimit(place placement, channels int) placement {
+	budget := o.scanBudget(channels)
+	if budget <= 0 || budget >= place.capacity() {
+		return place
+	}
+	return scanLimitedPlacement{placement: place, limit: budget}
+}
+
+func (p scanLimitedPlacement) capacity() int {
+	return p.limit
+}
+
+func (p scanLimitedPlacement) maskBit(bitIndex int) byte {
+	return maskBit(p.placement, bitIndex)
+}
+
+// noPayload reports a failed read as ErrNoPayload, unless it was cut short
+// by the scan budget
+func noPayload(err error) error {
+	if err == ErrScanLimit {
+		return ErrScanLimit
+	}
+	return ErrNoPayload
+}
@@ -0,0 +1,76 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestScanLimit verifies extraction succeeds within its scan budget and
+// gives up with ErrScanLimit once past it
+func TestScanLimit(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	payload := bytes.Repeat([]byte(secretStringIn), 100)
+	if err := stegImg.EmbedBytes(payload); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := reloadImage(t, &stegImg)
+	stored := (envelopeHeaderLen + len(payload) + 4) * 8
+
+	secret, err := reloaded.ExtractBytes(WithMaxScanBits(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, payload) {
+		t.Error("Extracted payload differs")
+	}
+	for _, opt := range []Option{WithMaxScanBits(stored - 8), WithMaxScanPixels(stored/3 - 8), WithMaxScanBits(8)} {
+		if _, err := reloaded.ExtractBytes(opt); err != ErrScanLimit {
+			t.Error("Correct Error not thrown, expected: '" + ErrScanLimit.Error() + "' got: '" + errString(err) + "'")
+		}
+		if _, err := reloaded.ExtractTo(new(bytes.Buffer), opt); err != ErrScanLimit {
+			t.Error("Correct Error not thrown, expected: '" + ErrScanLimit.Error() + "' got: '" + errString(err) + "'")
+		}
+	}
+
+	// A clean image is still found empty when the header fits the budget
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cleanImg.ExtractBytes(WithMaxScanBits(1024)); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestScanLimitLegacy verifies the scan budget bounds string extraction
+func TestScanLimitLegacy(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cleanImg.DoStegExtract(WithMaxScanPixels(100)); err != ErrScanLimit {
+		t.Error("Correct Error not thrown, expected: '" + ErrScanLimit.Error() + "' got: '" + errString(err) + "'")
+	}
+
+	if err := cleanImg.DoStegEmbed(secretStringIn); err != nil {
+		t.Fatal(err)
+	}
+	secretOut, err := reloadImage(t, &cleanImg).DoStegExtract(WithMaxScanPixels(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secretOut != secretStringIn {
+		t.Errorf("Extracted %q, expected %q", secretOut, secretStringIn)
+	}
+}
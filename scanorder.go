@@ -0,0 +1,129 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+)
+
+// ScanOrder is the order pixels are walked in when embedding
+type ScanOrder int
+
+const (
+	// ScanColumnMajor walks each column top to bottom, left to right. It's
+	// the default, and the order legacy string embedding uses.
+	ScanColumnMajor ScanOrder = iota
+	// ScanRowMajor walks each row left to right, top to bottom, as most
+	// other LSB tools do
+	ScanRowMajor
+	// ScanZigzag walks rows alternately left to right and right to left,
+	// top to bottom
+	ScanZigzag
+)
+
+// flagScanOrder holds the ScanOrder of the payload in the top two envelope
+// flag bits, column-major being zero so older envelopes read as such
+const (
+	flagScanOrder      uint16 = 3 << scanOrderFlagShift
+	scanOrderFlagShift        = 14
+)
+
+var (
+	errScanOrder            = errors.New("unknown scan order")
+	errScanOrderUnsupported = errors.New("scan orders other than column-major need plain LSB embedding of an image without palette transparency")
+	errScanOrderAppend      = errors.New("can't append to existing payloads in a different scan order")
+)
+
+// WithScanOrder walks pixels in the given order when embedding. The order
+// is recorded in the header, and the header itself written in that order,
+// so extraction finds it without being told. Custom codecs have no header
+// to record it in, so the same order must be given on extraction. Adaptive
+// depth and paletted images with transparency only support ScanColumnMajor.
+func WithScanOrder(order ScanOrder) Option {
+	return func(o *options) {
+		o.scanOrder = order
+	}
+}
+
+// scanOrder returns the order the envelope's payload was embedded in
+func (e *envelope) scanOrder() ScanOrder {
+	return ScanOrder(e.flags & flagScanOrder >> scanOrderFlagShift)
+}
+
+// orderedPlacement walks the pixels of an image in a ScanOrder other than
+// column-major
+type orderedPlacement struct {
+	bounds   image.Rectangle
+	channels int
+	order    ScanOrder
+}
+
+// newOrderedPlacement returns a placement walking img in order
+func newOrderedPlacement(img image.Image, order ScanOrder) (placement, error) {
+	place := newSequentialPlacement(img)
+	switch {
+	case order == ScanColumnMajor:
+		return place, nil
+	case order != ScanRowMajor && order != ScanZigzag:
+		return nil, errScanOrder
+	}
+	if _, ok := place.(sequentialPlacement); !ok {
+		return nil, errScanOrderUnsupported
+	}
+	return orderedPlacement{bounds: img.Bounds(), channels: imageChannels(img), order: order}, nil
+}
+
+func (p orderedPlacement) locate(bitIndex int) (x int, y int, ch int, plane int) {
+	pixel := bitIndex / p.channels
+	width := p.bounds.Dx()
+	row, col := pixel/width, pixel%width
+	if p.order == ScanZigzag && row%2 == 1 {
+		col = width - 1 - col
+	}
+	return p.bounds.Min.X + col, p.bounds.Min.Y + row, bitIndex % p.channels, 0
+}
+
+func (p orderedPlacement) capacity() int {
+	return bitCapacity(p.bounds, p.channels)
+}
+
+// orderedCodecPlacement returns the placement of a payload framed by a
+// custom codec in the options' scan order
+func (s *StegImage) orderedCodecPlacement(o *options) (placement, error) {
+	if s.imgLoaded == nil {
+		return nil, errNoImageLoaded
+	}
+	if o.adaptive {
+		return nil, errScanOrderUnsupported
+	}
+	place, err := newOrderedPlacement(s.imgLoaded, o.scanOrder)
+	if err == nil {
+		place, err = o.selectChannels(s.imgLoaded, place)
+	}
+	if err != nil {
+		return nil, err
+	}
+	place = o.scanLimit(o.orderBits(saltPlacement(s.imgLoaded, place, o)), o.pixelChannels(s.imgLoaded))
+	if o.matrix != 0 {
+		return o.matrixPlacement(place)
+	}
+	return place, nil
+}
+
+// detectScanOrder looks for an envelope written in a scan order other than
+// column-major, returning its placement, or nil if there's none
+func (s *StegImage) detectScanOrder(o *options) placement {
+	for _, order := range []ScanOrder{ScanRowMajor, ScanZigzag} {
+		place, err := newOrderedPlacement(s.imgLoaded, order)
+		if err == nil {
+			place, err = o.selectChannels(s.imgLoaded, place)
+		}
+		if err != nil {
+			return nil
+		}
+		place = o.scanLimit(o.orderBits(saltPlacement(s.imgLoaded, place, o)), o.pixelChannels(s.imgLoaded))
+		if e, _, err := s.readEnvelopeHeader(place, 0); err == nil && e.scanOrder() == order {
+			return place
+		}
+	}
+	return nil
+}
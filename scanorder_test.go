@@ -0,0 +1,57 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestScanOrder verifies payloads embedded in each scan order extract
+// without the order being given, and that the orders really differ
+func TestScanOrder(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payload := bytes.Repeat([]byte(secretStringIn), 100)
+	var outputs [][]byte
+	for _, order := range []ScanOrder{ScanColumnMajor, ScanRowMajor, ScanZigzag} {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedBytes(payload, WithScanOrder(order), WithPassphrase("hunter2")); err != nil {
+			t.Fatal(order, err)
+		}
+		reloaded := reloadImage(t, &stegImg)
+		secret, err := reloaded.ExtractBytes(WithPassphrase("hunter2"))
+		if err != nil {
+			t.Fatal(order, err)
+		}
+		if !bytes.Equal(secret, payload) {
+			t.Errorf("Order %d: extracted payload differs", order)
+		}
+		for _, other := range outputs {
+			if bytes.Equal(other, pngBytes(t, &stegImg)) {
+				t.Errorf("Order %d gave the same image as an earlier order", order)
+			}
+		}
+		outputs = append(outputs, pngBytes(t, &stegImg))
+
+		// A second embed in any order finds the first
+		if err := reloaded.EmbedBytes(payload, WithScanOrder((order+1)%3)); err != ErrPayloadExists {
+			t.Error("Correct Error not thrown, expected: '" + ErrPayloadExists.Error() + "' got: '" + errString(err) + "'")
+		}
+	}
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes(payload, WithScanOrder(ScanRowMajor), WithAdaptiveDepth(), WithPassphrase("hunter2")); err != errScanOrderUnsupported {
+		t.Error("Correct Error not thrown, expected: '" + errScanOrderUnsupported.Error() + "' got: '" + errString(err) + "'")
+	}
+	if err := stegImg.EmbedBytes(payload, WithScanOrder(7)); err != errScanOrder {
+		t.Error("Correct Error not thrown, expected: '" + errScanOrder.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,123 @@
+package libsteg
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// scanQueueDepth is how many files each worker may have queued, bounding
+// the memory held by read but unanalysed files
+const scanQueueDepth = 2
+
+// WithScanWorkers sets how many images are decoded and analysed at once,
+// by default one per CPU
+func WithScanWorkers(n int) ScanOption {
+	return func(o *scanOptions) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithScanReport calls fn with each result as soon as it and every result
+// before it are ready, in scan order, so reports can be written while the
+// scan continues
+func WithScanReport(fn func(ScanResult)) ScanOption {
+	return func(o *scanOptions) {
+		o.report = fn
+	}
+}
+
+// scanFile is a file read from disk, waiting to be analysed
+type scanFile struct {
+	index int
+	path  string
+	data  []byte
+	err   error
+}
+
+// scanOutcome is an analysed file, waiting to be reported in order
+type scanOutcome struct {
+	index   int
+	result  ScanResult
+	ok      bool
+	resumed bool
+}
+
+// pipeline scans the files walk passes to read. Reading, decoding with LSB
+// analysis, and reporting run concurrently, connected by bounded channels
+// so disk and CPU are both kept busy; results come back in walk order.
+func (o *scanOptions) pipeline(walk func(read func(path string, open func() (io.ReadCloser, error)))) []ScanResult {
+	files := make(chan scanFile, o.workers*scanQueueDepth)
+	outcomes := make(chan scanOutcome, o.workers*scanQueueDepth)
+
+	// Read stage
+	go func() {
+		defer close(files)
+		index := 0
+		walk(func(path string, open func() (io.ReadCloser, error)) {
+			file := scanFile{index: index, path: path}
+			index++
+			f, err := open()
+			if err != nil {
+				file.err = err
+			} else {
+				file.data, file.err = ioutil.ReadAll(f)
+				f.Close()
+			}
+			files <- file
+		})
+	}()
+
+	// Analysis stage
+	var wg sync.WaitGroup
+	for i := 0; i < o.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				outcome := scanOutcome{index: file.index}
+				if file.err != nil {
+					outcome.result, outcome.ok = ScanResult{Path: file.path, Err: file.err}, true
+				} else {
+					outcome.result, outcome.ok, outcome.resumed = o.analyse(file.path, file.data)
+				}
+				outcomes <- outcome
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Report stage, restoring walk order
+	var results []ScanResult
+	pending := make(map[int]scanOutcome)
+	next, resumed := 0, 0
+	for outcome := range outcomes {
+		pending[outcome.index] = outcome
+		for {
+			outcome, ready := pending[next]
+			if !ready {
+				break
+			}
+			delete(pending, next)
+			next++
+			if outcome.resumed {
+				resumed++
+			}
+			if outcome.ok {
+				results = append(results, outcome.result)
+				if o.report != nil {
+					o.report(outcome.result)
+				}
+			}
+			if o.progress != nil {
+				o.progress(ScanProgress{Path: outcome.result.Path, Scanned: next, Resumed: resumed})
+			}
+		}
+	}
+	return results
+}
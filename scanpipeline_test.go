@@ -0,0 +1,50 @@
+package libsteg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestScanPipelineOrder verifies results from several workers are
+// reported and returned in input order, missing files included
+func TestScanPipelineOrder(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	dir := t.TempDir()
+	images := [][]byte{stegPNG(t, secretStringIn), stegPNG(t, "something else")}
+	var paths []string
+	for i := 0; i < 12; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%02d.png", i))
+		if i == 5 {
+			path = filepath.Join(dir, "missing.png")
+		} else if err := ioutil.WriteFile(path, images[i%2], 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var reported []string
+	results := ScanBatch(paths, WithScanWorkers(4),
+		WithScanReport(func(r ScanResult) { reported = append(reported, r.Path) }))
+	if len(results) != len(paths) || len(reported) != len(paths) {
+		t.Fatalf("Expected %d results, got %d returned and %d reported", len(paths), len(results), len(reported))
+	}
+	for i, result := range results {
+		if result.Path != paths[i] || reported[i] != paths[i] {
+			t.Errorf("Result %d out of order: %s", i, result.Path)
+		}
+		if i == 5 {
+			if !os.IsNotExist(result.Err) {
+				t.Errorf("Expected a not exist error, got %v", result.Err)
+			}
+		} else if result.Err != nil || len(result.Payloads) != 1 {
+			t.Errorf("Unexpected scan result for %s: %+v", result.Path, result)
+		}
+	}
+}
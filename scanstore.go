@@ -0,0 +1,183 @@
+package libsteg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ScanOption configures ScanFS and ScanBatch
+type ScanOption func(*scanOptions)
+
+// scanOptions holds the settings built up from a list of ScanOption
+type scanOptions struct {
+	store    ScanStore
+	progress func(ScanProgress)
+	report   func(ScanResult)
+	workers  int
+}
+
+func newScanOptions(opts []ScanOption) *scanOptions {
+	o := &scanOptions{workers: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ScanStore persists the results of a scan keyed by the SHA-256 of each
+// file's contents, so an interrupted scan resumes without decoding the
+// files already seen. Implementations must be safe for concurrent use.
+type ScanStore interface {
+	// Lookup returns the payloads stored for key, ok is false if the file
+	// hasn't been scanned
+	Lookup(key string) (payloads []PayloadInfo, ok bool, err error)
+	// Store records the payloads found in the file with key
+	Store(key string, payloads []PayloadInfo) error
+}
+
+// ScanProgress reports how far a scan has got, after each file
+type ScanProgress struct {
+	Path    string // File just processed
+	Scanned int    // Files processed so far, including resumed ones
+	Resumed int    // Files whose results came from the ScanStore
+}
+
+// WithScanStore records results in store and reuses those already there
+func WithScanStore(store ScanStore) ScanOption {
+	return func(o *scanOptions) {
+		o.store = store
+	}
+}
+
+// WithScanProgress calls fn after each file is processed
+func WithScanProgress(fn func(ScanProgress)) ScanOption {
+	return func(o *scanOptions) {
+		o.progress = fn
+	}
+}
+
+// analyse reads the payloads from the image in data, consulting and
+// updating the store if one is set. ok is false if data doesn't hold a
+// registered image format, resumed is true if the store had the result.
+func (o *scanOptions) analyse(path string, data []byte) (result ScanResult, ok bool, resumed bool) {
+	if o.store == nil {
+		result, ok = scanImage(path, bytes.NewReader(data))
+		return result, ok, false
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	payloads, found, err := o.store.Lookup(key)
+	if err != nil {
+		log.Warning(err)
+	} else if found {
+		return ScanResult{Path: path, Payloads: payloads}, true, true
+	}
+
+	result, ok = scanImage(path, bytes.NewReader(data))
+	// Only clean results are stored, failures are retried on resume
+	if ok && result.Err == nil {
+		if err := o.store.Store(key, result.Payloads); err != nil {
+			log.Warning(err)
+		}
+	}
+	return result, ok, false
+}
+
+var errScanStoreClosed = errors.New("scan store closed")
+
+// FileScanStore is a ScanStore kept in a file of JSON lines, appended to
+// as each image is scanned so little is lost if the process is killed
+type FileScanStore struct {
+	mu      sync.Mutex
+	f       *os.File
+	results map[string][]PayloadInfo
+}
+
+// fileScanRecord is one line of a FileScanStore
+type fileScanRecord struct {
+	Key      string        `json:"key"`
+	Payloads []PayloadInfo `json:"payloads"`
+}
+
+// OpenFileScanStore opens the store at path, creating it if needed and
+// loading any results from an earlier scan. A partly written last line,
+// as left by an interrupted scan, is ignored.
+func OpenFileScanStore(path string) (*FileScanStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	store := &FileScanStore{f: f, results: make(map[string][]PayloadInfo)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	var valid int64
+	for scanner.Scan() {
+		var record fileScanRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			break
+		}
+		store.results[record.Key] = record.Payloads
+		valid += int64(len(scanner.Bytes())) + 1
+	}
+	// Drop anything after the last complete record before appending
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		log.Error(err)
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		log.Error(err)
+		return nil, err
+	}
+	return store, nil
+}
+
+// Lookup implements ScanStore
+func (s *FileScanStore) Lookup(key string) ([]PayloadInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payloads, ok := s.results[key]
+	return payloads, ok, nil
+}
+
+// Store implements ScanStore
+func (s *FileScanStore) Store(key string, payloads []PayloadInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errScanStoreClosed
+	}
+	line, err := json.Marshal(fileScanRecord{Key: key, Payloads: payloads})
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.results[key] = payloads
+	return nil
+}
+
+// Close closes the underlying file
+func (s *FileScanStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errScanStoreClosed
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
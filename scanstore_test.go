@@ -0,0 +1,61 @@
+package libsteg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestScanResume verifies a second scan with the same store reuses the
+// stored results, even after a partly written record
+func TestScanResume(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	fsys := fstest.MapFS{
+		"a.png":     {Data: stegPNG(t, secretStringIn)},
+		"sub/b.png": {Data: stegPNG(t, secretStringIn, WithPassphrase("pass"))},
+		"notes.txt": {Data: []byte("not an image")},
+	}
+	path := filepath.Join(t.TempDir(), "scan.jsonl")
+
+	scan := func() ([]ScanResult, ScanProgress) {
+		store, err := OpenFileScanStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		var last ScanProgress
+		results, err := ScanFS(fsys, WithScanStore(store),
+			WithScanProgress(func(p ScanProgress) { last = p }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results, last
+	}
+
+	first, progress := scan()
+	if len(first) != 2 || progress.Scanned != 3 || progress.Resumed != 0 {
+		t.Fatalf("Unexpected first scan: %+v %+v", first, progress)
+	}
+
+	// Simulate a scan killed mid-write
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"key":"trunc`)
+	f.Close()
+
+	second, progress := scan()
+	if progress.Resumed != 2 {
+		t.Errorf("Expected 2 resumed files, got %+v", progress)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Resumed results differ: %+v != %+v", first, second)
+	}
+}
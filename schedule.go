@@ -0,0 +1,217 @@
+package libsteg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSchedulerStopped is the result of jobs a Scheduler never started
+	// because its context was done
+	ErrSchedulerStopped  = errors.New("scheduler stopped before the job started")
+	errRemoveUnsupported = errors.New("file system can't remove files")
+)
+
+// Scheduler runs a batch of embedding jobs in the background at a limited
+// pace, so bulk watermarking can share a host with foreground traffic.
+// Jobs run one at a time, spaced out by Interval and DutyCycle, with the
+// file IO they do held to IORate. Pause and Resume may be called from any
+// goroutine while Run is in progress.
+type Scheduler struct {
+	// Batch gives the options, file system, error policy and report
+	// callback for the jobs. Its Workers is ignored.
+	Batch Batch
+
+	Interval  time.Duration // Least time between the starts of two jobs
+	DutyCycle float64       // Fraction of time spent running jobs, idling for the rest, 0 for no idling
+	IORate    int64         // Bytes per second read and written by jobs, 0 for no limit
+
+	mu     sync.Mutex
+	resume chan struct{} // Closed on Resume, nil unless paused
+}
+
+// Pause stops the scheduler starting more jobs until Resume is called. A
+// job already running is finished.
+func (s *Scheduler) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resume == nil {
+		s.resume = make(chan struct{})
+	}
+}
+
+// Resume lets a paused scheduler carry on
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resume != nil {
+		close(s.resume)
+		s.resume = nil
+	}
+}
+
+// Paused reports whether the scheduler is paused
+func (s *Scheduler) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resume != nil
+}
+
+// Run processes jobs in order at the scheduler's pace and returns one
+// result per job, as Batch.Run does. If ctx is done first the jobs not
+// yet started are left with ErrSchedulerStopped and ctx's error is
+// returned.
+func (s *Scheduler) Run(ctx context.Context, jobs []BatchJob) ([]BatchResult, error) {
+	fsys := s.Batch.FS
+	if fsys == nil {
+		fsys = osFS{}
+	}
+	if s.IORate > 0 {
+		fsys = &throttledFS{WritableFS: fsys, throttle: &ioThrottle{rate: s.IORate, start: time.Now()}}
+	}
+
+	results := make([]BatchResult, len(jobs))
+	var next time.Time
+	var stopErr error
+	failed := false
+	for index, job := range jobs {
+		if stopErr == nil {
+			stopErr = s.wait(ctx, next)
+		}
+		switch {
+		case stopErr != nil:
+			results[index] = BatchResult{Job: job, Err: ErrSchedulerStopped}
+			continue
+		case failed && s.Batch.Policy == BatchFailFast:
+			results[index] = BatchResult{Job: job, Err: ErrBatchCanceled}
+			continue
+		}
+
+		start := time.Now()
+		result := BatchResult{Job: job, Err: s.Batch.runJobOn(fsys, job)}
+		results[index] = result
+		failed = failed || result.Err != nil
+		if s.Batch.Report != nil {
+			s.Batch.Report(result)
+		}
+
+		next = start.Add(s.Interval)
+		if s.DutyCycle > 0 && s.DutyCycle < 1 {
+			elapsed := time.Since(start)
+			idle := time.Duration(float64(elapsed) * (1 - s.DutyCycle) / s.DutyCycle)
+			if until := start.Add(elapsed + idle); until.After(next) {
+				next = until
+			}
+		}
+	}
+
+	err := s.Batch.collect(results)
+	if stopErr != nil {
+		log.Error(stopErr)
+		return results, stopErr
+	}
+	return results, err
+}
+
+// wait blocks until the scheduler isn't paused and until has passed, or
+// ctx is done
+func (s *Scheduler) wait(ctx context.Context, until time.Time) error {
+	for {
+		s.mu.Lock()
+		resume := s.resume
+		s.mu.Unlock()
+		if resume != nil {
+			select {
+			case <-resume:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		d := time.Until(until)
+		if d <= 0 {
+			return ctx.Err()
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+			// Check for a pause that came in while idling
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// ioThrottle holds the bytes passed through it to a rate, sleeping once
+// they run ahead of it
+type ioThrottle struct {
+	mu    sync.Mutex
+	rate  int64 // Bytes per second
+	start time.Time
+	bytes int64 // Passed through since start
+}
+
+func (t *ioThrottle) add(n int) {
+	t.mu.Lock()
+	t.bytes += int64(n)
+	due := t.start.Add(time.Duration(float64(t.bytes) / float64(t.rate) * float64(time.Second)))
+	t.mu.Unlock()
+	time.Sleep(time.Until(due))
+}
+
+// throttledFS passes the reads and writes of its files through a throttle
+type throttledFS struct {
+	WritableFS
+	throttle *ioThrottle
+}
+
+func (f *throttledFS) Open(name string) (fs.File, error) {
+	file, err := f.WritableFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return throttledFile{file, f.throttle}, nil
+}
+
+func (f *throttledFS) Create(name string) (io.WriteCloser, error) {
+	w, err := f.WritableFS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return throttledWriter{w, f.throttle}, nil
+}
+
+func (f *throttledFS) Remove(name string) error {
+	if r, ok := f.WritableFS.(removeFS); ok {
+		return r.Remove(name)
+	}
+	return errRemoveUnsupported
+}
+
+type throttledFile struct {
+	fs.File
+	throttle *ioThrottle
+}
+
+func (f throttledFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.throttle.add(n)
+	return n, err
+}
+
+type throttledWriter struct {
+	io.WriteCloser
+	throttle *ioThrottle
+}
+
+func (w throttledWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.throttle.add(n)
+	return n, err
+}
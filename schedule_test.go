@@ -0,0 +1,118 @@
+package libsteg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logging "github.com/op/go-logging"
+)
+
+// scheduleJobs returns n jobs embedding into copies of the tiny image
+func scheduleJobs(dir string, n int) []BatchJob {
+	var jobs []BatchJob
+	for i := 0; i < n; i++ {
+		jobs = append(jobs, BatchJob{
+			Carrier: tinyImageFile,
+			Payload: []byte(fmt.Sprintf("%s %d", secretStringIn, i)),
+			Output:  filepath.Join(dir, fmt.Sprintf("%02d.png", i)),
+		})
+	}
+	return jobs
+}
+
+// TestSchedulerPace verifies jobs are spaced out and their IO held to the
+// rate given
+func TestSchedulerPace(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	jobs := scheduleJobs(t.TempDir(), 3)
+	sched := &Scheduler{Interval: 40 * time.Millisecond}
+	start := time.Now()
+	results, err := sched.Run(context.Background(), jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Jobs finished in %v, expected at least 80ms", elapsed)
+	}
+	for _, result := range results {
+		f, err := os.Open(result.Job.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := ExtractCarrier(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payload) != string(result.Job.Payload) {
+			t.Errorf("Extracted %q, expected %q", payload, result.Job.Payload)
+		}
+	}
+
+	info, err := os.Stat(tinyImageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reading the cover alone takes a tenth of a second at this rate
+	sched = &Scheduler{IORate: info.Size() * 10}
+	start = time.Now()
+	if _, err := sched.Run(context.Background(), scheduleJobs(t.TempDir(), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("IO finished in %v, expected at least 100ms", elapsed)
+	}
+}
+
+// TestSchedulerPause verifies nothing runs while paused, that Resume
+// carries on and that a done context cancels the jobs left
+func TestSchedulerPause(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	dir := t.TempDir()
+	jobs := scheduleJobs(dir, 2)
+	sched := &Scheduler{}
+	sched.Pause()
+	if !sched.Paused() {
+		t.Error("Scheduler not paused")
+	}
+	done := make(chan error)
+	go func() {
+		_, err := sched.Run(context.Background(), jobs)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(jobs[0].Output); !os.IsNotExist(err) {
+		t.Error("Job run while paused")
+	}
+	sched.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(jobs[1].Output); err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched.Pause()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	results, err := sched.Run(ctx, scheduleJobs(t.TempDir(), 2))
+	if err != context.Canceled {
+		t.Error("Correct Error not thrown, expected: '" + context.Canceled.Error() + "' got: '" + errString(err) + "'")
+	}
+	for _, result := range results {
+		if result.Err != ErrSchedulerStopped {
+			t.Errorf("Job result %v, expected %v", result.Err, ErrSchedulerStopped)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+const (
+	sidecarMagic   = "LSSC"
+	sidecarVersion = 1
+	sidecarKeyLen  = 16
+	// sidecarLen is magic + version + placement key + mask key + codec +
+	// data length + CRC32
+	sidecarLen = 4 + 1 + 2*sidecarKeyLen + 1 + 4 + 4
+)
+
+var (
+	errSidecarPassphrase = errors.New("sidecar needs a passphrase")
+	errBadSidecar        = errors.New("not a libsteg sidecar")
+)
+
+// EmbedWithSidecar embeds the payload with no header in the image at all,
+// returning a small sidecar, encrypted with passphrase, that holds what's
+// needed to find it again. The payload's bits are scattered over the
+// pixels by a random key and whitened by another, both kept in the
+// sidecar, so the image alone gives nothing away and extraction needs
+// both. Compression and payload encryption options apply as usual and must
+// be given again on extraction, save the choice of codec, which the
+// sidecar records.
+func (s *StegImage) EmbedWithSidecar(payload []byte, passphrase string, opts ...Option) (sidecar []byte, err error) {
+	if passphrase == "" {
+		s.logger().Error(errSidecarPassphrase)
+		return nil, errSidecarPassphrase
+	}
+	if err = s.createMutableImage(); err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	o := newOptions(opts)
+	stored, err := sealPayload(bytes.NewReader(payload), o, s.logger())
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+
+	keys := make([]byte, 2*sidecarKeyLen)
+	if _, err = io.ReadFull(o.rand, keys); err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	place, err := sidecarPlacement(s.newImg, keys)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	if _, err = (&lsbWriter{img: s.newImg, place: place}).Write(stored); err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	o.checkCapacity(len(stored), place, s.logger())
+
+	plain := make([]byte, 0, sidecarLen)
+	plain = append(plain, sidecarMagic...)
+	plain = append(plain, sidecarVersion)
+	plain = append(plain, keys...)
+	plain = append(plain, byte(o.compression))
+	plain = binary.BigEndian.AppendUint32(plain, uint32(len(stored)))
+	plain = binary.BigEndian.AppendUint32(plain, crc32.ChecksumIEEE(stored))
+
+	buf := new(bytes.Buffer)
+	ew, err := newEncryptWriter(buf, passphrase, o.rand)
+	if err == nil {
+		if _, err = ew.Write(plain); err == nil {
+			err = ew.Close()
+		}
+	}
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExtractWithSidecar retrieves a payload embedded with EmbedWithSidecar,
+// given the sidecar it returned and the passphrase protecting it
+func (s *StegImage) ExtractWithSidecar(sidecar []byte, passphrase string, opts ...Option) ([]byte, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+	plain, err := decrypt(sidecar, passphrase)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	if len(plain) != sidecarLen || string(plain[:4]) != sidecarMagic || plain[4] != sidecarVersion {
+		s.logger().Error(errBadSidecar)
+		return nil, errBadSidecar
+	}
+	keys := plain[5 : 5+2*sidecarKeyLen]
+	rest := plain[5+2*sidecarKeyLen:]
+	codec := Compression(rest[0])
+	dataLen := int(binary.BigEndian.Uint32(rest[1:5]))
+	sum := binary.BigEndian.Uint32(rest[5:9])
+
+	place, err := sidecarPlacement(s.imgLoaded, keys)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	stored, err := s.readBytes(place, 0, dataLen)
+	if err != nil {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	if crc32.ChecksumIEEE(stored) != sum {
+		s.logger().Error(ErrPayloadCorrupt)
+		return nil, ErrPayloadCorrupt
+	}
+
+	o := newOptions(opts)
+	o.compression = codec
+	payload, err := openPayload(stored, o)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
+
+// sidecarPlacement scatters bits over every pixel by the first key and
+// whitens them with the second
+func sidecarPlacement(img image.Image, keys []byte) (placement, error) {
+	keyed, err := newKeyedPlacement(img, keys[:sidecarKeyLen], 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(keys[sidecarKeyLen:])
+	if err != nil {
+		return nil, err
+	}
+	return &saltedPlacement{placement: keyed, block: block, cached: -1}, nil
+}
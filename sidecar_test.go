@@ -0,0 +1,51 @@
+package libsteg
+
+import (
+	"bytes"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestSidecar verifies a payload round trips through an image and its
+// sidecar, leaves no envelope to find, and needs the right passphrase
+func TestSidecar(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	payload := bytes.Repeat([]byte(secretStringIn), 50)
+	sidecar, err := stegImg.EmbedWithSidecar(payload, "hunter2", WithCompression(CompressionAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := reloadImage(t, &stegImg)
+
+	secret, err := reloaded.ExtractWithSidecar(sidecar, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, payload) {
+		t.Error("Extracted payload differs")
+	}
+	if _, err := reloaded.ExtractBytes(); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := reloaded.ExtractWithSidecar(sidecar, "wrong"); err == nil {
+		t.Error("Sidecar opened with the wrong passphrase")
+	}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cleanImg.ExtractWithSidecar(sidecar, "hunter2"); err != ErrPayloadCorrupt {
+		t.Error("Correct Error not thrown, expected: '" + ErrPayloadCorrupt.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := cleanImg.EmbedWithSidecar(payload, ""); err != errSidecarPassphrase {
+		t.Error("Correct Error not thrown, expected: '" + errSidecarPassphrase.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,73 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"io"
+)
+
+// signTrailerLen is the signer's public key followed by the signature
+const signTrailerLen = ed25519.PublicKeySize + ed25519.SignatureSize
+
+var (
+	// ErrSignatureInvalid is returned when a payload's signature doesn't
+	// verify or wasn't made by the expected key
+	ErrSignatureInvalid = errors.New("payload signature invalid")
+	// ErrNotSigned is returned when a verify key is given but the payload
+	// carries no signature
+	ErrNotSigned = errors.New("payload is not signed")
+)
+
+// signOpts selects Ed25519ph so the envelope can be signed from a running
+// SHA-512 as it is streamed, rather than buffering the whole payload
+var signOpts = &ed25519.Options{Hash: crypto.SHA512}
+
+// WithSigningKey signs the envelope with key on embed so recipients can
+// verify who produced the image, even when the payload isn't encrypted
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(o *options) {
+		o.signingKey = key
+	}
+}
+
+// WithVerifyKey requires the payload to carry a valid signature made by
+// key, extraction fails with ErrNotSigned or ErrSignatureInvalid otherwise
+func WithVerifyKey(key ed25519.PublicKey) Option {
+	return func(o *options) {
+		o.verifyKey = key
+	}
+}
+
+// signEnvelope signs the digest of the stored data and header, returning
+// the signature trailer
+func signEnvelope(key ed25519.PrivateKey, digest []byte) ([]byte, error) {
+	sig, err := key.Sign(nil, digest, signOpts)
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, 0, signTrailerLen)
+	trailer = append(trailer, key.Public().(ed25519.PublicKey)...)
+	return append(trailer, sig...), nil
+}
+
+// verifySignature checks the signature trailer against the envelope
+// header and the data read from data. The embedded public key is only
+// trusted if it matches the expected key.
+func verifySignature(trailer []byte, header []byte, data io.Reader, key ed25519.PublicKey) error {
+	signer := ed25519.PublicKey(trailer[:ed25519.PublicKeySize])
+	if !bytes.Equal(signer, key) {
+		return ErrSignatureInvalid
+	}
+	h := sha512.New()
+	if _, err := io.Copy(h, data); err != nil {
+		return err
+	}
+	h.Write(header)
+	if ed25519.VerifyWithOptions(signer, h.Sum(nil), trailer[ed25519.PublicKeySize:], signOpts) != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package libsteg
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BSigned does a back to back test of a signed payload, verifying
+// with the signer's key and rejecting another key
+func TestB2BSigned(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn), WithSigningKey(priv)); err != nil {
+		t.Fatal(err)
+	}
+	tamperedImg := reloadImage(t, &cleanImg)
+
+	payloadOut, err := tamperedImg.ExtractBytes(WithVerifyKey(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	if _, err = tamperedImg.ExtractBytes(WithVerifyKey(otherPub)); err != ErrSignatureInvalid {
+		t.Error("Correct Error not thrown, expected: '" + ErrSignatureInvalid.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestUnsignedWithVerifyKey verifies an unsigned payload is rejected when
+// a signature is required
+func TestUnsignedWithVerifyKey(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	pub, _, _ := ed25519.GenerateKey(nil)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloadImage(t, &cleanImg).ExtractBytes(WithVerifyKey(pub)); err != ErrNotSigned {
+		t.Error("Correct Error not thrown, expected: '" + ErrNotSigned.Error() + "' got: '" + errString(err) + "'")
+	}
+}
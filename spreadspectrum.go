@@ -0,0 +1,283 @@
+package libsteg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+)
+
+const (
+	// spreadGrid is the number of cells along each side of the image the
+	// spread-spectrum pattern is laid over. Cells are fractions of the image
+	// rather than pixel counts, so the pattern survives rescaling.
+	spreadGrid = 64
+	// spreadMinChips is the fewest cells each payload bit may be spread over
+	spreadMinChips = 32
+	// spreadSumLen is the length of the check on spread-spectrum payloads,
+	// the low 16 bits of their CRC32
+	spreadSumLen = 2
+	// defaultSpreadStrength is the pattern amplitude in 8-bit levels
+	defaultSpreadStrength = 3
+	// spreadClip bounds the residual of each cell in 8-bit levels, so edges
+	// in the image don't swamp the pattern
+	spreadClip = 8
+)
+
+var errSpreadStrength = errors.New("spread-spectrum strength must be between 1 and 32")
+
+// WithSpreadStrength sets the amplitude, in 8-bit levels added to or taken
+// from each channel, of the pattern EmbedSpreadSpectrum adds. Stronger
+// patterns survive more noise and recompression but are more visible.
+func WithSpreadStrength(strength float64) Option {
+	return func(o *options) {
+		o.spreadStrength = strength
+	}
+}
+
+// SpreadSpectrumCapacity returns the largest payload in bytes
+// EmbedSpreadSpectrum can spread over any image
+func SpreadSpectrumCapacity() int {
+	return spreadGrid*spreadGrid/spreadMinChips/8 - spreadSumLen
+}
+
+// EmbedSpreadSpectrum embeds a short payload as a robust watermark. The
+// image is divided into a grid of cells and every bit of the payload is
+// spread over many of them, chosen by the passphrase, each brightened or
+// darkened by a key-derived pseudo-noise pattern. Detection correlates the
+// image against the same pattern, so the payload survives noise, scaling
+// and mild recompression that destroy LSB payloads, at the cost of holding
+// only a few bytes, see SpreadSpectrumCapacity. Only WithPassphrase and
+// WithSpreadStrength apply, and the payload isn't encrypted.
+func (s *StegImage) EmbedSpreadSpectrum(payload []byte, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+	dst, err := spreadEmbed(s.imgLoaded, payload, newOptions(opts))
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	s.newImg, s.robustImg = dst, dst
+	ob.bytes = int64(len(payload))
+	return nil
+}
+
+// spreadEmbed returns a copy of img with payload spread over it
+func spreadEmbed(img image.Image, payload []byte, o *options) (*image.NRGBA, error) {
+	strength := o.spreadStrength
+	if strength == 0 {
+		strength = defaultSpreadStrength
+	}
+	if strength < 1 || strength > 32 {
+		return nil, errSpreadStrength
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() < spreadGrid || bounds.Dy() < spreadGrid || len(payload) > SpreadSpectrumCapacity() {
+		return nil, ErrInsufficientCapacity
+	}
+	pattern, err := newSpreadPattern(o.passphrase, len(payload))
+	if err != nil {
+		return nil, err
+	}
+	bits := spreadFrame(payload)
+	chips := make([]float64, spreadGrid*spreadGrid)
+	for b, one := range bits {
+		sign := -strength
+		if one {
+			sign = strength
+		}
+		for j := 0; j < pattern.chips; j++ {
+			cell, pn := pattern.chip(b, j)
+			chips[cell] = sign * pn
+		}
+	}
+
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			delta := chips[spreadCell(bounds, x, y)]
+			if delta == 0 {
+				continue
+			}
+			c := dst.NRGBAAt(x, y)
+			c.R, c.G, c.B = spreadShift(c.R, delta), spreadShift(c.G, delta), spreadShift(c.B, delta)
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst, nil
+}
+
+// ExtractSpreadSpectrum detects an n byte payload embedded with
+// EmbedSpreadSpectrum using the same passphrase, returning ErrNoPayload if
+// the pattern isn't found
+func (s *StegImage) ExtractSpreadSpectrum(n int, opts ...Option) (payload []byte, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+	if n < 0 || n > SpreadSpectrumCapacity() {
+		s.logger().Error(ErrInsufficientCapacity)
+		return nil, ErrInsufficientCapacity
+	}
+	o := newOptions(opts)
+	pattern, err := newSpreadPattern(o.passphrase, n)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	payload, _ = pattern.detect(s.imgLoaded)
+	if payload == nil {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	ob.bytes = int64(len(payload))
+	return payload, nil
+}
+
+// spreadPattern assigns each bit of a spread-spectrum frame its cells and
+// the pseudo-noise sign of each
+type spreadPattern struct {
+	n     int // Payload length in bytes
+	chips int // Cells per bit
+	perm  *feistel
+	pn    []byte // One bit per chip
+}
+
+func newSpreadPattern(passphrase string, n int) (*spreadPattern, error) {
+	key, err := placementKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	perm, err := newFeistel(key, spreadGrid*spreadGrid)
+	if err != nil {
+		return nil, err
+	}
+	bits := (n + spreadSumLen) * 8
+	chips := spreadGrid * spreadGrid / bits
+	pnKey := sha256.Sum256(key)
+	pn := make([]byte, (bits*chips+7)/8)
+	io.ReadFull(newDeterministicReader(pnKey[:16]), pn)
+	return &spreadPattern{n: n, chips: chips, perm: perm, pn: pn}, nil
+}
+
+// chip returns the cell holding chip j of bit b and its pseudo-noise sign
+func (p *spreadPattern) chip(b int, j int) (cell int, pn float64) {
+	i := b*p.chips + j
+	cell = int(p.perm.permute(uint64(i)))
+	if p.pn[i/8]&(1<<(i%8)) != 0 {
+		return cell, 1
+	}
+	return cell, -1
+}
+
+// detect correlates img against the pattern, returning the payload if its
+// check matches, and the watermarkScore of the bits
+func (p *spreadPattern) detect(img image.Image) (payload []byte, score float64) {
+	residual := spreadResidual(img)
+	bits := make([]bool, (p.n+spreadSumLen)*8)
+	confident := 0
+	for b := range bits {
+		var corr, energy float64
+		for j := 0; j < p.chips; j++ {
+			cell, pn := p.chip(b, j)
+			corr += pn * residual[cell]
+			energy += residual[cell] * residual[cell]
+		}
+		bits[b] = corr > 0
+		if confidentBit(corr, energy) {
+			confident++
+		}
+	}
+	return spreadDeframe(bits), float64(confident) / float64(len(bits))
+}
+
+// spreadFrame appends the check to payload and returns it as bits, MSB
+// first
+func spreadFrame(payload []byte) []bool {
+	frame := make([]byte, len(payload)+spreadSumLen)
+	copy(frame, payload)
+	binary.BigEndian.PutUint16(frame[len(payload):], uint16(crc32.ChecksumIEEE(payload)))
+	bits := make([]bool, len(frame)*8)
+	for i := range bits {
+		bits[i] = frame[i/8]&(0x80>>(i%8)) != 0
+	}
+	return bits
+}
+
+// spreadDeframe reverses spreadFrame, returning nil if the check doesn't
+// match
+func spreadDeframe(bits []bool) []byte {
+	frame := make([]byte, len(bits)/8)
+	for i, one := range bits {
+		if one {
+			frame[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	n := len(frame) - spreadSumLen
+	if binary.BigEndian.Uint16(frame[n:]) != uint16(crc32.ChecksumIEEE(frame[:n])) {
+		return nil
+	}
+	return frame[:n]
+}
+
+// spreadResidual returns the mean luma of each cell of img less the mean of
+// its neighbours, clipped to spreadClip, removing most of the image content
+// so the pattern stands out
+func spreadResidual(img image.Image) []float64 {
+	bounds := img.Bounds()
+	sums := make([]float64, spreadGrid*spreadGrid)
+	counts := make([]int, spreadGrid*spreadGrid)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cell := spreadCell(bounds, x, y)
+			sums[cell] += luma(img.At(x, y))
+			counts[cell]++
+		}
+	}
+	means := make([]float64, len(sums))
+	for i := range sums {
+		if counts[i] > 0 {
+			means[i] = sums[i] / float64(counts[i])
+		}
+	}
+	residual := make([]float64, len(means))
+	for cy := 0; cy < spreadGrid; cy++ {
+		for cx := 0; cx < spreadGrid; cx++ {
+			var sum float64
+			var count int
+			for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				nx, ny := cx+d[0], cy+d[1]
+				if nx >= 0 && nx < spreadGrid && ny >= 0 && ny < spreadGrid {
+					sum += means[ny*spreadGrid+nx]
+					count++
+				}
+			}
+			r := means[cy*spreadGrid+cx] - sum/float64(count)
+			residual[cy*spreadGrid+cx] = math.Max(-spreadClip, math.Min(spreadClip, r))
+		}
+	}
+	return residual
+}
+
+// spreadCell returns the grid cell pixel (x, y) of bounds falls in
+func spreadCell(bounds image.Rectangle, x int, y int) int {
+	cx := (x - bounds.Min.X) * spreadGrid / bounds.Dx()
+	cy := (y - bounds.Min.Y) * spreadGrid / bounds.Dy()
+	return cy*spreadGrid + cx
+}
+
+// spreadShift adds delta to an 8-bit channel value, clamping the result
+func spreadShift(v uint8, delta float64) uint8 {
+	return uint8(clampInt(int(math.Round(float64(v)+delta)), 0, 255))
+}
@@ -0,0 +1,80 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestSpreadSpectrum verifies a spread-spectrum payload survives noise,
+// rescaling and JPEG recompression, and isn't found with the wrong key
+func TestSpreadSpectrum(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	id := []byte("ID42")
+	if err := stegImg.EmbedSpreadSpectrum(id, WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	marked := stegImg.newImg
+	bounds := marked.Bounds()
+
+	noisy := image.NewNRGBA(bounds)
+	draw.Draw(noisy, bounds, marked, bounds.Min, draw.Src)
+	rng := rand.New(rand.NewSource(1))
+	for i := range noisy.Pix {
+		if i%4 != 3 {
+			noisy.Pix[i] = uint8(clampInt(int(noisy.Pix[i])+rng.Intn(9)-4, 0, 255))
+		}
+	}
+	larger, err := resizeImage(marked, bounds.Dx()*3/2, bounds.Dy()*3/2, ResizeBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smaller, err := resizeImage(marked, bounds.Dx()*4/5, bounds.Dy()*4/5, ResizeBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, marked, &jpeg.Options{Quality: 85}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, img := range map[string]image.Image{
+		"clean":        reloadImage(t, &stegImg).imgLoaded,
+		"noisy":        noisy,
+		"larger":       larger,
+		"smaller":      smaller,
+		"recompressed": recompressed,
+	} {
+		got, err := NewStegImageFromImage(img).ExtractSpreadSpectrum(len(id), WithPassphrase("hunter2"))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !bytes.Equal(got, id) {
+			t.Errorf("%s: extracted %q, expected %q", name, got, id)
+		}
+	}
+
+	if _, err = NewStegImageFromImage(marked).ExtractSpreadSpectrum(len(id), WithPassphrase("wrong")); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrNoPayload) + "' got: '" + errString(err) + "'")
+	}
+	if err = stegImg.EmbedSpreadSpectrum(make([]byte, SpreadSpectrumCapacity()+1)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+	if err = stegImg.EmbedSpreadSpectrum(id, WithSpreadStrength(0.5)); err != errSpreadStrength {
+		t.Error("Correct Error not thrown, expected: '" + errString(errSpreadStrength) + "' got: '" + errString(err) + "'")
+	}
+}
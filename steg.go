@@ -2,6 +2,7 @@ package libsteg
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -9,9 +10,12 @@ import (
 	"image/color"
 	"image/draw"
 	"image/png"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/op/go-logging"
 )
@@ -21,42 +25,147 @@ const (
 	stopStegConst string = "##STOP_STEG##"
 )
 
+var (
+	// ErrInsufficientCapacity is returned when the image doesn't have enough
+	// pixels to hold the secret
+	ErrInsufficientCapacity = errors.New("not enough pixels to hide secret")
+	errNoImageLoaded        = errors.New("no image loaded")
+	errNoEmbeddedSecret     = errors.New("error finding embedded secret string")
+)
+
 // StegImage type holds all vars needed for image manipulation
 type StegImage struct {
 	imgLoaded  image.Image
 	imgType    string
-	secretBits []int // Splice of ints for bits of secret
-	newImg     *image.RGBA
+	secretBits []int       // Splice of ints for bits of secret
+	newImg     draw.Image  // See createMutableImage for the concrete types
+	pngProfile *PNGProfile // Encoder profile for the new image, if set
+
+	srcChunks   []sourceChunk // Ancillary chunks of a loaded PNG
+	chunkPolicy chunkPolicy   // Which srcChunks the new image gets
+
+	keepFormat bool        // Write the new image in imgType, see SetKeepFormat
+	robustImg  image.Image // newImg, if a robust embed made it
+
+	traceCtx    context.Context // Parent of the stages' spans, see SetTraceContext
+	instanceLog *instanceLogger // Overrides the package logging level, if set
 }
 
-// By default set the logger to only log CRITICAL level messages
-var log = setupLogging(logging.CRITICAL)
+// By default set the logger to only log CRITICAL level messages, to stdout
+var log = setupLogging(os.Stdout, logging.CRITICAL)
+
+// The writer and format the logger was last set up with, and the
+// formatted backend writing to it
+var (
+	logOutput  io.Writer = os.Stdout
+	logFormat            = LogFormatText
+	logBackend logging.Backend
+)
 
 // SetLoggingLevel sets the libsteg logging level
 func SetLoggingLevel(level logging.Level) {
 	logging.SetLevel(level, "libsteg")
 }
 
+// SetLoggingLevel sets the logging level for this StegImage alone, so one
+// image can be debugged without changing what every other StegImage in the
+// process logs. It covers the messages the StegImage's methods log, and
+// the INFO message as each operation ends; messages from package level
+// functions still follow the global SetLoggingLevel.
+func (s *StegImage) SetLoggingLevel(level logging.Level) {
+	s.instanceLog = &instanceLogger{level: level}
+}
+
+// stegLogger is the part of logging.Logger libsteg logs through, so a
+// StegImage's own level can stand in for the package one
+type stegLogger interface {
+	IsEnabledFor(level logging.Level) bool
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Notice(args ...interface{})
+	Warning(args ...interface{})
+	Error(args ...interface{})
+}
+
+// logger returns the logger for s, honouring any level set for it
+func (s *StegImage) logger() stegLogger {
+	if s == nil || s.instanceLog == nil {
+		return log
+	}
+	return s.instanceLog
+}
+
+// instanceLogger logs at a StegImage's own level, handing records straight
+// to the backend, as a logging.Logger always checks the package level
+type instanceLogger struct {
+	level logging.Level
+}
+
+// instanceLogSeq numbers the records instanceLoggers log
+var instanceLogSeq uint64
+
+func (l *instanceLogger) IsEnabledFor(level logging.Level) bool {
+	return level <= l.level
+}
+
+func (l *instanceLogger) log(level logging.Level, args []interface{}) {
+	if !l.IsEnabledFor(level) {
+		return
+	}
+	rec := &logging.Record{
+		ID:     atomic.AddUint64(&instanceLogSeq, 1),
+		Time:   time.Now(),
+		Module: "libsteg",
+		Level:  level,
+		Args:   args,
+	}
+	// Two frames up is the caller of Error, Debug and so on
+	logBackend.Log(level, 2, rec)
+}
+
+func (l *instanceLogger) Debug(args ...interface{})   { l.log(logging.DEBUG, args) }
+func (l *instanceLogger) Info(args ...interface{})    { l.log(logging.INFO, args) }
+func (l *instanceLogger) Notice(args ...interface{})  { l.log(logging.NOTICE, args) }
+func (l *instanceLogger) Warning(args ...interface{}) { l.log(logging.WARNING, args) }
+func (l *instanceLogger) Error(args ...interface{})   { l.log(logging.ERROR, args) }
+
+// SetLogOutput sends libsteg log messages to w rather than stdout, for
+// hosts such as browsers where stdout isn't wanted
+func SetLogOutput(w io.Writer) {
+	logOutput = w
+	setupLogging(w, logging.GetLevel("libsteg"))
+}
+
 // setupLogging initialises the libsteg logger
-func setupLogging(level logging.Level) *logging.Logger {
+func setupLogging(w io.Writer, level logging.Level) *logging.Logger {
 	logger := logging.MustGetLogger("libsteg")
-	format := logging.MustStringFormatter(
-		`%{color}%{time:15:04:05.000} %{shortfunc} ▶ %{level:.4s} %{id:03x}%{color:reset} %{message}`,
-	)
 
-	backend1 := logging.NewLogBackend(os.Stdout, "", 0)
-	backend1Formatter := logging.NewBackendFormatter(backend1, format)
-	backend1Leveled := logging.AddModuleLevel(backend1Formatter)
+	backend1 := logging.NewLogBackend(w, "", 0)
+	logBackend = logging.NewBackendFormatter(backend1, logFormat.formatter())
+	backend1Leveled := logging.AddModuleLevel(logBackend)
 	backend1Leveled.SetLevel(level, "")
 	logging.SetBackend(backend1Leveled)
 
 	return logger
 }
 
-// Base64Embed performs a full base64 based embed
+// Base64Embed performs a full base64 based embed. The image may also be
+// given as a base64 data URI, in which case one is returned.
 func Base64Embed(imageB64In string, secret string) (imageB64Out string, err error) {
+	return Base64EmbedContext(context.Background(), imageB64In, secret)
+}
+
+// Base64EmbedContext is Base64Embed traced as a "libsteg.Base64Embed" span
+// under any span in ctx, with the decode, embed and encode stages as its
+// children
+func Base64EmbedContext(ctx context.Context, imageB64In string, secret string) (imageB64Out string, err error) {
+	ctx, span := startSpan(ctx, "Base64Embed")
+	defer endSpan(span, &err)
+	_, isURI, _ := splitDataURI(imageB64In)
+
 	// Load clean image
 	var cleanImg StegImage
+	cleanImg.SetTraceContext(ctx)
 	err = cleanImg.LoadImageFromB64(imageB64In)
 	if err != nil {
 		log.Error(err)
@@ -71,7 +180,11 @@ func Base64Embed(imageB64In string, secret string) (imageB64Out string, err erro
 	}
 
 	// Write manipulated image to Base64
-	imageB64Out, err = cleanImg.WriteNewImageToB64()
+	if isURI {
+		imageB64Out, err = cleanImg.WriteNewImageToDataURI()
+	} else {
+		imageB64Out, err = cleanImg.WriteNewImageToB64()
+	}
 	if err != nil {
 		log.Error(err)
 		return "", err
@@ -79,10 +192,21 @@ func Base64Embed(imageB64In string, secret string) (imageB64Out string, err erro
 	return imageB64Out, nil
 }
 
-// Base64Extract performs a full base64 based extract
+// Base64Extract performs a full base64 based extract, accepting a base64
+// data URI too
 func Base64Extract(imageB64In string) (secret string, err error) {
+	return Base64ExtractContext(context.Background(), imageB64In)
+}
+
+// Base64ExtractContext is Base64Extract traced as a
+// "libsteg.Base64Extract" span under any span in ctx, with the decode and
+// extract stages as its children
+func Base64ExtractContext(ctx context.Context, imageB64In string) (secret string, err error) {
+	ctx, span := startSpan(ctx, "Base64Extract")
+	defer endSpan(span, &err)
 	// Load tampered image
 	var tamperedImg StegImage
+	tamperedImg.SetTraceContext(ctx)
 	if err = tamperedImg.LoadImageFromB64(imageB64In); err != nil {
 		log.Error(err)
 		return "", err
@@ -97,61 +221,85 @@ func Base64Extract(imageB64In string) (secret string, err error) {
 	return secret, nil
 }
 
-// LoadImageFromFile loads the given image into the StegImage structure
-func (s *StegImage) LoadImageFromFile(imgPath string) error {
-	reader, err := os.Open(imgPath)
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-	defer reader.Close()
+// NewStegImageFromImage returns a StegImage holding img as its loaded
+// image, for images already decoded, e.g. from a camera or renderer. img
+// may be a SubImage view with a non-zero origin, only the pixels within
+// its bounds are used. Embedding WithInPlace into such a view hides the
+// payload in that region of the parent image.
+func NewStegImageFromImage(img image.Image) *StegImage {
+	return &StegImage{imgLoaded: img}
+}
 
-	// Read into an image
-	s.imgLoaded, s.imgType, err = image.Decode(reader)
-	if err != nil {
-		log.Error(err)
-		return err
+// Embed embeds secret into a copy of img and returns the copy, leaving
+// img itself unchanged
+func Embed(img image.Image, secret []byte, opts ...Option) (image.Image, error) {
+	s := NewStegImageFromImage(img)
+	if err := s.EmbedBytes(secret, opts...); err != nil {
+		return nil, err
 	}
-	log.Notice("Image Type Loaded:", s.imgType)
-	return nil
+	return s.Stego(), nil
 }
 
-// LoadImageFromB64 loads the given base64 encoded image into the
-// StegImage structure
+// Extract retrieves a secret embedded with Embed from img
+func Extract(img image.Image, opts ...Option) ([]byte, error) {
+	return NewStegImageFromImage(img).ExtractBytes(opts...)
+}
+
+// LoadImageFromB64 loads the given base64 encoded image, or base64 data
+// URI, into the StegImage structure
 func (s *StegImage) LoadImageFromB64(b64Img string) (err error) {
+	if b64Img, _, err = splitDataURI(b64Img); err != nil {
+		s.logger().Error(err)
+		return err
+	}
 	// Load image file from base 64 string
 	reader := base64.NewDecoder(base64.StdEncoding, strings.NewReader(b64Img))
+	return s.loadImage(reader)
+}
 
-	// Read into an image
-	s.imgLoaded, s.imgType, err = image.Decode(reader)
+// LoadImage loads the image read from r into the StegImage structure
+func (s *StegImage) LoadImage(r io.Reader) error {
+	return s.loadImage(r)
+}
+
+// loadImage decodes the image read from r into the StegImage structure
+func (s *StegImage) loadImage(r io.Reader) (err error) {
+	defer endSpan(s.startSpan("decode"), &err)
+	if r, err = checkDecodeFormat(r); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	// Read into an image, noting any PNG chunks on the way past
+	collector := &pngChunkCollector{}
+	s.imgLoaded, s.imgType, err = image.Decode(io.TeeReader(r, collector))
 	if err != nil {
-		log.Error(err)
+		s.logger().Error(err)
 		return err
 	}
-	log.Notice("Image Type Loaded:", s.imgType)
+	s.srcChunks = collector.chunks
+	s.logger().Notice("Image Type Loaded:", s.imgType)
 	return nil
 }
 
-// WriteNewImageToFile outputs the image held in StegImage.newImg to
-// the imgPath given
-func (s *StegImage) WriteNewImageToFile(imgPath string) (err error) {
-	myfile, _ := os.Create(imgPath)
-	defer myfile.Close()
-
-	enc := png.Encoder{CompressionLevel: png.BestCompression}
-	err = enc.Encode(myfile, s.newImg)
-	if err != nil {
-		log.Error(err)
+// WriteNewImage encodes the image held in StegImage.newImg as PNG, or the
+// source's format under SetKeepFormat, to w
+func (s *StegImage) WriteNewImage(w io.Writer) error {
+	if s.newImg == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
 	}
-
-	return err
+	if err := s.encodeNewImage(w, png.BestCompression); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	return nil
 }
 
 // WriteNewImageToB64 base64 encodes the image held in StegImage.newImg and
 // returns it as a string
 func (s *StegImage) WriteNewImageToB64() (b64Img string, err error) {
 	buf := new(bytes.Buffer)
-	err = png.Encode(buf, s.newImg)
+	err = s.encodeNewImage(buf, png.DefaultCompression)
 	if err != nil {
 		return "", err
 	}
@@ -159,45 +307,103 @@ func (s *StegImage) WriteNewImageToB64() (b64Img string, err error) {
 	return b64Img, err
 }
 
-// DoStegExtract retrieves the embedded secret from the loaded image
-func (s *StegImage) DoStegExtract() (secretOut string, err error) {
-	secretOut, err = s.getSecretString()
+// DoStegExtract retrieves the embedded secret from the loaded image. Of
+// the options only the scan limits apply.
+func (s *StegImage) DoStegExtract(opts ...Option) (secretOut string, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("extract"), &err)
+	secretOut, err = s.getSecretString(newOptions(opts))
 	if err != nil {
-		log.Error(err)
+		s.logger().Error(err)
 		return "", err
 	}
-	log.Info("Secret Found:", secretOut)
+	s.logger().Info("Secret Found:", secretOut)
+	ob.bytes = int64(len(secretOut))
 
 	return secretOut, err
 }
 
 // DoStegEmbed embeds the given secret into the loaded image
 func (s *StegImage) DoStegEmbed(secretIn string) (err error) {
-	err = s.createMutableImage()
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("embed"), &err)
+	err = s.createRGBAImage()
 	if err != nil {
-		log.Error(err)
+		s.logger().Error(err)
 		return err
 	}
 
 	err = s.loadSecret(secretIn)
 	if err != nil {
-		log.Error(err)
+		s.logger().Error(err)
 		return err
 	}
 
 	err = s.embedSecret()
 	if err != nil {
-		log.Error(err)
+		s.logger().Error(err)
 		return err
 	}
+	ob.bytes = int64(len(secretIn))
 
 	return err
 }
 
+// createMutableImage copies the loaded image into one matching its
+// channel depth, so embedding works on the true LSB of 16-bit channels,
+// alpha isn't premultiplied into the colour channels, and grayscale and
+// paletted images keep their format rather than being expanded to 8-bit
+// RGBA
 func (s *StegImage) createMutableImage() error {
 	// Ensure we have an image Loaded
 	if s.imgLoaded == nil {
-		return errors.New("no image loaded")
+		return errNoImageLoaded
+	}
+	if src, ok := s.imgLoaded.(*image.Paletted); ok {
+		s.newImg = newPalettedCopy(src)
+		return nil
+	}
+	bounds := s.imgLoaded.Bounds()
+	// The common 8-bit layouts are copied row by row, rather than pixel by
+	// pixel through draw.Draw
+	switch src := s.imgLoaded.(type) {
+	case *image.RGBA:
+		dst := image.NewRGBA(bounds)
+		copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, bounds.Dx()*4, bounds.Dy())
+		s.newImg = dst
+		return nil
+	case *image.NRGBA:
+		dst := image.NewNRGBA(bounds)
+		copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, bounds.Dx()*4, bounds.Dy())
+		s.newImg = dst
+		return nil
+	}
+	switch s.imgLoaded.ColorModel() {
+	case color.RGBA64Model:
+		s.newImg = image.NewRGBA64(bounds)
+	case color.NRGBA64Model:
+		s.newImg = image.NewNRGBA64(bounds)
+	case color.NRGBAModel:
+		s.newImg = image.NewNRGBA(bounds)
+	case color.GrayModel:
+		s.newImg = image.NewGray(bounds)
+	case color.Gray16Model:
+		s.newImg = image.NewGray16(bounds)
+	default:
+		s.newImg = image.NewRGBA(bounds)
+	}
+	draw.Draw(s.newImg, bounds, s.imgLoaded, bounds.Min, draw.Src)
+	return nil
+}
+
+// createRGBAImage copies the loaded image into an 8-bit RGBA image, as the
+// string based embedSecret expects
+func (s *StegImage) createRGBAImage() error {
+	// Ensure we have an image Loaded
+	if s.imgLoaded == nil {
+		return errNoImageLoaded
 	}
 	s.newImg = image.NewRGBA(s.imgLoaded.Bounds())
 	draw.Draw(s.newImg, s.newImg.Bounds(), s.imgLoaded,
@@ -206,11 +412,11 @@ func (s *StegImage) createMutableImage() error {
 }
 
 func (s *StegImage) loadSecret(secret string) (err error) {
-	log.Notice("Loaded secret", secret)
+	s.logger().Notice("Loaded secret", secret)
 	secretWordBin := stringToBinary(secret + stopStegConst)
-	log.Debug("Secret " + secret + " in binary: " + secretWordBin)
+	s.logger().Debug("Secret " + secret + " in binary: " + secretWordBin)
 	secretWordBinList := strings.Split(secretWordBin, "")
-	s.secretBits, err = stringsToInts(secretWordBinList)
+	s.secretBits, err = stringsToInts(secretWordBinList, s.logger())
 	return err
 }
 
@@ -219,8 +425,8 @@ func (s *StegImage) embedSecret() (err error) {
 	bounds := s.newImg.Bounds()
 
 	// Check if we can store the secret message
-	if len(s.secretBits) > (bounds.Max.X * bounds.Max.Y * 3) {
-		return errors.New("not enough pixels to hide secret")
+	if len(s.secretBits) > bitCapacity(bounds, 3) {
+		return ErrInsufficientCapacity
 	}
 
 	for x := bounds.Min.X; x < bounds.Max.X; x++ {
@@ -228,22 +434,22 @@ func (s *StegImage) embedSecret() (err error) {
 			if secretIndex < len(s.secretBits) {
 				r, g, b, a := s.newImg.At(x, y).RGBA()
 
-				newR := manipulateLSB(int(r), s.secretBits[secretIndex])
+				newR := manipulateLSB(int(r), s.secretBits[secretIndex], s.logger())
 				secretIndex++
 
 				newG := uint8(g)
 				if secretIndex < len(s.secretBits) {
-					newG = manipulateLSB(int(g), s.secretBits[secretIndex])
+					newG = manipulateLSB(int(g), s.secretBits[secretIndex], s.logger())
 					secretIndex++
 				}
 
 				newB := uint8(b)
 				if secretIndex < len(s.secretBits) {
-					newB = manipulateLSB(int(b), s.secretBits[secretIndex])
+					newB = manipulateLSB(int(b), s.secretBits[secretIndex], s.logger())
 					secretIndex++
 				}
 				// Set new Color
-				s.newImg.SetRGBA(x, y,
+				s.newImg.Set(x, y,
 					color.RGBA{newR, newG, newB, uint8(a / 0x101)})
 			} else {
 				break
@@ -256,62 +462,43 @@ func (s *StegImage) embedSecret() (err error) {
 	return nil
 }
 
-func getBitsFromRGB(values ...uint32) (bitsOut []int) {
-	for _, v := range values {
-		if v%2 == 0 {
-			bitsOut = append(bitsOut, 0)
-		} else {
-			bitsOut = append(bitsOut, 1)
-		}
-	}
-	return bitsOut
-}
-
-func (s *StegImage) getSecretString() (secret string, err error) {
+// getSecretString reads LSBs a character at a time until the stop marker
+// appears, so no more than the secret is ever held in memory
+func (s *StegImage) getSecretString(o *options) (secret string, err error) {
 	bounds := s.imgLoaded.Bounds()
-	bitsOut := make([]int, 0, bounds.Max.X*bounds.Max.Y)
-	for x := bounds.Min.X; x <= bounds.Max.X; x++ {
-		for y := bounds.Min.Y; y <= bounds.Max.Y; y++ {
+	budget := o.scanBudget(3)
+	var out strings.Builder
+	char, bits, scanned := 0, 0, 0
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if scanned += 3; budget > 0 && scanned > budget {
+				return "", ErrScanLimit
+			}
 			r, g, b, _ := s.imgLoaded.At(x, y).RGBA()
-			bitsOut = append(bitsOut, getBitsFromRGB(r, g, b)...)
+			for _, v := range [3]uint32{r, g, b} {
+				char = char<<1 | int(v&1)
+				if bits++; bits < 8 {
+					continue
+				}
+				out.WriteString(string(rune(char)))
+				char, bits = 0, 0
+				// The marker is ASCII, so it can only newly appear at the end
+				if strings.HasSuffix(out.String(), stopStegConst) {
+					secret = strings.TrimSuffix(out.String(), stopStegConst)
+					s.logger().Info("Secret string:", secret)
+					return secret, nil
+				}
+			}
 		}
 	}
 
-	tmp := bitsToString(bitsOut)
-	if strings.Contains(tmp, stopStegConst) {
-		secret = strings.Split(tmp, stopStegConst)[0]
-		log.Info("Secret string:", secret)
-		return secret, nil
-	}
-
-	return secret, errors.New("error finding embedded secret string")
-}
-
-func bitsToString(bits []int) (out string) {
-	for i := 0; i < len(bits); i += 8 {
-		batch := bits[i : i+8]
-		out = out + getCharFromBits(batch)
-	}
-	return out
-}
-
-func getCharFromBits(bits []int) (char string) {
-	tmp := 0
-	for b := 0; b < 8; b++ {
-		n := bits[b]
-		if n == 1 {
-			// Set bit
-			tmp = tmp | (1 << (7 - uint(b)))
-		}
-	}
-	char = string(tmp)
-	return char
+	return secret, errNoEmbeddedSecret
 }
 
-func manipulateLSB(i int, bit int) (newI uint8) {
+func manipulateLSB(i int, bit int, logger stegLogger) (newI uint8) {
 	newI = uint8(i)
-	log.Debug("Int Before:", newI, stringToBinary(string(newI)))
-	log.Debug("Value to Set LSB to:", bit)
+	logger.Debug("Int Before:", newI, stringToBinary(string(newI)))
+	logger.Debug("Value to Set LSB to:", bit)
 	if bit == 0 {
 		// Unset LSB
 		newI = newI &^ (1 << 0)
@@ -319,7 +506,7 @@ func manipulateLSB(i int, bit int) (newI uint8) {
 		// Set LSB
 		newI = newI | (1 << 0)
 	}
-	log.Debug("Int After:", newI, stringToBinary(string(newI)))
+	logger.Debug("Int After:", newI, stringToBinary(string(newI)))
 	return newI
 }
 
@@ -330,12 +517,12 @@ func stringToBinary(s string) (res string) {
 	return res
 }
 
-func stringsToInts(s []string) (ints []int, err error) {
+func stringsToInts(s []string, logger stegLogger) (ints []int, err error) {
 	for _, i := range s {
 		var j int
 		j, err = strconv.Atoi(i)
 		if err != nil {
-			log.Error(err)
+			logger.Error(err)
 			break
 		}
 		ints = append(ints, j)
@@ -1,8 +1,9 @@
 package libsteg
 
 import (
+	"bytes"
 	"flag"
-	"fmt"
+	"image"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -115,6 +116,29 @@ func TestCleanFileExtract(t *testing.T) {
 	}
 }
 
+// TestExtractStopsAtFirstMarker verifies extraction ends at the first stop
+// marker, ignoring whatever follows it, and keeps non-ASCII characters
+func TestExtractStopsAtFirstMarker(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	secretIn := "Kärl" + stopStegConst + "trailing"
+	if err := stegImg.DoStegEmbed(secretIn); err != nil {
+		t.Fatal(err)
+	}
+	secretOut, err := reloadImage(t, &stegImg).DoStegExtract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secretOut != "Kärl" {
+		t.Errorf("Extracted %q, expected %q", secretOut, "Kärl")
+	}
+}
+
 // TestSecretTooLarge verifies that an error is thrown when the image
 // is too small to embed the secret string inside
 func TestSecretTooLarge(t *testing.T) {
@@ -179,25 +203,13 @@ func TestColourValuesDifference(t *testing.T) {
 		t.Error(err)
 	}
 
-	// Check that the first n rgb values are only +1/-1 difference at most
-	bounds := cleanImg.imgLoaded.Bounds()
-	for x := bounds.Min.X; x < bounds.Max.X; x++ {
-		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-			r1, g1, b1, _ := cleanImg.imgLoaded.At(x, y).RGBA()
-			r2, g2, b2, _ := tamperedImg.imgLoaded.At(x, y).RGBA()
-			if err := checkColourDifference(uint8(r1), uint8(r2)); err != nil {
-				t.Log(err)
-				t.FailNow()
-			}
-			if err := checkColourDifference(uint8(g1), uint8(g2)); err != nil {
-				t.Log(err)
-				t.FailNow()
-			}
-			if err := checkColourDifference(uint8(b1), uint8(b2)); err != nil {
-				t.Log(err)
-				t.FailNow()
-			}
-		}
+	// Check that the rgb values are only +1/-1 difference at most
+	delta, err := MaxChannelDelta(cleanImg.imgLoaded, tamperedImg.imgLoaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta > 1 {
+		t.Fatalf("Colour difference not in acceptable range (-1,0,1) : %d", delta)
 	}
 }
 
@@ -226,12 +238,98 @@ func BenchmarkB64Embed(b *testing.B) {
 	benchmarkRet = imageB64Out
 }
 
-func checkColourDifference(x uint8, y uint8) (err error) {
-	z := int8(x - y)
+// TestB2BReaderWriter does a back to back test through LoadImage and
+// WriteNewImage, as used where there's no file system
+func TestB2BReaderWriter(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover, err := ioutil.ReadFile(tinyImageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cleanImg StegImage
+	if err = cleanImg.WriteNewImage(new(bytes.Buffer)); err != errNoImageLoaded {
+		t.Error("Correct Error not thrown, expected: '" + errString(errNoImageLoaded) + "' got: '" + errString(err) + "'")
+	}
+	if err = cleanImg.LoadImage(bytes.NewReader(cover)); err != nil {
+		t.Fatal(err)
+	}
+	if err = cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err = cleanImg.WriteNewImage(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var stego StegImage
+	if err = stego.LoadImage(buf); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stego.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestSubImage verifies embedding into and extracting from a SubImage view
+// with a non-zero origin, both the legacy string and the envelope way, and
+// in place without touching pixels outside the view
+func TestSubImage(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
 
-	if z == 0 || z == -1 || z == 1 {
-		return nil
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	region := image.Rect(40, 60, 70, 90)
+	sub := cleanImg.imgLoaded.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(region)
+
+	legacy := NewStegImageFromImage(sub)
+	// 30x30 pixels hold 337 bytes, less the stop marker
+	if err := legacy.DoStegEmbed(strings.Repeat("k", 400)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	secretIn := strings.Repeat(secretStringIn, 40)
+	if err := legacy.DoStegEmbed(secretIn); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Stego().Bounds() != region {
+		t.Errorf("Stego image has bounds %v, expected %v", legacy.Stego().Bounds(), region)
+	}
+	for _, s := range []*StegImage{NewStegImageFromImage(legacy.Stego()), reloadImage(t, legacy)} {
+		secretOut, err := s.DoStegExtract()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secretOut != secretIn {
+			t.Error("Secrets Do Not Match!")
+		}
 	}
 
-	return fmt.Errorf("Colour difference not in acceptable range (-1,0,1) : %d", z)
+	parent := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	view := parent.SubImage(region)
+	if err := NewStegImageFromImage(view).EmbedBytes([]byte(secretIn), WithInPlace()); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range parent.Pix {
+		x, y := i/4%100, i/4/100
+		if v != 0 && !image.Pt(x, y).In(region) {
+			t.Fatalf("Pixel %d,%d outside the view was changed", x, y)
+		}
+	}
+	payload, err := NewStegImageFromImage(parent.SubImage(region)).ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != secretIn {
+		t.Error("Secrets Do Not Match!")
+	}
 }
@@ -0,0 +1,63 @@
+package libsteg
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// steganoMaxDigits bounds the decimal length prefix read by SteganoCodec,
+// so a clean image isn't read to the end looking for the colon
+const steganoMaxDigits = 10
+
+// SteganoCodec is an EnvelopeCodec for the layout of the lsb module of the
+// Python stegano package: the payload length in decimal, a colon, then the
+// payload, one byte per character. Use it through WithSteganoCompat, which
+// also walks the pixels the way stegano does.
+type SteganoCodec struct{}
+
+// WithSteganoCompat reads and writes payloads in the bit layout of
+// stegano's lsb.hide and lsb.reveal with their default generator and
+// UTF-8 encoding: the SteganoCodec frame embedded MSB first in the red,
+// green and blue LSBs of each pixel, row by row. stegano stores one byte
+// per character, so text exchanged with it must be ASCII or Latin-1.
+// Passphrases, compression and the other options that change the stored
+// bytes make payloads stegano can't read.
+func WithSteganoCompat() Option {
+	return func(o *options) {
+		o.codec = SteganoCodec{}
+		o.scanOrder = ScanRowMajor
+		o.bitOrder = MSBFirst
+		o.channelOrder = ChannelsRGB
+	}
+}
+
+// Frame implements EnvelopeCodec
+func (SteganoCodec) Frame(payload []byte) ([]byte, error) {
+	framed := strconv.AppendInt(nil, int64(len(payload)), 10)
+	framed = append(framed, ':')
+	return append(framed, payload...), nil
+}
+
+// Deframe implements EnvelopeCodec
+func (SteganoCodec) Deframe(r io.Reader) ([]byte, error) {
+	var n int
+	c := make([]byte, 1)
+	for digits := 0; ; digits++ {
+		if _, err := io.ReadFull(r, c); err != nil {
+			return nil, ErrNoPayload
+		}
+		if c[0] == ':' && digits > 0 {
+			break
+		}
+		if c[0] < '0' || c[0] > '9' || digits == steganoMaxDigits {
+			return nil, ErrNoPayload
+		}
+		n = n*10 + int(c[0]-'0')
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil || len(payload) != n {
+		return nil, ErrNoPayload
+	}
+	return payload, nil
+}
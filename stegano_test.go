@@ -0,0 +1,89 @@
+package libsteg
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// steganoHide writes message into img as stegano's lsb.hide does
+func steganoHide(img *image.NRGBA, message string) {
+	framed := fmt.Sprintf("%d:%s", len(message), message)
+	var bits []byte
+	for _, c := range []byte(framed) {
+		for b := 7; b >= 0; b-- {
+			bits = append(bits, c>>uint(b)&1)
+		}
+	}
+	for len(bits)%3 != 0 {
+		bits = append(bits, 0)
+	}
+	w := img.Bounds().Dx()
+	for i := 0; i < len(bits); i += 3 {
+		x, y := i/3%w, i/3/w
+		c := img.NRGBAAt(x, y)
+		c.R, c.G, c.B = c.R&^1|bits[i], c.G&^1|bits[i+1], c.B&^1|bits[i+2]
+		img.SetNRGBA(x, y, c)
+	}
+}
+
+// steganoReveal reads a message from img as stegano's lsb.reveal does
+func steganoReveal(img image.Image) string {
+	w := img.Bounds().Dx()
+	var out []byte
+	var c byte
+	for i := 0; i < bitCapacity(img.Bounds(), 3); i++ {
+		r, g, b, _ := img.At(i/3%w, i/3/w).RGBA()
+		c = c<<1 | byte([]uint32{r, g, b}[i%3]>>8&1)
+		if i%8 == 7 {
+			out = append(out, c)
+			c = 0
+			var n int
+			if k, err := fmt.Sscanf(string(out), "%d:", &n); err == nil && k == 1 &&
+				len(out) == len(fmt.Sprint(n))+1+n {
+				return string(out[len(fmt.Sprint(n))+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// TestSteganoCompat verifies payloads are exchanged both ways with the
+// stegano layout
+func TestSteganoCompat(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	message := "Hello from Python"
+	cover := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for i := range cover.Pix {
+		cover.Pix[i] = byte(i * 7)
+		if i%4 == 3 {
+			cover.Pix[i] = 0xff // Opaque, stegano reads straight values
+		}
+	}
+	theirs := image.NewNRGBA(cover.Bounds())
+	copy(theirs.Pix, cover.Pix)
+	steganoHide(theirs, message)
+	payload, err := NewStegImageFromImage(theirs).ExtractBytes(WithSteganoCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != message {
+		t.Errorf("Extracted %q, expected %q", payload, message)
+	}
+
+	ours := NewStegImageFromImage(cover)
+	if err := ours.EmbedBytes([]byte(message), WithSteganoCompat()); err != nil {
+		t.Fatal(err)
+	}
+	stego := reloadImage(t, ours)
+	if got := steganoReveal(stego.imgLoaded); got != message {
+		t.Errorf("stegano would reveal %q, expected %q", got, message)
+	}
+	if _, err := NewStegImageFromImage(cover).ExtractBytes(WithSteganoCompat()); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}
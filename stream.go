@@ -0,0 +1,271 @@
+package libsteg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// channelLocation maps an index into the embedded bit stream onto the
+// pixel and colour channel (0=R, 1=G, 2=B, or 0 for grayscale) holding it.
+// Pixels are walked column by column, matching embedSecret.
+func channelLocation(bounds image.Rectangle, channels int, bitIndex int) (x int, y int, ch int) {
+	pixel := bitIndex / channels
+	height := bounds.Dy()
+	return bounds.Min.X + pixel/height, bounds.Min.Y + pixel%height, bitIndex % channels
+}
+
+// bitCapacity returns the number of LSBs available in the given bounds
+func bitCapacity(bounds image.Rectangle, channels int) int {
+	return bounds.Dx() * bounds.Dy() * channels
+}
+
+// imageChannels returns the number of channels carrying payload bits in
+// img, one for grayscale and paletted images and three (RGB) otherwise
+func imageChannels(img image.Image) int {
+	if _, ok := img.(*image.Paletted); ok {
+		return 1
+	}
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		return 1
+	}
+	return 3
+}
+
+// lsbWriter writes bytes into the LSBs of an image as they arrive
+type lsbWriter struct {
+	img    draw.Image // See createMutableImage for the concrete types
+	place  placement
+	offset int              // Index of the next bit to write
+	meter  *distortionMeter // Perceptual cost of the changes, if budgeted
+}
+
+// Write embeds p, failing with ErrInsufficientCapacity as soon as the
+// image has no room left
+func (w *lsbWriter) Write(p []byte) (n int, err error) {
+	if m, ok := w.place.(matrixPlacement); ok {
+		return w.writeMatrix(m, p)
+	}
+	for _, c := range p {
+		if w.offset+8 > w.place.capacity() {
+			return n, ErrInsufficientCapacity
+		}
+		for b := 7; b >= 0; b-- {
+			x, y, ch, plane := w.place.locate(w.offset)
+			var before color.Color
+			if w.meter != nil {
+				before = w.img.At(x, y)
+			}
+			bit := (c >> uint(b)) & 1
+			if r, ok := w.place.(redundantPlacement); ok {
+				r.set(w.img, w.offset, bit)
+			} else {
+				setBit(w.img, x, y, ch, plane, bit^maskBit(w.place, w.offset))
+			}
+			w.offset++
+			if w.meter != nil {
+				if err = w.meter.add(before, w.img.At(x, y)); err != nil {
+					return n, err
+				}
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// writeMatrix embeds as much of p as fits with matrix embedding, all at
+// once so no more than one LSB per group changes
+func (w *lsbWriter) writeMatrix(m matrixPlacement, p []byte) (n int, err error) {
+	n = minInt(len(p), (m.capacity()-w.offset)/8)
+	if n < 0 {
+		n = 0
+	}
+	bits := make([]byte, 0, n*8)
+	for _, c := range p[:n] {
+		for b := 7; b >= 0; b-- {
+			bits = append(bits, c>>uint(b)&1)
+		}
+	}
+	if err = m.write(w.img, w.offset, bits, w.meter); err != nil {
+		return 0, err
+	}
+	w.offset += len(bits)
+	if n < len(p) {
+		return n, ErrInsufficientCapacity
+	}
+	return n, nil
+}
+
+// channelByte returns the pixel buffer of img and the index of the byte
+// holding the low bits of one colour channel, or ok false if img isn't one
+// of the concrete types createMutableImage produces
+func channelByte(img image.Image, x, y, ch int) (pix []byte, i int, ok bool) {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return img.Pix, img.PixOffset(x, y) + ch, true
+	case *image.NRGBA:
+		return img.Pix, img.PixOffset(x, y) + ch, true
+	case *image.RGBA64:
+		// Channels are big endian, the LSB lives in the second byte
+		return img.Pix, img.PixOffset(x, y) + ch*2 + 1, true
+	case *image.NRGBA64:
+		return img.Pix, img.PixOffset(x, y) + ch*2 + 1, true
+	case *image.Gray:
+		return img.Pix, img.PixOffset(x, y), true
+	case *image.Gray16:
+		return img.Pix, img.PixOffset(x, y) + 1, true
+	case *image.Paletted:
+		return img.Pix, img.PixOffset(x, y), true
+	}
+	return nil, 0, false
+}
+
+// setBit sets one bit plane of one colour channel, writing the pixel
+// buffer directly so 16-bit and non-premultiplied images keep their full
+// precision
+func setBit(img draw.Image, x, y, ch, plane int, bit byte) {
+	if pix, i, ok := channelByte(img, x, y, ch); ok {
+		pix[i] = pix[i]&^(1<<uint(plane)) | bit<<uint(plane)
+	}
+}
+
+// getBit reads one bit plane of one colour channel, or of the palette
+// index for paletted images
+func getBit(img image.Image, x, y, ch, plane int) byte {
+	if pix, i, ok := channelByte(img, x, y, ch); ok {
+		return pix[i] >> uint(plane) & 1
+	}
+	rv, gv, bv, _ := img.At(x, y).RGBA()
+	return byte([]uint32{rv, gv, bv}[ch] >> uint(plane) & 1)
+}
+
+// lsbReader reads bytes back out of the LSBs of an image
+type lsbReader struct {
+	img    image.Image
+	place  placement
+	offset int // Index of the next bit to read
+}
+
+func (r *lsbReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if r.offset+8 > r.place.capacity() {
+			return n, io.EOF
+		}
+		var c byte
+		for b := 0; b < 8; b++ {
+			switch v := r.place.(type) {
+			case redundantPlacement:
+				c = c<<1 | v.vote(r.img, r.offset)
+			case matrixPlacement:
+				c = c<<1 | v.bit(r.img, r.offset)
+			default:
+				x, y, ch, plane := r.place.locate(r.offset)
+				c = c<<1 | (getBit(r.img, x, y, ch, plane) ^ maskBit(r.place, r.offset))
+			}
+			r.offset++
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
+
+// countingWriter counts the bytes passed through to w
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// EmbedFromReader embeds a payload of unknown length, streaming it into the
+// image as it is read from r. It fails with ErrInsufficientCapacity as
+// soon as r produces more data than the image can hold.
+func (s *StegImage) EmbedFromReader(r io.Reader, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("embed"), &err)
+	counted := &countingReader{r: r}
+	if err = s.embedStream(counted, flagChecksum, newOptions(opts)); err == nil {
+		ob.bytes = counted.n
+	}
+	return err
+}
+
+// ExtractTo writes the payload embedded with EmbedBytes or EmbedFromReader
+// to w as it is recovered from the pixels, so large payloads never have to
+// fit in memory. The stored data is verified in a first pass over the
+// image, so nothing is written unless its checksum, HMAC and signature
+// hold, and encrypted chunks are authenticated before they are written.
+// It returns the number of payload bytes written.
+func (s *StegImage) ExtractTo(w io.Writer, opts ...Option) (n int64, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("extract"), &err)
+	o := newOptions(opts)
+	if o.codec != nil {
+		// Custom codecs deframe into memory
+		payload, err := s.readCodec(o)
+		if err != nil {
+			s.logger().Error(err)
+			return 0, err
+		}
+		written, err := w.Write(payload)
+		if err == nil {
+			ob.bytes = int64(written)
+		}
+		return int64(written), err
+	}
+
+	place, err := s.extractPlacement(o)
+	if err != nil {
+		s.logger().Error(err)
+		return 0, err
+	}
+	e, dataLen, err := s.readEnvelopeHeader(place, 0)
+	if err != nil {
+		s.logger().Error(err)
+		return 0, err
+	}
+	start := e.headerLen()
+	trailer, err := s.readBytes(place, start+dataLen, e.trailerLen())
+	if err != nil {
+		err = noPayload(err)
+		s.logger().Error(err)
+		return 0, err
+	}
+	data := func() io.Reader {
+		return io.LimitReader(&lsbReader{img: s.imgLoaded, place: place, offset: start * 8}, int64(dataLen))
+	}
+	if err = e.verifyFrom(data, dataLen, trailer, o); err != nil {
+		s.locateDamage(err, place, start, dataLen)
+		s.logger().Error(err)
+		return 0, err
+	}
+
+	r, _, err := e.openReader(data(), o)
+	if err != nil {
+		s.logger().Error(err)
+		return 0, err
+	}
+	defer r.Close()
+	if n, err = io.Copy(w, r); err != nil {
+		s.logger().Error(err)
+		return n, err
+	}
+	ob.bytes = n
+	return n, nil
+}
@@ -0,0 +1,190 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestB2BReader does a back to back test of a streamed payload
+func TestB2BReader(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := strings.Repeat(secretStringIn, 100)
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	// Hide the length from EmbedFromReader
+	r := io.MultiReader(strings.NewReader(payloadIn))
+	if err := cleanImg.EmbedFromReader(r, WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+
+	payloadOut, err := reloadImage(t, &cleanImg).ExtractBytes(WithPassphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != payloadIn {
+		t.Error("Payloads Do Not Match!")
+	}
+}
+
+// TestReaderTooLarge verifies EmbedFromReader stops reading once the image
+// is full rather than consuming the whole reader
+func TestReaderTooLarge(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+
+	capacity := bitCapacity(cleanImg.imgLoaded.Bounds(), 3) / 8
+	r := bytes.NewReader(make([]byte, capacity*100))
+	err := cleanImg.EmbedFromReader(r)
+	if err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	if r.Len() < capacity*98 {
+		t.Error("Reader was consumed past the image capacity")
+	}
+}
+
+// TestB2B16Bit verifies a 16-bit image round trips with only the LSB of
+// each 16-bit channel value touched
+func TestB2B16Bit(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	src := image.NewRGBA64(image.Rect(0, 0, 40, 40))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			v := uint16(x*1601 + y*37)
+			src.SetRGBA64(x, y, color.RGBA64{v, v ^ 0x5a5a, v ^ 0xa5a5, 0xffff})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(base64.StdEncoding.EncodeToString(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	stegImg := reloadImage(t, &cleanImg)
+
+	payloadOut, err := stegImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			r0, g0, b0, _ := src.At(x, y).RGBA()
+			r1, g1, b1, _ := stegImg.imgLoaded.At(x, y).RGBA()
+			if r0^r1 > 1 || g0^g1 > 1 || b0^b1 > 1 {
+				t.Fatalf("Pixel (%d,%d) changed above the LSB", x, y)
+			}
+		}
+	}
+}
+
+// TestB2BGray verifies grayscale images embed one bit per pixel and are
+// written back out as grayscale
+func TestB2BGray(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, src := range []image.Image{image.NewGray(image.Rect(0, 0, 40, 40)), image.NewGray16(image.Rect(0, 0, 40, 40))} {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, src); err != nil {
+			t.Fatal(err)
+		}
+		var cleanImg StegImage
+		if err := cleanImg.LoadImageFromB64(base64.StdEncoding.EncodeToString(buf.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if err := cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+			t.Fatal(err)
+		}
+		stegImg := reloadImage(t, &cleanImg)
+		if stegImg.imgLoaded.ColorModel() != src.ColorModel() {
+			t.Errorf("Grayscale image written as %T", stegImg.imgLoaded)
+		}
+
+		payloadOut, err := stegImg.ExtractBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(payloadOut) != secretStringIn {
+			t.Error("Secrets Do Not Match!")
+		}
+
+		// Only one bit per pixel is available, so this can't fit with its header
+		payloadIn := make([]byte, bitCapacity(src.Bounds(), 1)/8)
+		if err = cleanImg.EmbedBytes(payloadIn); err != ErrInsufficientCapacity {
+			t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+		}
+	}
+}
+
+// TestExtractTo verifies ExtractTo streams the same payload ExtractBytes
+// returns, and writes nothing when the payload can't be opened
+func TestExtractTo(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	payloadIn := strings.Repeat(secretStringIn, 200)
+	opts := []Option{
+		WithPassphrase("hunter2"),
+		WithCompression(CompressionGzip),
+		WithHMAC(),
+		WithMetadata(Metadata{ContentType: "text/plain"}),
+	}
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanImg.EmbedBytes([]byte(payloadIn), opts...); err != nil {
+		t.Fatal(err)
+	}
+	stego := reloadImage(t, &cleanImg)
+
+	out := new(bytes.Buffer)
+	n, err := stego.ExtractTo(out, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != payloadIn || n != int64(len(payloadIn)) {
+		t.Error("Payloads Do Not Match!")
+	}
+
+	out.Reset()
+	_, wantErr := stego.ExtractBytes(WithPassphrase("wrong"), WithHMAC())
+	_, err = stego.ExtractTo(out, WithPassphrase("wrong"), WithHMAC())
+	if err == nil || err != wantErr {
+		t.Error("Correct Error not thrown, expected: '" + errString(wantErr) + "' got: '" + errString(err) + "'")
+	}
+	if out.Len() != 0 {
+		t.Error("Payload written despite failed verification")
+	}
+}
@@ -0,0 +1,183 @@
+package libsteg
+
+import (
+	"errors"
+	"image"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation names the kind of call reported to telemetry
+type Operation string
+
+const (
+	// OpEmbed is reported by EmbedBytes, EmbedText, EmbedJSON, EmbedProto,
+	// EmbedFromReader, DoStegEmbed and the robust embeds such as
+	// EmbedWavelet and WatermarkEmbed
+	OpEmbed Operation = "embed"
+	// OpExtract is reported by ExtractBytes, ExtractWithMetadata,
+	// ExtractJSON, ExtractProto, ExtractText, ExtractTo, DoStegExtract and
+	// the robust extracts such as ExtractWavelet and WatermarkVerify
+	OpExtract Operation = "extract"
+)
+
+// ErrorClass is a coarse category of failure, saying nothing about the
+// image or payload involved
+type ErrorClass string
+
+const (
+	// ErrorNone is reported for calls that succeed
+	ErrorNone ErrorClass = ""
+	// ErrorNoPayload is reported when no payload was found
+	ErrorNoPayload ErrorClass = "no_payload"
+	// ErrorCapacity is reported when the payload didn't fit or a scan
+	// limit was reached
+	ErrorCapacity ErrorClass = "capacity"
+	// ErrorIntegrity is reported when a checksum, HMAC or signature
+	// didn't hold
+	ErrorIntegrity ErrorClass = "integrity"
+	// ErrorKey is reported when a passphrase or identity was missing or
+	// wrong
+	ErrorKey ErrorClass = "key"
+	// ErrorOther is reported for every other failure
+	ErrorOther ErrorClass = "other"
+)
+
+// TelemetrySink receives one report per operation. Implementations must be
+// safe for concurrent use and should return quickly, as they're called on
+// the caller's goroutine.
+type TelemetrySink interface {
+	Report(op Operation, class ErrorClass, d time.Duration)
+}
+
+var telemetry struct {
+	sync.RWMutex
+	sink TelemetrySink
+}
+
+// EnableTelemetry opts in to reporting operations to sink. Nothing is
+// reported until it's called. Only the operation, its error class and
+// duration are reported, never payloads, keys, paths, sizes or error
+// messages. A nil sink turns reporting off again.
+func EnableTelemetry(sink TelemetrySink) {
+	telemetry.Lock()
+	defer telemetry.Unlock()
+	telemetry.sink = sink
+}
+
+// observation is one operation being reported to telemetry, metrics and
+// the log
+type observation struct {
+	op     Operation
+	start  time.Time
+	bounds image.Rectangle // Of the image operated on, empty if none
+	bytes  int64           // Payload bytes processed, reported on success
+	logger stegLogger      // Logs the operation as it ends
+}
+
+// observe starts observing an operation on img, which may be nil. Its done
+// method is meant to be deferred.
+func observe(op Operation, img image.Image) *observation {
+	ob := &observation{op: op, start: time.Now(), logger: log}
+	if img != nil {
+		ob.bounds = img.Bounds()
+	}
+	return ob
+}
+
+// observe starts observing an operation on s's image, logged at s's level
+func (s *StegImage) observe(op Operation) *observation {
+	ob := observe(op, s.imgLoaded)
+	ob.logger = s.logger()
+	return ob
+}
+
+// done reports the operation, ended with the error *errp, to telemetry and
+// metrics, if enabled, and logs it
+func (ob *observation) done(errp *error) {
+	d, class := time.Since(ob.start), classifyError(*errp)
+	telemetry.RLock()
+	sink := telemetry.sink
+	telemetry.RUnlock()
+	if sink != nil {
+		sink.Report(ob.op, class, d)
+	}
+	reportMetrics(ob.op, class, d)
+	if class == ErrorNone {
+		countBytes(ob.op, ob.bytes)
+	}
+	logOperation(ob, class, d)
+}
+
+// classifyError maps err onto its ErrorClass
+func classifyError(err error) ErrorClass {
+	var damage *DamageError
+	switch {
+	case err == nil:
+		return ErrorNone
+	case errors.Is(err, ErrNoPayload), err == errNoEmbeddedSecret:
+		return ErrorNoPayload
+	case errors.Is(err, ErrInsufficientCapacity), errors.Is(err, ErrScanLimit), errors.Is(err, ErrPayloadTooLarge):
+		return ErrorCapacity
+	case errors.As(err, &damage), errors.Is(err, ErrPayloadCorrupt), errors.Is(err, ErrDigestMismatch), errors.Is(err, ErrHMACMismatch),
+		errors.Is(err, ErrSignatureInvalid), errors.Is(err, ErrNotSigned), errors.Is(err, ErrNotEncrypted):
+		return ErrorIntegrity
+	case errors.Is(err, ErrDecryptFailed), errors.Is(err, errPassphraseRequired),
+		errors.Is(err, ErrNotRecipient), errors.Is(err, errIdentityRequired):
+		return ErrorKey
+	}
+	return ErrorOther
+}
+
+// TelemetryCount holds the totals for one operation
+type TelemetryCount struct {
+	Operation Operation
+	Calls     int64
+	Errors    map[ErrorClass]int64 // Failed calls by class
+	Duration  time.Duration        // Total time spent in the calls
+}
+
+// TelemetryCounters is a TelemetrySink keeping running totals in memory,
+// for exporting to whatever monitoring a service uses
+type TelemetryCounters struct {
+	mu     sync.Mutex
+	counts map[Operation]*TelemetryCount
+}
+
+// Report implements TelemetrySink
+func (c *TelemetryCounters) Report(op Operation, class ErrorClass, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[Operation]*TelemetryCount)
+	}
+	count, ok := c.counts[op]
+	if !ok {
+		count = &TelemetryCount{Operation: op, Errors: make(map[ErrorClass]int64)}
+		c.counts[op] = count
+	}
+	count.Calls++
+	count.Duration += d
+	if class != ErrorNone {
+		count.Errors[class]++
+	}
+}
+
+// Snapshot returns a copy of the totals, ordered by operation
+func (c *TelemetryCounters) Snapshot() []TelemetryCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make([]TelemetryCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		errs := make(map[ErrorClass]int64, len(count.Errors))
+		for class, n := range count.Errors {
+			errs[class] = n
+		}
+		snapshot := *count
+		snapshot.Errors = errs
+		counts = append(counts, snapshot)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Operation < counts[j].Operation })
+	return counts
+}
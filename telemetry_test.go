@@ -0,0 +1,71 @@
+package libsteg
+
+import (
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestClassifyError checks failures fall into their coarse classes
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for err, class := range map[error]ErrorClass{
+		nil:                                  ErrorNone,
+		ErrNoPayload:                         ErrorNoPayload,
+		errNoEmbeddedSecret:                  ErrorNoPayload,
+		ErrInsufficientCapacity:              ErrorCapacity,
+		ErrScanLimit:                         ErrorCapacity,
+		ErrHMACMismatch:                      ErrorIntegrity,
+		&DamageError{Err: ErrPayloadCorrupt}: ErrorIntegrity,
+		ErrDecryptFailed:                     ErrorKey,
+		errNoImageLoaded:                     ErrorOther,
+	} {
+		if got := classifyError(err); got != class {
+			t.Errorf("%v classed as %q, expected %q", err, got, class)
+		}
+	}
+}
+
+// TestTelemetry verifies operations are only reported once enabled, and
+// are counted by outcome. It isn't parallel as the sink is global.
+func TestTelemetry(t *testing.T) {
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	counters := new(TelemetryCounters)
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	EnableTelemetry(counters)
+	defer EnableTelemetry(nil)
+
+	stego := reloadImage(t, &stegImg)
+	if _, err := stego.ExtractBytes(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStegImageFromImage(stegImg.imgLoaded).ExtractBytes(); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+	if err := stegImg.EmbedBytes(make([]byte, 1<<20)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + ErrInsufficientCapacity.Error() + "' got: '" + errString(err) + "'")
+	}
+	EnableTelemetry(nil)
+	stego.ExtractBytes()
+
+	snapshot := counters.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected counts for 2 operations, got %+v", snapshot)
+	}
+	embed, extract := snapshot[0], snapshot[1]
+	if embed.Operation != OpEmbed || embed.Calls != 1 || embed.Errors[ErrorCapacity] != 1 {
+		t.Errorf("Unexpected embed counts %+v", embed)
+	}
+	if extract.Operation != OpExtract || extract.Calls != 2 || extract.Errors[ErrorNoPayload] != 1 {
+		t.Errorf("Unexpected extract counts %+v", extract)
+	}
+}
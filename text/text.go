@@ -0,0 +1,134 @@
+// Package text hides payloads in cover text as zero-width characters, for
+// channels where images can't be transmitted.
+//
+// Each payload bit becomes a zero-width non-joiner (0) or zero-width joiner
+// (1), spread across the word gaps of the cover so the text renders
+// unchanged. The bits are prefixed by the payload length as a 32-bit big
+// endian integer.
+package text
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zeroBit = '\u200c' // Zero-width non-joiner
+	oneBit  = '\u200d' // Zero-width joiner
+
+	lengthBits = 32
+)
+
+var (
+	// ErrNoPayload is returned when the text holds no zero-width characters
+	ErrNoPayload = errors.New("no payload found in text")
+	// ErrCoverHasZeroWidth is returned when the cover already contains
+	// joiners, as in emoji sequences, which would corrupt the payload
+	ErrCoverHasZeroWidth = errors.New("cover text already contains zero-width joiners")
+	errEmptyCover        = errors.New("cover text is empty")
+	errTruncated         = errors.New("payload truncated")
+)
+
+// Embed returns cover with payload hidden in it as zero-width characters
+func Embed(cover string, payload []byte) (string, error) {
+	if cover == "" {
+		return "", errEmptyCover
+	}
+	if strings.ContainsRune(cover, zeroBit) || strings.ContainsRune(cover, oneBit) {
+		return "", ErrCoverHasZeroWidth
+	}
+
+	framed := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(framed, uint32(len(payload)))
+	copy(framed[4:], payload)
+	bits := make([]rune, 0, len(framed)*8)
+	for _, b := range framed {
+		for i := 7; i >= 0; i-- {
+			if b>>uint(i)&1 == 1 {
+				bits = append(bits, oneBit)
+			} else {
+				bits = append(bits, zeroBit)
+			}
+		}
+	}
+
+	// Insert after each run of spaces, or after the first character if
+	// there are none, never at the very start where it's easily trimmed
+	gaps := wordGaps(cover)
+	if len(gaps) == 0 {
+		_, size := utf8.DecodeRuneInString(cover)
+		gaps = []int{size}
+	}
+
+	var out strings.Builder
+	out.Grow(len(cover) + len(bits)*utf8.RuneLen(zeroBit))
+	last := 0
+	for i, gap := range gaps {
+		out.WriteString(cover[last:gap])
+		last = gap
+		// Share the bits evenly, earlier gaps taking any remainder
+		from, to := len(bits)*i/len(gaps), len(bits)*(i+1)/len(gaps)
+		out.WriteString(string(bits[from:to]))
+	}
+	out.WriteString(cover[last:])
+	return out.String(), nil
+}
+
+// Extract returns the payload hidden in text by Embed
+func Extract(text string) ([]byte, error) {
+	var bytes []byte
+	var current byte
+	var count int
+	for _, r := range text {
+		if r != zeroBit && r != oneBit {
+			continue
+		}
+		current <<= 1
+		if r == oneBit {
+			current |= 1
+		}
+		if count++; count%8 == 0 {
+			bytes = append(bytes, current)
+			current = 0
+		}
+	}
+	if count == 0 {
+		return nil, ErrNoPayload
+	}
+	if count < lengthBits {
+		return nil, errTruncated
+	}
+
+	length := binary.BigEndian.Uint32(bytes)
+	if uint64(len(bytes)-4) < uint64(length) {
+		return nil, errTruncated
+	}
+	return bytes[4 : 4+length], nil
+}
+
+// Strip returns text with any zero-width payload characters removed
+func Strip(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == zeroBit || r == oneBit {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// wordGaps returns the byte offsets just after each run of whitespace
+// followed by more text
+func wordGaps(s string) (gaps []int) {
+	inSpace := false
+	for i, r := range s {
+		space := unicode.IsSpace(r)
+		if inSpace && !space {
+			gaps = append(gaps, i)
+		}
+		inSpace = space
+	}
+	return gaps
+}
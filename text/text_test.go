@@ -0,0 +1,58 @@
+package text
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const coverText = "The quick brown fox jumps over the lazy dog"
+
+// TestEmbedExtract verifies a payload round trips and the visible text is
+// unchanged
+func TestEmbedExtract(t *testing.T) {
+	t.Parallel()
+
+	for _, cover := range []string{coverText, "Word", "日本語"} {
+		payload := []byte("Karl")
+		stego, err := Embed(cover, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Strip(stego) != cover {
+			t.Errorf("Visible text changed: %q", Strip(stego))
+		}
+		if strings.HasPrefix(stego, string(zeroBit)) || strings.HasPrefix(stego, string(oneBit)) {
+			t.Error("Payload placed at start of text")
+		}
+		out, err := Extract(stego)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, payload) {
+			t.Errorf("Extracted %q, expected %q", out, payload)
+		}
+	}
+}
+
+// TestExtractErrors verifies the error cases
+func TestExtractErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Extract(coverText); err != ErrNoPayload {
+		t.Errorf("Expected ErrNoPayload, got %v", err)
+	}
+	stego, err := Embed(coverText, []byte("Karl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Extract(stego[:len(stego)-len(" dog")-6]); err != errTruncated {
+		t.Errorf("Expected errTruncated, got %v", err)
+	}
+	if _, err := Embed("\U0001F468\u200d\U0001F469\u200d\U0001F467", []byte("Karl")); err != ErrCoverHasZeroWidth {
+		t.Errorf("Expected ErrCoverHasZeroWidth, got %v", err)
+	}
+	if _, err := Embed("", []byte("Karl")); err != errEmptyCover {
+		t.Errorf("Expected errEmptyCover, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package libsteg
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeForm is a Unicode normalization form applied to text payloads
+type UnicodeForm int
+
+const (
+	// FormNone leaves the text as given, the default
+	FormNone UnicodeForm = iota
+	// FormNFC composes characters, as macOS input methods and most web
+	// forms produce them
+	FormNFC
+	// FormNFD decomposes characters, as the macOS file system stores them
+	FormNFD
+)
+
+// TextNormalization says how text payloads are normalized before they're
+// embedded, so the same secret typed on different systems embeds as the
+// same bytes
+type TextNormalization struct {
+	Form     UnicodeForm
+	Newlines bool // Convert CRLF and CR line endings to LF
+}
+
+// The text normalization of a payload embedded with EmbedText is held in
+// envelope flag bits 9 to 11
+const (
+	flagTextForm      uint16 = 3 << textFormFlagShift
+	textFormFlagShift        = 9
+	flagTextNewlines  uint16 = 1 << 11
+)
+
+var errUnicodeForm = errors.New("unknown Unicode normalization form")
+
+// WithTextNormalization normalizes text embedded with EmbedText as n says.
+// The normalization is recorded in the envelope and returned by
+// ExtractText, so text to compare with the payload can be normalized alike.
+func WithTextNormalization(n TextNormalization) Option {
+	return func(o *options) {
+		o.textNorm = n
+	}
+}
+
+// Apply returns s normalized
+func (n TextNormalization) Apply(s string) string {
+	if n.Newlines {
+		s = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s)
+	}
+	switch n.Form {
+	case FormNFC:
+		s = norm.NFC.String(s)
+	case FormNFD:
+		s = norm.NFD.String(s)
+	}
+	return s
+}
+
+// flags returns the envelope flags recording the normalization
+func (n TextNormalization) flags() (uint16, error) {
+	if n.Form < FormNone || n.Form > FormNFD {
+		return 0, errUnicodeForm
+	}
+	flags := uint16(n.Form) << textFormFlagShift
+	if n.Newlines {
+		flags |= flagTextNewlines
+	}
+	return flags, nil
+}
+
+// textNormalization returns the normalization recorded in the envelope
+func (e *envelope) textNormalization() TextNormalization {
+	return TextNormalization{
+		Form:     UnicodeForm(e.flags & flagTextForm >> textFormFlagShift),
+		Newlines: e.flags&flagTextNewlines != 0,
+	}
+}
+
+// EmbedText embeds text as a checksummed payload, normalized as set with
+// WithTextNormalization
+func (s *StegImage) EmbedText(text string, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("embed"), &err)
+	o := newOptions(opts)
+	flags, err := o.textNorm.flags()
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	text = o.textNorm.Apply(text)
+	if err = s.embedStream(strings.NewReader(text), flagChecksum|flags, o); err == nil {
+		ob.bytes = int64(len(text))
+	}
+	return err
+}
+
+// ExtractText retrieves text embedded with EmbedText along with the
+// normalization it was embedded with
+func (s *StegImage) ExtractText(opts ...Option) (text string, n TextNormalization, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	defer endSpan(s.startSpan("extract"), &err)
+	o := newOptions(opts)
+	if o.codec != nil {
+		// Custom codecs have no flags to record the normalization in
+		payload, err := s.readCodec(o)
+		if err != nil {
+			s.logger().Error(err)
+			return "", TextNormalization{}, err
+		}
+		ob.bytes = int64(len(payload))
+		return string(payload), TextNormalization{}, nil
+	}
+	e, err := s.extractEnvelope(o)
+	if err != nil {
+		s.logger().Error(err)
+		return "", TextNormalization{}, err
+	}
+	payload, _, err := e.open(o)
+	if err != nil {
+		s.logger().Error(err)
+		return "", TextNormalization{}, err
+	}
+	ob.bytes = int64(len(payload))
+	return string(payload), e.textNormalization(), nil
+}
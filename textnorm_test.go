@@ -0,0 +1,64 @@
+package libsteg
+
+import (
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestTextNormalization verifies secrets typed differently extract the
+// same once normalized, and the normalization is recorded
+func TestTextNormalization(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	composed := "K\u00e4rl\r\nline two\rthree"
+	decomposed := "Ka\u0308rl\nline two\nthree"
+	n := TextNormalization{Form: FormNFC, Newlines: true}
+	want := "K\u00e4rl\nline two\nthree"
+
+	for _, secret := range []string{composed, decomposed} {
+		var stegImg StegImage
+		if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := stegImg.EmbedText(secret, WithTextNormalization(n)); err != nil {
+			t.Fatal(err)
+		}
+		text, applied, err := reloadImage(t, &stegImg).ExtractText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != want {
+			t.Errorf("Extracted %q, expected %q", text, want)
+		}
+		if applied != n {
+			t.Errorf("Recorded normalization %+v, expected %+v", applied, n)
+		}
+		if applied.Apply(decomposed) != text {
+			t.Error("Typed secret doesn't compare equal once normalized")
+		}
+	}
+
+	if got := (TextNormalization{Form: FormNFD}).Apply("\u00e4\r\n"); got != "a\u0308\r\n" {
+		t.Errorf("NFD gave %q", got)
+	}
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedText(composed); err != nil {
+		t.Fatal(err)
+	}
+	text, applied, err := reloadImage(t, &stegImg).ExtractText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != composed || applied != (TextNormalization{}) {
+		t.Errorf("Unnormalized text changed: %q %+v", text, applied)
+	}
+	if err := stegImg.EmbedText(composed, WithTextNormalization(TextNormalization{Form: 7})); err != errUnicodeForm {
+		t.Error("Correct Error not thrown, expected: '" + errUnicodeForm.Error() + "' got: '" + errString(err) + "'")
+	}
+}
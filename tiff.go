@@ -0,0 +1,468 @@
+package libsteg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+var errBadTIFF = errors.New("not a supported baseline TIFF")
+
+// TIFF tags the image layout is read from or written to
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPlanarConfig    = 284
+	tiffPredictor       = 317
+	tiffTileWidth       = 322
+	tiffSubIFDs         = 330
+	tiffExtraSamples    = 338
+	tiffExifIFD         = 34665
+	tiffGPSIFD          = 34853
+	tiffInteropIFD      = 40965
+)
+
+// TIFF compression schemes that can be read, Deflate is written back
+const (
+	tiffCompressionNone         = 1
+	tiffCompressionDeflate      = 8
+	tiffCompressionDeflateAdobe = 32946
+)
+
+// ExtraSamples values for the alpha of a four sample image
+const (
+	tiffAlphaAssociated   = 1
+	tiffAlphaUnassociated = 2
+)
+
+// tiffMaxPixels bounds the size of image a TIFF may claim
+const tiffMaxPixels = 1 << 28
+
+// tiffTypeSize is the size in bytes of each TIFF field type
+var tiffTypeSize = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// EmbedTIFF embeds payload into the TIFF read from r, such as a 16-bit
+// TIFF exported from a camera RAW, and writes the result to w as a TIFF of
+// the same bit depth. Uncompressed and Deflate images with 8 or 16 bits
+// per sample are supported. Samples are embedded into as they are stored,
+// so 16-bit images carry the payload in their true 16-bit LSBs. Every tag
+// not describing the pixel layout is copied through, including the ICC
+// profile and the EXIF and GPS IFDs.
+func EmbedTIFF(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	t, err := readTIFF(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	s := StegImage{imgLoaded: t.img, imgType: "tiff"}
+	if err = s.EmbedBytes(payload, opts...); err != nil {
+		return err
+	}
+	if err = t.write(w, s.newImg); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ExtractTIFF retrieves a payload embedded with EmbedTIFF from the TIFF
+// read from r
+func ExtractTIFF(r io.Reader, opts ...Option) ([]byte, error) {
+	t, err := readTIFF(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	s := StegImage{imgLoaded: t.img, imgType: "tiff"}
+	return s.ExtractBytes(opts...)
+}
+
+// tiffEntry is one IFD entry, value holding its raw bytes in the file's
+// byte order, or sub the entries of the IFD it points at
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+	sub   []tiffEntry
+}
+
+// uints returns the values of a BYTE, SHORT or LONG entry
+func (e tiffEntry) uints(order binary.ByteOrder) []uint32 {
+	size := tiffTypeSize[e.typ]
+	vals := make([]uint32, 0, e.count)
+	for i := 0; i+size <= len(e.value); i += size {
+		switch e.typ {
+		case 1:
+			vals = append(vals, uint32(e.value[i]))
+		case 3:
+			vals = append(vals, uint32(order.Uint16(e.value[i:])))
+		case 4, 13:
+			vals = append(vals, order.Uint32(e.value[i:]))
+		}
+	}
+	return vals
+}
+
+// tiffFile is a parsed single image TIFF
+type tiffFile struct {
+	order       binary.ByteOrder
+	entries     []tiffEntry // IFD0, less the tags describing the strips
+	width       int
+	height      int
+	bits        int // Bits per sample, 8 or 16
+	spp         int // Samples per pixel, 1, 3 or 4
+	compression uint32
+	img         image.Image
+}
+
+// readTIFF parses the first image of a TIFF. Samples are held raw in a
+// Gray or RGBA image of the same depth, so alpha is never applied and
+// writing them back is lossless.
+func readTIFF(r io.Reader) (*tiffFile, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) < 8 {
+		return nil, errBadTIFF
+	}
+	t := &tiffFile{}
+	switch string(doc[:4]) {
+	case "II*\x00":
+		t.order = binary.LittleEndian
+	case "MM\x00*":
+		t.order = binary.BigEndian
+	default:
+		return nil, errBadTIFF
+	}
+	entries, err := readTIFFIFD(doc, t.order, t.order.Uint32(doc[4:]), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[uint16][]uint32)
+	for _, e := range entries {
+		switch e.tag {
+		case tiffImageWidth, tiffImageLength, tiffBitsPerSample, tiffCompression, tiffPhotometric,
+			tiffStripOffsets, tiffSamplesPerPixel, tiffRowsPerStrip, tiffStripByteCounts,
+			tiffPlanarConfig, tiffPredictor:
+			fields[e.tag] = e.uints(t.order)
+		}
+		switch {
+		case e.tag == tiffCompression, e.tag == tiffStripOffsets, e.tag == tiffRowsPerStrip,
+			e.tag == tiffStripByteCounts, e.tag == tiffPredictor, e.tag == tiffSubIFDs:
+			// Rewritten for the new strip, or pointing at images not kept
+		case e.tag >= tiffTileWidth && e.tag <= tiffTileWidth+3:
+			return nil, errBadTIFF
+		default:
+			t.entries = append(t.entries, e)
+		}
+	}
+	field := func(tag uint16, def uint32) uint32 {
+		if vals := fields[tag]; len(vals) > 0 {
+			return vals[0]
+		}
+		return def
+	}
+
+	t.width, t.height = int(field(tiffImageWidth, 0)), int(field(tiffImageLength, 0))
+	t.bits, t.spp = int(field(tiffBitsPerSample, 1)), int(field(tiffSamplesPerPixel, 1))
+	t.compression = field(tiffCompression, tiffCompressionNone)
+	predictor := field(tiffPredictor, 1)
+	photometric := field(tiffPhotometric, 0)
+	if t.width <= 0 || t.height <= 0 || t.width*t.height > tiffMaxPixels {
+		return nil, errBadTIFF
+	}
+	for _, bits := range fields[tiffBitsPerSample] {
+		if int(bits) != t.bits {
+			return nil, errBadTIFF
+		}
+	}
+	switch {
+	case t.bits != 8 && t.bits != 16,
+		t.spp == 1 && photometric > 1,
+		(t.spp == 3 || t.spp == 4) && photometric != 2,
+		t.spp != 1 && t.spp != 3 && t.spp != 4,
+		field(tiffPlanarConfig, 1) != 1,
+		predictor != 1 && predictor != 2:
+		return nil, errBadTIFF
+	}
+	switch t.compression {
+	case tiffCompressionNone, tiffCompressionDeflate, tiffCompressionDeflateAdobe:
+	default:
+		return nil, errBadTIFF
+	}
+
+	// Gather the strips into one buffer of rows
+	rowBytes := t.width * t.spp * t.bits / 8
+	raw := make([]byte, t.height*rowBytes)
+	offsets, counts := fields[tiffStripOffsets], fields[tiffStripByteCounts]
+	rowsPerStrip := int(field(tiffRowsPerStrip, uint32(t.height)))
+	if len(offsets) == 0 || len(offsets) != len(counts) || rowsPerStrip <= 0 {
+		return nil, errBadTIFF
+	}
+	for i := range offsets {
+		start := i * rowsPerStrip * rowBytes
+		if start >= len(raw) {
+			break
+		}
+		end := uint64(offsets[i]) + uint64(counts[i])
+		if end > uint64(len(doc)) {
+			return nil, errBadTIFF
+		}
+		strip := doc[offsets[i]:end]
+		want := minInt(rowsPerStrip*rowBytes, len(raw)-start)
+		if t.compression != tiffCompressionNone {
+			zr, err := zlib.NewReader(bytes.NewReader(strip))
+			if err != nil {
+				return nil, errBadTIFF
+			}
+			if strip, err = ioutil.ReadAll(io.LimitReader(zr, int64(want))); err != nil {
+				return nil, errBadTIFF
+			}
+		}
+		if len(strip) < want {
+			return nil, errBadTIFF
+		}
+		copy(raw[start:], strip[:want])
+	}
+	if predictor == 2 {
+		t.undoPredictor(raw, rowBytes)
+	}
+
+	t.img = t.samplesToImage(raw)
+	return t, nil
+}
+
+// readTIFFIFD reads the IFD at offset, following the EXIF, GPS and
+// interoperability IFDs it points at
+func readTIFFIFD(doc []byte, order binary.ByteOrder, offset uint32, depth int) ([]tiffEntry, error) {
+	if depth > 2 || uint64(offset)+2 > uint64(len(doc)) {
+		return nil, errBadTIFF
+	}
+	n := int(order.Uint16(doc[offset:]))
+	if int(offset)+2+12*n > len(doc) {
+		return nil, errBadTIFF
+	}
+	entries := make([]tiffEntry, 0, n)
+	for i := 0; i < n; i++ {
+		field := doc[int(offset)+2+12*i:]
+		e := tiffEntry{
+			tag:   order.Uint16(field),
+			typ:   order.Uint16(field[2:]),
+			count: order.Uint32(field[4:]),
+		}
+		size, ok := tiffTypeSize[e.typ]
+		if !ok {
+			// Unknown types can't be relocated, so they're dropped
+			continue
+		}
+		valueLen := uint64(size) * uint64(e.count)
+		if valueLen <= 4 {
+			e.value = append([]byte(nil), field[8:8+valueLen]...)
+		} else {
+			at := uint64(order.Uint32(field[8:]))
+			if at+valueLen > uint64(len(doc)) {
+				return nil, errBadTIFF
+			}
+			e.value = append([]byte(nil), doc[at:at+valueLen]...)
+		}
+		switch e.tag {
+		case tiffExifIFD, tiffGPSIFD, tiffInteropIFD:
+			vals := e.uints(order)
+			if len(vals) != 1 {
+				return nil, errBadTIFF
+			}
+			sub, err := readTIFFIFD(doc, order, vals[0], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			e.sub = sub
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// undoPredictor reverses horizontal differencing, row by row
+func (t *tiffFile) undoPredictor(raw []byte, rowBytes int) {
+	for row := 0; row < len(raw); row += rowBytes {
+		samples := raw[row : row+rowBytes]
+		if t.bits == 8 {
+			for i := t.spp; i < len(samples); i++ {
+				samples[i] += samples[i-t.spp]
+			}
+			continue
+		}
+		for i := t.spp * 2; i < len(samples); i += 2 {
+			t.order.PutUint16(samples[i:], t.order.Uint16(samples[i:])+t.order.Uint16(samples[i-t.spp*2:]))
+		}
+	}
+}
+
+// samplesToImage holds raw samples in an image of matching depth, with
+// opaque alpha added to RGB
+func (t *tiffFile) samplesToImage(raw []byte) image.Image {
+	rect := image.Rect(0, 0, t.width, t.height)
+	var img image.Image
+	var pix []byte
+	switch {
+	case t.spp == 1 && t.bits == 8:
+		gray := image.NewGray(rect)
+		img, pix = gray, gray.Pix
+	case t.spp == 1:
+		gray := image.NewGray16(rect)
+		img, pix = gray, gray.Pix
+	case t.bits == 8:
+		rgba := image.NewRGBA(rect)
+		img, pix = rgba, rgba.Pix
+	default:
+		rgba := image.NewRGBA64(rect)
+		img, pix = rgba, rgba.Pix
+	}
+	t.convertSamples(raw, pix, false)
+	return img
+}
+
+// convertSamples copies samples between the file layout in raw and the
+// big endian, one or four channel layout in pix
+func (t *tiffFile) convertSamples(raw []byte, pix []byte, toRaw bool) {
+	width := t.bits / 8
+	channels := 1
+	if t.spp > 1 {
+		channels = 4
+	}
+	for p := 0; p < t.width*t.height; p++ {
+		for c := 0; c < channels; c++ {
+			dst := (p*channels + c) * width
+			if c >= t.spp {
+				if !toRaw {
+					pix[dst], pix[dst+width-1] = 0xff, 0xff
+				}
+				continue
+			}
+			src := (p*t.spp + c) * width
+			switch {
+			case width == 1 && toRaw:
+				raw[src] = pix[dst]
+			case width == 1:
+				pix[dst] = raw[src]
+			case toRaw:
+				t.order.PutUint16(raw[src:], binary.BigEndian.Uint16(pix[dst:]))
+			default:
+				binary.BigEndian.PutUint16(pix[dst:], t.order.Uint16(raw[src:]))
+			}
+		}
+	}
+}
+
+// write writes img as a single strip TIFF carrying the entries read from
+// the source file, in its byte order and compression
+func (t *tiffFile) write(w io.Writer, img image.Image) error {
+	pix, _, ok := channelByte(img, 0, 0, 0)
+	if !ok {
+		return errBadTIFF
+	}
+	raw := make([]byte, t.height*t.width*t.spp*t.bits/8)
+	t.convertSamples(raw, pix, true)
+
+	var compression uint32 = tiffCompressionNone
+	if t.compression != tiffCompressionNone {
+		compression = tiffCompressionDeflate
+		buf := new(bytes.Buffer)
+		zw := zlib.NewWriter(buf)
+		zw.Write(raw)
+		zw.Close()
+		raw = buf.Bytes()
+	}
+
+	buf := new(bytes.Buffer)
+	if t.order == binary.LittleEndian {
+		buf.WriteString("II*\x00")
+	} else {
+		buf.WriteString("MM\x00*")
+	}
+	buf.Write(make([]byte, 4))
+	stripOffset := buf.Len()
+	buf.Write(raw)
+
+	entries := append([]tiffEntry{
+		t.shortEntry(tiffCompression, compression),
+		t.longEntry(tiffStripOffsets, uint32(stripOffset)),
+		t.longEntry(tiffRowsPerStrip, uint32(t.height)),
+		t.longEntry(tiffStripByteCounts, uint32(len(raw))),
+	}, t.entries...)
+	t.order.PutUint32(buf.Bytes()[4:], writeTIFFIFD(buf, t.order, entries))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// shortEntry returns a single value SHORT entry
+func (t *tiffFile) shortEntry(tag uint16, v uint32) tiffEntry {
+	value := make([]byte, 2)
+	t.order.PutUint16(value, uint16(v))
+	return tiffEntry{tag: tag, typ: 3, count: 1, value: value}
+}
+
+// longEntry returns a single value LONG entry
+func (t *tiffFile) longEntry(tag uint16, v uint32) tiffEntry {
+	value := make([]byte, 4)
+	t.order.PutUint32(value, v)
+	return tiffEntry{tag: tag, typ: 4, count: 1, value: value}
+}
+
+// writeTIFFIFD appends entries to buf as an IFD, sorted by tag, followed
+// by the values too large to fit in an entry and any IFDs pointed at. It
+// returns the offset of the IFD.
+func writeTIFFIFD(buf *bytes.Buffer, order binary.ByteOrder, entries []tiffEntry) uint32 {
+	entries = append([]tiffEntry(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	if buf.Len()%2 == 1 {
+		buf.WriteByte(0)
+	}
+	start := buf.Len()
+	binary.Write(buf, order, uint16(len(entries)))
+	for _, e := range entries {
+		field := make([]byte, 12)
+		order.PutUint16(field, e.tag)
+		order.PutUint16(field[2:], e.typ)
+		order.PutUint32(field[4:], e.count)
+		if len(e.value) <= 4 && e.sub == nil {
+			copy(field[8:], e.value)
+		}
+		buf.Write(field)
+	}
+	buf.Write(make([]byte, 4)) // No next IFD
+
+	for i, e := range entries {
+		slot := start + 2 + 12*i + 8
+		switch {
+		case e.sub != nil:
+			order.PutUint32(buf.Bytes()[slot:], writeTIFFIFD(buf, order, e.sub))
+		case len(e.value) > 4:
+			if buf.Len()%2 == 1 {
+				buf.WriteByte(0)
+			}
+			order.PutUint32(buf.Bytes()[slot:], uint32(buf.Len()))
+			buf.Write(e.value)
+		}
+	}
+	return uint32(start)
+}
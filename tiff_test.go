@@ -0,0 +1,120 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testTIFF returns a 16-bit RGB TIFF with an ICC profile and an EXIF IFD
+func testTIFF(t *testing.T, order binary.ByteOrder, compression uint32) []byte {
+	t.Helper()
+	img := image.NewRGBA64(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetRGBA64(x, y, color.RGBA64{uint16(x * 1021), uint16(y * 1361), uint16((x + y) * 577), 0xffff})
+		}
+	}
+	src := &tiffFile{order: order, width: 64, height: 48, bits: 16, spp: 3, compression: compression}
+	shorts := func(tag uint16, vals ...uint16) tiffEntry {
+		value := make([]byte, 2*len(vals))
+		for i, v := range vals {
+			order.PutUint16(value[2*i:], v)
+		}
+		return tiffEntry{tag: tag, typ: 3, count: uint32(len(vals)), value: value}
+	}
+	src.entries = []tiffEntry{
+		shorts(tiffImageWidth, 64),
+		shorts(tiffImageLength, 48),
+		shorts(tiffBitsPerSample, 16, 16, 16),
+		shorts(tiffPhotometric, 2),
+		shorts(tiffSamplesPerPixel, 3),
+		{tag: 34675, typ: 7, count: 9, value: []byte("icc bytes")},
+		{tag: tiffExifIFD, typ: 4, count: 1, value: make([]byte, 4), sub: []tiffEntry{
+			{tag: 36867, typ: 2, count: 20, value: []byte("2026:10:16 12:00:00\x00")},
+		}},
+	}
+	buf := new(bytes.Buffer)
+	if err := src.write(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestB2BTIFF embeds into 16-bit TIFFs and checks only the 16-bit LSBs
+// change and the ICC profile and EXIF survive
+func TestB2BTIFF(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	for _, tc := range []struct {
+		name        string
+		order       binary.ByteOrder
+		compression uint32
+	}{
+		{"big endian", binary.BigEndian, tiffCompressionNone},
+		{"little endian deflate", binary.LittleEndian, tiffCompressionDeflate},
+	} {
+		cover := testTIFF(t, tc.order, tc.compression)
+		out := new(bytes.Buffer)
+		if err := EmbedCarrier(bytes.NewReader(cover), out, []byte(secretStringIn), WithPassphrase("hunter2")); err != nil {
+			t.Fatal(tc.name, err)
+		}
+		payloadOut, err := ExtractTIFF(bytes.NewReader(out.Bytes()), WithPassphrase("hunter2"))
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		if string(payloadOut) != secretStringIn {
+			t.Error(tc.name, "Payloads Do Not Match!")
+		}
+
+		before, err := readTIFF(bytes.NewReader(cover))
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		after, err := readTIFF(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		if after.bits != 16 || after.spp != 3 || after.order != tc.order || after.compression != tc.compression {
+			t.Errorf("%s: layout changed: %d bits, %d samples, compression %d", tc.name, after.bits, after.spp, after.compression)
+		}
+		if delta, err := MaxChannelDelta(before.img, after.img); err != nil || delta != 1 {
+			t.Errorf("%s: expected a 16-bit delta of 1, got %d (%v)", tc.name, delta, err)
+		}
+
+		var icc, exif bool
+		for _, e := range after.entries {
+			switch e.tag {
+			case 34675:
+				icc = string(e.value) == "icc bytes"
+			case tiffExifIFD:
+				exif = len(e.sub) == 1 && string(e.sub[0].value) == "2026:10:16 12:00:00\x00"
+			}
+		}
+		if !icc || !exif {
+			t.Errorf("%s: metadata lost, ICC kept: %t, EXIF kept: %t", tc.name, icc, exif)
+		}
+	}
+}
+
+// TestTIFFUnsupported verifies layouts that can't be embedded into are
+// rejected
+func TestTIFFUnsupported(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover := testTIFF(t, binary.BigEndian, tiffCompressionNone)
+	// Claim LZW compression
+	lzw := bytes.Replace(cover, []byte{1, 3, 0, 3, 0, 0, 0, 1, 0, 1}, []byte{1, 3, 0, 3, 0, 0, 0, 1, 0, 5}, 1)
+	for _, doc := range [][]byte{[]byte("MM\x00*"), lzw} {
+		_, err := ExtractTIFF(bytes.NewReader(doc))
+		if err != errBadTIFF {
+			t.Error("Correct Error not thrown, expected: '" + errString(errBadTIFF) + "' got: '" + errString(err) + "'")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+)
+
+// minTileSize is the smallest tile WithTiles accepts
+const minTileSize = 8
+
+// tileSync marks the start of every tile, so the tile grid can be found
+// again after cropping
+var tileSync = [4]byte{0x7e, 'T', 'I', 'L'}
+
+var (
+	errTileSize         = errors.New("tile size must be at least 8 pixels")
+	errTilesUnsupported = errors.New("tiles can't be combined with adaptive, redundant, matrix, salted, visible checksum, codec, scan order or channel order embedding")
+)
+
+// WithTiles embeds a complete copy of the payload in every size by size
+// pixel tile of the image, each starting with a sync marker. Extracting
+// WithTiles of the same size searches for the tile grid, so the payload is
+// still found after the image is cropped, as long as one complete tile
+// survives. Every tile must hold the whole envelope, so payloads are small.
+func WithTiles(size int) Option {
+	return func(o *options) {
+		o.tileSize = size
+	}
+}
+
+// checkTiles rejects options tiled embedding doesn't support
+func (o *options) checkTiles() error {
+	if o.tileSize < minTileSize {
+		return errTileSize
+	}
+	if o.adaptive || o.redundancy > 1 || o.matrix != 0 || o.coverSalt || o.visibleChecksum ||
+		o.codec != nil || o.scanOrder != ScanColumnMajor || o.channelOrder != "" {
+		return errTilesUnsupported
+	}
+	return nil
+}
+
+// tileAt returns the placement of the tile with its top left corner at pt
+func (o *options) tileAt(img image.Image, pt image.Point) placement {
+	bounds := image.Rectangle{Min: pt, Max: pt.Add(image.Pt(o.tileSize, o.tileSize))}
+	return o.orderBits(sequentialPlacement{bounds: bounds, channels: imageChannels(img)})
+}
+
+// tileOrigins returns the top left corners of the complete tiles of bounds
+// when the grid starts offset from its corner
+func tileOrigins(bounds image.Rectangle, size int, offset image.Point) (origins []image.Point) {
+	for y := bounds.Min.Y + offset.Y; y+size <= bounds.Max.Y; y += size {
+		for x := bounds.Min.X + offset.X; x+size <= bounds.Max.X; x += size {
+			origins = append(origins, image.Pt(x, y))
+		}
+	}
+	return origins
+}
+
+// writeTiles frames the payload read from r once and writes it, after the
+// sync marker, into every complete tile of the manipulated image
+func (s *StegImage) writeTiles(r io.Reader, flags uint16, o *options) error {
+	if err := o.checkTiles(); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	framed, err := marshalEnvelope(r, flags, o, s.logger())
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	origins := tileOrigins(s.newImg.Bounds(), o.tileSize, image.Point{})
+	if len(origins) == 0 {
+		s.logger().Error(ErrInsufficientCapacity)
+		return ErrInsufficientCapacity
+	}
+	var meter *distortionMeter
+	if o.distortionBudget > 0 {
+		meter = &distortionMeter{budget: o.distortionBudget}
+	}
+	tile := append(tileSync[:], framed...)
+	for _, pt := range origins {
+		place := o.tileAt(s.newImg, pt)
+		if _, err = (&lsbWriter{img: s.newImg, place: place, meter: meter}).Write(tile); err != nil {
+			s.logger().Error(err)
+			return err
+		}
+	}
+	o.checkCapacity(len(tile), o.tileAt(s.newImg, origins[0]), s.logger())
+	return nil
+}
+
+// tilePlacement searches the loaded image for a tile holding a complete
+// envelope, trying every offset of the tile grid, and returns where the
+// envelope starts in it
+func (s *StegImage) tilePlacement(o *options) (placement, error) {
+	if err := o.checkTiles(); err != nil {
+		return nil, err
+	}
+	bounds := s.imgLoaded.Bounds()
+	for dy := 0; dy < o.tileSize; dy++ {
+		for dx := 0; dx < o.tileSize; dx++ {
+			for _, pt := range tileOrigins(bounds, o.tileSize, image.Pt(dx, dy)) {
+				place := o.tileAt(s.imgLoaded, pt)
+				sync, err := s.readBytes(place, 0, len(tileSync))
+				if err != nil || !bytes.Equal(sync, tileSync[:]) {
+					continue
+				}
+				shifted := shiftedPlacement{placement: place, shift: len(tileSync) * 8}
+				if _, err = s.readEnvelope(o, shifted); err == nil {
+					return shifted, nil
+				}
+			}
+		}
+	}
+	return nil, ErrNoPayload
+}
@@ -0,0 +1,61 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestTiles verifies a tiled payload is found in a crop holding one
+// complete tile, and not in one too small to
+func TestTiles(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("tiled secret")
+	opts := []Option{WithTiles(48), WithPassphrase("hunter2")}
+	if err := stegImg.EmbedBytes(secret, opts...); err != nil {
+		t.Fatal(err)
+	}
+	marked := reloadImage(t, &stegImg)
+	got, err := marked.ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Extracted %q, expected %q", got, secret)
+	}
+
+	crop := func(r image.Rectangle) *StegImage {
+		dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(dst, dst.Bounds(), marked.imgLoaded, r.Min, draw.Src)
+		return NewStegImageFromImage(dst)
+	}
+	got, err = crop(image.Rect(17, 29, 97, 119)).ExtractBytes(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Extracted %q from crop, expected %q", got, secret)
+	}
+	if _, err = crop(image.Rect(17, 29, 90, 119)).ExtractBytes(opts...); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrNoPayload) + "' got: '" + errString(err) + "'")
+	}
+
+	if err = stegImg.EmbedBytes(secret, WithTiles(4)); err != errTileSize {
+		t.Error("Correct Error not thrown, expected: '" + errString(errTileSize) + "' got: '" + errString(err) + "'")
+	}
+	if err = stegImg.EmbedBytes(secret, WithTiles(48), WithAdaptiveDepth()); err != errTilesUnsupported {
+		t.Error("Correct Error not thrown, expected: '" + errString(errTilesUnsupported) + "' got: '" + errString(err) + "'")
+	}
+	if err = stegImg.EmbedBytes(make([]byte, 48*48*3/8), WithTiles(48)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,46 @@
+package libsteg
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies libsteg's spans
+const tracerName = "github.com/karlwebster/libsteg"
+
+// SetTraceContext makes the spans of this StegImage's stages children of
+// the span in ctx. Spans go to the global OpenTelemetry tracer provider,
+// so they cost next to nothing unless one is configured. The stages are
+// "libsteg.decode" when an image is loaded, "libsteg.embed" and
+// "libsteg.extract" for payloads, and "libsteg.encode" when the new image
+// is written.
+func (s *StegImage) SetTraceContext(ctx context.Context) {
+	s.traceCtx = ctx
+}
+
+// startSpan starts the span for a stage of s
+func (s *StegImage) startSpan(stage string) trace.Span {
+	_, span := startSpan(s.traceCtx, stage)
+	return span
+}
+
+// startSpan starts a span for stage, a child of any span in ctx
+func startSpan(ctx context.Context, stage string) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(tracerName).Start(ctx, "libsteg."+stage)
+}
+
+// endSpan ends span, marking it failed if *errp is set. It's meant to be
+// deferred.
+func endSpan(span trace.Span, errp *error) {
+	if *errp != nil {
+		span.RecordError(*errp)
+		span.SetStatus(codes.Error, (*errp).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,108 @@
+package libsteg
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	logging "github.com/op/go-logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan notes its name, parent and outcome
+type recordedSpan struct {
+	trace.Span
+	name   string
+	parent trace.Span
+	failed bool
+	ended  bool
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	s.failed = code == codes.Error
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.ended = true
+}
+
+// recordingTracer records every span started
+type recordingTracer struct {
+	trace.Tracer
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	_, base := r.Tracer.Start(ctx, name, opts...)
+	span := &recordedSpan{Span: base, name: name, parent: trace.SpanFromContext(ctx)}
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// recordingProvider hands out its recordingTracer
+type recordingProvider struct {
+	trace.TracerProvider
+	tracer *recordingTracer
+}
+
+func (p recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return p.tracer
+}
+
+// TestTracing verifies the base64 calls trace each stage under their own
+// span. It isn't parallel as the tracer provider is global.
+func TestTracing(t *testing.T) {
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	// Recording spans atop the global tracer would recurse, as it
+	// delegates to whatever provider is set
+	noopProvider := noop.NewTracerProvider()
+	p := &recordingTracer{Tracer: noopProvider.Tracer(tracerName)}
+	otel.SetTracerProvider(recordingProvider{TracerProvider: noopProvider, tracer: p})
+	defer otel.SetTracerProvider(noopProvider)
+
+	out, err := Base64Embed(CleanB64Image, secretStringIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Base64Extract(out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Base64Extract(CleanB64Image); err == nil {
+		t.Fatal("Extracted a secret from a clean image")
+	}
+
+	var names []string
+	for _, span := range p.spans {
+		names = append(names, span.name)
+		if !span.ended {
+			t.Errorf("Span %s not ended", span.name)
+		}
+	}
+	want := []string{
+		"libsteg.Base64Embed", "libsteg.decode", "libsteg.embed", "libsteg.encode",
+		"libsteg.Base64Extract", "libsteg.decode", "libsteg.extract",
+		"libsteg.Base64Extract", "libsteg.decode", "libsteg.extract",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("Spans %v, expected %v", names, want)
+	}
+	for i, span := range p.spans {
+		if span.name != want[i] {
+			t.Errorf("Span %d is %s, expected %s", i, span.name, want[i])
+		}
+		isRoot := span.name == "libsteg.Base64Embed" || span.name == "libsteg.Base64Extract"
+		if _, childOfRecorded := span.parent.(*recordedSpan); childOfRecorded == isRoot {
+			t.Errorf("Span %d, %s, has the wrong parent", i, span.name)
+		}
+	}
+	if last := p.spans[len(p.spans)-1]; !last.failed || !p.spans[len(p.spans)-3].failed {
+		t.Error("Failed extract not marked as an error")
+	}
+}
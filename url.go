@@ -0,0 +1,116 @@
+package libsteg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultURLMaxBytes is the largest image LoadImageFromURL fetches unless
+// told otherwise
+const defaultURLMaxBytes = 32 << 20
+
+var (
+	// ErrURLTooLarge is returned when the image at a URL exceeds the size
+	// limit
+	ErrURLTooLarge = errors.New("image at URL exceeds the size limit")
+	// ErrURLContentType is returned when a URL serves something other than
+	// an image
+	ErrURLContentType = errors.New("URL didn't serve an image")
+	errURLScheme      = errors.New("only http and https URLs can be loaded")
+)
+
+// URLOption configures LoadImageFromURL
+type URLOption func(*urlOptions)
+
+type urlOptions struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// WithURLClient fetches with client rather than http.DefaultClient, e.g.
+// to set timeouts or credentials for object storage
+func WithURLClient(client *http.Client) URLOption {
+	return func(o *urlOptions) {
+		o.client = client
+	}
+}
+
+// WithURLMaxBytes sets the largest image fetched, 32 MiB by default
+func WithURLMaxBytes(n int64) URLOption {
+	return func(o *urlOptions) {
+		if n > 0 {
+			o.maxBytes = n
+		}
+	}
+}
+
+// LoadImageFromURL fetches the image at an http or https URL into the
+// StegImage structure. The response must be an image/* or generic binary
+// content type and no larger than the size limit, checked against
+// Content-Length up front and enforced while reading.
+func (s *StegImage) LoadImageFromURL(ctx context.Context, rawURL string, opts ...URLOption) error {
+	o := &urlOptions{client: http.DefaultClient, maxBytes: defaultURLMaxBytes}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		s.logger().Error(errURLScheme)
+		return errURLScheme
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("fetching image: %s", resp.Status)
+		s.logger().Error(err)
+		return err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+	case mediaType == "application/octet-stream", mediaType == "binary/octet-stream":
+		// Object stores often serve uploads without a more specific type
+	default:
+		s.logger().Error(ErrURLContentType)
+		return ErrURLContentType
+	}
+	if resp.ContentLength > o.maxBytes {
+		s.logger().Error(ErrURLTooLarge)
+		return ErrURLTooLarge
+	}
+
+	// Read one byte past the limit to tell a full image from a cut off one
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, o.maxBytes+1))
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if int64(len(body)) > o.maxBytes {
+		s.logger().Error(ErrURLTooLarge)
+		return ErrURLTooLarge
+	}
+	return s.loadImage(bytes.NewReader(body))
+}
@@ -0,0 +1,80 @@
+package libsteg
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestLoadImageFromURL serves a stego image and checks it loads, and that
+// the size and content type checks hold
+func TestLoadImageFromURL(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	cover, err := ioutil.ReadFile(tinyImageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cleanImg StegImage
+	if err = cleanImg.LoadImageFromFile(tinyImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err = cleanImg.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	stego := pngBytes(t, &cleanImg)
+
+	mux := http.NewServeMux()
+	serve := func(path string, contentType string, body []byte) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write(body)
+		})
+	}
+	serve("/stego.png", "image/png", stego)
+	serve("/upload", "application/octet-stream", cover)
+	serve("/page", "text/html; charset=utf-8", []byte("<html></html>"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stegoImg StegImage
+	if err = stegoImg.LoadImageFromURL(context.Background(), server.URL+"/stego.png"); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := stegoImg.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Payloads Do Not Match!")
+	}
+
+	var s StegImage
+	if err = s.LoadImageFromURL(context.Background(), server.URL+"/upload"); err != nil {
+		t.Error(err)
+	}
+	for _, tc := range []struct {
+		url  string
+		opts []URLOption
+		err  error
+	}{
+		{server.URL + "/page", nil, ErrURLContentType},
+		{server.URL + "/upload", []URLOption{WithURLMaxBytes(int64(len(cover)) - 1)}, ErrURLTooLarge},
+		{"file:///etc/passwd", nil, errURLScheme},
+	} {
+		if err = s.LoadImageFromURL(context.Background(), tc.url, tc.opts...); err != tc.err {
+			t.Error("Correct Error not thrown, expected: '" + errString(tc.err) + "' got: '" + errString(err) + "'")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = s.LoadImageFromURL(ctx, server.URL+"/stego.png"); err == nil {
+		t.Error("Expected a canceled context to fail the fetch")
+	}
+}
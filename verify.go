@@ -0,0 +1,73 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+// ErrVerifyMismatch is returned WithVerify when the payload read back from
+// the freshly written image differs from the one embedded
+var ErrVerifyMismatch = errors.New("payload read back after embedding doesn't match")
+
+// WithVerify makes embedding check its own work: the stego image is
+// encoded as it would be written, decoded again and the payload extracted
+// with the same options and compared with the input. Extraction errors
+// are returned as they are, and a payload that reads back differently
+// fails with ErrVerifyMismatch, so pipelines catch encode and decode
+// asymmetries before the image leaves them. The payload is held in memory
+// to compare. Payloads sealed WithRecipients can only be opened by the
+// recipients, so for those only the envelope's checks are verified.
+func WithVerify() Option {
+	return func(o *options) {
+		o.verify = true
+	}
+}
+
+// embedVerified embeds the payload read from r, then reads it back from
+// the encoded image
+func (s *StegImage) embedVerified(r io.Reader, flags uint16, o *options) error {
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	inner := *o
+	inner.verify = false
+	if err = s.embedStream(bytes.NewReader(payload), flags, &inner); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = s.encodeNewImage(buf, png.DefaultCompression); err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	var written StegImage
+	if err = written.LoadImage(buf); err != nil {
+		return err
+	}
+	var got []byte
+	if inner.codec != nil {
+		got, err = written.readCodec(&inner)
+	} else {
+		var e *envelope
+		if e, err = written.extractEnvelope(&inner); err == nil {
+			if e.flags&flagRecipients != 0 {
+				return nil
+			}
+			got, _, err = e.open(&inner)
+		}
+	}
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if !bytes.Equal(got, payload) {
+		s.logger().Error(ErrVerifyMismatch)
+		return ErrVerifyMismatch
+	}
+	return nil
+}
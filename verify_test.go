@@ -0,0 +1,50 @@
+package libsteg
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// truncatingCodec frames payloads with SteganoCodec but drops the last
+// byte reading them back, an asymmetry WithVerify should catch
+type truncatingCodec struct{ SteganoCodec }
+
+func (c truncatingCodec) Deframe(r io.Reader) ([]byte, error) {
+	payload, err := c.SteganoCodec.Deframe(r)
+	if len(payload) > 0 {
+		payload = payload[:len(payload)-1]
+	}
+	return payload, err
+}
+
+// TestVerify checks WithVerify passes faithful embeds and catches ones
+// that read back differently
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	recipient, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	for name, opts := range map[string][]Option{
+		"plain":      {WithVerify()},
+		"encrypted":  {WithVerify(), WithPassphrase("hunter2"), WithCompression(CompressionGzip)},
+		"recipients": {WithVerify(), WithRecipients(recipient.PublicKey())},
+		"codec":      {WithVerify(), WithEnvelopeCodec(SteganoCodec{})},
+	} {
+		if err := stegImg.EmbedBytes([]byte(secretStringIn), opts...); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	err := stegImg.EmbedBytes([]byte(secretStringIn), WithVerify(), WithEnvelopeCodec(truncatingCodec{}))
+	if err != ErrVerifyMismatch {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrVerifyMismatch) + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,183 @@
+package libsteg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// y4mSignature starts every YUV4MPEG2 stream
+const y4mSignature = "YUV4MPEG2 "
+
+var errBadY4M = errors.New("not a supported 8-bit Y4M stream")
+
+// EmbedSequence embeds payload into a sequence of frames, such as the
+// decoded frames of a raw video, spreading it over as many frames as it
+// needs. Each frame holds its chunk with a chunk index, so frames can be
+// extracted in any order but none may be dropped. The frames themselves
+// are left unchanged.
+func EmbedSequence(frames []image.Image, payload []byte, opts ...Option) ([]draw.Image, error) {
+	out, err := embedFrames(frames, bytes.NewReader(payload), newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExtractSequence retrieves a payload embedded with EmbedSequence
+func ExtractSequence(frames []image.Image, opts ...Option) ([]byte, error) {
+	payload, err := extractFrames(frames, newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
+
+// EmbedY4M embeds payload into the uncompressed YUV4MPEG2 video read from
+// r and writes the result to w. The payload is spread over the frames as
+// with EmbedSequence, in the luma plane only, since chroma is usually
+// subsampled. Headers and chroma are copied through untouched. The whole
+// video is held in memory.
+func EmbedY4M(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	video, err := readY4M(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	out, err := embedFrames(video.lumaFrames(), bytes.NewReader(payload), newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	for i, frame := range video.frames {
+		copy(frame.data, out[i].(*image.Gray).Pix)
+	}
+	if err = video.write(w); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ExtractY4M retrieves a payload embedded with EmbedY4M from the video read
+// from r
+func ExtractY4M(r io.Reader, opts ...Option) ([]byte, error) {
+	video, err := readY4M(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	payload, err := extractFrames(video.lumaFrames(), newOptions(opts))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return payload, nil
+}
+
+// y4mVideo is a parsed YUV4MPEG2 stream
+type y4mVideo struct {
+	header        string // Stream header line, without the newline
+	width, height int
+	frames        []y4mFrame
+}
+
+// y4mFrame is one frame, data holding its planes with luma first
+type y4mFrame struct {
+	header string // FRAME line, without the newline
+	data   []byte
+}
+
+// readY4M parses an 8-bit YUV4MPEG2 stream
+func readY4M(r io.Reader) (*y4mVideo, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	end := bytes.IndexByte(doc, '\n')
+	if !bytes.HasPrefix(doc, []byte(y4mSignature)) || end < 0 {
+		return nil, errBadY4M
+	}
+
+	video := &y4mVideo{header: string(doc[:end])}
+	colour := "420jpeg"
+	for _, param := range strings.Fields(video.header)[1:] {
+		switch param[0] {
+		case 'W':
+			video.width, err = strconv.Atoi(param[1:])
+		case 'H':
+			video.height, err = strconv.Atoi(param[1:])
+		case 'C':
+			colour = param[1:]
+		}
+		if err != nil {
+			return nil, errBadY4M
+		}
+	}
+	if video.width <= 0 || video.height <= 0 {
+		return nil, errBadY4M
+	}
+
+	luma := video.width * video.height
+	halfW, halfH := (video.width+1)/2, (video.height+1)/2
+	var frameLen int
+	switch colour {
+	case "420jpeg", "420paldv", "420mpeg2", "420":
+		frameLen = luma + 2*halfW*halfH
+	case "422":
+		frameLen = luma + 2*halfW*video.height
+	case "444":
+		frameLen = 3 * luma
+	case "mono":
+		frameLen = luma
+	default:
+		// Higher bit depths and alpha aren't supported
+		return nil, errBadY4M
+	}
+
+	for rest := doc[end+1:]; len(rest) > 0; {
+		end = bytes.IndexByte(rest, '\n')
+		if !bytes.HasPrefix(rest, []byte("FRAME")) || end < 0 || len(rest) < end+1+frameLen {
+			return nil, errBadY4M
+		}
+		video.frames = append(video.frames, y4mFrame{
+			header: string(rest[:end]),
+			data:   rest[end+1 : end+1+frameLen],
+		})
+		rest = rest[end+1+frameLen:]
+	}
+	return video, nil
+}
+
+// lumaFrames returns the luma plane of each frame as a grayscale image
+// sharing the frame's buffer
+func (v *y4mVideo) lumaFrames() []image.Image {
+	frames := make([]image.Image, len(v.frames))
+	for i, frame := range v.frames {
+		frames[i] = &image.Gray{
+			Pix:    frame.data[:v.width*v.height],
+			Stride: v.width,
+			Rect:   image.Rect(0, 0, v.width, v.height),
+		}
+	}
+	return frames
+}
+
+// write writes the video back out as a YUV4MPEG2 stream
+func (v *y4mVideo) write(w io.Writer) error {
+	buf := new(bytes.Buffer)
+	buf.WriteString(v.header + "\n")
+	for _, frame := range v.frames {
+		buf.WriteString(frame.header + "\n")
+		buf.Write(frame.data)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
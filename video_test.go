@@ -0,0 +1,106 @@
+package libsteg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/rand"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// testY4M builds a 4:2:0 Y4M stream of noise frames
+func testY4M(width, height, frames int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "YUV4MPEG2 W%d H%d F25:1 Ip A1:1 C420jpeg\n", width, height)
+	frame := make([]byte, width*height+2*((width+1)/2)*((height+1)/2))
+	for i := 0; i < frames; i++ {
+		rng.Read(frame)
+		buf.WriteString("FRAME\n")
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+// TestB2BY4M verifies a payload spread over several frames round trips
+// with only the luma LSBs changed
+func TestB2BY4M(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	const width, height = 33, 21
+	cover := testY4M(width, height, 4)
+	payload := strings.Repeat(secretStringIn, 50)
+	out := new(bytes.Buffer)
+	if err := EmbedY4M(bytes.NewReader(cover), out, []byte(payload), WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := ExtractY4M(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != payload {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	before, _ := readY4M(bytes.NewReader(cover))
+	after, err := readY4M(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.header != before.header || len(after.frames) != len(before.frames) {
+		t.Fatal("Stream structure changed")
+	}
+	touched := 0
+	for i := range before.frames {
+		luma := width * height
+		if !bytes.Equal(before.frames[i].data[luma:], after.frames[i].data[luma:]) {
+			t.Errorf("Chroma of frame %d changed", i)
+		}
+		if !bytes.Equal(before.frames[i].data[:luma], after.frames[i].data[:luma]) {
+			touched++
+		}
+		for j := 0; j < luma; j++ {
+			if d := int(before.frames[i].data[j]) - int(after.frames[i].data[j]); d > 1 || d < -1 {
+				t.Fatalf("Luma of frame %d changed by %d", i, d)
+			}
+		}
+	}
+	if touched < 2 {
+		t.Errorf("Payload not spread over frames, %d touched", touched)
+	}
+
+	_, err = ExtractY4M(strings.NewReader("YUV4MPEG2 W2 H2 C420p10\n"))
+	if err != errBadY4M {
+		t.Error("Correct Error not thrown, expected: '" + errBadY4M.Error() + "' got: '" + errString(err) + "'")
+	}
+}
+
+// TestB2BSequence verifies frames can be extracted in any order
+func TestB2BSequence(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var cleanImg StegImage
+	if err := cleanImg.LoadImageFromB64(CleanB64Image); err != nil {
+		t.Fatal(err)
+	}
+	frames := []image.Image{cleanImg.imgLoaded, cleanImg.imgLoaded, cleanImg.imgLoaded}
+	capacity := newSequentialPlacement(cleanImg.imgLoaded).capacity() / 8
+	payload := bytes.Repeat([]byte(secretStringIn), capacity/4)
+	out, err := EmbedSequence(frames, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shuffled := []image.Image{out[2], out[0], out[1]}
+	payloadOut, err := ExtractSequence(shuffled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payloadOut, payload) {
+		t.Error("Secrets Do Not Match!")
+	}
+}
@@ -0,0 +1,139 @@
+// Package videosig is an experimental prototype for covert signalling over
+// video calls, for research use. Short payloads are embedded into outgoing
+// raw RGBA frames, as captured before encoding or as carried by
+// uncompressed RTP video, and recovered from incoming ones.
+//
+// Each message is embedded whole into one frame with libsteg's frame
+// sequence carrier, and repeated over several consecutive frames so it
+// survives dropped frames. Messages carry a sequence number so receivers
+// report each once. Lossy video codecs destroy LSBs, so messages only
+// survive paths that deliver frames losslessly.
+package videosig
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"sync"
+
+	"github.com/karlwebster/libsteg"
+)
+
+const (
+	// MaxPayload is the largest message sent, keeping the per frame cost low
+	MaxPayload = 1024
+	// defaultRepeat is how many frames carry each message
+	defaultRepeat = 3
+)
+
+var (
+	// ErrPayloadTooLarge is returned when sending more than MaxPayload bytes
+	ErrPayloadTooLarge = errors.New("signalling payload too large")
+	errShortMessage    = errors.New("signalling message too short")
+)
+
+// Sender embeds queued messages into outgoing frames
+type Sender struct {
+	mu      sync.Mutex
+	opts    []libsteg.Option
+	repeat  int
+	queue   [][]byte
+	seq     uint16
+	current []byte // Message being sent, with its sequence number
+	left    int    // Frames still to carry current
+}
+
+// NewSender returns a Sender embedding with opts, e.g. a passphrase shared
+// with the receiver. Each message is repeated over repeat frames, or a
+// default of 3 if repeat isn't positive.
+func NewSender(repeat int, opts ...libsteg.Option) *Sender {
+	if repeat <= 0 {
+		repeat = defaultRepeat
+	}
+	return &Sender{opts: opts, repeat: repeat}
+}
+
+// Send queues payload for the next free frames
+func (s *Sender) Send(payload []byte) error {
+	if len(payload) > MaxPayload {
+		return ErrPayloadTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, append([]byte(nil), payload...))
+	return nil
+}
+
+// Pending returns the number of messages not yet fully sent
+func (s *Sender) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.queue)
+	if s.left > 0 {
+		n++
+	}
+	return n
+}
+
+// Process embeds the message being sent into frame, in place. Frames are
+// left untouched when nothing is queued.
+func (s *Sender) Process(frame *image.RGBA) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.left == 0 {
+		if len(s.queue) == 0 {
+			return nil
+		}
+		s.seq++
+		s.current = make([]byte, 2, 2+len(s.queue[0]))
+		binary.BigEndian.PutUint16(s.current, s.seq)
+		s.current = append(s.current, s.queue[0]...)
+		s.queue = s.queue[1:]
+		s.left = s.repeat
+	}
+
+	out, err := libsteg.EmbedSequence([]image.Image{frame}, s.current, s.opts...)
+	if err != nil {
+		return err
+	}
+	copy(frame.Pix, out[0].(*image.RGBA).Pix)
+	s.left--
+	return nil
+}
+
+// Receiver recovers messages from incoming frames
+type Receiver struct {
+	mu   sync.Mutex
+	opts []libsteg.Option
+	seen bool
+	last uint16 // Sequence number of the last message reported
+}
+
+// NewReceiver returns a Receiver extracting with opts, matching the
+// sender's
+func NewReceiver(opts ...libsteg.Option) *Receiver {
+	return &Receiver{opts: opts}
+}
+
+// Process extracts the message carried by frame, ok is false if the frame
+// carries none or one already reported
+func (r *Receiver) Process(frame image.Image) (payload []byte, ok bool, err error) {
+	message, err := libsteg.ExtractSequence([]image.Image{frame}, r.opts...)
+	if err == libsteg.ErrNoPayload {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if len(message) < 2 {
+		return nil, false, errShortMessage
+	}
+
+	seq := binary.BigEndian.Uint16(message)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen && seq == r.last {
+		return nil, false, nil
+	}
+	r.seen, r.last = true, seq
+	return message[2:], true, nil
+}
@@ -0,0 +1,71 @@
+package videosig
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/karlwebster/libsteg"
+)
+
+// testFrame returns a noisy raw RGBA frame
+func testFrame(rng *rand.Rand) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	rng.Read(frame.Pix)
+	return frame
+}
+
+// TestSignalling sends messages over a stream of frames with some dropped
+// and checks each arrives once, in order
+func TestSignalling(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	sender := NewSender(3, libsteg.WithPassphrase("hunter2"))
+	receiver := NewReceiver(libsteg.WithPassphrase("hunter2"))
+	messages := []string{"mute", "unmute", "hang up"}
+	for _, m := range messages {
+		if err := sender.Send([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var received []string
+	for i := 0; i < 12; i++ {
+		frame := testFrame(rng)
+		if err := sender.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+		// Drop the first frame carrying each message
+		if i%3 == 0 && i < 9 {
+			continue
+		}
+		payload, ok, err := receiver.Process(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			received = append(received, string(payload))
+		}
+	}
+	if len(received) != len(messages) {
+		t.Fatalf("Expected %d messages, got %q", len(messages), received)
+	}
+	for i := range messages {
+		if received[i] != messages[i] {
+			t.Errorf("Message %d: expected %q, got %q", i, messages[i], received[i])
+		}
+	}
+	if sender.Pending() != 0 {
+		t.Errorf("Expected every message sent, %d pending", sender.Pending())
+	}
+}
+
+// TestSendTooLarge verifies oversized messages are refused
+func TestSendTooLarge(t *testing.T) {
+	t.Parallel()
+
+	if err := NewSender(0).Send(make([]byte, MaxPayload+1)); err != ErrPayloadTooLarge {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package libsteg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+// visibleDigestLen is the length of the visible pixel digest in the header
+const visibleDigestLen = sha256.Size
+
+var (
+	// ErrVisibleEdited is returned by VerifyVisible when the visible image
+	// has changed since the payload was embedded
+	ErrVisibleEdited     = errors.New("visible image edited since embedding")
+	errNoVisibleChecksum = errors.New("payload has no visible pixel checksum")
+)
+
+// WithVisibleChecksum stores a SHA-256 digest of the cover's size and its
+// pixels above the bit planes embedding changes in the envelope header, so
+// VerifyVisible can tell whether the visible image was edited, e.g.
+// recoloured or retouched, after embedding. This is separate from payload
+// integrity: an edit that spares the LSBs leaves the payload intact but is
+// still detected. With WithHMAC or WithSigningKey the digest is
+// authenticated too.
+func WithVisibleChecksum() Option {
+	return func(o *options) {
+		o.visibleChecksum = true
+	}
+}
+
+// VerifyVisible checks the loaded image against the visible pixel checksum
+// stored with its payload, returning ErrVisibleEdited if they differ
+func (s *StegImage) VerifyVisible(opts ...Option) error {
+	o := newOptions(opts)
+	place, err := s.extractPlacement(o)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	e, _, err := s.readEnvelopeHeader(place, 0)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	if e.flags&flagVisible == 0 {
+		s.logger().Error(errNoVisibleChecksum)
+		return errNoVisibleChecksum
+	}
+	if !bytes.Equal(e.visible, visibleDigest(s.imgLoaded)) {
+		s.logger().Warning(ErrVisibleEdited)
+		return ErrVisibleEdited
+	}
+	return nil
+}
+
+// visibleDigest hashes the size of img and its pixels with the bit planes
+// embedding may change cleared
+func visibleDigest(img image.Image) []byte {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, [2]uint32{uint32(img.Bounds().Dx()), uint32(img.Bounds().Dy())})
+	writeCoverHash(h, img)
+	return h.Sum(nil)
+}
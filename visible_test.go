@@ -0,0 +1,62 @@
+package libsteg
+
+import (
+	"bytes"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestVisibleChecksum verifies an untouched stego image passes, one with
+// a recoloured pixel is caught, and payloads without a digest are refused
+func TestVisibleChecksum(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := stegImg.EmbedBytes([]byte(secretStringIn), WithVisibleChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := reloadImage(t, &stegImg)
+	if err := reloaded.VerifyVisible(); err != nil {
+		t.Error(err)
+	}
+	secret, err := reloaded.ExtractBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, []byte(secretStringIn)) {
+		t.Errorf("Extracted %q, expected %q", secret, secretStringIn)
+	}
+
+	// Flip a high bit, leaving the LSBs and so the payload alone
+	img, ok := reloaded.imgLoaded.(draw.Image)
+	if !ok {
+		t.Fatalf("Reloaded image %T isn't drawable", reloaded.imgLoaded)
+	}
+	bounds := img.Bounds()
+	r, g, b, a := img.At(bounds.Max.X-1, bounds.Max.Y-1).RGBA()
+	img.Set(bounds.Max.X-1, bounds.Max.Y-1, color.RGBA64{uint16(r ^ 0x8000), uint16(g), uint16(b), uint16(a)})
+	if err := reloaded.VerifyVisible(); err != ErrVisibleEdited {
+		t.Error("Correct Error not thrown, expected: '" + ErrVisibleEdited.Error() + "' got: '" + errString(err) + "'")
+	}
+	if _, err := reloaded.ExtractBytes(); err != nil {
+		t.Error(err)
+	}
+
+	var plain StegImage
+	if err := plain.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.EmbedBytes([]byte(secretStringIn)); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadImage(t, &plain).VerifyVisible(); err != errNoVisibleChecksum {
+		t.Error("Correct Error not thrown, expected: '" + errNoVisibleChecksum.Error() + "' got: '" + errString(err) + "'")
+	}
+}
@@ -0,0 +1,117 @@
+package libsteg
+
+import (
+	"encoding/binary"
+	"image"
+	"math"
+)
+
+// watermarkIDLen is the length of the IDs WatermarkEmbed embeds
+const watermarkIDLen = 8
+
+// WatermarkMode names the robust embedding a watermark was read from
+type WatermarkMode uint8
+
+// Robust embeddings WatermarkEmbed layers
+const (
+	// WatermarkNone means no watermark was found
+	WatermarkNone WatermarkMode = iota
+	// WatermarkSpreadSpectrum survives noise and rescaling, see
+	// EmbedSpreadSpectrum
+	WatermarkSpreadSpectrum
+	// WatermarkDCT survives JPEG recompression, see EmbedDCTWatermark
+	WatermarkDCT
+)
+
+func (m WatermarkMode) String() string {
+	switch m {
+	case WatermarkSpreadSpectrum:
+		return "spread-spectrum"
+	case WatermarkDCT:
+		return "dct"
+	}
+	return "none"
+}
+
+// WatermarkResult reports what WatermarkVerify found
+type WatermarkResult struct {
+	Detected bool   // Whether an ID was read and passed its check
+	ID       uint64 // The ID read, if Detected
+	// Score is the fraction of the ID's bits read with confidence from the
+	// strongest watermark, near 1 for marked images and near 0 otherwise.
+	// It gives a sense of how damaged a watermark is, while Detected
+	// decides whether one is present.
+	Score float64
+	Mode  WatermarkMode // Where the ID was read from
+}
+
+// WatermarkEmbed marks the loaded image invisibly with id, for attributing
+// content rather than carrying data. The ID is embedded twice, spread over
+// the whole image and in its DCT coefficients, so WatermarkVerify still
+// finds it after the image is rescaled, recompressed as JPEG or lightly
+// edited. WithPassphrase keys the watermark and WithSpreadStrength tunes
+// the spread-spectrum half.
+func (s *StegImage) WatermarkEmbed(id uint64, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+	o := newOptions(opts)
+	var b [watermarkIDLen]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	dst, err := spreadEmbed(s.imgLoaded, b[:], o)
+	if err == nil {
+		dst, err = dctEmbed(dst, b[:], o)
+	}
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	s.newImg, s.robustImg = dst, dst
+	return nil
+}
+
+// WatermarkVerify looks for a watermark WatermarkEmbed added to img with
+// the same passphrase. An unmarked image isn't an error: the result says
+// whether an ID was detected.
+func WatermarkVerify(img image.Image, opts ...Option) (result WatermarkResult, err error) {
+	ob := observe(OpExtract, img)
+	defer ob.done(&err)
+	o := newOptions(opts)
+	spread, err := newSpreadPattern(o.passphrase, watermarkIDLen)
+	if err != nil {
+		log.Error(err)
+		return WatermarkResult{}, err
+	}
+	id, score := spread.detect(img)
+	result.consider(id, score, WatermarkSpreadSpectrum)
+	// Images too small for the DCT half were never fully marked
+	if dct, err := newDCTPattern(img.Bounds(), o.passphrase, watermarkIDLen); err == nil {
+		id, score = dct.detect(img)
+		result.consider(id, score, WatermarkDCT)
+	}
+	return result, nil
+}
+
+// consider keeps the reading from mode if it's better than those so far,
+// preferring readings that passed their check
+func (r *WatermarkResult) consider(id []byte, score float64, mode WatermarkMode) {
+	detected := id != nil
+	if r.Detected && !detected || r.Detected == detected && score <= r.Score {
+		return
+	}
+	*r = WatermarkResult{Detected: detected, Score: score}
+	if detected {
+		r.ID = binary.BigEndian.Uint64(id)
+		r.Mode = mode
+	}
+}
+
+// confidentBit reports whether a bit read by correlation, with energy the
+// sum of the squared samples correlated, is unlikely to be chance: more
+// than two standard deviations from zero if the samples were unmarked
+func confidentBit(corr float64, energy float64) bool {
+	return energy > 0 && math.Abs(corr) >= 2*math.Sqrt(energy)
+}
@@ -0,0 +1,74 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestWatermark verifies a watermark is detected after rescaling and JPEG
+// recompression, and isn't in unmarked images or with the wrong key
+func TestWatermark(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	const id = 0x0123456789abcdef
+	if err := stegImg.WatermarkEmbed(id, WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	marked := stegImg.newImg
+	bounds := marked.Bounds()
+
+	larger, err := resizeImage(marked, bounds.Dx()*3/2, bounds.Dy()*3/2, ResizeBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, marked, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, img := range map[string]image.Image{
+		"marked":       marked,
+		"larger":       larger,
+		"recompressed": recompressed,
+	} {
+		result, err := WatermarkVerify(img, WithPassphrase("hunter2"))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if !result.Detected || result.ID != id || result.Mode == WatermarkNone {
+			t.Errorf("%s: watermark not detected: %+v", name, result)
+		}
+		if result.Score < 0.4 {
+			t.Errorf("%s: score %.2f unexpectedly low", name, result.Score)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		img  image.Image
+		opts []Option
+	}{
+		"unmarked":  {stegImg.imgLoaded, []Option{WithPassphrase("hunter2")}},
+		"wrong key": {marked, []Option{WithPassphrase("wrong")}},
+	} {
+		result, err := WatermarkVerify(tc.img, tc.opts...)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if result.Detected || result.Mode != WatermarkNone || result.Score > 0.25 {
+			t.Errorf("%s: unexpected watermark: %+v", name, result)
+		}
+	}
+}
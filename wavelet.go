@@ -0,0 +1,213 @@
+package libsteg
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	// waveletStep is the quantizer step, in luma levels, of the diagonal
+	// detail coefficients holding bits. A coefficient can drift by up to a
+	// quarter of it before its bit flips.
+	waveletStep = 12
+	// waveletLenLen is the length of the payload length stored ahead of a
+	// wavelet payload
+	waveletLenLen = 2
+)
+
+// WaveletCapacity returns the largest payload in bytes EmbedWavelet can
+// hide in the loaded image
+func (s *StegImage) WaveletCapacity() (int, error) {
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return 0, errNoImageLoaded
+	}
+	return waveletCapacity(s.imgLoaded.Bounds()), nil
+}
+
+func waveletCapacity(bounds image.Rectangle) int {
+	n := (bounds.Dx()/2)*(bounds.Dy()/2)/8 - waveletLenLen - spreadSumLen
+	if n < 0 {
+		return 0
+	}
+	if n > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return n
+}
+
+// EmbedWavelet hides payload in the diagonal detail subband of a one level
+// Haar wavelet transform of the image's luma, one bit per 2x2 pixel block
+// in an order chosen by the passphrase. Each bit is stored by quantizing
+// its coefficient, so unlike LSB payloads it survives small amounts of
+// noise, such as dithering or a colour space round trip, while holding far
+// more than EmbedSpreadSpectrum. It doesn't survive JPEG, see
+// EmbedDCTWatermark. Only WithPassphrase applies and the payload isn't
+// encrypted.
+func (s *StegImage) EmbedWavelet(payload []byte, opts ...Option) (err error) {
+	ob := s.observe(OpEmbed)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return errNoImageLoaded
+	}
+	bounds := s.imgLoaded.Bounds()
+	if len(payload) > waveletCapacity(bounds) {
+		s.logger().Error(ErrInsufficientCapacity)
+		return ErrInsufficientCapacity
+	}
+	o := newOptions(opts)
+	pattern, err := newWaveletPattern(bounds, o.passphrase)
+	if err != nil {
+		s.logger().Error(err)
+		return err
+	}
+	frame := make([]byte, waveletLenLen, waveletLenLen+len(payload))
+	binary.BigEndian.PutUint16(frame, uint16(len(payload)))
+
+	dst := image.NewNRGBA(bounds)
+	draw.Draw(dst, bounds, s.imgLoaded, bounds.Min, draw.Src)
+	for i, one := range spreadFrame(append(frame, payload...)) {
+		block := pattern.block(i)
+		hh := waveletDetail(dst, block)
+		target := waveletQuantize(hh, one)
+		waveletShift(dst, block, target-hh)
+	}
+	s.newImg = dst
+	ob.bytes = int64(len(payload))
+	return nil
+}
+
+// ExtractWavelet retrieves a payload embedded with EmbedWavelet using the
+// same passphrase, returning ErrNoPayload if none is found
+func (s *StegImage) ExtractWavelet(opts ...Option) (payload []byte, err error) {
+	ob := s.observe(OpExtract)
+	defer ob.done(&err)
+	if s.imgLoaded == nil {
+		s.logger().Error(errNoImageLoaded)
+		return nil, errNoImageLoaded
+	}
+	bounds := s.imgLoaded.Bounds()
+	o := newOptions(opts)
+	pattern, err := newWaveletPattern(bounds, o.passphrase)
+	if err != nil {
+		s.logger().Error(err)
+		return nil, err
+	}
+	read := func(from int, n int) []bool {
+		bits := make([]bool, n)
+		for i := range bits {
+			bits[i] = waveletBit(waveletDetail(s.imgLoaded, pattern.block(from+i)))
+		}
+		return bits
+	}
+	var size [waveletLenLen]byte
+	for i, one := range read(0, waveletLenLen*8) {
+		if one {
+			size[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	n := int(binary.BigEndian.Uint16(size[:]))
+	if n > waveletCapacity(bounds) {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	frame := spreadDeframe(read(0, (waveletLenLen+n+spreadSumLen)*8))
+	if frame == nil {
+		s.logger().Error(ErrNoPayload)
+		return nil, ErrNoPayload
+	}
+	ob.bytes = int64(len(frame) - waveletLenLen)
+	return frame[waveletLenLen:], nil
+}
+
+// waveletPattern orders the 2x2 blocks of an image by a key-derived
+// permutation
+type waveletPattern struct {
+	bounds image.Rectangle
+	cols   int // Whole blocks across the image
+	perm   *feistel
+}
+
+func newWaveletPattern(bounds image.Rectangle, passphrase string) (*waveletPattern, error) {
+	cols, rows := bounds.Dx()/2, bounds.Dy()/2
+	if cols*rows == 0 {
+		return nil, ErrInsufficientCapacity
+	}
+	key, err := placementKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	perm, err := newFeistel(key, cols*rows)
+	if err != nil {
+		return nil, err
+	}
+	return &waveletPattern{bounds: bounds, cols: cols, perm: perm}, nil
+}
+
+// block returns the top left pixel of the block holding bit i
+func (p *waveletPattern) block(i int) image.Point {
+	b := int(p.perm.permute(uint64(i)))
+	return p.bounds.Min.Add(image.Pt(b%p.cols*2, b/p.cols*2))
+}
+
+// waveletDetail returns the orthonormal Haar diagonal detail coefficient
+// of the luma of the 2x2 block at pt
+func waveletDetail(img image.Image, pt image.Point) float64 {
+	return (luma(img.At(pt.X, pt.Y)) - luma(img.At(pt.X+1, pt.Y)) -
+		luma(img.At(pt.X, pt.Y+1)) + luma(img.At(pt.X+1, pt.Y+1))) / 2
+}
+
+// waveletQuantize returns the point nearest hh on the lattice for bit: odd
+// multiples of half the step for one, even ones for zero
+func waveletQuantize(hh float64, one bool) float64 {
+	offset := 0.0
+	if one {
+		offset = waveletStep / 2
+	}
+	return math.Round((hh-offset)/waveletStep)*waveletStep + offset
+}
+
+// waveletBit returns the bit of the lattice point nearest hh
+func waveletBit(hh float64) bool {
+	return math.Abs(hh-waveletQuantize(hh, true)) < math.Abs(hh-waveletQuantize(hh, false))
+}
+
+// waveletShift changes the diagonal detail coefficient of the 2x2 block at
+// pt by delta, moving every channel alike so only the luma changes. The
+// whole block is brightened or darkened as needed to keep channels in
+// range, which leaves the coefficient alone.
+func waveletShift(img *image.NRGBA, pt image.Point, delta float64) {
+	var px [4]*[4]uint8
+	signs := [4]float64{1, -1, -1, 1}
+	lo, hi := 0.0, 0.0
+	for i := range px {
+		off := img.PixOffset(pt.X+i%2, pt.Y+i/2)
+		px[i] = (*[4]uint8)(img.Pix[off : off+4])
+		for ch := 0; ch < 3; ch++ {
+			v := float64(px[i][ch]) + signs[i]*delta/2
+			if v < lo {
+				lo = v
+			}
+			if v-255 > hi {
+				hi = v - 255
+			}
+		}
+	}
+	dc := 0.0
+	switch {
+	case lo < 0 && hi > 0:
+		// The channels span too much to fit, so clamping will cost the bit
+	case lo < 0:
+		dc = -lo
+	case hi > 0:
+		dc = -hi
+	}
+	for i := range px {
+		for ch := 0; ch < 3; ch++ {
+			px[i][ch] = spreadShift(px[i][ch], signs[i]*delta/2+dc)
+		}
+	}
+}
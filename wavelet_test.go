@@ -0,0 +1,65 @@
+package libsteg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+// TestWavelet verifies a wavelet payload round trips and survives mild
+// noise that destroys LSB payloads
+func TestWavelet(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	var stegImg StegImage
+	if err := stegImg.LoadImageFromFile(cleanImageFile); err != nil {
+		t.Fatal(err)
+	}
+	capacity, err := stegImg.WaveletCapacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 200)
+	rand.New(rand.NewSource(1)).Read(payload)
+	if err = stegImg.EmbedWavelet(payload, WithPassphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	marked := stegImg.newImg
+	bounds := marked.Bounds()
+
+	noisy := image.NewNRGBA(bounds)
+	draw.Draw(noisy, bounds, marked, bounds.Min, draw.Src)
+	rng := rand.New(rand.NewSource(2))
+	for i := range noisy.Pix {
+		if i%4 != 3 {
+			noisy.Pix[i] = uint8(clampInt(int(noisy.Pix[i])+rng.Intn(3)-1, 0, 255))
+		}
+	}
+
+	for name, img := range map[string]image.Image{
+		"clean": reloadImage(t, &stegImg).imgLoaded,
+		"noisy": noisy,
+	} {
+		got, err := NewStegImageFromImage(img).ExtractWavelet(WithPassphrase("hunter2"))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !bytes.Equal(got, payload) {
+			t.Errorf("%s: extracted payload differs", name)
+		}
+	}
+
+	if _, err = NewStegImageFromImage(marked).ExtractWavelet(WithPassphrase("wrong")); err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrNoPayload) + "' got: '" + errString(err) + "'")
+	}
+	if err = stegImg.EmbedWavelet(make([]byte, capacity)); err != nil {
+		t.Errorf("Payload of the full %d byte capacity didn't fit: %v", capacity, err)
+	}
+	if err = stegImg.EmbedWavelet(make([]byte, capacity+1)); err != ErrInsufficientCapacity {
+		t.Error("Correct Error not thrown, expected: '" + errString(ErrInsufficientCapacity) + "' got: '" + errString(err) + "'")
+	}
+}
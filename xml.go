@@ -0,0 +1,120 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var errBadXML = errors.New("not a well-formed XML document")
+
+// EmbedXML hides payload in the SVG or other XML document read from r and
+// writes the result to w. The payload is framed in the usual envelope,
+// with the usual options, and stored base64 encoded in a comment after the
+// root element, where it doesn't affect rendering or the document tree.
+// Tools that strip comments, such as SVG minifiers, remove the payload.
+func EmbedXML(r io.Reader, w io.Writer, payload []byte, opts ...Option) error {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if _, err = xmlComments(doc); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	o := newOptions(opts)
+	framed, err := marshalEnvelope(bytes.NewReader(payload), flagChecksum, o, log)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// Keep any trailing newline after the comment rather than before it
+	body := bytes.TrimRight(doc, " \t\r\n")
+	out := new(bytes.Buffer)
+	out.Write(body)
+	out.WriteString("\n<!--")
+	// Base64 can't contain "--", which would end the comment early
+	out.WriteString(base64.StdEncoding.EncodeToString(framed))
+	out.WriteString("-->")
+	out.Write(doc[len(body):])
+
+	if _, err = w.Write(out.Bytes()); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ExtractXML retrieves a payload embedded with EmbedXML from the document
+// read from r
+func ExtractXML(r io.Reader, opts ...Option) ([]byte, error) {
+	doc, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	comments, err := xmlComments(doc)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	for _, comment := range comments {
+		framed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(comment))
+		if err != nil || !bytes.HasPrefix(framed, []byte(envelopeMagic)) {
+			continue
+		}
+		e, err := parseEnvelope(framed, o)
+		if err == ErrNoPayload {
+			continue
+		} else if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		payload, _, err := e.open(o)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return payload, nil
+	}
+	log.Error(ErrNoPayload)
+	return nil, ErrNoPayload
+}
+
+// xmlComments checks doc is well formed with a root element and returns
+// the text of its comments in document order
+func xmlComments(doc []byte) (comments []string, err error) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	// Only the structure matters, so pass other encodings through as is
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	root := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errBadXML
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			root = true
+		case xml.Comment:
+			comments = append(comments, string(tok))
+		}
+	}
+	if !root {
+		return nil, errBadXML
+	}
+	return comments, nil
+}
@@ -0,0 +1,69 @@
+package libsteg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	logging "github.com/op/go-logging"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- drawn by hand -->
+<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">
+  <rect x="1" y="1" width="8" height="8" fill="red"/>
+</svg>
+`
+
+// TestB2BXML verifies a payload round trips through an SVG which keeps
+// the same element tree
+func TestB2BXML(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	out := new(bytes.Buffer)
+	if err := EmbedXML(strings.NewReader(testSVG), out, []byte(secretStringIn), WithPassphrase("pass")); err != nil {
+		t.Fatal(err)
+	}
+	payloadOut, err := ExtractXML(bytes.NewReader(out.Bytes()), WithPassphrase("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payloadOut) != secretStringIn {
+		t.Error("Secrets Do Not Match!")
+	}
+
+	var before, after struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	}
+	if err := xml.Unmarshal([]byte(testSVG), &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(out.Bytes(), &after); err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Error("Element tree changed")
+	}
+	if !strings.HasSuffix(out.String(), "-->\n") {
+		t.Error("Trailing newline not kept at the end of the document")
+	}
+}
+
+// TestXMLErrors verifies malformed documents and documents without a
+// payload are rejected
+func TestXMLErrors(t *testing.T) {
+	t.Parallel()
+	SetLoggingLevel(logging.Level(*loggingLevel))
+
+	err := EmbedXML(strings.NewReader("<svg><rect></svg>"), new(bytes.Buffer), []byte(secretStringIn))
+	if err != errBadXML {
+		t.Error("Correct Error not thrown, expected: '" + errBadXML.Error() + "' got: '" + errString(err) + "'")
+	}
+	_, err = ExtractXML(strings.NewReader(testSVG))
+	if err != ErrNoPayload {
+		t.Error("Correct Error not thrown, expected: '" + ErrNoPayload.Error() + "' got: '" + errString(err) + "'")
+	}
+}